@@ -21,6 +21,20 @@ func (a *BlockValidatorAPI) LatestValidated(ctx context.Context) (*staker.Global
 	return a.val.ReadLastValidatedInfo()
 }
 
+type StakerAPI struct {
+	staker *staker.Staker
+}
+
+// DecisionLog returns up to count of the staker's most recent decisions, newest first.
+func (a *StakerAPI) DecisionLog(ctx context.Context, count hexutil.Uint64) ([]staker.StakerDecision, error) {
+	return a.staker.LatestDecisions(uint64(count))
+}
+
+// DryRunAct returns the decision the staker would make now and the transactions it would send.
+func (a *StakerAPI) DryRunAct(ctx context.Context) (*staker.StakerDryRunResult, error) {
+	return a.staker.DryRunAct(ctx)
+}
+
 type BlockValidatorDebugAPI struct {
 	val *staker.StatelessBlockValidator
 }
@@ -0,0 +1,486 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/arbnode/dataposter"
+	"github.com/yingdianRao/nitro/cmd/genericconf"
+	"github.com/yingdianRao/nitro/util/arbmath"
+	"github.com/yingdianRao/nitro/util/headerreader"
+	"github.com/yingdianRao/nitro/util/redisutil"
+	"github.com/yingdianRao/nitro/util/stopwaiter"
+)
+
+var (
+	balanceTopUpCounter        = metrics.NewRegisteredCounter("arb/balancemonitor/topup/count", nil)
+	balanceTopUpFailureCounter = metrics.NewRegisteredCounter("arb/balancemonitor/topup/failures", nil)
+	balanceTopUpSentGauge      = metrics.NewRegisteredGaugeFloat64("arb/balancemonitor/topup/sent_in_window", nil)
+	treasuryBalanceGauge       = metrics.NewRegisteredGaugeFloat64("arb/balancemonitor/treasury/balance", nil)
+)
+
+const (
+	BalanceMonitorBatchPoster     = "batch-poster"
+	BalanceMonitorStaker          = "staker"
+	BalanceMonitorValidatorWallet = "validator-wallet"
+	BalanceMonitorGasRefunder     = "gas-refunder"
+)
+
+// WalletBalanceConfig holds the thresholds for a single monitored wallet, in ether.
+// A zero threshold disables the corresponding check.
+type WalletBalanceConfig struct {
+	AlertBelow float64 `koanf:"alert-below" reload:"hot"`
+	TopUpBelow float64 `koanf:"top-up-below" reload:"hot"`
+	TopUpTo    float64 `koanf:"top-up-to" reload:"hot"`
+}
+
+func WalletBalanceConfigAddOptions(prefix string, f *flag.FlagSet, defaultConfig WalletBalanceConfig) {
+	f.Float64(prefix+".alert-below", defaultConfig.AlertBelow, "fail the balance healthcheck and log an error when the balance drops below this many ether (0 to disable)")
+	f.Float64(prefix+".top-up-below", defaultConfig.TopUpBelow, "send ether from the treasury wallet when the balance drops below this many ether (0 to disable)")
+	f.Float64(prefix+".top-up-to", defaultConfig.TopUpTo, "balance in ether a top up brings the wallet back to")
+}
+
+func (c *WalletBalanceConfig) Validate() error {
+	if c.AlertBelow < 0 || c.TopUpBelow < 0 || c.TopUpTo < 0 {
+		return errors.New("wallet balance thresholds must not be negative")
+	}
+	if c.TopUpBelow > 0 && c.TopUpTo <= c.TopUpBelow {
+		return fmt.Errorf("top-up-to (%v) must be greater than top-up-below (%v)", c.TopUpTo, c.TopUpBelow)
+	}
+	return nil
+}
+
+type BalanceTopUpConfig struct {
+	Enable            bool                        `koanf:"enable"`
+	MinInterval       time.Duration               `koanf:"min-interval" reload:"hot"`
+	MaxPerWindow      float64                     `koanf:"max-per-window" reload:"hot"`
+	Window            time.Duration               `koanf:"window" reload:"hot"`
+	GasLimit          uint64                      `koanf:"gas-limit" reload:"hot"`
+	RedisUrl          string                      `koanf:"redis-url"`
+	DataPoster        dataposter.DataPosterConfig `koanf:"data-poster" reload:"hot"`
+	ParentChainWallet genericconf.WalletConfig    `koanf:"parent-chain-wallet"`
+}
+
+var DefaultBalanceTopUpL1WalletConfig = genericconf.WalletConfig{
+	Pathname:      "treasury-wallet",
+	Password:      genericconf.WalletConfigDefault.Password,
+	PrivateKey:    genericconf.WalletConfigDefault.PrivateKey,
+	Account:       genericconf.WalletConfigDefault.Account,
+	OnlyCreateKey: genericconf.WalletConfigDefault.OnlyCreateKey,
+}
+
+var DefaultBalanceTopUpConfig = BalanceTopUpConfig{
+	Enable:            false,
+	MinInterval:       time.Hour,
+	MaxPerWindow:      1,
+	Window:            24 * time.Hour,
+	GasLimit:          100_000,
+	RedisUrl:          "",
+	DataPoster:        dataposter.DefaultDataPosterConfigForValidator,
+	ParentChainWallet: DefaultBalanceTopUpL1WalletConfig,
+}
+
+var TestBalanceTopUpConfig = BalanceTopUpConfig{
+	Enable:            false,
+	MinInterval:       time.Second,
+	MaxPerWindow:      10,
+	Window:            time.Hour,
+	GasLimit:          100_000,
+	RedisUrl:          "",
+	DataPoster:        dataposter.TestDataPosterConfigForValidator,
+	ParentChainWallet: DefaultBalanceTopUpL1WalletConfig,
+}
+
+func BalanceTopUpConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBalanceTopUpConfig.Enable, "automatically top up monitored wallets from the treasury wallet")
+	f.Duration(prefix+".min-interval", DefaultBalanceTopUpConfig.MinInterval, "minimum time between two top ups of the same wallet")
+	f.Float64(prefix+".max-per-window", DefaultBalanceTopUpConfig.MaxPerWindow, "maximum ether sent by the treasury wallet across all top ups within a window")
+	f.Duration(prefix+".window", DefaultBalanceTopUpConfig.Window, "length of the window max-per-window applies to")
+	f.Uint64(prefix+".gas-limit", DefaultBalanceTopUpConfig.GasLimit, "gas limit of top up transactions (must cover transfers to smart contract wallets)")
+	f.String(prefix+".redis-url", DefaultBalanceTopUpConfig.RedisUrl, "if non-empty, the Redis URL to store queued top up transactions in")
+	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f, dataposter.DefaultDataPosterConfigForValidator)
+	genericconf.WalletConfigAddOptions(prefix+".parent-chain-wallet", f, DefaultBalanceTopUpConfig.ParentChainWallet.Pathname)
+}
+
+func (c *BalanceTopUpConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.MaxPerWindow <= 0 || c.Window <= 0 {
+		return errors.New("balance top up requires a positive max-per-window and window")
+	}
+	if c.GasLimit < params.TxGas {
+		return fmt.Errorf("balance top up gas limit %v is below the intrinsic gas of a transfer", c.GasLimit)
+	}
+	return nil
+}
+
+type BalanceMonitorConfig struct {
+	Enable          bool                `koanf:"enable"`
+	CheckInterval   time.Duration       `koanf:"check-interval" reload:"hot"`
+	HealthcheckAddr string              `koanf:"healthcheck-addr"`
+	BatchPoster     WalletBalanceConfig `koanf:"batch-poster" reload:"hot"`
+	Staker          WalletBalanceConfig `koanf:"staker" reload:"hot"`
+	ValidatorWallet WalletBalanceConfig `koanf:"validator-wallet" reload:"hot"`
+	GasRefunder     WalletBalanceConfig `koanf:"gas-refunder" reload:"hot"`
+	TopUp           BalanceTopUpConfig  `koanf:"top-up" reload:"hot"`
+}
+
+type BalanceMonitorConfigFetcher func() *BalanceMonitorConfig
+
+var DefaultBalanceMonitorConfig = BalanceMonitorConfig{
+	Enable:          false,
+	CheckInterval:   time.Minute,
+	HealthcheckAddr: "",
+	TopUp:           DefaultBalanceTopUpConfig,
+}
+
+var TestBalanceMonitorConfig = BalanceMonitorConfig{
+	Enable:          false,
+	CheckInterval:   100 * time.Millisecond,
+	HealthcheckAddr: "",
+	TopUp:           TestBalanceTopUpConfig,
+}
+
+func BalanceMonitorConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBalanceMonitorConfig.Enable, "enable monitoring the balances of the batch poster, staker, validator wallet and gas refunder")
+	f.Duration(prefix+".check-interval", DefaultBalanceMonitorConfig.CheckInterval, "how often to check wallet balances")
+	f.String(prefix+".healthcheck-addr", DefaultBalanceMonitorConfig.HealthcheckAddr, "if set, serve a healthcheck on this address that fails while any wallet is below its alert threshold")
+	WalletBalanceConfigAddOptions(prefix+".batch-poster", f, DefaultBalanceMonitorConfig.BatchPoster)
+	WalletBalanceConfigAddOptions(prefix+".staker", f, DefaultBalanceMonitorConfig.Staker)
+	WalletBalanceConfigAddOptions(prefix+".validator-wallet", f, DefaultBalanceMonitorConfig.ValidatorWallet)
+	WalletBalanceConfigAddOptions(prefix+".gas-refunder", f, DefaultBalanceMonitorConfig.GasRefunder)
+	BalanceTopUpConfigAddOptions(prefix+".top-up", f)
+}
+
+func (c *BalanceMonitorConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.CheckInterval <= 0 {
+		return errors.New("balance monitor check interval must be positive")
+	}
+	for name, walletConfig := range c.wallets() {
+		if err := walletConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid %v balance config: %w", name, err)
+		}
+	}
+	return c.TopUp.Validate()
+}
+
+func (c *BalanceMonitorConfig) wallets() map[string]*WalletBalanceConfig {
+	return map[string]*WalletBalanceConfig{
+		BalanceMonitorBatchPoster:     &c.BatchPoster,
+		BalanceMonitorStaker:          &c.Staker,
+		BalanceMonitorValidatorWallet: &c.ValidatorWallet,
+		BalanceMonitorGasRefunder:     &c.GasRefunder,
+	}
+}
+
+type monitoredWallet struct {
+	name         string
+	address      func() *common.Address
+	balanceGauge metrics.GaugeFloat64
+	lowGauge     metrics.Gauge
+	low          bool
+	lastTopUp    time.Time
+}
+
+type topUpRecord struct {
+	time   time.Time
+	amount *big.Int
+}
+
+// topUpLimiter caps the total value sent by the treasury within a sliding window.
+type topUpLimiter struct {
+	records []topUpRecord
+}
+
+func (l *topUpLimiter) prune(now time.Time, window time.Duration) {
+	for len(l.records) > 0 && now.Sub(l.records[0].time) >= window {
+		l.records = l.records[1:]
+	}
+}
+
+func (l *topUpLimiter) sent() *big.Int {
+	total := new(big.Int)
+	for _, record := range l.records {
+		total.Add(total, record.amount)
+	}
+	return total
+}
+
+// allowance returns how much may still be sent within the current window.
+func (l *topUpLimiter) allowance(now time.Time, window time.Duration, maxPerWindow *big.Int) *big.Int {
+	l.prune(now, window)
+	return arbmath.BigMax(arbmath.BigSub(maxPerWindow, l.sent()), common.Big0)
+}
+
+func (l *topUpLimiter) record(now time.Time, amount *big.Int) {
+	l.records = append(l.records, topUpRecord{time: now, amount: amount})
+}
+
+// topUpAmount returns how much to send to bring balance back to the configured level,
+// or nil if the wallet doesn't need a top up.
+func topUpAmount(balance *big.Int, config *WalletBalanceConfig) *big.Int {
+	if config.TopUpBelow <= 0 || balance.Cmp(etherToWei(config.TopUpBelow)) >= 0 {
+		return nil
+	}
+	amount := arbmath.BigSub(etherToWei(config.TopUpTo), balance)
+	if amount.Sign() <= 0 {
+		return nil
+	}
+	return amount
+}
+
+func etherToWei(ether float64) *big.Int {
+	return arbmath.FloatToBig(ether * params.Ether)
+}
+
+// BalanceMonitor watches the balances of the node's parent chain wallets, raising alerts
+// and failing its healthcheck when one runs low, and optionally tops them up from a treasury wallet.
+type BalanceMonitor struct {
+	stopwaiter.StopWaiter
+	l1Reader   *headerreader.HeaderReader
+	config     BalanceMonitorConfigFetcher
+	dataPoster *dataposter.DataPoster
+
+	mutex   sync.Mutex
+	wallets []*monitoredWallet
+	limiter topUpLimiter
+}
+
+func NewBalanceMonitor(
+	ctx context.Context, l1Reader *headerreader.HeaderReader, config BalanceMonitorConfigFetcher,
+	treasuryDB ethdb.Database, txOptsTreasury *bind.TransactOpts, parentChainID *big.Int,
+) (*BalanceMonitor, error) {
+	m := &BalanceMonitor{
+		l1Reader: l1Reader,
+		config:   config,
+	}
+	cfg := config()
+	if cfg.TopUp.Enable {
+		if txOptsTreasury == nil && cfg.TopUp.DataPoster.ExternalSigner.URL == "" {
+			return nil, errors.New("balance top up enabled, but no treasury wallet")
+		}
+		redisClient, err := redisutil.RedisClientFromURL(cfg.TopUp.RedisUrl)
+		if err != nil {
+			return nil, fmt.Errorf("creating redis client from url: %w", err)
+		}
+		var sender string
+		if txOptsTreasury != nil {
+			sender = txOptsTreasury.From.String()
+		} else {
+			sender = cfg.TopUp.DataPoster.ExternalSigner.Address
+		}
+		m.dataPoster, err = dataposter.NewDataPoster(ctx,
+			&dataposter.DataPosterOpts{
+				Database:     treasuryDB,
+				HeaderReader: l1Reader,
+				Auth:         txOptsTreasury,
+				RedisClient:  redisClient,
+				Config:       func() *dataposter.DataPosterConfig { return &config().TopUp.DataPoster },
+				MetadataRetriever: func(context.Context, *big.Int) ([]byte, error) {
+					return nil, nil
+				},
+				RedisKey:      sender + ".treasury-data-poster.queue",
+				ParentChainID: parentChainID,
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// AddWallet starts monitoring the wallet under the given name.
+// The address getter may return nil while the wallet doesn't exist yet.
+func (m *BalanceMonitor) AddWallet(name string, address func() *common.Address) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.wallets = append(m.wallets, &monitoredWallet{
+		name:         name,
+		address:      address,
+		balanceGauge: metrics.NewRegisteredGaugeFloat64("arb/balancemonitor/"+name+"/balance", nil),
+		lowGauge:     metrics.NewRegisteredGauge("arb/balancemonitor/"+name+"/low", nil),
+	})
+}
+
+// LowWallets returns the names of the wallets currently below their alert threshold.
+func (m *BalanceMonitor) LowWallets() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var low []string
+	for _, wallet := range m.wallets {
+		if wallet.low {
+			low = append(low, wallet.name)
+		}
+	}
+	sort.Strings(low)
+	return low
+}
+
+func walletBalanceConfig(config *BalanceMonitorConfig, name string) *WalletBalanceConfig {
+	if walletConfig, ok := config.wallets()[name]; ok {
+		return walletConfig
+	}
+	return &WalletBalanceConfig{}
+}
+
+func (m *BalanceMonitor) checkWallet(ctx context.Context, config *BalanceMonitorConfig, wallet *monitoredWallet) {
+	address := wallet.address()
+	if address == nil {
+		return
+	}
+	balance, err := m.l1Reader.Client().BalanceAt(ctx, *address, nil)
+	if err != nil {
+		log.Warn("error getting wallet balance", "wallet", wallet.name, "address", *address, "err", err)
+		return
+	}
+	wallet.balanceGauge.Update(arbmath.BalancePerEther(balance))
+	walletConfig := walletBalanceConfig(config, wallet.name)
+
+	low := walletConfig.AlertBelow > 0 && balance.Cmp(etherToWei(walletConfig.AlertBelow)) < 0
+	if low {
+		wallet.lowGauge.Update(1)
+		log.Error("wallet balance below alert threshold", "wallet", wallet.name, "address", *address, "balance", arbmath.BalancePerEther(balance), "threshold", walletConfig.AlertBelow)
+	} else {
+		wallet.lowGauge.Update(0)
+		if wallet.low {
+			log.Info("wallet balance recovered", "wallet", wallet.name, "address", *address, "balance", arbmath.BalancePerEther(balance))
+		}
+	}
+	wallet.low = low
+
+	// the data poster is only created when top ups are enabled
+	if m.dataPoster == nil {
+		return
+	}
+	amount := topUpAmount(balance, walletConfig)
+	if amount == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(wallet.lastTopUp) < config.TopUp.MinInterval {
+		return
+	}
+	allowance := m.limiter.allowance(now, config.TopUp.Window, etherToWei(config.TopUp.MaxPerWindow))
+	if allowance.Sign() <= 0 {
+		log.Warn("not topping up wallet, treasury hit its limit for the window", "wallet", wallet.name, "address", *address, "maxPerWindow", config.TopUp.MaxPerWindow)
+		return
+	}
+	if amount.Cmp(allowance) > 0 {
+		log.Warn("limiting wallet top up to remaining allowance", "wallet", wallet.name, "wanted", arbmath.BalancePerEther(amount), "allowance", arbmath.BalancePerEther(allowance))
+		amount = allowance
+	}
+	tx, err := m.topUp(ctx, config, *address, amount)
+	if err != nil {
+		balanceTopUpFailureCounter.Inc(1)
+		log.Error("error topping up wallet", "wallet", wallet.name, "address", *address, "amount", arbmath.BalancePerEther(amount), "err", err)
+		return
+	}
+	wallet.lastTopUp = now
+	m.limiter.record(now, amount)
+	balanceTopUpCounter.Inc(1)
+	balanceTopUpSentGauge.Update(arbmath.BalancePerEther(m.limiter.sent()))
+	log.Info("topped up wallet", "wallet", wallet.name, "address", *address, "amount", arbmath.BalancePerEther(amount), "tx", tx.Hash())
+}
+
+func (m *BalanceMonitor) topUp(ctx context.Context, config *BalanceMonitorConfig, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	treasuryBalance, err := m.l1Reader.Client().BalanceAt(ctx, m.dataPoster.Sender(), nil)
+	if err != nil {
+		return nil, err
+	}
+	treasuryBalanceGauge.Update(arbmath.BalancePerEther(treasuryBalance))
+	if treasuryBalance.Cmp(amount) < 0 {
+		return nil, fmt.Errorf("treasury balance %v too low to send %v", treasuryBalance, amount)
+	}
+	nonce, _, err := m.dataPoster.GetNextNonceAndMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.dataPoster.PostSimpleTransaction(ctx, nonce, to, nil, config.TopUp.GasLimit, amount)
+}
+
+func (m *BalanceMonitor) update(ctx context.Context) time.Duration {
+	config := m.config()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, wallet := range m.wallets {
+		m.checkWallet(ctx, config, wallet)
+	}
+	return config.CheckInterval
+}
+
+type balanceMonitorHealthcheck struct {
+	m *BalanceMonitor
+}
+
+func (h balanceMonitorHealthcheck) ServeHTTP(response http.ResponseWriter, _ *http.Request) {
+	low := h.m.LowWallets()
+	if len(low) == 0 {
+		response.WriteHeader(http.StatusOK)
+		return
+	}
+	response.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = response.Write([]byte("low balance: " + strings.Join(low, ",") + "\n"))
+}
+
+func (m *BalanceMonitor) launchHealthcheckServer(ctx context.Context) {
+	server := &http.Server{
+		Addr:              m.config().HealthcheckAddr,
+		Handler:           balanceMonitorHealthcheck{m},
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		err := server.Shutdown(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			log.Warn("error shutting down balance healthcheck server", "err", err)
+		}
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Warn("error serving balance healthcheck server", "err", err)
+	}
+}
+
+func (m *BalanceMonitor) Start(ctxIn context.Context) {
+	m.StopWaiter.Start(ctxIn, m)
+	if m.dataPoster != nil {
+		m.dataPoster.Start(ctxIn)
+	}
+	m.CallIteratively(m.update)
+	if m.config().HealthcheckAddr != "" {
+		m.LaunchThread(m.launchHealthcheckServer)
+	}
+}
+
+func (m *BalanceMonitor) StopAndWait() {
+	m.StopWaiter.StopAndWait()
+	if m.dataPoster != nil {
+		m.dataPoster.StopAndWait()
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/yingdianRao/nitro/util/arbmath"
+)
+
+func TestTopUpAmount(t *testing.T) {
+	config := &WalletBalanceConfig{TopUpBelow: 1, TopUpTo: 3}
+	for _, tc := range []struct {
+		balance float64
+		want    float64
+	}{
+		{balance: 0, want: 3},
+		{balance: 0.5, want: 2.5},
+		{balance: 1, want: 0},
+		{balance: 5, want: 0},
+	} {
+		got := topUpAmount(etherToWei(tc.balance), config)
+		if tc.want == 0 {
+			if got != nil {
+				Fail(t, "balance", tc.balance, "got unexpected top up", got)
+			}
+			continue
+		}
+		if !arbmath.BigEquals(got, etherToWei(tc.want)) {
+			Fail(t, "balance", tc.balance, "wanted top up", tc.want, "got", got)
+		}
+	}
+	if got := topUpAmount(big.NewInt(0), &WalletBalanceConfig{}); got != nil {
+		Fail(t, "top up without threshold", got)
+	}
+}
+
+func TestTopUpLimiter(t *testing.T) {
+	var limiter topUpLimiter
+	maxPerWindow := etherToWei(2)
+	start := time.Unix(1_000_000, 0)
+
+	if allowance := limiter.allowance(start, time.Hour, maxPerWindow); !arbmath.BigEquals(allowance, maxPerWindow) {
+		Fail(t, "unexpected initial allowance", allowance)
+	}
+	limiter.record(start, etherToWei(1.5))
+	if allowance := limiter.allowance(start.Add(time.Minute), time.Hour, maxPerWindow); !arbmath.BigEquals(allowance, etherToWei(0.5)) {
+		Fail(t, "unexpected allowance after first top up", allowance)
+	}
+	limiter.record(start.Add(time.Minute), etherToWei(0.5))
+	if allowance := limiter.allowance(start.Add(2*time.Minute), time.Hour, maxPerWindow); allowance.Sign() != 0 {
+		Fail(t, "expected allowance to be used up, got", allowance)
+	}
+	// the first top up leaves the window
+	if allowance := limiter.allowance(start.Add(time.Hour), time.Hour, maxPerWindow); !arbmath.BigEquals(allowance, etherToWei(1.5)) {
+		Fail(t, "unexpected allowance after window moved", allowance)
+	}
+}
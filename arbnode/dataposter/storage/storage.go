@@ -18,9 +18,11 @@ import (
 var (
 	ErrStorageRace = errors.New("storage race error")
 
-	BlockValidatorPrefix string = "v" // the prefix for all block validator keys
-	StakerPrefix         string = "S" // the prefix for all staker keys
-	BatchPosterPrefix    string = "b" // the prefix for all batch poster keys
+	BlockValidatorPrefix    string = "v" // the prefix for all block validator keys
+	StakerPrefix            string = "S" // the prefix for all staker keys
+	BatchPosterPrefix       string = "b" // the prefix for all batch poster keys
+	StakerDecisionLogPrefix string = "D" // the prefix for all staker decision log keys
+	TreasuryPrefix          string = "T" // the prefix for all balance top up treasury keys
 	// TODO(anodar): move everything else from schema.go file to here once
 	// execution split is complete.
 )
@@ -30,6 +30,7 @@ type MessagePruner struct {
 	lastPruneDone               time.Time
 	cachedPrunedMessages        uint64
 	cachedPrunedDelayedMessages uint64
+	cachedPrunedFeedSignatures  uint64
 }
 
 type MessagePrunerConfig struct {
@@ -123,6 +124,11 @@ func (m *MessagePruner) deleteOldMessagesFromDB(ctx context.Context, messageCoun
 		log.Info("Pruned last batch messages:", "first pruned key", prunedKeysRange[0], "last pruned key", prunedKeysRange[len(prunedKeysRange)-1])
 	}
 
+	_, err = deleteFromLastPrunedUptoEndKey(ctx, m.transactionStreamer.db, feedSignaturePrefix, &m.cachedPrunedFeedSignatures, uint64(messageCount))
+	if err != nil {
+		return fmt.Errorf("error deleting last batch feed signatures: %w", err)
+	}
+
 	prunedKeysRange, err = deleteFromLastPrunedUptoEndKey(ctx, m.inboxTracker.db, rlpDelayedMessagePrefix, &m.cachedPrunedDelayedMessages, delayedMessageCount)
 	if err != nil {
 		return fmt.Errorf("error deleting last batch delayed messages: %w", err)
@@ -29,6 +29,7 @@ import (
 	"github.com/yingdianRao/nitro/arbstate"
 	"github.com/yingdianRao/nitro/arbutil"
 	"github.com/yingdianRao/nitro/broadcastclient"
+	"github.com/yingdianRao/nitro/broadcastclient/backfill"
 	"github.com/yingdianRao/nitro/broadcastclients"
 	"github.com/yingdianRao/nitro/broadcaster"
 	"github.com/yingdianRao/nitro/cmd/chaininfo"
@@ -94,6 +95,8 @@ type Config struct {
 	Maintenance         MaintenanceConfig           `koanf:"maintenance" reload:"hot"`
 	ResourceMgmt        resourcemanager.Config      `koanf:"resource-mgmt" reload:"hot"`
 	Celestia            celestia.DAConfig           `koanf:"celestia-cfg"`
+	BalanceMonitor      BalanceMonitorConfig        `koanf:"balance-monitor" reload:"hot"`
+	BackfillAPI         backfill.APIConfig          `koanf:"backfill-api"`
 }
 
 func (c *Config) Validate() error {
@@ -128,6 +131,12 @@ func (c *Config) Validate() error {
 	if err := c.Staker.Validate(); err != nil {
 		return err
 	}
+	if err := c.SeqCoordinator.Validate(); err != nil {
+		return err
+	}
+	if err := c.BalanceMonitor.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -157,6 +166,8 @@ func ConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool, feed
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 	TransactionStreamerConfigAddOptions(prefix+".transaction-streamer", f)
 	MaintenanceConfigAddOptions(prefix+".maintenance", f)
+	BalanceMonitorConfigAddOptions(prefix+".balance-monitor", f)
+	backfill.APIConfigAddOptions(prefix+".backfill-api", f)
 }
 
 var ConfigDefault = Config{
@@ -176,6 +187,8 @@ var ConfigDefault = Config{
 	TransactionStreamer: DefaultTransactionStreamerConfig,
 	ResourceMgmt:        resourcemanager.DefaultConfig,
 	Maintenance:         DefaultMaintenanceConfig,
+	BalanceMonitor:      DefaultBalanceMonitorConfig,
+	BackfillAPI:         backfill.DefaultAPIConfig,
 }
 
 func ConfigDefaultL1Test() *Config {
@@ -201,6 +214,7 @@ func ConfigDefaultL1NonSequencerTest() *Config {
 	config.Staker = staker.TestL1ValidatorConfig
 	config.Staker.Enable = false
 	config.BlockValidator.ValidationServerConfigs = []rpcclient.ClientConfig{{URL: ""}}
+	config.BalanceMonitor = TestBalanceMonitorConfig
 
 	return &config
 }
@@ -264,11 +278,13 @@ type Node struct {
 	Staker                  *staker.Staker
 	BroadcastServer         *broadcaster.Broadcaster
 	BroadcastClients        *broadcastclients.BroadcastClients
+	FeedBackfiller          *backfill.Backfiller
 	SeqCoordinator          *SeqCoordinator
 	MaintenanceRunner       *MaintenanceRunner
 	DASLifecycleManager     *das.LifecycleManager
 	ClassicOutboxRetriever  *ClassicOutboxRetriever
 	SyncMonitor             *SyncMonitor
+	BalanceMonitor          *BalanceMonitor
 	configFetcher           ConfigFetcher
 	ctx                     context.Context
 }
@@ -369,6 +385,7 @@ func createNodeImpl(
 	deployInfo *chaininfo.RollupAddresses,
 	txOptsValidator *bind.TransactOpts,
 	txOptsBatchPoster *bind.TransactOpts,
+	txOptsTreasury *bind.TransactOpts,
 	dataSigner signature.DataSignerFunc,
 	fatalErrChan chan error,
 	parentChainID *big.Int,
@@ -421,6 +438,9 @@ func createNodeImpl(
 	if err != nil {
 		return nil, err
 	}
+	if config.BackfillAPI.Enable {
+		txStreamer.StoreFeedSignatures()
+	}
 	var coordinator *SeqCoordinator
 	var bpVerifier *contracts.AddressVerifier
 	if deployInfo != nil && l1client != nil {
@@ -448,20 +468,49 @@ func createNodeImpl(
 	}
 
 	var broadcastClients *broadcastclients.BroadcastClients
+	var feedBackfiller *backfill.Backfiller
 	if config.Feed.Input.Enable() {
 		currentMessageCount, err := txStreamer.GetMessageCount()
 		if err != nil {
 			return nil, err
 		}
+		var signerRegistry contracts.SignerRegistryInterface
+		if config.Feed.Input.SignerRegistry.Enable() {
+			if l1client == nil {
+				return nil, errors.New("feed signer registry requires a parent chain client")
+			}
+			signerRegistry, err = contracts.NewSignerRegistry(&config.Feed.Input.SignerRegistry, l1client)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var feedStreamer broadcastclient.TransactionStreamerInterface = txStreamer
+		if config.Feed.Input.Backfill.Enable {
+			var addrVerifier contracts.AddressVerifierInterface
+			if bpVerifier != nil {
+				addrVerifier = bpVerifier
+			}
+			feedBackfiller, err = backfill.NewBackfiller(
+				func() *backfill.Config { return &configFetcher.Get().Feed.Input.Backfill },
+				txStreamer,
+				l2ChainId,
+				addrVerifier,
+			)
+			if err != nil {
+				return nil, err
+			}
+			feedStreamer = feedBackfiller
+		}
 
 		broadcastClients, err = broadcastclients.NewBroadcastClients(
 			func() *broadcastclient.Config { return &configFetcher.Get().Feed.Input },
 			l2ChainId,
 			currentMessageCount,
-			txStreamer,
+			feedStreamer,
 			nil,
 			fatalErrChan,
 			bpVerifier,
+			signerRegistry,
 		)
 		if err != nil {
 			return nil, err
@@ -487,6 +536,7 @@ func createNodeImpl(
 			Staker:                  nil,
 			BroadcastServer:         broadcastServer,
 			BroadcastClients:        broadcastClients,
+			FeedBackfiller:          feedBackfiller,
 			SeqCoordinator:          coordinator,
 			MaintenanceRunner:       maintenanceRunner,
 			DASLifecycleManager:     nil,
@@ -598,6 +648,7 @@ func createNodeImpl(
 
 	var stakerObj *staker.Staker
 	var messagePruner *MessagePruner
+	var stakerWallet staker.ValidatorWalletInterface
 
 	if config.Staker.Enable {
 		dp, err := StakerDataposter(
@@ -648,7 +699,7 @@ func createNodeImpl(
 			confirmedNotifiers = append(confirmedNotifiers, messagePruner)
 		}
 
-		stakerObj, err = staker.NewStaker(l1Reader, wallet, bind.CallOpts{}, config.Staker, blockValidator, statelessBlockValidator, nil, confirmedNotifiers, deployInfo.ValidatorUtils, fatalErrChan)
+		stakerObj, err = staker.NewStaker(l1Reader, wallet, bind.CallOpts{}, config.Staker, blockValidator, statelessBlockValidator, nil, confirmedNotifiers, deployInfo.ValidatorUtils, rawdb.NewTable(arbDb, storage.StakerDecisionLogPrefix), fatalErrChan)
 		if err != nil {
 			return nil, err
 		}
@@ -665,6 +716,7 @@ func createNodeImpl(
 		if err != nil {
 			return nil, err
 		}
+		stakerWallet = wallet
 		log.Info("running as validator", "txSender", validatorAddr, "actingAsWallet", wallet.Address(), "whitelisted", whitelisted, "strategy", config.Staker.Strategy)
 	}
 
@@ -693,6 +745,35 @@ func createNodeImpl(
 		}
 	}
 
+	var balanceMonitor *BalanceMonitor
+	if config.BalanceMonitor.Enable && l1Reader != nil {
+		balanceMonitor, err = NewBalanceMonitor(
+			ctx,
+			l1Reader,
+			func() *BalanceMonitorConfig { return &configFetcher.Get().BalanceMonitor },
+			rawdb.NewTable(arbDb, storage.TreasuryPrefix),
+			txOptsTreasury,
+			parentChainID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if batchPoster != nil {
+			batchPosterAddr := batchPoster.dataPoster.Sender()
+			balanceMonitor.AddWallet(BalanceMonitorBatchPoster, func() *common.Address { return &batchPosterAddr })
+		}
+		if stakerWallet != nil {
+			balanceMonitor.AddWallet(BalanceMonitorStaker, stakerWallet.TxSenderAddress)
+			if _, isContract := stakerWallet.(*validatorwallet.Contract); isContract {
+				balanceMonitor.AddWallet(BalanceMonitorValidatorWallet, stakerWallet.Address)
+			}
+		}
+		if config.Staker.GasRefunderAddress != "" {
+			gasRefunderAddr := common.HexToAddress(config.Staker.GasRefunderAddress)
+			balanceMonitor.AddWallet(BalanceMonitorGasRefunder, func() *common.Address { return &gasRefunderAddr })
+		}
+	}
+
 	// always create DelayedSequencer, it won't do anything if it is disabled
 	delayedSequencer, err = NewDelayedSequencer(l1Reader, inboxReader, exec, coordinator, func() *DelayedSequencerConfig { return &configFetcher.Get().DelayedSequencer })
 	if err != nil {
@@ -717,11 +798,13 @@ func createNodeImpl(
 		Staker:                  stakerObj,
 		BroadcastServer:         broadcastServer,
 		BroadcastClients:        broadcastClients,
+		FeedBackfiller:          feedBackfiller,
 		SeqCoordinator:          coordinator,
 		MaintenanceRunner:       maintenanceRunner,
 		DASLifecycleManager:     dasLifecycleManager,
 		ClassicOutboxRetriever:  classicOutbox,
 		SyncMonitor:             syncMonitor,
+		BalanceMonitor:          balanceMonitor,
 		configFetcher:           configFetcher,
 		ctx:                     ctx,
 	}, nil
@@ -743,12 +826,13 @@ func CreateNode(
 	deployInfo *chaininfo.RollupAddresses,
 	txOptsValidator *bind.TransactOpts,
 	txOptsBatchPoster *bind.TransactOpts,
+	txOptsTreasury *bind.TransactOpts,
 	dataSigner signature.DataSignerFunc,
 	fatalErrChan chan error,
 	parentChainID *big.Int,
 	blobReader arbstate.BlobReader,
 ) (*Node, error) {
-	currentNode, err := createNodeImpl(ctx, stack, exec, arbDb, configFetcher, l2Config, l1client, deployInfo, txOptsValidator, txOptsBatchPoster, dataSigner, fatalErrChan, parentChainID, blobReader)
+	currentNode, err := createNodeImpl(ctx, stack, exec, arbDb, configFetcher, l2Config, l1client, deployInfo, txOptsValidator, txOptsBatchPoster, txOptsTreasury, dataSigner, fatalErrChan, parentChainID, blobReader)
 	if err != nil {
 		return nil, err
 	}
@@ -772,6 +856,28 @@ func CreateNode(
 		})
 	}
 
+	if currentNode.Staker != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arbstaker",
+			Version:   "1.0",
+			Service:   &StakerAPI{staker: currentNode.Staker},
+			Public:    false,
+		})
+	}
+	if config := configFetcher.Get(); config.BackfillAPI.Enable {
+		// only the sequencer produces the messages it would sign, others serve the feed signatures
+		var apiSigner signature.DataSignerFunc
+		if config.Sequencer {
+			apiSigner = dataSigner
+		}
+		apis = append(apis, rpc.API{
+			Namespace: backfill.APINamespace,
+			Version:   "1.0",
+			Service:   backfill.NewAPI(currentNode.TxStreamer, l2Config.ChainID.Uint64(), apiSigner),
+			Public:    false,
+		})
+	}
+
 	stack.RegisterAPIs(apis)
 
 	return currentNode, nil
@@ -890,6 +996,12 @@ func (n *Node) Start(ctx context.Context) error {
 	if n.L1Reader != nil {
 		n.L1Reader.Start(ctx)
 	}
+	if n.BalanceMonitor != nil {
+		n.BalanceMonitor.Start(ctx)
+	}
+	if n.FeedBackfiller != nil {
+		n.FeedBackfiller.Start(ctx)
+	}
 	if n.BroadcastClients != nil {
 		go func() {
 			if n.InboxReader != nil {
@@ -936,12 +1048,18 @@ func (n *Node) StopAndWait() {
 	if n.BroadcastClients != nil {
 		n.BroadcastClients.StopAndWait()
 	}
+	if n.FeedBackfiller != nil && n.FeedBackfiller.Started() {
+		n.FeedBackfiller.StopAndWait()
+	}
 	if n.BlockValidator != nil && n.BlockValidator.Started() {
 		n.BlockValidator.StopAndWait()
 	}
 	if n.Staker != nil {
 		n.Staker.StopAndWait()
 	}
+	if n.BalanceMonitor != nil && n.BalanceMonitor.Started() {
+		n.BalanceMonitor.StopAndWait()
+	}
 	if n.StatelessBlockValidator != nil {
 		n.StatelessBlockValidator.Stop()
 	}
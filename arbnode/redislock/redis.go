@@ -14,12 +14,13 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/go-redis/redis/v8"
 	flag "github.com/spf13/pflag"
+	"github.com/yingdianRao/nitro/util/coordination"
 	"github.com/yingdianRao/nitro/util/stopwaiter"
 )
 
 type Simple struct {
 	stopwaiter.StopWaiter
-	client      redis.UniversalClient
+	store       coordination.Store
 	config      SimpleCfgFetcher
 	lockedUntil int64
 	mutex       sync.Mutex
@@ -49,13 +50,23 @@ func AddConfigOptions(prefix string, f *flag.FlagSet) {
 }
 
 func NewSimple(client redis.UniversalClient, config SimpleCfgFetcher, readyToLock func() bool) (*Simple, error) {
+	var store coordination.Store
+	if client != nil {
+		store = &coordination.RedisStore{Client: client}
+	}
+	return NewSimpleWithStore(store, config, readyToLock)
+}
+
+// NewSimpleWithStore creates a lock held in store, which may be backed by something else than Redis.
+// A nil store makes the lock always held.
+func NewSimpleWithStore(store coordination.Store, config SimpleCfgFetcher, readyToLock func() bool) (*Simple, error) {
 	randBig, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
 	if err != nil {
 		return nil, err
 	}
 	return &Simple{
 		myId:        config().MyId + "-" + strconv.FormatInt(randBig.Int64(), 16), // unique even if config is not
-		client:      client,
+		store:       store,
 		config:      config,
 		readyToLock: readyToLock,
 	}, nil
@@ -72,7 +83,7 @@ var DefaultCfg = SimpleCfg{
 func (l *Simple) attemptLock(ctx context.Context) (bool, error) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	if l.stopping || l.client == nil {
+	if l.stopping || l.store == nil {
 		return false, nil
 	}
 	if !l.readyToLock() {
@@ -82,9 +93,9 @@ func (l *Simple) attemptLock(ctx context.Context) (bool, error) {
 	config := l.config()
 	timeAtStart := time.Now()
 
-	err := l.client.Watch(ctx, func(tx *redis.Tx) error {
-		current, err := tx.Get(ctx, config.Key).Result()
-		if errors.Is(err, redis.Nil) {
+	err := l.store.Watch(ctx, func(tx coordination.Tx) error {
+		current, err := tx.Get(ctx, config.Key)
+		if errors.Is(err, coordination.ErrNotFound) {
 			current = ""
 			err = nil
 		}
@@ -94,19 +105,17 @@ func (l *Simple) attemptLock(ctx context.Context) (bool, error) {
 		if current != "" && (current != l.myId) {
 			return nil
 		}
-		pipe := tx.TxPipeline()
-		pipe.Set(ctx, config.Key, l.myId, config.LockoutDuration)
-		pipe.PExpireAt(ctx, config.Key, timeAtStart.Add(config.LockoutDuration))
-		err = execTestPipe(pipe, ctx)
-		if errors.Is(err, redis.TxFailedErr) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
+		tx.Set(config.Key, l.myId, config.LockoutDuration)
+		tx.ExpireAt(config.Key, timeAtStart.Add(config.LockoutDuration))
 		gotLock = true
 		return nil
 	}, config.Key)
+	if err != nil {
+		gotLock = false
+	}
+	if errors.Is(err, coordination.ErrTxFailed) {
+		err = nil
+	}
 
 	if !gotLock {
 		atomicTimeWrite(&l.lockedUntil, time.Time{})
@@ -140,7 +149,7 @@ func (l *Simple) AttemptLock(ctx context.Context) bool {
 }
 
 func (l *Simple) Locked() bool {
-	if l.client == nil || !l.config().Enable {
+	if l.store == nil || !l.config().Enable {
 		return true
 	}
 	return time.Now().Before(atomicTimeRead(&l.lockedUntil))
@@ -154,9 +163,9 @@ func (l *Simple) CouldAcquireLock(ctx context.Context) (bool, error) {
 	if l.stopping || !l.readyToLock() {
 		return false, nil
 	}
-	// l.client shouldn't be nil here because Locked would've returned true
-	current, err := l.client.Get(ctx, l.config().Key).Result()
-	if errors.Is(err, redis.Nil) {
+	// l.store shouldn't be nil here because Locked would've returned true
+	current, err := l.store.Get(ctx, l.config().Key)
+	if errors.Is(err, coordination.ErrNotFound) {
 		// Lock is free for the taking
 		return true, nil
 	}
@@ -171,14 +180,14 @@ func (l *Simple) Release(ctx context.Context) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	if l.client == nil {
+	if l.store == nil {
 		return
 	}
 
 	config := l.config()
-	err := l.client.Watch(ctx, func(tx *redis.Tx) error {
-		current, err := tx.Get(ctx, config.Key).Result()
-		if errors.Is(err, redis.Nil) {
+	err := l.store.Watch(ctx, func(tx coordination.Tx) error {
+		current, err := tx.Get(ctx, config.Key)
+		if errors.Is(err, coordination.ErrNotFound) {
 			return nil
 		}
 		if err != nil {
@@ -187,26 +196,18 @@ func (l *Simple) Release(ctx context.Context) {
 		if current != l.myId {
 			return nil
 		}
-		pipe := tx.TxPipeline()
-		pipe.Del(ctx, config.Key, l.myId)
-		err = execTestPipe(pipe, ctx)
-		if errors.Is(err, redis.TxFailedErr) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
+		tx.Del(config.Key)
 		return nil
 	}, config.Key)
 
-	if err != nil {
+	if err != nil && !errors.Is(err, coordination.ErrTxFailed) {
 		log.Error("release returned error", "err", err)
 	}
 }
 
 func (l *Simple) Start(ctxin context.Context) {
 	l.StopWaiter.Start(ctxin, l)
-	if l.config().BackgroundLock && l.client != nil {
+	if l.config().BackgroundLock && l.store != nil {
 		l.CallIteratively(func(ctx context.Context) time.Duration {
 			_, err := l.attemptLock(ctx)
 			if err != nil {
@@ -225,19 +226,6 @@ func (l *Simple) StopAndWait() {
 	l.StopWaiter.StopAndWait()
 }
 
-func execTestPipe(pipe redis.Pipeliner, ctx context.Context) error {
-	cmders, err := pipe.Exec(ctx)
-	if err != nil {
-		return err
-	}
-	for _, cmder := range cmders {
-		if err := cmder.Err(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // notice: It is possible for two consecutive reads to get decreasing values. That shouldn't matter.
 func atomicTimeRead(addr *int64) time.Time {
 	asint64 := atomic.LoadInt64(addr)
@@ -14,7 +14,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	flag "github.com/spf13/pflag"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -25,6 +24,7 @@ import (
 	"github.com/yingdianRao/nitro/execution"
 	"github.com/yingdianRao/nitro/util/arbmath"
 	"github.com/yingdianRao/nitro/util/contracts"
+	"github.com/yingdianRao/nitro/util/coordination"
 	"github.com/yingdianRao/nitro/util/redisutil"
 	"github.com/yingdianRao/nitro/util/signature"
 	"github.com/yingdianRao/nitro/util/stopwaiter"
@@ -37,7 +37,7 @@ var (
 type SeqCoordinator struct {
 	stopwaiter.StopWaiter
 
-	redisutil.RedisCoordinator
+	coordination.Coordinator
 
 	sync             *SyncMonitor
 	streamer         *TransactionStreamer
@@ -58,17 +58,20 @@ type SeqCoordinator struct {
 }
 
 type SeqCoordinatorConfig struct {
-	Enable                bool          `koanf:"enable"`
-	ChosenHealthcheckAddr string        `koanf:"chosen-healthcheck-addr"`
-	RedisUrl              string        `koanf:"redis-url"`
-	LockoutDuration       time.Duration `koanf:"lockout-duration"`
-	LockoutSpare          time.Duration `koanf:"lockout-spare"`
-	SeqNumDuration        time.Duration `koanf:"seq-num-duration"`
-	UpdateInterval        time.Duration `koanf:"update-interval"`
-	RetryInterval         time.Duration `koanf:"retry-interval"`
-	HandoffTimeout        time.Duration `koanf:"handoff-timeout"`
-	SafeShutdownDelay     time.Duration `koanf:"safe-shutdown-delay"`
-	ReleaseRetries        int           `koanf:"release-retries"`
+	Enable                bool                    `koanf:"enable"`
+	ChosenHealthcheckAddr string                  `koanf:"chosen-healthcheck-addr"`
+	Backend               string                  `koanf:"backend"`
+	RedisUrl              string                  `koanf:"redis-url"`
+	Raft                  coordination.RaftConfig `koanf:"raft"`
+	LockoutDuration       time.Duration           `koanf:"lockout-duration"`
+	LockoutSpare          time.Duration           `koanf:"lockout-spare"`
+	SeqNumDuration        time.Duration           `koanf:"seq-num-duration"`
+	UpdateInterval        time.Duration           `koanf:"update-interval"`
+	RetryInterval         time.Duration           `koanf:"retry-interval"`
+	HandoffTimeout        time.Duration           `koanf:"handoff-timeout"`
+	QueueHandoffTimeout   time.Duration           `koanf:"queue-handoff-timeout"`
+	SafeShutdownDelay     time.Duration           `koanf:"safe-shutdown-delay"`
+	ReleaseRetries        int                     `koanf:"release-retries"`
 	// Max message per poll.
 	MsgPerPoll arbutil.MessageIndex       `koanf:"msg-per-poll"`
 	MyUrl      string                     `koanf:"my-url"`
@@ -82,9 +85,25 @@ func (c *SeqCoordinatorConfig) Url() string {
 	return c.MyUrl
 }
 
+func (c *SeqCoordinatorConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	switch c.Backend {
+	case coordination.BackendRedis:
+		return nil
+	case coordination.BackendRaft:
+		return c.Raft.Validate()
+	default:
+		return fmt.Errorf("unknown sequencer coordinator backend %q", c.Backend)
+	}
+}
+
 func SeqCoordinatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultSeqCoordinatorConfig.Enable, "enable sequence coordinator")
+	f.String(prefix+".backend", DefaultSeqCoordinatorConfig.Backend, "the backend to coordinate via, either \"redis\" (through redis-url) or \"raft\" (an embedded Raft group among the sequencers, configured under raft)")
 	f.String(prefix+".redis-url", DefaultSeqCoordinatorConfig.RedisUrl, "the Redis URL to coordinate via")
+	coordination.RaftConfigAddOptions(prefix+".raft", f)
 	f.String(prefix+".chosen-healthcheck-addr", DefaultSeqCoordinatorConfig.ChosenHealthcheckAddr, "if non-empty, launch an HTTP service binding to this address that returns status code 200 when chosen and 503 otherwise")
 	f.Duration(prefix+".lockout-duration", DefaultSeqCoordinatorConfig.LockoutDuration, "")
 	f.Duration(prefix+".lockout-spare", DefaultSeqCoordinatorConfig.LockoutSpare, "")
@@ -92,6 +111,7 @@ func SeqCoordinatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Duration(prefix+".update-interval", DefaultSeqCoordinatorConfig.UpdateInterval, "")
 	f.Duration(prefix+".retry-interval", DefaultSeqCoordinatorConfig.RetryInterval, "")
 	f.Duration(prefix+".handoff-timeout", DefaultSeqCoordinatorConfig.HandoffTimeout, "the maximum amount of time to spend waiting for another sequencer to accept the lockout when handing it off on shutdown or db compaction")
+	f.Duration(prefix+".queue-handoff-timeout", DefaultSeqCoordinatorConfig.QueueHandoffTimeout, "the maximum amount of time to spend forwarding queued transactions to the next chosen sequencer, waiting for it to return their results, before releasing the lockout to it (0 = release without forwarding first)")
 	f.Duration(prefix+".safe-shutdown-delay", DefaultSeqCoordinatorConfig.SafeShutdownDelay, "if non-zero will add delay after transferring control")
 	f.Int(prefix+".release-retries", DefaultSeqCoordinatorConfig.ReleaseRetries, "the number of times to retry releasing the wants lockout and chosen one status on shutdown")
 	f.Uint64(prefix+".msg-per-poll", uint64(DefaultSeqCoordinatorConfig.MsgPerPoll), "will only be marked as wanting the lockout if not too far behind")
@@ -102,12 +122,15 @@ func SeqCoordinatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 var DefaultSeqCoordinatorConfig = SeqCoordinatorConfig{
 	Enable:                false,
 	ChosenHealthcheckAddr: "",
+	Backend:               coordination.BackendRedis,
 	RedisUrl:              "",
+	Raft:                  coordination.DefaultRaftConfig,
 	LockoutDuration:       time.Minute,
 	LockoutSpare:          30 * time.Second,
 	SeqNumDuration:        24 * time.Hour,
 	UpdateInterval:        250 * time.Millisecond,
 	HandoffTimeout:        30 * time.Second,
+	QueueHandoffTimeout:   5 * time.Second,
 	SafeShutdownDelay:     5 * time.Second,
 	ReleaseRetries:        4,
 	RetryInterval:         50 * time.Millisecond,
@@ -117,19 +140,22 @@ var DefaultSeqCoordinatorConfig = SeqCoordinatorConfig{
 }
 
 var TestSeqCoordinatorConfig = SeqCoordinatorConfig{
-	Enable:            false,
-	RedisUrl:          "",
-	LockoutDuration:   time.Second * 2,
-	LockoutSpare:      time.Millisecond * 10,
-	SeqNumDuration:    time.Minute * 10,
-	UpdateInterval:    time.Millisecond * 10,
-	HandoffTimeout:    time.Millisecond * 200,
-	SafeShutdownDelay: time.Millisecond * 100,
-	ReleaseRetries:    4,
-	RetryInterval:     time.Millisecond * 3,
-	MsgPerPoll:        20,
-	MyUrl:             redisutil.INVALID_URL,
-	Signer:            signature.DefaultSignVerifyConfig,
+	Enable:              false,
+	Backend:             coordination.BackendRedis,
+	RedisUrl:            "",
+	Raft:                coordination.TestRaftConfig,
+	LockoutDuration:     time.Second * 2,
+	LockoutSpare:        time.Millisecond * 10,
+	SeqNumDuration:      time.Minute * 10,
+	UpdateInterval:      time.Millisecond * 10,
+	HandoffTimeout:      time.Millisecond * 200,
+	QueueHandoffTimeout: time.Millisecond * 100,
+	SafeShutdownDelay:   time.Millisecond * 100,
+	ReleaseRetries:      4,
+	RetryInterval:       time.Millisecond * 3,
+	MsgPerPoll:          20,
+	MyUrl:               redisutil.INVALID_URL,
+	Signer:              signature.DefaultSignVerifyConfig,
 }
 
 func NewSeqCoordinator(
@@ -140,21 +166,21 @@ func NewSeqCoordinator(
 	sync *SyncMonitor,
 	config SeqCoordinatorConfig,
 ) (*SeqCoordinator, error) {
-	redisCoordinator, err := redisutil.NewRedisCoordinator(config.RedisUrl)
+	signer, err := signature.NewSignVerify(&config.Signer, dataSigner, bpvalidator)
 	if err != nil {
 		return nil, err
 	}
-	signer, err := signature.NewSignVerify(&config.Signer, dataSigner, bpvalidator)
+	store, err := coordination.NewStore(config.Backend, &config.Raft, config.RedisUrl)
 	if err != nil {
 		return nil, err
 	}
 	coordinator := &SeqCoordinator{
-		RedisCoordinator: *redisCoordinator,
-		sync:             sync,
-		streamer:         streamer,
-		sequencer:        sequencer,
-		config:           config,
-		signer:           signer,
+		Coordinator: coordination.Coordinator{Store: store},
+		sync:        sync,
+		streamer:    streamer,
+		sequencer:   sequencer,
+		config:      config,
+		signer:      signer,
 	}
 	streamer.SetSeqCoordinator(coordinator)
 	return coordinator, nil
@@ -170,7 +196,7 @@ func (c *SeqCoordinator) SetDelayedSequencer(delayedSequencer *DelayedSequencer)
 	c.delayedSequencer = delayedSequencer
 }
 
-func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, redisClient redis.UniversalClient, keyConfig string, msgIndex arbutil.MessageIndex) error {
+func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, store coordination.Store, keyConfig string, msgIndex arbutil.MessageIndex) error {
 	signerConfig := signature.EmptySimpleHmacConfig
 	if keyConfig == "" {
 		signerConfig.Dangerous.DisableSignatureVerification = true
@@ -188,9 +214,10 @@ func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, redisClient
 	if err != nil {
 		return err
 	}
-	redisClient.Set(ctx, redisutil.MessageKeyFor(msgIndex), msg, DefaultSeqCoordinatorConfig.SeqNumDuration)
-	redisClient.Set(ctx, redisutil.MessageSigKeyFor(msgIndex), sig, DefaultSeqCoordinatorConfig.SeqNumDuration)
-	return nil
+	if err := store.Set(ctx, redisutil.MessageKeyFor(msgIndex), string(msg), DefaultSeqCoordinatorConfig.SeqNumDuration); err != nil {
+		return err
+	}
+	return store.Set(ctx, redisutil.MessageSigKeyFor(msgIndex), string(sig), DefaultSeqCoordinatorConfig.SeqNumDuration)
 }
 
 func atomicTimeWrite(addr *int64, t time.Time) {
@@ -204,19 +231,6 @@ func atomicTimeRead(addr *int64) time.Time {
 	return time.UnixMilli(asint64)
 }
 
-func execTestPipe(pipe redis.Pipeliner, ctx context.Context) error {
-	cmders, err := pipe.Exec(ctx)
-	if err != nil {
-		return err
-	}
-	for _, cmder := range cmders {
-		if err := cmder.Err(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (c *SeqCoordinator) msgCountToSignedBytes(msgCount arbutil.MessageIndex) ([]byte, error) {
 	var msgCountBytes [8]byte
 	binary.BigEndian.PutUint64(msgCountBytes[:], uint64(msgCount))
@@ -272,10 +286,10 @@ func (c *SeqCoordinator) acquireLockoutAndWriteMessage(ctx context.Context, msgC
 	defer c.wantsLockoutMutex.Unlock()
 	setWantsLockout := c.avoidLockout <= 0
 	lockoutUntil := time.Now().Add(c.config.LockoutDuration)
-	err = c.Client.Watch(ctx, func(tx *redis.Tx) error {
-		current, err := tx.Get(ctx, redisutil.CHOSENSEQ_KEY).Result()
+	err = c.Store.Watch(ctx, func(tx coordination.Tx) error {
+		current, err := tx.Get(ctx, redisutil.CHOSENSEQ_KEY)
 		var wasEmpty bool
-		if errors.Is(err, redis.Nil) {
+		if errors.Is(err, coordination.ErrNotFound) {
 			wasEmpty = true
 			err = nil
 		}
@@ -283,9 +297,9 @@ func (c *SeqCoordinator) acquireLockoutAndWriteMessage(ctx context.Context, msgC
 			return err
 		}
 		if !wasEmpty && (current != c.config.Url()) {
-			return fmt.Errorf("%w: failed to catch lock. redis shows chosen: %s", execution.ErrRetrySequencer, current)
+			return fmt.Errorf("%w: failed to catch lock. coordinator shows chosen: %s", execution.ErrRetrySequencer, current)
 		}
-		remoteMsgCount, err := c.getRemoteMsgCountImpl(ctx, tx)
+		remoteMsgCount, err := c.getRemoteMsgCountImpl(ctx, tx.Get)
 		if err != nil {
 			return err
 		}
@@ -298,37 +312,32 @@ func (c *SeqCoordinator) acquireLockoutAndWriteMessage(ctx context.Context, msgC
 			log.Info("coordinator failed to become main", "expected", msgCountExpected, "found", remoteMsgCount, "message is nil?", messageData == nil)
 			return fmt.Errorf("%w: failed to catch lock. expected msg %d found %d", execution.ErrRetrySequencer, msgCountExpected, remoteMsgCount)
 		}
-		pipe := tx.TxPipeline()
 		initialDuration := c.config.LockoutDuration
 		if initialDuration < 2*time.Second {
 			initialDuration = 2 * time.Second
 		}
 		if wasEmpty {
-			pipe.Set(ctx, redisutil.CHOSENSEQ_KEY, c.config.Url(), initialDuration)
+			tx.Set(redisutil.CHOSENSEQ_KEY, c.config.Url(), initialDuration)
 		}
-		pipe.Set(ctx, redisutil.MSG_COUNT_KEY, msgCountMsg, c.config.SeqNumDuration)
+		tx.Set(redisutil.MSG_COUNT_KEY, string(msgCountMsg), c.config.SeqNumDuration)
 		if messageData != nil {
-			pipe.Set(ctx, redisutil.MessageKeyFor(msgCountToWrite-1), *messageData, c.config.SeqNumDuration)
+			tx.Set(redisutil.MessageKeyFor(msgCountToWrite-1), *messageData, c.config.SeqNumDuration)
 			if messageSigData != nil {
-				pipe.Set(ctx, redisutil.MessageSigKeyFor(msgCountToWrite-1), *messageSigData, c.config.SeqNumDuration)
+				tx.Set(redisutil.MessageSigKeyFor(msgCountToWrite-1), *messageSigData, c.config.SeqNumDuration)
 			}
 		}
-		pipe.PExpireAt(ctx, redisutil.CHOSENSEQ_KEY, lockoutUntil)
+		tx.ExpireAt(redisutil.CHOSENSEQ_KEY, lockoutUntil)
 		if setWantsLockout {
 			myWantsLockoutKey := redisutil.WantsLockoutKeyFor(c.config.Url())
-			pipe.Set(ctx, myWantsLockoutKey, redisutil.WANTS_LOCKOUT_VAL, initialDuration)
-			pipe.PExpireAt(ctx, myWantsLockoutKey, lockoutUntil)
-		}
-		err = execTestPipe(pipe, ctx)
-		if errors.Is(err, redis.TxFailedErr) {
-			return fmt.Errorf("%w: failed to catch sequencer lock", execution.ErrRetrySequencer)
-		}
-		if err != nil {
-			return fmt.Errorf("chosen sequencer failed to update redis: %w", err)
+			tx.Set(myWantsLockoutKey, redisutil.WANTS_LOCKOUT_VAL, initialDuration)
+			tx.ExpireAt(myWantsLockoutKey, lockoutUntil)
 		}
 		return nil
 	}, redisutil.CHOSENSEQ_KEY, redisutil.MSG_COUNT_KEY)
 
+	if errors.Is(err, coordination.ErrTxFailed) {
+		return fmt.Errorf("%w: failed to catch sequencer lock", execution.ErrRetrySequencer)
+	}
 	if err != nil {
 		return err
 	}
@@ -340,9 +349,9 @@ func (c *SeqCoordinator) acquireLockoutAndWriteMessage(ctx context.Context, msgC
 	return nil
 }
 
-func (c *SeqCoordinator) getRemoteMsgCountImpl(ctx context.Context, r redis.Cmdable) (arbutil.MessageIndex, error) {
-	resStr, err := r.Get(ctx, redisutil.MSG_COUNT_KEY).Result()
-	if errors.Is(err, redis.Nil) {
+func (c *SeqCoordinator) getRemoteMsgCountImpl(ctx context.Context, get func(context.Context, string) (string, error)) (arbutil.MessageIndex, error) {
+	resStr, err := get(ctx, redisutil.MSG_COUNT_KEY)
+	if errors.Is(err, coordination.ErrNotFound) {
 		return 0, nil
 	}
 	if err != nil {
@@ -352,7 +361,7 @@ func (c *SeqCoordinator) getRemoteMsgCountImpl(ctx context.Context, r redis.Cmda
 }
 
 func (c *SeqCoordinator) GetRemoteMsgCount() (arbutil.MessageIndex, error) {
-	return c.getRemoteMsgCountImpl(c.GetContext(), c.Client)
+	return c.getRemoteMsgCountImpl(c.GetContext(), c.Store.Get)
 }
 
 func (c *SeqCoordinator) wantsLockoutUpdate(ctx context.Context) error {
@@ -368,16 +377,17 @@ func (c *SeqCoordinator) wantsLockoutUpdateWithMutex(ctx context.Context) error
 	}
 	myWantsLockoutKey := redisutil.WantsLockoutKeyFor(c.config.Url())
 	wantsLockoutUntil := time.Now().Add(c.config.LockoutDuration)
-	pipe := c.Client.TxPipeline()
 	initialDuration := c.config.LockoutDuration
 	if initialDuration < 2*time.Second {
 		initialDuration = 2 * time.Second
 	}
-	pipe.Set(ctx, myWantsLockoutKey, redisutil.WANTS_LOCKOUT_VAL, initialDuration)
-	pipe.PExpireAt(ctx, myWantsLockoutKey, wantsLockoutUntil)
-	err := execTestPipe(pipe, ctx)
+	err := c.Store.Watch(ctx, func(tx coordination.Tx) error {
+		tx.Set(myWantsLockoutKey, redisutil.WANTS_LOCKOUT_VAL, initialDuration)
+		tx.ExpireAt(myWantsLockoutKey, wantsLockoutUntil)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update wants lockout key in redis: %w", err)
+		return fmt.Errorf("failed to update wants lockout key in coordinator: %w", err)
 	}
 	c.reportedWantsLockout = true
 	return nil
@@ -386,9 +396,9 @@ func (c *SeqCoordinator) wantsLockoutUpdateWithMutex(ctx context.Context) error
 func (c *SeqCoordinator) chosenOneRelease(ctx context.Context) error {
 	atomicTimeWrite(&c.lockoutUntil, time.Time{})
 	isActiveSequencer.Update(0)
-	releaseErr := c.Client.Watch(ctx, func(tx *redis.Tx) error {
-		current, err := tx.Get(ctx, redisutil.CHOSENSEQ_KEY).Result()
-		if errors.Is(err, redis.Nil) {
+	releaseErr := c.Store.Watch(ctx, func(tx coordination.Tx) error {
+		current, err := tx.Get(ctx, redisutil.CHOSENSEQ_KEY)
+		if errors.Is(err, coordination.ErrNotFound) {
 			return nil
 		}
 		if err != nil {
@@ -397,20 +407,16 @@ func (c *SeqCoordinator) chosenOneRelease(ctx context.Context) error {
 		if current != c.config.Url() {
 			return nil
 		}
-		pipe := tx.TxPipeline()
-		pipe.Del(ctx, redisutil.CHOSENSEQ_KEY)
-		err = execTestPipe(pipe, ctx)
-		if err != nil {
-			return fmt.Errorf("chosen sequencer failed to update redis: %w", err)
-		}
+		tx.Del(redisutil.CHOSENSEQ_KEY)
 		return nil
 	}, redisutil.CHOSENSEQ_KEY)
 	if releaseErr == nil {
 		return nil
 	}
+	releaseErr = fmt.Errorf("chosen sequencer failed to update coordinator: %w", releaseErr)
 	// got error - was it still released?
-	current, readErr := c.Client.Get(ctx, redisutil.CHOSENSEQ_KEY).Result()
-	if errors.Is(readErr, redis.Nil) {
+	current, readErr := c.Store.Get(ctx, redisutil.CHOSENSEQ_KEY)
+	if errors.Is(readErr, coordination.ErrNotFound) {
 		return nil
 	}
 	if current != c.config.Url() {
@@ -426,11 +432,11 @@ func (c *SeqCoordinator) wantsLockoutRelease(ctx context.Context) error {
 		return nil
 	}
 	myWantsLockoutKey := redisutil.WantsLockoutKeyFor(c.config.Url())
-	releaseErr := c.Client.Del(ctx, myWantsLockoutKey).Err()
+	releaseErr := c.Store.Del(ctx, myWantsLockoutKey)
 	if releaseErr != nil {
 		// got error - was it still deleted?
-		readErr := c.Client.Get(ctx, myWantsLockoutKey).Err()
-		if !errors.Is(readErr, redis.Nil) {
+		_, readErr := c.Store.Get(ctx, myWantsLockoutKey)
+		if !errors.Is(readErr, coordination.ErrNotFound) {
 			return releaseErr
 		}
 	}
@@ -464,6 +470,8 @@ func (c *SeqCoordinator) updateWithLockout(ctx context.Context, nextChosen strin
 				// The error was already logged in ForwardTo, just clean up state.
 				// Setting prevChosenSequencer to an empty string will cause the next update to attempt to reconnect.
 				setPrevChosenTo = ""
+			} else {
+				c.forwardQueueForHandoff(ctx, nextChosen)
 			}
 		}
 		if err := c.chosenOneRelease(ctx); err != nil {
@@ -494,6 +502,25 @@ func (c *SeqCoordinator) updateWithLockout(ctx context.Context, nextChosen strin
 	return c.noRedisError()
 }
 
+// forwardQueueForHandoff sends the transactions still queued in our sequencer to the next chosen one, which is
+// already receiving new transactions through ForwardTo, and waits for it to accept them for up to the queue handoff
+// timeout. Only after that the lockout is released, so none time out waiting for a sequencer that will never
+// sequence them. The next chosen sequencer may hold the forwards until it acquired the lockout, the ones still
+// pending when the timeout expires complete once it did.
+func (c *SeqCoordinator) forwardQueueForHandoff(ctx context.Context, nextChosen string) {
+	if c.config.QueueHandoffTimeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.config.QueueHandoffTimeout)
+	defer cancel()
+	start := time.Now()
+	if err := c.sequencer.ForwardQueue(ctx); err != nil {
+		log.Warn("failed to forward queued transactions before handing off the lockout", "nextChosen", nextChosen, "err", err)
+		return
+	}
+	log.Info("forwarded queued transactions to the next chosen sequencer", "nextChosen", nextChosen, "elapsed", time.Since(start))
+}
+
 func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	chosenSeq, err := c.RecommendSequencerWantingLockout(ctx)
 	if err != nil {
@@ -538,7 +565,7 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	var msgReadErr error
 	for msgToRead < readUntil {
 		var resString string
-		resString, msgReadErr = c.Client.Get(ctx, redisutil.MessageKeyFor(msgToRead)).Result()
+		resString, msgReadErr = c.Store.Get(ctx, redisutil.MessageKeyFor(msgToRead))
 		if msgReadErr != nil {
 			log.Warn("coordinator failed reading message", "pos", msgToRead, "err", msgReadErr)
 			break
@@ -547,8 +574,8 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 		var sigString string
 		var sigBytes []byte
 		sigSeparateKey := true
-		sigString, msgReadErr = c.Client.Get(ctx, redisutil.MessageSigKeyFor(msgToRead)).Result()
-		if errors.Is(msgReadErr, redis.Nil) {
+		sigString, msgReadErr = c.Store.Get(ctx, redisutil.MessageSigKeyFor(msgToRead))
+		if errors.Is(msgReadErr, coordination.ErrNotFound) {
 			// no separate signature. Try reading old-style sig
 			if len(rsBytes) < 32 {
 				log.Warn("signature not found for msg", "pos", msgToRead)
@@ -787,7 +814,7 @@ func (c *SeqCoordinator) StopAndWait() {
 			time.Sleep(c.retryAfterRedisError())
 		}
 	}
-	_ = c.Client.Close()
+	_ = c.Store.Close()
 }
 
 func (c *SeqCoordinator) CurrentlyChosen() bool {
@@ -14,6 +14,7 @@ import (
 
 	"github.com/yingdianRao/nitro/arbos/arbostypes"
 	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/util/coordination"
 	"github.com/yingdianRao/nitro/util/redisutil"
 	"github.com/yingdianRao/nitro/util/signature"
 )
@@ -91,12 +92,9 @@ func coordinatorTestThread(ctx context.Context, coord *SeqCoordinator, data *Coo
 	}
 }
 
-func TestRedisSeqCoordinatorAtomic(t *testing.T) {
+func testSeqCoordinatorAtomic(t *testing.T, ctx context.Context, coordConfig SeqCoordinatorConfig, stores []coordination.Store) {
 	NumOfThreads := 10
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
-	coordConfig := TestSeqCoordinatorConfig
 	coordConfig.LockoutDuration = time.Millisecond * 100
 	coordConfig.LockoutSpare = time.Millisecond * 10
 	coordConfig.Signer.ECDSA.AcceptSequencer = false
@@ -111,29 +109,20 @@ func TestRedisSeqCoordinatorAtomic(t *testing.T) {
 	nullSigner, err := signature.NewSignVerify(&coordConfig.Signer, nil, nil)
 	Require(t, err)
 
-	redisUrl := redisutil.CreateTestRedis(ctx, t)
-	coordConfig.RedisUrl = redisUrl
-	redisClient, err := redisutil.RedisClientFromURL(redisUrl)
-	Require(t, err)
-	if redisClient == nil {
-		t.Fatal("redisClient is nil")
-	}
-
 	for i := 0; i < NumOfThreads; i++ {
 		config := coordConfig
 		config.MyUrl = fmt.Sprint(i)
-		redisCoordinator, err := redisutil.NewRedisCoordinator(config.RedisUrl)
-		Require(t, err)
 		coordinator := &SeqCoordinator{
-			RedisCoordinator: *redisCoordinator,
-			config:           config,
-			signer:           nullSigner,
+			Coordinator: coordination.Coordinator{Store: stores[i%len(stores)]},
+			config:      config,
+			signer:      nullSigner,
 		}
 		go coordinatorTestThread(ctx, coordinator, &testData)
 	}
 
 	for round := int32(0); round < 10; round++ {
-		redisClient.Del(ctx, redisutil.CHOSENSEQ_KEY, redisutil.MSG_COUNT_KEY)
+		err := stores[0].Del(ctx, redisutil.CHOSENSEQ_KEY, redisutil.MSG_COUNT_KEY)
+		Require(t, err)
 		testData.messageCount = 0
 		for i := 0; i < messagesPerRound; i++ {
 			testData.sequencer[i] = ""
@@ -154,5 +143,33 @@ func TestRedisSeqCoordinatorAtomic(t *testing.T) {
 		// wait out the current lock
 		time.Sleep(time.Millisecond * 20)
 	}
+}
+
+func TestRedisSeqCoordinatorAtomic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	coordConfig := TestSeqCoordinatorConfig
+	coordConfig.RedisUrl = redisutil.CreateTestRedis(ctx, t)
+	var stores []coordination.Store
+	for i := 0; i < 10; i++ {
+		store, err := coordination.NewRedisStore(coordConfig.RedisUrl)
+		Require(t, err)
+		stores = append(stores, store)
+	}
+	testSeqCoordinatorAtomic(t, ctx, coordConfig, stores)
+}
+
+func TestRaftSeqCoordinatorAtomic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
+	coordConfig := TestSeqCoordinatorConfig
+	coordConfig.Backend = coordination.BackendRaft
+	nodes := coordination.CreateTestRaftGroup(t, 3)
+	var stores []coordination.Store
+	for _, node := range nodes {
+		stores = append(stores, node)
+	}
+	testSeqCoordinatorAtomic(t, ctx, coordConfig, stores)
 }
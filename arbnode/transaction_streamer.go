@@ -64,6 +64,8 @@ type TransactionStreamer struct {
 	broadcasterQueuedMessagesPos         uint64
 	broadcasterQueuedMessagesActiveReorg bool
 
+	storeFeedSignatures bool
+
 	coordinator     *SeqCoordinator
 	broadcastServer *broadcaster.Broadcaster
 	inboxReader     *InboxReader
@@ -161,6 +163,66 @@ func (s *TransactionStreamer) SetInboxReaders(inboxReader *InboxReader, delayedB
 	s.delayedBridge = delayedBridge
 }
 
+// StoreFeedSignatures makes the streamer keep the signatures of the feed messages it receives,
+// to serve them to peers backfilling their feed.
+func (s *TransactionStreamer) StoreFeedSignatures() {
+	if s.Started() {
+		panic("trying to store feed signatures after start")
+	}
+	s.storeFeedSignatures = true
+}
+
+// feedSignature is the signature a feed message was received with, along with the hash it signs,
+// so that it's only served along with the same message.
+type feedSignature struct {
+	Hash      common.Hash
+	Signature []byte
+}
+
+func (s *TransactionStreamer) writeFeedSignatures(feedMessages []*m.BroadcastFeedMessage) error {
+	batch := s.db.NewBatch()
+	for _, feedMessage := range feedMessages {
+		if len(feedMessage.Signature) == 0 {
+			continue
+		}
+		hash, err := feedMessage.Hash(s.chainConfig.ChainID.Uint64())
+		if err != nil {
+			return err
+		}
+		data, err := rlp.EncodeToBytes(feedSignature{Hash: hash, Signature: feedMessage.Signature})
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(dbKey(feedSignaturePrefix, uint64(feedMessage.SequenceNumber)), data); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// GetFeedSignature returns the signature the message at seqNum was received with from the feed,
+// or nil if there's none for the message hashing to hash.
+func (s *TransactionStreamer) GetFeedSignature(seqNum arbutil.MessageIndex, hash common.Hash) ([]byte, error) {
+	key := dbKey(feedSignaturePrefix, uint64(seqNum))
+	has, err := s.db.Has(key)
+	if err != nil || !has {
+		return nil, err
+	}
+	data, err := s.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var signature feedSignature
+	if err := rlp.DecodeBytes(data, &signature); err != nil {
+		return nil, err
+	}
+	if signature.Hash != hash {
+		// the message was replaced since
+		return nil, nil
+	}
+	return signature.Signature, nil
+}
+
 func (s *TransactionStreamer) cleanupInconsistentState() error {
 	// If it doesn't exist yet, set the message count to 0
 	hasMessageCount, err := s.db.Has(messageCountKey)
@@ -457,6 +519,11 @@ func (s *TransactionStreamer) AddBroadcastMessages(feedMessages []*m.BroadcastFe
 	if err != nil {
 		return err
 	}
+	if s.storeFeedSignatures {
+		if err := s.writeFeedSignatures(feedMessages[dups:]); err != nil {
+			return err
+		}
+	}
 	messages = messages[dups:]
 	broadcastStartPos += arbutil.MessageIndex(dups)
 	if oldMsg != nil {
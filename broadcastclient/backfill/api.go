@@ -0,0 +1,104 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package backfill
+
+import (
+	"context"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/signature"
+)
+
+// APINamespace is the RPC namespace of the API, which peers backfill their feed gaps from.
+const APINamespace = "arbfeed"
+
+// MaxAPIMessages bounds the number of messages returned by a single getMessages call.
+const MaxAPIMessages = 1024
+
+type APIConfig struct {
+	Enable bool `koanf:"enable"`
+}
+
+var DefaultAPIConfig = APIConfig{
+	Enable: false,
+}
+
+func APIConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultAPIConfig.Enable, "serve the messages with the signatures they were received with from the feed, for peers to backfill their feed gaps from")
+}
+
+type MessageSource interface {
+	GetMessage(seqNum arbutil.MessageIndex) (*arbostypes.MessageWithMetadata, error)
+	GetMessageCount() (arbutil.MessageIndex, error)
+	GetFeedSignature(seqNum arbutil.MessageIndex, hash common.Hash) ([]byte, error)
+}
+
+// API serves the node's messages as feed messages, for peers to backfill the gaps in their feed.
+// Messages are served with the signature they were received with from the feed, or signed with the
+// data signer if there's none, which should only be given on the sequencer producing the messages.
+type API struct {
+	source     MessageSource
+	chainId    uint64
+	dataSigner signature.DataSignerFunc
+}
+
+// NewAPI returns an API serving the messages of source, signing those without a feed signature
+// with dataSigner if it's not nil.
+func NewAPI(source MessageSource, chainId uint64, dataSigner signature.DataSignerFunc) *API {
+	return &API{
+		source:     source,
+		chainId:    chainId,
+		dataSigner: dataSigner,
+	}
+}
+
+// GetMessages returns up to count messages from start, fewer if the node doesn't have them yet.
+func (a *API) GetMessages(ctx context.Context, start hexutil.Uint64, count hexutil.Uint64) ([]*m.BroadcastFeedMessage, error) {
+	if count > MaxAPIMessages {
+		count = MaxAPIMessages
+	}
+	msgCount, err := a.source.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	end := arbutil.MessageIndex(start + count)
+	if end > msgCount {
+		end = msgCount
+	}
+	var messages []*m.BroadcastFeedMessage
+	for seqNum := arbutil.MessageIndex(start); seqNum < end; seqNum++ {
+		msg, err := a.source.GetMessage(seqNum)
+		if err != nil {
+			return nil, fmt.Errorf("error reading message %v: %w", seqNum, err)
+		}
+		feedMessage := &m.BroadcastFeedMessage{
+			SequenceNumber: seqNum,
+			Message:        *msg,
+		}
+		hash, err := feedMessage.Hash(a.chainId)
+		if err != nil {
+			return nil, err
+		}
+		feedMessage.Signature, err = a.source.GetFeedSignature(seqNum, hash)
+		if err != nil {
+			return nil, fmt.Errorf("error reading feed signature of message %v: %w", seqNum, err)
+		}
+		if feedMessage.Signature == nil && a.dataSigner != nil {
+			feedMessage.Signature, err = a.dataSigner(hash.Bytes())
+			if err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, feedMessage)
+	}
+	return messages, nil
+}
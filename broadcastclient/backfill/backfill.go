@@ -0,0 +1,397 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package backfill fills the gaps in a node's feed from the RPCs of peer nodes.
+//
+// Feed messages past the node's last message are queued by the transaction streamer until the messages
+// before them arrive, which without backfilling only happens once the inbox reader reads their batch.
+// A Backfiller sits between the feed clients and the transaction streamer: when feed messages leave a gap,
+// it reads the missing messages from its peers' API, and adds them followed by the feed messages received since.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/contracts"
+	"github.com/yingdianRao/nitro/util/signature"
+	"github.com/yingdianRao/nitro/util/stopwaiter"
+)
+
+var (
+	gapsCounter          = metrics.NewRegisteredCounter("arb/feed/backfill/gaps", nil)
+	skippedGapsCounter   = metrics.NewRegisteredCounter("arb/feed/backfill/skipped", nil)
+	messagesCounter      = metrics.NewRegisteredCounter("arb/feed/backfill/messages", nil)
+	failuresCounter      = metrics.NewRegisteredCounter("arb/feed/backfill/failures", nil)
+	disagreementsCounter = metrics.NewRegisteredCounter("arb/feed/backfill/disagreements", nil)
+)
+
+var errPeersDisagree = errors.New("feed backfill peers returned different messages")
+
+type Config struct {
+	Enable        bool                     `koanf:"enable"`
+	URL           []string                 `koanf:"url"`
+	Quorum        int                      `koanf:"quorum"`
+	Verify        signature.VerifierConfig `koanf:"verify"`
+	MaxGap        uint64                   `koanf:"max-gap" reload:"hot"`
+	BatchSize     uint64                   `koanf:"batch-size" reload:"hot"`
+	Timeout       time.Duration            `koanf:"timeout" reload:"hot"`
+	RetryInterval time.Duration            `koanf:"retry-interval" reload:"hot"`
+}
+
+type ConfigFetcher func() *Config
+
+var DefaultConfig = Config{
+	Enable: false,
+	URL:    []string{},
+	Quorum: 1,
+	Verify: signature.VerifierConfig{
+		AllowedAddresses: []string{},
+		AcceptSequencer:  true,
+		Dangerous: signature.DangerousVerifierConfig{
+			AcceptMissing: false,
+		},
+	},
+	MaxGap:        10_000,
+	BatchSize:     MaxAPIMessages,
+	Timeout:       5 * time.Second,
+	RetryInterval: time.Second,
+}
+
+var DefaultTestConfig = Config{
+	Enable:        false,
+	URL:           []string{},
+	Quorum:        1,
+	Verify:        signature.TestingFeedVerifierConfig,
+	MaxGap:        10_000,
+	BatchSize:     MaxAPIMessages,
+	Timeout:       time.Second,
+	RetryInterval: 10 * time.Millisecond,
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultConfig.Enable, "fill gaps in the feed from peer nodes, instead of waiting for the inbox reader to read the missing messages")
+	f.StringSlice(prefix+".url", DefaultConfig.URL, "list of RPC URLs of the peer nodes to backfill from, serving the "+APINamespace+" API")
+	f.Int(prefix+".quorum", DefaultConfig.Quorum, "number of peers that must return the same messages for them to be accepted")
+	f.StringSlice(prefix+".verify.allowed-addresses", DefaultConfig.Verify.AllowedAddresses, "a list of addresses allowed to sign the backfilled messages")
+	f.Bool(prefix+".verify.accept-sequencer", DefaultConfig.Verify.AcceptSequencer, "accept backfilled messages signed by the sequencer")
+	f.Bool(prefix+".verify.dangerous.accept-missing", DefaultConfig.Verify.Dangerous.AcceptMissing, "accept backfilled messages without a signature, only verifying that a quorum of peers agree on them")
+	f.Uint64(prefix+".max-gap", DefaultConfig.MaxGap, "the largest gap to backfill, larger ones are left to the inbox reader")
+	f.Uint64(prefix+".batch-size", DefaultConfig.BatchSize, "the number of messages to request from peers at once")
+	f.Duration(prefix+".timeout", DefaultConfig.Timeout, "timeout of requests to peers")
+	f.Duration(prefix+".retry-interval", DefaultConfig.RetryInterval, "duration to wait before retrying a failed backfill")
+}
+
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if len(c.URL) == 0 {
+		return errors.New("feed backfill enabled without peer urls")
+	}
+	if c.Quorum < 1 || c.Quorum > len(c.URL) {
+		return fmt.Errorf("feed backfill quorum %v must be between 1 and the number of peers %v", c.Quorum, len(c.URL))
+	}
+	if c.Verify.Dangerous.AcceptMissing && c.Quorum < 2 {
+		return errors.New("feed backfill accepting unsigned messages requires a quorum of at least 2 peers")
+	}
+	if c.BatchSize == 0 || c.BatchSize > MaxAPIMessages {
+		return fmt.Errorf("feed backfill batch-size must be between 1 and %v", MaxAPIMessages)
+	}
+	return nil
+}
+
+// TransactionStreamer is the part of the transaction streamer the Backfiller adds messages to.
+type TransactionStreamer interface {
+	AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessage) error
+	GetMessageCount() (arbutil.MessageIndex, error)
+}
+
+type peer struct {
+	url    string
+	client *rpc.Client
+}
+
+func (p *peer) getMessages(ctx context.Context, start arbutil.MessageIndex, count uint64) ([]*m.BroadcastFeedMessage, error) {
+	if p.client == nil {
+		client, err := rpc.DialContext(ctx, p.url)
+		if err != nil {
+			return nil, err
+		}
+		p.client = client
+	}
+	var messages []*m.BroadcastFeedMessage
+	err := p.client.CallContext(ctx, &messages, APINamespace+"_getMessages", hexutil.Uint64(start), hexutil.Uint64(count))
+	return messages, err
+}
+
+// Backfiller passes feed messages on to the transaction streamer, and fills the gaps they leave
+// from its peers. It's meant to be given to the feed clients as their transaction streamer.
+type Backfiller struct {
+	stopwaiter.StopWaiter
+
+	config   ConfigFetcher
+	streamer TransactionStreamer
+	chainId  uint64
+	verifier *signature.Verifier
+	// peers are only used by the backfilling thread, they're built along with the verifier
+	// and quorum from the config at creation, only its other settings are reloaded
+	peers  []*peer
+	quorum int
+	gaps   chan struct{}
+
+	mutex sync.Mutex
+	// pending are the feed messages received past the gap, it's empty if there's none
+	pending []*m.BroadcastFeedMessage
+}
+
+func NewBackfiller(config ConfigFetcher, streamer TransactionStreamer, chainId uint64, addrVerifier contracts.AddressVerifierInterface) (*Backfiller, error) {
+	c := config()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	verifier, err := signature.NewVerifier(&c.Verify, addrVerifier, nil)
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]*peer, 0, len(c.URL))
+	for _, url := range c.URL {
+		peers = append(peers, &peer{url: url})
+	}
+	return &Backfiller{
+		config:   config,
+		streamer: streamer,
+		chainId:  chainId,
+		verifier: verifier,
+		peers:    peers,
+		quorum:   c.Quorum,
+		gaps:     make(chan struct{}, 1),
+	}, nil
+}
+
+func (b *Backfiller) Start(ctxIn context.Context) {
+	b.StopWaiter.Start(ctxIn, b)
+	b.LaunchThread(b.backfillGaps)
+}
+
+func (b *Backfiller) StopAndWait() {
+	b.StopWaiter.StopAndWait()
+	for _, p := range b.peers {
+		if p.client != nil {
+			p.client.Close()
+		}
+	}
+}
+
+// AddBroadcastMessages adds feedMessages to the transaction streamer, and starts backfilling if they leave a gap.
+func (b *Backfiller) AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessage) error {
+	if len(feedMessages) == 0 {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err := b.streamer.AddBroadcastMessages(feedMessages); err != nil {
+		return err
+	}
+	msgCount, err := b.streamer.GetMessageCount()
+	if err != nil {
+		return err
+	}
+	first := feedMessages[0].SequenceNumber
+	if first <= msgCount {
+		b.pending = nil
+		return nil
+	}
+
+	if n := len(b.pending); n > 0 && b.pending[n-1].SequenceNumber+1 == first {
+		b.pending = append(b.pending, feedMessages...)
+	} else {
+		if first-msgCount > arbutil.MessageIndex(b.config().MaxGap) {
+			log.Debug("feed gap too large to backfill, waiting for the inbox reader", "messageCount", msgCount, "sequenceNumber", first)
+			skippedGapsCounter.Inc(1)
+			b.pending = nil
+			return nil
+		}
+		if len(b.pending) == 0 {
+			log.Info("backfilling feed gap", "messageCount", msgCount, "sequenceNumber", first)
+			gapsCounter.Inc(1)
+		}
+		b.pending = append([]*m.BroadcastFeedMessage{}, feedMessages...)
+	}
+	// the feed messages are kept until the gap is filled, as many as it may be backfilled for
+	if uint64(len(b.pending)) > b.config().MaxGap {
+		log.Warn("feed gap not backfilled in time, waiting for the inbox reader", "messageCount", msgCount, "sequenceNumber", b.pending[0].SequenceNumber)
+		skippedGapsCounter.Inc(1)
+		b.pending = nil
+		return nil
+	}
+	select {
+	case b.gaps <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// gap returns the range of messages missing before the pending feed messages, if any.
+func (b *Backfiller) gap() (arbutil.MessageIndex, arbutil.MessageIndex, bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.pending) == 0 {
+		return 0, 0, false, nil
+	}
+	msgCount, err := b.streamer.GetMessageCount()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	end := b.pending[0].SequenceNumber
+	if end <= msgCount {
+		// the inbox reader filled the gap
+		pending := b.pending
+		b.pending = nil
+		return 0, 0, false, b.streamer.AddBroadcastMessages(pending)
+	}
+	return msgCount, end, true, nil
+}
+
+// add adds backfilled messages, then the pending feed messages once they follow the added ones.
+func (b *Backfiller) add(messages []*m.BroadcastFeedMessage) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err := b.streamer.AddBroadcastMessages(messages); err != nil {
+		return err
+	}
+	messagesCounter.Inc(int64(len(messages)))
+	// adding messages before the ones the streamer queued replaces them
+	if len(b.pending) > 0 && messages[len(messages)-1].SequenceNumber+1 >= b.pending[0].SequenceNumber {
+		pending := b.pending
+		b.pending = nil
+		log.Info("backfilled feed gap", "sequenceNumber", pending[0].SequenceNumber)
+		return b.streamer.AddBroadcastMessages(pending)
+	}
+	return nil
+}
+
+func (b *Backfiller) backfillGaps(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.gaps:
+		}
+		for {
+			start, end, ok, err := b.gap()
+			if err == nil && !ok {
+				break
+			}
+			if err == nil {
+				var messages []*m.BroadcastFeedMessage
+				messages, err = b.fetch(ctx, start, end)
+				if err == nil {
+					err = b.add(messages)
+				}
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				failuresCounter.Inc(1)
+				log.Warn("error backfilling feed gap", "err", err)
+				timer := time.NewTimer(b.config().RetryInterval)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+	}
+}
+
+// fetch reads messages from start up to end from the peers, until a quorum of them return the same verified messages.
+func (b *Backfiller) fetch(ctx context.Context, start, end arbutil.MessageIndex) ([]*m.BroadcastFeedMessage, error) {
+	config := b.config()
+	count := uint64(end - start)
+	if count > config.BatchSize {
+		count = config.BatchSize
+	}
+	var accepted []*m.BroadcastFeedMessage
+	var acceptedHashes []common.Hash
+	agreeing := 0
+	var lastErr error
+	for _, p := range b.peers {
+		peerCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+		messages, err := p.getMessages(peerCtx, start, count)
+		cancel()
+		var hashes []common.Hash
+		if err == nil {
+			hashes, err = b.verify(ctx, start, messages)
+		}
+		if err != nil {
+			log.Warn("error backfilling feed from peer", "url", p.url, "start", start, "err", err)
+			lastErr = err
+			continue
+		}
+		if accepted == nil {
+			accepted, acceptedHashes = messages, hashes
+		} else {
+			// peers may not all have the latest messages
+			if len(hashes) < len(acceptedHashes) {
+				accepted, acceptedHashes = accepted[:len(hashes)], acceptedHashes[:len(hashes)]
+			}
+			for i := range acceptedHashes {
+				if hashes[i] != acceptedHashes[i] {
+					disagreementsCounter.Inc(1)
+					log.Error("feed backfill peers returned different messages", "url", p.url, "sequenceNumber", messages[i].SequenceNumber)
+					return nil, errPeersDisagree
+				}
+			}
+		}
+		agreeing++
+		if agreeing >= b.quorum {
+			return accepted, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("not enough peers")
+	}
+	return nil, fmt.Errorf("only %v of the %v peers needed returned messages from %v: %w", agreeing, b.quorum, start, lastErr)
+}
+
+// verify checks that messages follow from start and are signed as configured, and returns their hashes.
+func (b *Backfiller) verify(ctx context.Context, start arbutil.MessageIndex, messages []*m.BroadcastFeedMessage) ([]common.Hash, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("peer has no messages from the gap")
+	}
+	hashes := make([]common.Hash, 0, len(messages))
+	for i, msg := range messages {
+		if msg == nil || msg.SequenceNumber != start+arbutil.MessageIndex(i) {
+			return nil, fmt.Errorf("peer returned message out of order at %v", start+arbutil.MessageIndex(i))
+		}
+		if msg.Message.Message == nil || msg.Message.Message.Header == nil {
+			return nil, fmt.Errorf("peer returned invalid message %v", msg.SequenceNumber)
+		}
+		hash, err := msg.Hash(b.chainId)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.verifier.VerifyHash(ctx, msg.Signature, hash); err != nil {
+			return nil, fmt.Errorf("error verifying signature of message %v: %w", msg.SequenceNumber, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package backfill
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/signature"
+	"github.com/yingdianRao/nitro/util/testhelpers"
+)
+
+const testChainId = uint64(9742)
+
+// testStreamer keeps the messages following its last one, and ignores the others.
+type testStreamer struct {
+	mutex      sync.Mutex
+	messages   []arbostypes.MessageWithMetadata
+	signatures map[arbutil.MessageIndex][]byte
+}
+
+func newTestStreamer(count int, salt byte) *testStreamer {
+	s := &testStreamer{}
+	for i := 0; i < count; i++ {
+		s.messages = append(s.messages, testMessage(i, salt))
+	}
+	return s
+}
+
+func testMessage(i int, salt byte) arbostypes.MessageWithMetadata {
+	header := *arbostypes.TestIncomingMessageWithRequestId.Header
+	return arbostypes.MessageWithMetadata{
+		Message: &arbostypes.L1IncomingMessage{
+			Header: &header,
+			L2msg:  []byte{byte(i), salt},
+		},
+	}
+}
+
+func (s *testStreamer) AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, msg := range feedMessages {
+		seqNum := int(msg.SequenceNumber)
+		if seqNum < len(s.messages) {
+			s.messages[seqNum] = msg.Message
+		} else if seqNum == len(s.messages) {
+			s.messages = append(s.messages, msg.Message)
+		} else {
+			continue
+		}
+		if len(msg.Signature) > 0 {
+			if s.signatures == nil {
+				s.signatures = make(map[arbutil.MessageIndex][]byte)
+			}
+			s.signatures[msg.SequenceNumber] = msg.Signature
+		}
+	}
+	return nil
+}
+
+func (s *testStreamer) GetFeedSignature(seqNum arbutil.MessageIndex, _ common.Hash) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.signatures[seqNum], nil
+}
+
+func (s *testStreamer) GetMessage(seqNum arbutil.MessageIndex) (*arbostypes.MessageWithMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if int(seqNum) >= len(s.messages) {
+		return nil, errors.New("message not found")
+	}
+	msg := s.messages[seqNum]
+	return &msg, nil
+}
+
+func (s *testStreamer) GetMessageCount() (arbutil.MessageIndex, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return arbutil.MessageIndex(len(s.messages)), nil
+}
+
+func feedMessages(from, to int, salt byte) []*m.BroadcastFeedMessage {
+	var messages []*m.BroadcastFeedMessage
+	for i := from; i < to; i++ {
+		messages = append(messages, &m.BroadcastFeedMessage{
+			SequenceNumber: arbutil.MessageIndex(i),
+			Message:        testMessage(i, salt),
+		})
+	}
+	return messages
+}
+
+func startPeer(t *testing.T, source MessageSource, dataSigner signature.DataSignerFunc) string {
+	t.Helper()
+	server := rpc.NewServer()
+	Require(t, server.RegisterName(APINamespace, NewAPI(source, testChainId, dataSigner)))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer.URL
+}
+
+func newTestBackfiller(t *testing.T, config *Config, streamer TransactionStreamer) *Backfiller {
+	t.Helper()
+	b, err := NewBackfiller(func() *Config { return config }, streamer, testChainId, nil)
+	Require(t, err)
+	return b
+}
+
+func TestBackfillGap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signer := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	config := DefaultTestConfig
+	config.Enable = true
+	config.URL = []string{startPeer(t, newTestStreamer(20, 0), signature.DataSignerFromPrivateKey(privateKey))}
+	config.Verify.AllowedAddresses = []string{signer.Hex()}
+	config.BatchSize = 4
+
+	streamer := newTestStreamer(5, 0)
+	b := newTestBackfiller(t, &config, streamer)
+	b.Start(ctx)
+	defer b.StopAndWait()
+
+	Require(t, b.AddBroadcastMessages(feedMessages(15, 18, 0)))
+	Require(t, b.AddBroadcastMessages(feedMessages(18, 20, 0)))
+
+	for i := 0; ; i++ {
+		count, err := streamer.GetMessageCount()
+		Require(t, err)
+		if count == 20 {
+			break
+		}
+		if i >= 500 {
+			Fail(t, "gap not backfilled, message count", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 20; i++ {
+		msg, err := streamer.GetMessage(arbutil.MessageIndex(i))
+		Require(t, err)
+		if msg.Message.L2msg[0] != byte(i) {
+			Fail(t, "unexpected message", i, msg.Message.L2msg)
+		}
+	}
+}
+
+func TestBackfillServesFeedSignatures(t *testing.T) {
+	ctx := context.Background()
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	dataSigner := signature.DataSignerFromPrivateKey(privateKey)
+
+	// the peer received its messages from a signed feed, and has no signer of its own
+	peerStreamer := newTestStreamer(0, 0)
+	signed := feedMessages(0, 10, 0)
+	for _, msg := range signed {
+		hash, err := msg.Hash(testChainId)
+		Require(t, err)
+		msg.Signature, err = dataSigner(hash.Bytes())
+		Require(t, err)
+	}
+	Require(t, peerStreamer.AddBroadcastMessages(signed))
+
+	config := DefaultTestConfig
+	config.Enable = true
+	config.URL = []string{startPeer(t, peerStreamer, nil)}
+	config.Verify.AllowedAddresses = []string{crypto.PubkeyToAddress(privateKey.PublicKey).Hex()}
+
+	b := newTestBackfiller(t, &config, newTestStreamer(2, 0))
+	defer b.StopAndWait()
+	messages, err := b.fetch(ctx, 2, 8)
+	Require(t, err)
+	if len(messages) != 6 {
+		Fail(t, "unexpected messages", len(messages))
+	}
+}
+
+func TestBackfillRejectsUnsignedMessages(t *testing.T) {
+	ctx := context.Background()
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+
+	config := DefaultTestConfig
+	config.Enable = true
+	config.URL = []string{startPeer(t, newTestStreamer(10, 0), nil)}
+	config.Verify.AllowedAddresses = []string{crypto.PubkeyToAddress(privateKey.PublicKey).Hex()}
+
+	b := newTestBackfiller(t, &config, newTestStreamer(2, 0))
+	defer b.StopAndWait()
+	if _, err := b.fetch(ctx, 2, 8); err == nil {
+		Fail(t, "unsigned messages accepted")
+	}
+}
+
+func TestBackfillQuorum(t *testing.T) {
+	ctx := context.Background()
+
+	config := DefaultTestConfig
+	config.Enable = true
+	config.Verify.Dangerous.AcceptMissing = true
+	config.Quorum = 2
+	config.URL = []string{
+		startPeer(t, newTestStreamer(10, 0), nil),
+		startPeer(t, newTestStreamer(6, 0), nil),
+		startPeer(t, newTestStreamer(10, 1), nil),
+	}
+
+	b := newTestBackfiller(t, &config, newTestStreamer(2, 0))
+	defer b.StopAndWait()
+	messages, err := b.fetch(ctx, 2, 8)
+	Require(t, err)
+	// the second peer only has the messages up to 6
+	if len(messages) != 4 || messages[0].SequenceNumber != 2 {
+		Fail(t, "unexpected messages", len(messages))
+	}
+
+	config.Quorum = 3
+	b = newTestBackfiller(t, &config, newTestStreamer(2, 0))
+	defer b.StopAndWait()
+	if _, err := b.fetch(ctx, 2, 8); !errors.Is(err, errPeersDisagree) {
+		Fail(t, "expected peers to disagree, got", err)
+	}
+}
+
+func TestBackfillSkipsLargeGap(t *testing.T) {
+	config := DefaultTestConfig
+	config.Enable = true
+	config.URL = []string{"http://127.0.0.1:1"}
+	config.MaxGap = 10
+
+	streamer := newTestStreamer(5, 0)
+	b, err := NewBackfiller(func() *Config { return &config }, streamer, testChainId, nil)
+	Require(t, err)
+
+	Require(t, b.AddBroadcastMessages(feedMessages(100, 101, 0)))
+	if len(b.pending) != 0 {
+		Fail(t, "large gap kept for backfilling")
+	}
+	Require(t, b.AddBroadcastMessages(feedMessages(10, 12, 0)))
+	if len(b.pending) != 2 {
+		Fail(t, "gap not kept for backfilling")
+	}
+	// the inbox reader catching up fills the gap
+	Require(t, streamer.AddBroadcastMessages(feedMessages(5, 10, 0)))
+	_, _, ok, err := b.gap()
+	Require(t, err)
+	if ok || len(b.pending) != 0 {
+		Fail(t, "gap not filled by the inbox reader")
+	}
+	if count, _ := streamer.GetMessageCount(); count != 12 {
+		Fail(t, "pending messages not added, message count", count)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := DefaultConfig
+	valid.Enable = true
+	valid.URL = []string{"http://a", "http://b"}
+	Require(t, valid.Validate())
+
+	tests := []func(c *Config){
+		func(c *Config) { c.URL = nil },
+		func(c *Config) { c.Quorum = 0 },
+		func(c *Config) { c.Quorum = 3 },
+		func(c *Config) { c.Verify.Dangerous.AcceptMissing = true; c.Quorum = 1 },
+		func(c *Config) { c.BatchSize = 0 },
+		func(c *Config) { c.BatchSize = MaxAPIMessages + 1 },
+	}
+	for i, modify := range tests {
+		config := valid
+		modify(&config)
+		if config.Validate() == nil {
+			Fail(t, "invalid config accepted", i)
+		}
+	}
+}
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
@@ -27,6 +27,8 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 
 	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/broadcastclient/backfill"
+	"github.com/yingdianRao/nitro/broadcaster/filter"
 	m "github.com/yingdianRao/nitro/broadcaster/message"
 	"github.com/yingdianRao/nitro/util/contracts"
 	"github.com/yingdianRao/nitro/util/signature"
@@ -45,6 +47,12 @@ type FeedConfig struct {
 }
 
 func (fc *FeedConfig) Validate() error {
+	if err := fc.Input.SignerRegistry.Validate(); err != nil {
+		return err
+	}
+	if err := fc.Input.Backfill.Validate(); err != nil {
+		return err
+	}
 	return fc.Output.Validate()
 }
 
@@ -63,15 +71,20 @@ var FeedConfigDefault = FeedConfig{
 }
 
 type Config struct {
-	ReconnectInitialBackoff time.Duration            `koanf:"reconnect-initial-backoff" reload:"hot"`
-	ReconnectMaximumBackoff time.Duration            `koanf:"reconnect-maximum-backoff" reload:"hot"`
-	RequireChainId          bool                     `koanf:"require-chain-id" reload:"hot"`
-	RequireFeedVersion      bool                     `koanf:"require-feed-version" reload:"hot"`
-	Timeout                 time.Duration            `koanf:"timeout" reload:"hot"`
-	URL                     []string                 `koanf:"url"`
-	SecondaryURL            []string                 `koanf:"secondary-url"`
-	Verify                  signature.VerifierConfig `koanf:"verify"`
-	EnableCompression       bool                     `koanf:"enable-compression" reload:"hot"`
+	ReconnectInitialBackoff time.Duration                  `koanf:"reconnect-initial-backoff" reload:"hot"`
+	ReconnectMaximumBackoff time.Duration                  `koanf:"reconnect-maximum-backoff" reload:"hot"`
+	RequireChainId          bool                           `koanf:"require-chain-id" reload:"hot"`
+	RequireFeedVersion      bool                           `koanf:"require-feed-version" reload:"hot"`
+	Timeout                 time.Duration                  `koanf:"timeout" reload:"hot"`
+	URL                     []string                       `koanf:"url"`
+	SecondaryURL            []string                       `koanf:"secondary-url"`
+	Merge                   bool                           `koanf:"merge"`
+	Verify                  signature.VerifierConfig       `koanf:"verify"`
+	SignerRegistry          contracts.SignerRegistryConfig `koanf:"signer-registry"`
+	Backfill                backfill.Config                `koanf:"backfill" reload:"hot"`
+	EnableCompression       bool                           `koanf:"enable-compression" reload:"hot"`
+	EnableBinary            bool                           `koanf:"enable-binary" reload:"hot"`
+	AuthToken               string                         `koanf:"auth-token" reload:"hot"`
 }
 
 func (c *Config) Enable() bool {
@@ -88,8 +101,13 @@ func ConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Duration(prefix+".timeout", DefaultConfig.Timeout, "duration to wait before timing out connection to sequencer feed")
 	f.StringSlice(prefix+".url", DefaultConfig.URL, "list of primary URLs of sequencer feed source")
 	f.StringSlice(prefix+".secondary-url", DefaultConfig.SecondaryURL, "list of secondary URLs of sequencer feed source. Would be started in the order they appear in the list when primary feeds fails")
+	f.Bool(prefix+".merge", DefaultConfig.Merge, "consume the primary and secondary feeds at once, delivering each message from the first feed to send it and alerting when the feeds diverge")
 	signature.FeedVerifierConfigAddOptions(prefix+".verify", f)
+	contracts.SignerRegistryConfigAddOptions(prefix+".signer-registry", f)
+	backfill.ConfigAddOptions(prefix+".backfill", f)
 	f.Bool(prefix+".enable-compression", DefaultConfig.EnableCompression, "enable per message deflate compression support")
+	f.Bool(prefix+".enable-binary", DefaultConfig.EnableBinary, "ask for the binary (rlp) feed encoding, servers not supporting it send json")
+	f.String(prefix+".auth-token", DefaultConfig.AuthToken, "API key or JWT to authenticate to the feed with, for relays giving authenticated clients other limits")
 }
 
 var DefaultConfig = Config{
@@ -98,10 +116,15 @@ var DefaultConfig = Config{
 	RequireChainId:          false,
 	RequireFeedVersion:      false,
 	Verify:                  signature.DefultFeedVerifierConfig,
+	SignerRegistry:          contracts.DefaultSignerRegistryConfig,
+	Backfill:                backfill.DefaultConfig,
 	URL:                     []string{},
 	SecondaryURL:            []string{},
+	Merge:                   false,
 	Timeout:                 20 * time.Second,
 	EnableCompression:       true,
+	EnableBinary:            false,
+	AuthToken:               "",
 }
 
 var DefaultTestConfig = Config{
@@ -110,10 +133,15 @@ var DefaultTestConfig = Config{
 	RequireChainId:          false,
 	RequireFeedVersion:      false,
 	Verify:                  signature.DefultFeedVerifierConfig,
+	SignerRegistry:          contracts.DefaultSignerRegistryConfig,
+	Backfill:                backfill.DefaultTestConfig,
 	URL:                     []string{""},
 	SecondaryURL:            []string{},
+	Merge:                   false,
 	Timeout:                 200 * time.Millisecond,
 	EnableCompression:       true,
+	EnableBinary:            false,
+	AuthToken:               "",
 }
 
 type TransactionStreamerInterface interface {
@@ -129,10 +157,13 @@ type BroadcastClient struct {
 	sigVerifier  *signature.Verifier
 
 	chainId uint64
+	// feedFilter is sent in the handshake, see SetFilter
+	feedFilter *filter.Filter
 
-	// Protects conn and shuttingDown
+	// Protects conn, encoding and shuttingDown
 	connMutex sync.Mutex
 	conn      net.Conn
+	encoding  string
 
 	retryCount int64
 
@@ -158,9 +189,10 @@ func NewBroadcastClient(
 	confirmedSequencerNumberListener chan arbutil.MessageIndex,
 	fatalErrChan chan error,
 	addrVerifier contracts.AddressVerifierInterface,
+	signerRegistry contracts.SignerRegistryInterface,
 	adjustCount func(int32),
 ) (*BroadcastClient, error) {
-	sigVerifier, err := signature.NewVerifier(&config().Verify, addrVerifier)
+	sigVerifier, err := signature.NewVerifier(&config().Verify, addrVerifier, signerRegistry)
 	if err != nil {
 		return nil, err
 	}
@@ -177,6 +209,17 @@ func NewBroadcastClient(
 	}, err
 }
 
+// SetFilter makes the client ask for the part of the feed passing f, it must be called before Start.
+// A filtered feed leaves messages out, so it must not be fed to a node's transaction streamer:
+// it is meant for consumers such as indexers, see the filter package.
+func (bc *BroadcastClient) SetFilter(f *filter.Filter) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	bc.feedFilter = f
+	return nil
+}
+
 func (bc *BroadcastClient) Start(ctxIn context.Context) {
 	bc.StopWaiter.Start(ctxIn, bc)
 	if bc.StopWaiter.Stopped() {
@@ -219,18 +262,34 @@ func (bc *BroadcastClient) connect(ctx context.Context, nextSeqNum arbutil.Messa
 		return nil, nil
 	}
 
-	header := ws.HandshakeHeaderHTTP(http.Header{
+	config := bc.config()
+	httpHeader := http.Header{
 		wsbroadcastserver.HTTPHeaderFeedClientVersion:       []string{strconv.Itoa(wsbroadcastserver.FeedClientVersion)},
 		wsbroadcastserver.HTTPHeaderRequestedSequenceNumber: []string{strconv.FormatUint(uint64(nextSeqNum), 10)},
-	})
+	}
+	if config.EnableBinary {
+		httpHeader[wsbroadcastserver.HTTPHeaderFeedEncoding] = []string{m.EncodingRLP}
+	}
+	if config.AuthToken != "" {
+		httpHeader[wsbroadcastserver.HTTPHeaderAuthorization] = []string{"Bearer " + config.AuthToken}
+	}
+	if bc.feedFilter != nil {
+		feedFilter, err := bc.feedFilter.Header()
+		if err != nil {
+			return nil, fmt.Errorf("error encoding feed filter: %w", err)
+		}
+		httpHeader[wsbroadcastserver.HTTPHeaderFeedFilter] = []string{feedFilter}
+	}
+	header := ws.HandshakeHeaderHTTP(httpHeader)
 
 	log.Info("connecting to arbitrum inbox message broadcaster", "url", bc.websocketUrl)
 	var foundChainId bool
 	var foundFeedServerVersion bool
 	var chainId uint64
 	var feedServerVersion uint64
+	// servers not sending the encoding header send json
+	encoding := m.EncodingJSON
 
-	config := bc.config()
 	var extensions []httphead.Option
 	deflateExt := wsflate.DefaultParameters.Option()
 	if config.EnableCompression {
@@ -273,6 +332,8 @@ func (bc *BroadcastClient) connect(ctx context.Context, nextSeqNum arbutil.Messa
 					)
 					return ErrIncorrectChainId
 				}
+			} else if headerName == wsbroadcastserver.HTTPHeaderFeedEncoding {
+				encoding = headerValue
 			}
 			return nil
 		},
@@ -323,8 +384,9 @@ func (bc *BroadcastClient) connect(ctx context.Context, nextSeqNum arbutil.Messa
 
 	bc.connMutex.Lock()
 	bc.conn = conn
+	bc.encoding = encoding
 	bc.connMutex.Unlock()
-	log.Info("Feed connected", "feedServerVersion", feedServerVersion, "chainId", chainId, "requestedSeqNum", nextSeqNum)
+	log.Info("Feed connected", "feedServerVersion", feedServerVersion, "chainId", chainId, "encoding", encoding, "requestedSeqNum", nextSeqNum)
 
 	return earlyFrameData, nil
 }
@@ -382,7 +444,12 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 
 			if msg != nil {
 				res := m.BroadcastMessage{}
-				err = json.Unmarshal(msg, &res)
+				// the frame type tells the encoding, rather than what was negotiated
+				if op == ws.OpBinary {
+					err = res.UnmarshalBinary(msg)
+				} else {
+					err = json.Unmarshal(msg, &res)
+				}
 				if err != nil {
 					log.Error("error unmarshalling message", "msg", msg, "err", err)
 					continue
@@ -403,6 +470,7 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 				}
 				if res.Version == 1 {
 					if len(res.Messages) > 0 {
+						verified := make([]*m.BroadcastFeedMessage, 0, len(res.Messages))
 						for _, message := range res.Messages {
 							if message == nil {
 								log.Warn("ignoring nil feed message")
@@ -410,6 +478,11 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 							}
 
 							err := bc.isValidSignature(ctx, message)
+							if errors.Is(err, contracts.ErrSignerRegistryUnavailable) {
+								// the message is requested again once reconnected, or read by the inbox reader
+								log.Error("feed signer registry unavailable, dropping feed message", "error", err, "sequence number", message.SequenceNumber)
+								continue
+							}
 							if err != nil {
 								log.Error("error validating feed signature", "error", err, "sequence number", message.SequenceNumber)
 								bc.fatalErrChan <- fmt.Errorf("error validating feed signature %v: %w", message.SequenceNumber, err)
@@ -417,9 +490,12 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 							}
 
 							bc.nextSeqNum = message.SequenceNumber + 1
+							verified = append(verified, message)
 						}
-						if err := bc.txStreamer.AddBroadcastMessages(res.Messages); err != nil {
-							log.Error("Error adding message from Sequencer Feed", "err", err)
+						if len(verified) > 0 {
+							if err := bc.txStreamer.AddBroadcastMessages(verified); err != nil {
+								log.Error("Error adding message from Sequencer Feed", "err", err)
+							}
 						}
 					}
 					if res.ConfirmedSequenceNumberMessage != nil && bc.confirmedSequenceNumberListener != nil {
@@ -435,6 +511,13 @@ func (bc *BroadcastClient) GetRetryCount() int64 {
 	return atomic.LoadInt64(&bc.retryCount)
 }
 
+// Encoding returns the feed encoding the server agreed to send on the current connection.
+func (bc *BroadcastClient) Encoding() string {
+	bc.connMutex.Lock()
+	defer bc.connMutex.Unlock()
+	return bc.encoding
+}
+
 func (bc *BroadcastClient) isShuttingDown() bool {
 	bc.connMutex.Lock()
 	defer bc.connMutex.Unlock()
@@ -492,5 +575,11 @@ func (bc *BroadcastClient) isValidSignature(ctx context.Context, message *m.Broa
 	if err != nil {
 		return fmt.Errorf("error getting message hash for sequence number %v: %w", message.SequenceNumber, err)
 	}
-	return bc.sigVerifier.VerifyHash(ctx, message.Signature, hash)
+	// signers are checked against their rotation windows at the time the message was sequenced,
+	// so that messages caught up on after a rotation are still accepted during the retired grace
+	at := time.Now()
+	if message.Message.Message != nil && message.Message.Message.Header != nil {
+		at = time.Unix(int64(message.Message.Message.Header.Timestamp), 0)
+	}
+	return bc.sigVerifier.VerifyHashAt(ctx, message.Signature, hash, at)
 }
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
@@ -23,6 +25,7 @@ import (
 	"github.com/yingdianRao/nitro/arbos/arbostypes"
 	"github.com/yingdianRao/nitro/arbutil"
 	"github.com/yingdianRao/nitro/broadcaster"
+	"github.com/yingdianRao/nitro/broadcaster/filter"
 	m "github.com/yingdianRao/nitro/broadcaster/message"
 	"github.com/yingdianRao/nitro/util/contracts"
 	"github.com/yingdianRao/nitro/util/signature"
@@ -208,7 +211,7 @@ func newTestBroadcastClient(config Config, listenerAddress net.Addr, chainId uin
 	} else {
 		config.Verify.AcceptSequencer = false
 	}
-	return NewBroadcastClient(func() *Config { return &config }, fmt.Sprintf("ws://127.0.0.1:%d/", port), chainId, currentMessageCount, txStreamer, confirmedSequenceNumberListener, feedErrChan, av, func(_ int32) {})
+	return NewBroadcastClient(func() *Config { return &config }, fmt.Sprintf("ws://127.0.0.1:%d/", port), chainId, currentMessageCount, txStreamer, confirmedSequenceNumberListener, feedErrChan, av, nil, func(_ int32) {})
 }
 
 func startMakeBroadcastClient(ctx context.Context, t *testing.T, clientConfig Config, addr net.Addr, index int, expectedCount int, chainId uint64, wg *sync.WaitGroup, sequencerAddr *common.Address) {
@@ -422,6 +425,337 @@ func TestBroadcastClientConfirmedMessage(t *testing.T) {
 
 	broadcastClient.StopAndWait()
 }
+func TestBroadcastClientBinaryEncoding(t *testing.T) {
+	t.Parallel()
+	testBroadcastClientEncoding(t, true, true, m.EncodingRLP)
+}
+
+func TestBroadcastClientBinaryEncodingNotEnabledOnServer(t *testing.T) {
+	t.Parallel()
+	testBroadcastClientEncoding(t, true, false, m.EncodingJSON)
+}
+
+func TestBroadcastClientBinaryEncodingNotRequested(t *testing.T) {
+	t.Parallel()
+	testBroadcastClientEncoding(t, false, true, m.EncodingJSON)
+}
+
+func testBroadcastClientEncoding(t *testing.T, clientBinary bool, serverBinary bool, expectedEncoding string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := wsbroadcastserver.DefaultTestBroadcasterConfig
+	config.EnableBinary = serverBinary
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	sequencerAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	dataSigner := signature.DataSignerFromPrivateKey(privateKey)
+
+	chainId := uint64(9742)
+	feedErrChan := make(chan error, 10)
+	b := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config }, chainId, feedErrChan, dataSigner)
+
+	Require(t, b.Initialize())
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	// the first message is sent from the backlog, the second one broadcast
+	Require(t, b.BroadcastSingle(arbostypes.TestMessageWithMetadataAndRequestId, 0))
+
+	clientConfig := DefaultTestConfig
+	clientConfig.EnableBinary = clientBinary
+	confirmedSequenceNumberListener := make(chan arbutil.MessageIndex, 10)
+	ts := NewDummyTransactionStreamer(chainId, &sequencerAddr)
+	broadcastClient, err := newTestBroadcastClient(
+		clientConfig,
+		b.ListenerAddr(),
+		chainId,
+		0,
+		ts,
+		confirmedSequenceNumberListener,
+		feedErrChan,
+		&sequencerAddr,
+	)
+	Require(t, err)
+	broadcastClient.Start(ctx)
+	defer broadcastClient.StopAndWait()
+
+	for i := 0; i < 2; i++ {
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case err := <-feedErrChan:
+			t.Fatal("feed error", err)
+		case receivedMsg := <-ts.messageReceiver:
+			if receivedMsg.SequenceNumber != arbutil.MessageIndex(i) {
+				t.Fatal("received sequence number", receivedMsg.SequenceNumber, "expected", i)
+			}
+			expectedHash, err := (&m.BroadcastFeedMessage{Message: arbostypes.TestMessageWithMetadataAndRequestId, SequenceNumber: receivedMsg.SequenceNumber}).Hash(chainId)
+			Require(t, err)
+			receivedHash, err := receivedMsg.Hash(chainId)
+			Require(t, err)
+			if receivedHash != expectedHash {
+				t.Fatal("received message", i, "differs from the broadcast one")
+			}
+		case <-timer.C:
+			t.Fatal("client did not receive message", i)
+		}
+		timer.Stop()
+		if i == 0 {
+			if encoding := broadcastClient.Encoding(); encoding != expectedEncoding {
+				t.Fatal("feed encoding", encoding, "expected", expectedEncoding)
+			}
+			Require(t, b.BroadcastSingle(arbostypes.TestMessageWithMetadataAndRequestId, 1))
+		}
+	}
+
+	b.Confirm(1)
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	select {
+	case confirmed := <-confirmedSequenceNumberListener:
+		if confirmed != 1 {
+			t.Fatal("confirmed", confirmed, "expected 1")
+		}
+	case <-timer.C:
+		t.Fatal("client did not receive confirm message")
+	}
+}
+
+func testMessageOfKind(kind uint8) arbostypes.MessageWithMetadata {
+	header := *arbostypes.TestIncomingMessageWithRequestId.Header
+	header.Kind = kind
+	return arbostypes.MessageWithMetadata{
+		Message: &arbostypes.L1IncomingMessage{Header: &header},
+	}
+}
+
+func TestBroadcastClientFilter(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := wsbroadcastserver.DefaultTestBroadcasterConfig
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	sequencerAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	dataSigner := signature.DataSignerFromPrivateKey(privateKey)
+
+	chainId := uint64(9742)
+	feedErrChan := make(chan error, 10)
+	b := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config }, chainId, feedErrChan, dataSigner)
+
+	Require(t, b.Initialize())
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	// messages are filtered both in the backlog and once broadcast
+	filtered := testMessageOfKind(arbostypes.L1MessageType_Invalid)
+	kept := testMessageOfKind(arbostypes.L1MessageType_EndOfBlock)
+	Require(t, b.BroadcastSingle(filtered, 0))
+	Require(t, b.BroadcastSingle(kept, 1))
+
+	confirmedSequenceNumberListener := make(chan arbutil.MessageIndex, 10)
+	ts := NewDummyTransactionStreamer(chainId, &sequencerAddr)
+	broadcastClient, err := newTestBroadcastClient(
+		DefaultTestConfig,
+		b.ListenerAddr(),
+		chainId,
+		0,
+		ts,
+		confirmedSequenceNumberListener,
+		feedErrChan,
+		&sequencerAddr,
+	)
+	Require(t, err)
+	Require(t, broadcastClient.SetFilter(&filter.Filter{Kinds: []int{arbostypes.L1MessageType_EndOfBlock}}))
+	broadcastClient.Start(ctx)
+	defer broadcastClient.StopAndWait()
+
+	for _, expected := range []arbutil.MessageIndex{1, 3} {
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case err := <-feedErrChan:
+			t.Fatal("feed error", err)
+		case receivedMsg := <-ts.messageReceiver:
+			if receivedMsg.SequenceNumber != expected {
+				t.Fatal("received sequence number", receivedMsg.SequenceNumber, "expected", expected)
+			}
+		case <-timer.C:
+			t.Fatal("client did not receive message", expected)
+		}
+		timer.Stop()
+		if expected == 1 {
+			Require(t, b.BroadcastSingle(filtered, 2))
+			Require(t, b.BroadcastSingle(kept, 3))
+		}
+	}
+
+	// confirmations are sent even along with messages filtered out
+	Require(t, b.BroadcastSingle(filtered, 4))
+	b.Confirm(4)
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	select {
+	case receivedMsg := <-ts.messageReceiver:
+		t.Fatal("received filtered message", receivedMsg.SequenceNumber)
+	case confirmed := <-confirmedSequenceNumberListener:
+		if confirmed != 4 {
+			t.Fatal("confirmed", confirmed, "expected 4")
+		}
+	case <-timer.C:
+		t.Fatal("client did not receive confirm message")
+	}
+}
+
+func TestBroadcastClientFilterBatch(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := wsbroadcastserver.DefaultTestBroadcasterConfig
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	sequencerAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	dataSigner := signature.DataSignerFromPrivateKey(privateKey)
+
+	chainId := uint64(9742)
+	feedErrChan := make(chan error, 10)
+	b := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config }, chainId, feedErrChan, dataSigner)
+
+	Require(t, b.Initialize())
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	ts := NewDummyTransactionStreamer(chainId, &sequencerAddr)
+	broadcastClient, err := newTestBroadcastClient(DefaultTestConfig, b.ListenerAddr(), chainId, 0, ts, nil, feedErrChan, &sequencerAddr)
+	Require(t, err)
+	Require(t, broadcastClient.SetFilter(&filter.Filter{Kinds: []int{arbostypes.L1MessageType_EndOfBlock}}))
+	broadcastClient.Start(ctx)
+	defer broadcastClient.StopAndWait()
+
+	for b.ClientCount() == 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// a batch mixing messages the filter matches with others only delivers the matching ones
+	filtered := testMessageOfKind(arbostypes.L1MessageType_Invalid)
+	kept := testMessageOfKind(arbostypes.L1MessageType_EndOfBlock)
+	Require(t, b.BroadcastMessages([]arbostypes.MessageWithMetadata{filtered, kept, filtered, kept, filtered}, 1))
+	Require(t, b.BroadcastMessages([]arbostypes.MessageWithMetadata{kept}, 6))
+
+	for _, expected := range []arbutil.MessageIndex{2, 4, 6} {
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case err := <-feedErrChan:
+			t.Fatal("feed error", err)
+		case receivedMsg := <-ts.messageReceiver:
+			if receivedMsg.SequenceNumber != expected {
+				t.Fatal("received sequence number", receivedMsg.SequenceNumber, "expected", expected)
+			}
+		case <-timer.C:
+			t.Fatal("client did not receive message", expected)
+		}
+		timer.Stop()
+	}
+}
+
+func TestBroadcastClientFilterNotEnabledOnServer(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := wsbroadcastserver.DefaultTestBroadcasterConfig
+	config.EnableFilters = false
+
+	chainId := uint64(9742)
+	feedErrChan := make(chan error, 10)
+	b := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config }, chainId, feedErrChan, nil)
+	Require(t, b.Initialize())
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	ts := NewDummyTransactionStreamer(chainId, nil)
+	broadcastClient, err := newTestBroadcastClient(DefaultTestConfig, b.ListenerAddr(), chainId, 0, ts, nil, feedErrChan, nil)
+	Require(t, err)
+	Require(t, broadcastClient.SetFilter(&filter.Filter{ConfirmationsOnly: true}))
+	// the server refuses to send the whole feed to a client asking for part of it
+	if _, err := broadcastClient.connect(ctx, 0); err == nil {
+		t.Fatal("connected with a filter the server doesn't support")
+	}
+}
+
+func TestBroadcastClientAuthentication(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	Require(t, os.WriteFile(keysFile, []byte(`{
+		"tiers": [{"name": "partner", "maxConnections": 1, "maxCatchup": 1}],
+		"keys": [{"name": "acme", "key": "acme-key", "tier": "partner"}]
+	}`), 0600))
+	config := wsbroadcastserver.DefaultTestBroadcasterConfig
+	config.Auth = wsbroadcastserver.AuthConfig{Enable: true, Require: true, KeysFile: keysFile}
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	sequencerAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	dataSigner := signature.DataSignerFromPrivateKey(privateKey)
+
+	chainId := uint64(9742)
+	feedErrChan := make(chan error, 10)
+	b := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config }, chainId, feedErrChan, dataSigner)
+	Require(t, b.Initialize())
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	for i := 0; i < 3; i++ {
+		Require(t, b.BroadcastSingle(arbostypes.TestMessageWithMetadataAndRequestId, arbutil.MessageIndex(i)))
+	}
+
+	// the server only lets clients with a key in
+	ts := NewDummyTransactionStreamer(chainId, &sequencerAddr)
+	anonymousClient, err := newTestBroadcastClient(DefaultTestConfig, b.ListenerAddr(), chainId, 0, ts, nil, feedErrChan, &sequencerAddr)
+	Require(t, err)
+	if _, err := anonymousClient.connect(ctx, 0); err == nil {
+		t.Fatal("connected without authenticating")
+	}
+
+	// the key's tier only gets the latest message of the backlog
+	clientConfig := DefaultTestConfig
+	clientConfig.AuthToken = "acme-key"
+	broadcastClient, err := newTestBroadcastClient(clientConfig, b.ListenerAddr(), chainId, 0, ts, nil, feedErrChan, &sequencerAddr)
+	Require(t, err)
+	broadcastClient.Start(ctx)
+	defer broadcastClient.StopAndWait()
+
+	for _, expected := range []arbutil.MessageIndex{2, 3} {
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case err := <-feedErrChan:
+			t.Fatal("feed error", err)
+		case receivedMsg := <-ts.messageReceiver:
+			if receivedMsg.SequenceNumber != expected {
+				t.Fatal("received sequence number", receivedMsg.SequenceNumber, "expected", expected)
+			}
+		case <-timer.C:
+			t.Fatal("client did not receive message", expected)
+		}
+		timer.Stop()
+		if expected == 2 {
+			Require(t, b.BroadcastSingle(arbostypes.TestMessageWithMetadataAndRequestId, 3))
+		}
+	}
+}
+
 func TestServerIncorrectChainId(t *testing.T) {
 	t.Parallel()
 	ctx, cancel := context.WithCancel(context.Background())
@@ -48,6 +48,10 @@ type BroadcastClients struct {
 	primaryRouter   *Router
 	secondaryRouter *Router
 
+	// merger is set when all feeds are consumed at once, see broadcastclient.Config.Merge
+	merger         *merger
+	mergedMessages chan feedMessage
+
 	// Use atomic access
 	connected int32
 }
@@ -60,6 +64,7 @@ func NewBroadcastClients(
 	confirmedSequenceNumberListener chan arbutil.MessageIndex,
 	fatalErrChan chan error,
 	addrVerifier contracts.AddressVerifierInterface,
+	signerRegistry contracts.SignerRegistryInterface,
 ) (*BroadcastClients, error) {
 	config := configFetcher()
 	if len(config.URL) == 0 && len(config.SecondaryURL) == 0 {
@@ -80,19 +85,31 @@ func NewBroadcastClients(
 		secondaryClients: make([]*broadcastclient.BroadcastClient, 0, len(config.SecondaryURL)),
 		secondaryURL:     config.SecondaryURL,
 	}
-	clients.makeClient = func(url string, router *Router) (*broadcastclient.BroadcastClient, error) {
+	newClient := func(url string, txStreamer broadcastclient.TransactionStreamerInterface, confirmations chan arbutil.MessageIndex) (*broadcastclient.BroadcastClient, error) {
 		return broadcastclient.NewBroadcastClient(
 			configFetcher,
 			url,
 			l2ChainId,
 			currentMessageCount,
-			router,
-			router.confirmedSequenceNumberChan,
+			txStreamer,
+			confirmations,
 			fatalErrChan,
 			addrVerifier,
+			signerRegistry,
 			func(delta int32) { clients.adjustCount(delta) },
 		)
 	}
+	clients.makeClient = func(url string, router *Router) (*broadcastclient.BroadcastClient, error) {
+		return newClient(url, router, router.confirmedSequenceNumberChan)
+	}
+
+	if config.Merge {
+		if err := clients.makeMergedClients(config, l2ChainId, newClient); len(clients.primaryClients) == 0 {
+			log.Error("no connected feed on startup", "err", err)
+			return nil, nil
+		}
+		return &clients, nil
+	}
 
 	var lastClientErr error
 	for _, address := range config.URL {
@@ -132,6 +149,10 @@ func clearAndResetTicker(timer *time.Ticker, interval time.Duration) {
 }
 
 func (bcs *BroadcastClients) Start(ctx context.Context) {
+	if bcs.merger != nil {
+		bcs.startMerged(ctx)
+		return
+	}
 	bcs.primaryRouter.StopWaiter.Start(ctx, bcs.primaryRouter)
 	bcs.secondaryRouter.StopWaiter.Start(ctx, bcs.secondaryRouter)
 
@@ -0,0 +1,217 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclients
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/broadcastclient"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+)
+
+var (
+	mergeDeliveredCounter = metrics.NewRegisteredCounter("arb/feed/merge/delivered", nil)
+	mergeCheckedCounter   = metrics.NewRegisteredCounter("arb/feed/merge/checked", nil)
+	mergeDivergentCounter = metrics.NewRegisteredCounter("arb/feed/merge/divergent", nil)
+	mergeUncheckedCounter = metrics.NewRegisteredCounter("arb/feed/merge/unchecked", nil)
+)
+
+// feedMessage is a message received from the feed at index feed of the merged feeds.
+type feedMessage struct {
+	feed int
+	msg  *m.BroadcastFeedMessage
+}
+
+// feedRouter tags the messages of one of the merged feeds with its index.
+type feedRouter struct {
+	feed     int
+	messages chan<- feedMessage
+}
+
+func (r *feedRouter) AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessage) error {
+	for _, received := range feedMessages {
+		msg := *received
+		r.messages <- feedMessage{feed: r.feed, msg: &msg}
+	}
+	return nil
+}
+
+type mergedMessage struct {
+	hash common.Hash
+	// feed is the index of the feed the message was delivered from
+	feed int
+	// copies is the number of copies of the message the other feeds sent
+	copies int
+}
+
+// merger delivers each sequence number from the first feed sending it,
+// and checks the copies sent by the other feeds against it.
+// Like the msgHandler of unmerged feeds, it only remembers the recent sequence numbers, older ones
+// are delivered again and left to the transaction streamer, as they may fill a gap or follow a reorg.
+type merger struct {
+	chainId uint64
+	urls    []string
+
+	recentNew map[arbutil.MessageIndex]*mergedMessage
+	recentOld map[arbutil.MessageIndex]*mergedMessage
+
+	firstCounters     []metrics.Counter
+	divergentCounters []metrics.Counter
+}
+
+func newMerger(chainId uint64, urls []string) *merger {
+	mg := &merger{
+		chainId:   chainId,
+		urls:      urls,
+		recentNew: make(map[arbutil.MessageIndex]*mergedMessage, RECENT_FEED_INITIAL_MAP_SIZE),
+		recentOld: make(map[arbutil.MessageIndex]*mergedMessage, RECENT_FEED_INITIAL_MAP_SIZE),
+	}
+	// feeds are told apart by their position in the configuration, urls don't make metric names
+	for i := range urls {
+		prefix := "arb/feed/merge/feed/" + strconv.Itoa(i)
+		mg.firstCounters = append(mg.firstCounters, metrics.GetOrRegisterCounter(prefix+"/first", nil))
+		mg.divergentCounters = append(mg.divergentCounters, metrics.GetOrRegisterCounter(prefix+"/divergent", nil))
+	}
+	return mg
+}
+
+// mergeResult is what the merger made of a feed message.
+type mergeResult int
+
+const (
+	// mergeDelivered messages are the first copy of their sequence number, they're delivered
+	mergeDelivered mergeResult = iota
+	// mergeChecked messages match the delivered copy
+	mergeChecked
+	// mergeDivergent messages don't match the delivered copy
+	mergeDivergent
+	// mergeSkipped messages couldn't be hashed
+	mergeSkipped
+)
+
+// handle merges msg, received from the feed at index feed.
+func (mg *merger) handle(feed int, msg *m.BroadcastFeedMessage) mergeResult {
+	hash, err := msg.Hash(mg.chainId)
+	if err != nil {
+		log.Warn("failed to hash feed message", "url", mg.urls[feed], "sequenceNumber", msg.SequenceNumber, "err", err)
+		return mergeSkipped
+	}
+	delivered, ok := mg.recentNew[msg.SequenceNumber]
+	if !ok {
+		delivered, ok = mg.recentOld[msg.SequenceNumber]
+	}
+	if ok {
+		delivered.copies++
+		if hash == delivered.hash {
+			mergeCheckedCounter.Inc(1)
+			return mergeChecked
+		}
+		mergeDivergentCounter.Inc(1)
+		mg.divergentCounters[feed].Inc(1)
+		log.Error(
+			"feeds diverged",
+			"sequenceNumber", msg.SequenceNumber,
+			"deliveredUrl", mg.urls[delivered.feed],
+			"deliveredHash", delivered.hash,
+			"url", mg.urls[feed],
+			"hash", hash,
+		)
+		return mergeDivergent
+	}
+	mg.recentNew[msg.SequenceNumber] = &mergedMessage{hash: hash, feed: feed}
+	mergeDeliveredCounter.Inc(1)
+	mg.firstCounters[feed].Inc(1)
+	return mergeDelivered
+}
+
+// rotate forgets the messages delivered before the previous rotation.
+func (mg *merger) rotate() {
+	if len(mg.urls) > 1 {
+		for _, delivered := range mg.recentOld {
+			if delivered.copies == 0 {
+				mergeUncheckedCounter.Inc(1)
+			}
+		}
+	}
+	mg.recentOld = mg.recentNew
+	mg.recentNew = make(map[arbutil.MessageIndex]*mergedMessage, RECENT_FEED_INITIAL_MAP_SIZE)
+}
+
+func (bcs *BroadcastClients) startMerged(ctx context.Context) {
+	bcs.primaryRouter.StopWaiter.Start(ctx, bcs.primaryRouter)
+	for _, client := range bcs.primaryClients {
+		client.Start(ctx)
+	}
+
+	bcs.primaryRouter.LaunchThread(func(ctx context.Context) {
+		recentFeedItemsCleanup := time.NewTicker(RECENT_FEED_ITEM_TTL)
+		defer recentFeedItemsCleanup.Stop()
+
+		var lastConfirmed arbutil.MessageIndex
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-recentFeedItemsCleanup.C:
+				bcs.merger.rotate()
+			case fm := <-bcs.mergedMessages:
+				if bcs.merger.handle(fm.feed, fm.msg) != mergeDelivered {
+					continue
+				}
+				if err := bcs.primaryRouter.forwardTxStreamer.AddBroadcastMessages([]*m.BroadcastFeedMessage{fm.msg}); err != nil {
+					log.Error("Error routing message from merged Sequencer Feeds", "err", err)
+				}
+			case cs := <-bcs.primaryRouter.confirmedSequenceNumberChan:
+				// a lagging feed doesn't take confirmations back
+				if cs <= lastConfirmed {
+					continue
+				}
+				lastConfirmed = cs
+				if bcs.primaryRouter.forwardConfirmationChan != nil {
+					bcs.primaryRouter.forwardConfirmationChan <- cs
+				}
+			}
+		}
+	})
+}
+
+// makeMergedClients creates a client for every primary and secondary feed, routing their messages to the merger.
+func (bcs *BroadcastClients) makeMergedClients(
+	config *broadcastclient.Config,
+	chainId uint64,
+	newClient func(string, broadcastclient.TransactionStreamerInterface, chan arbutil.MessageIndex) (*broadcastclient.BroadcastClient, error),
+) error {
+	var urls []string
+	for _, url := range append(append([]string{}, config.URL...), config.SecondaryURL...) {
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) < 2 {
+		log.Warn("merging sequencer feeds needs several feeds to cross-check them", "feeds", len(urls))
+	}
+	bcs.mergedMessages = make(chan feedMessage, ROUTER_QUEUE_SIZE)
+	var lastClientErr error
+	var clientUrls []string
+	for _, url := range urls {
+		router := &feedRouter{feed: len(clientUrls), messages: bcs.mergedMessages}
+		client, err := newClient(url, router, bcs.primaryRouter.confirmedSequenceNumberChan)
+		if err != nil {
+			lastClientErr = err
+			log.Warn("init broadcast client failed", "address", url)
+			continue
+		}
+		bcs.primaryClients = append(bcs.primaryClients, client)
+		clientUrls = append(clientUrls, url)
+	}
+	bcs.merger = newMerger(chainId, clientUrls)
+	return lastClientErr
+}
@@ -0,0 +1,65 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package broadcastclients
+
+import (
+	"testing"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+)
+
+func testFeedMessage(seqNum arbutil.MessageIndex, delayedMessagesRead uint64) *m.BroadcastFeedMessage {
+	return &m.BroadcastFeedMessage{
+		SequenceNumber: seqNum,
+		Message: arbostypes.MessageWithMetadata{
+			Message:             &arbostypes.TestIncomingMessageWithRequestId,
+			DelayedMessagesRead: delayedMessagesRead,
+		},
+	}
+}
+
+func TestMerger(t *testing.T) {
+	mg := newMerger(412346, []string{"ws://a", "ws://b", "ws://c"})
+
+	expectResult := func(feed int, msg *m.BroadcastFeedMessage, expected mergeResult) {
+		t.Helper()
+		if result := mg.handle(feed, msg); result != expected {
+			t.Fatalf("message %v from feed %v: got result %v, expected %v", msg.SequenceNumber, feed, result, expected)
+		}
+	}
+
+	// each message is delivered from the first feed sending it, and checked against the other copies
+	expectResult(0, testFeedMessage(1, 0), mergeDelivered)
+	expectResult(1, testFeedMessage(1, 0), mergeChecked)
+	expectResult(1, testFeedMessage(2, 0), mergeDelivered)
+	expectResult(0, testFeedMessage(2, 0), mergeChecked)
+	expectResult(2, testFeedMessage(1, 1), mergeDivergent)
+	expectResult(2, testFeedMessage(2, 0), mergeChecked)
+
+	// copies are checked until the second rotation, then delivered again for the transaction streamer to handle
+	mg.rotate()
+	expectResult(2, testFeedMessage(3, 0), mergeDelivered)
+	mg.rotate()
+	expectResult(0, testFeedMessage(3, 1), mergeDivergent)
+	mg.rotate()
+	expectResult(1, testFeedMessage(2, 0), mergeDelivered)
+	expectResult(1, testFeedMessage(3, 0), mergeDelivered)
+
+	// sequence numbers before the latest one delivered fill gaps in the feed
+	expectResult(0, testFeedMessage(6, 0), mergeDelivered)
+	expectResult(0, testFeedMessage(5, 0), mergeDelivered)
+	expectResult(1, testFeedMessage(5, 0), mergeChecked)
+
+	expectResult(1, testFeedMessage(4, 0), mergeDelivered)
+	if delivered := mg.recentNew[4]; delivered == nil || delivered.feed != 1 || delivered.copies != 0 {
+		t.Fatalf("unexpected delivered message %+v", delivered)
+	}
+	expectResult(0, testFeedMessage(4, 0), mergeChecked)
+	expectResult(2, testFeedMessage(4, 0), mergeChecked)
+	if copies := mg.recentNew[4].copies; copies != 2 {
+		t.Fatal("expected 2 copies of the delivered message, got", copies)
+	}
+}
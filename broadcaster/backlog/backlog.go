@@ -26,6 +26,7 @@ var (
 // Backlog defines the interface for backlog.
 type Backlog interface {
 	Head() BacklogSegment
+	Tail() BacklogSegment
 	Append(*m.BroadcastMessage) error
 	Get(uint64, uint64) (*m.BroadcastMessage, error)
 	Count() uint64
@@ -56,6 +57,11 @@ func (b *backlog) Head() BacklogSegment {
 	return b.head.Load()
 }
 
+// Tail return the tail backlogSegment within the backlog, which holds the latest messages.
+func (b *backlog) Tail() BacklogSegment {
+	return b.tail.Load()
+}
+
 func (b *backlog) backlogSizeInBytes() (uint64, error) {
 	headSeg := b.head.Load()
 	tailSeg := b.tail.Load()
@@ -321,10 +327,13 @@ func newBacklogSegment() *backlogSegment {
 // variable of type BacklogSegment is nil or not. Comparing whether an
 // interface is nil directly will not work.
 func IsBacklogSegmentNil(segment BacklogSegment) bool {
-	if segment == nil {
-		return true
-	} else if segment.(*backlogSegment) == nil {
+	switch s := segment.(type) {
+	case nil:
 		return true
+	case *backlogSegment:
+		return s == nil
+	case *diskSegment:
+		return s == nil
 	}
 	return false
 }
@@ -1,24 +1,64 @@
 package backlog
 
 import (
+	"errors"
+
 	flag "github.com/spf13/pflag"
 )
 
 type ConfigFetcher func() *Config
 
 type Config struct {
-	SegmentLimit int `koanf:"segment-limit" reload:"hot"`
+	SegmentLimit int        `koanf:"segment-limit" reload:"hot"`
+	Disk         DiskConfig `koanf:"disk" reload:"hot"`
+}
+
+func (c *Config) Validate() error {
+	return c.Disk.Validate()
 }
 
 func AddOptions(prefix string, f *flag.FlagSet) {
 	f.Int(prefix+".segment-limit", DefaultConfig.SegmentLimit, "the maximum number of messages each segment within the backlog can contain")
+	DiskConfigAddOptions(prefix+".disk", f)
+}
+
+type DiskConfig struct {
+	Enable    bool   `koanf:"enable"`
+	Dir       string `koanf:"dir"`
+	Retention uint64 `koanf:"retention" reload:"hot"`
+}
+
+func DiskConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultDiskConfig.Enable, "also store the backlog on disk, to serve catch-up from messages already confirmed and keep serving it across restarts")
+	f.String(prefix+".dir", DefaultDiskConfig.Dir, "directory the backlog is stored in")
+	f.Uint64(prefix+".retention", DefaultDiskConfig.Retention, "the number of most recent messages kept on disk")
+}
+
+func (c *DiskConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Dir == "" {
+		return errors.New("disk backlog enabled without a directory")
+	}
+	if c.Retention == 0 {
+		return errors.New("disk backlog retention must be positive")
+	}
+	return nil
 }
 
 var (
+	DefaultDiskConfig = DiskConfig{
+		Enable:    false,
+		Dir:       "",
+		Retention: 1_000_000,
+	}
 	DefaultConfig = Config{
 		SegmentLimit: 240,
+		Disk:         DefaultDiskConfig,
 	}
 	DefaultTestConfig = Config{
 		SegmentLimit: 3,
+		Disk:         DefaultDiskConfig,
 	}
 )
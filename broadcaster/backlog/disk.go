@@ -0,0 +1,364 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package backlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/containers"
+)
+
+var (
+	diskBacklogMessagePrefix = []byte("backlog-message-")
+	diskBacklogRangeKey      = []byte("backlog-range")
+
+	diskBacklogSizeGauge     = metrics.NewRegisteredGauge("arb/feed/backlog/disk/messages", nil)
+	diskBacklogErrorsCounter = metrics.NewRegisteredCounter("arb/feed/backlog/disk/errors", nil)
+)
+
+// diskBacklogRange holds the sequence numbers of the first message stored on disk, and of the one following the last.
+type diskBacklogRange struct {
+	First uint64
+	Next  uint64
+}
+
+func diskBacklogMessageKey(sequenceNumber uint64) []byte {
+	key := make([]byte, len(diskBacklogMessagePrefix)+8)
+	copy(key, diskBacklogMessagePrefix)
+	binary.BigEndian.PutUint64(key[len(diskBacklogMessagePrefix):], sequenceNumber)
+	return key
+}
+
+// DiskBacklog is a Backlog keeping the messages not yet confirmed in memory like the in-memory one,
+// and every message of its retention window on disk, to serve catch-up from older sequence numbers
+// and keep serving it across restarts. It behaves as the in-memory backlog until opened.
+type DiskBacklog struct {
+	memory *backlog
+	config ConfigFetcher
+	db     ethdb.Database
+
+	// the messages stored on disk are the ones from first to next excluded
+	first atomic.Uint64
+	next  atomic.Uint64
+}
+
+// NewDiskBacklog creates a DiskBacklog, its messages are stored in the directory configured once it's opened.
+func NewDiskBacklog(c ConfigFetcher) *DiskBacklog {
+	memory := &backlog{
+		config: c,
+	}
+	memory.lookupByIndex.Store(&containers.SyncMap[uint64, *backlogSegment]{})
+	return &DiskBacklog{
+		memory: memory,
+		config: c,
+	}
+}
+
+// Open opens the database of the backlog, the messages stored by a previous run are served again.
+func (b *DiskBacklog) Open() error {
+	if b.db != nil {
+		return errors.New("disk backlog already opened")
+	}
+	db, err := rawdb.NewPebbleDBDatabase(b.config().Disk.Dir, 16, 16, "broadcaster/backlog/", false, false)
+	if err != nil {
+		return fmt.Errorf("error opening disk backlog: %w", err)
+	}
+	has, err := db.Has(diskBacklogRangeKey)
+	if err == nil && has {
+		var data []byte
+		data, err = db.Get(diskBacklogRangeKey)
+		if err == nil {
+			var stored diskBacklogRange
+			err = rlp.DecodeBytes(data, &stored)
+			b.first.Store(stored.First)
+			b.next.Store(stored.Next)
+		}
+	}
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("error reading disk backlog range: %w", err)
+	}
+	b.db = db
+	diskBacklogSizeGauge.Update(int64(b.diskCount()))
+	if b.diskCount() > 0 {
+		log.Info("opened disk backlog", "first", b.first.Load(), "last", b.next.Load()-1)
+	}
+	return nil
+}
+
+// Close closes the database of the backlog.
+func (b *DiskBacklog) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Close()
+}
+
+func (b *DiskBacklog) diskCount() uint64 {
+	first, next := b.first.Load(), b.next.Load()
+	if next < first {
+		return 0
+	}
+	return next - first
+}
+
+// Head returns the oldest segment of the backlog, which is read from disk if it has older messages than the memory.
+func (b *DiskBacklog) Head() BacklogSegment {
+	first := b.first.Load()
+	if b.fromMemory(first) {
+		return b.memory.Head()
+	}
+	segment, err := b.readSegment(first)
+	if err != nil {
+		logWarnDiskBacklog(err, "error reading the head of the disk backlog")
+		return b.memory.Head()
+	}
+	return segment
+}
+
+// Tail returns the newest segment of the backlog, which is read from disk if the memory has no messages.
+func (b *DiskBacklog) Tail() BacklogSegment {
+	tail := b.memory.Tail()
+	if !IsBacklogSegmentNil(tail) || b.db == nil || b.diskCount() == 0 {
+		return tail
+	}
+	start := b.first.Load()
+	if limit := uint64(b.config().SegmentLimit); b.next.Load()-start > limit {
+		start = b.next.Load() - limit
+	}
+	segment, err := b.readSegment(start)
+	if err != nil {
+		logWarnDiskBacklog(err, "error reading the tail of the disk backlog")
+		return nil
+	}
+	return segment
+}
+
+// Append stores the messages on disk, then adds them to the in-memory backlog.
+// Failing to store them is logged rather than returned, so that clients still get the messages.
+func (b *DiskBacklog) Append(bm *m.BroadcastMessage) error {
+	if b.db != nil && len(bm.Messages) > 0 {
+		if err := b.store(bm.Messages); err != nil {
+			logWarnDiskBacklog(err, "error storing messages in disk backlog")
+		}
+	}
+	return b.memory.Append(bm)
+}
+
+// fromMemory returns whether message i is served from memory: if it's in memory, or if the disk has no older message.
+func (b *DiskBacklog) fromMemory(i uint64) bool {
+	if b.db == nil || b.diskCount() == 0 {
+		return true
+	}
+	head := b.memory.Head()
+	return !IsBacklogSegmentNil(head) && (i >= head.Start() || head.Start() <= b.first.Load())
+}
+
+// Get reads messages from the given start to end MessageIndex, from disk if they are older than the memory's.
+func (b *DiskBacklog) Get(start, end uint64) (*m.BroadcastMessage, error) {
+	if b.fromMemory(start) {
+		return b.memory.Get(start, end)
+	}
+	if first := b.first.Load(); start < first {
+		// like the in-memory backlog, start from the oldest message kept
+		start = first
+	}
+	msgs, err := b.read(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &m.BroadcastMessage{Version: m.V1, Messages: msgs}, nil
+}
+
+// Count returns the number of messages stored within the in-memory backlog.
+func (b *DiskBacklog) Count() uint64 {
+	return b.memory.Count()
+}
+
+// Lookup returns the segment storing the given message index, read from disk if it is older than the memory's.
+func (b *DiskBacklog) Lookup(i uint64) (BacklogSegment, error) {
+	if b.fromMemory(i) {
+		return b.memory.Lookup(i)
+	}
+	return b.readSegment(i)
+}
+
+// readSegment reads the segment starting with message i from disk. It ends before the in-memory messages,
+// so that the segment following it starts with them.
+func (b *DiskBacklog) readSegment(i uint64) (*diskSegment, error) {
+	end := i + uint64(b.config().SegmentLimit) - 1
+	if next := b.next.Load(); end >= next {
+		end = next - 1
+	}
+	if head := b.memory.Head(); !IsBacklogSegmentNil(head) && head.Start() > i && end >= head.Start() {
+		end = head.Start() - 1
+	}
+	msgs, err := b.read(i, end)
+	if err != nil {
+		return nil, err
+	}
+	return &diskSegment{backlog: b, messages: msgs}, nil
+}
+
+// read reads the messages from start to end from disk.
+func (b *DiskBacklog) read(start, end uint64) ([]*m.BroadcastFeedMessage, error) {
+	if start > end || start < b.first.Load() || end >= b.next.Load() {
+		return nil, errOutOfBounds
+	}
+	msgs := make([]*m.BroadcastFeedMessage, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		data, err := b.db.Get(diskBacklogMessageKey(i))
+		if err != nil {
+			// the message may have just been pruned
+			return nil, fmt.Errorf("error reading message %d from disk backlog: %w", i, err)
+		}
+		var msg m.BroadcastFeedMessage
+		if err := rlp.DecodeBytes(data, &msg); err != nil {
+			return nil, fmt.Errorf("error decoding message %d from disk backlog: %w", i, err)
+		}
+		msgs = append(msgs, &msg)
+	}
+	return msgs, nil
+}
+
+// store writes the messages following the last stored one to disk, and prunes the messages outside the retention window.
+// Like the in-memory backlog, it ignores messages already seen, and drops the older messages if new ones leave a gap.
+func (b *DiskBacklog) store(msgs []*m.BroadcastFeedMessage) error {
+	first, next := b.first.Load(), b.next.Load()
+	batch := b.db.NewBatch()
+	for _, msg := range msgs {
+		sequenceNumber := uint64(msg.SequenceNumber)
+		if first < next && sequenceNumber < next {
+			continue
+		}
+		if first == next || sequenceNumber > next {
+			if first < next {
+				log.Warn("new message sequence number leaves a gap in the disk backlog, dropping older messages", "sequenceNumber", sequenceNumber, "expected", next)
+				// stop serving the dropped messages before deleting them
+				b.first.Store(next)
+				if err := b.deleteMessages(batch, first, next); err != nil {
+					return err
+				}
+			}
+			first, next = sequenceNumber, sequenceNumber
+		}
+		data, err := rlp.EncodeToBytes(msg)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(diskBacklogMessageKey(sequenceNumber), data); err != nil {
+			return err
+		}
+		next = sequenceNumber + 1
+	}
+
+	if retention := b.config().Disk.Retention; next-first > retention {
+		pruneTo := next - retention
+		b.first.Store(pruneTo)
+		if err := b.deleteMessages(batch, first, pruneTo); err != nil {
+			return err
+		}
+		first = pruneTo
+	}
+
+	data, err := rlp.EncodeToBytes(&diskBacklogRange{First: first, Next: next})
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(diskBacklogRangeKey, data); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	b.first.Store(first)
+	b.next.Store(next)
+	diskBacklogSizeGauge.Update(int64(b.diskCount()))
+	return nil
+}
+
+// deleteMessages deletes the messages from start to end excluded, writing the batch whenever it grows too large.
+func (b *DiskBacklog) deleteMessages(batch ethdb.Batch, start, end uint64) error {
+	for i := start; i < end; i++ {
+		if err := batch.Delete(diskBacklogMessageKey(i)); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return nil
+}
+
+func logWarnDiskBacklog(err error, msg string) {
+	diskBacklogErrorsCounter.Inc(1)
+	log.Warn(msg, "err", err)
+}
+
+// diskSegment is a BacklogSegment of messages read from disk, it isn't modified once read.
+type diskSegment struct {
+	backlog  *DiskBacklog
+	messages []*m.BroadcastFeedMessage
+}
+
+// Start returns the first message index within the diskSegment.
+func (s *diskSegment) Start() uint64 {
+	return uint64(s.messages[0].SequenceNumber)
+}
+
+// End returns the last message index within the diskSegment.
+func (s *diskSegment) End() uint64 {
+	return uint64(s.messages[len(s.messages)-1].SequenceNumber)
+}
+
+// Next returns the segment following the diskSegment, which is the in-memory head once it is reached.
+func (s *diskSegment) Next() BacklogSegment {
+	next := s.End() + 1
+	if head := s.backlog.memory.Head(); !IsBacklogSegmentNil(head) && head.Start() == next {
+		return head
+	}
+	segment, err := s.backlog.readSegment(next)
+	if err != nil {
+		if next < s.backlog.next.Load() {
+			logWarnDiskBacklog(err, "error reading next disk backlog segment")
+		}
+		return nil
+	}
+	return segment
+}
+
+// Contains confirms whether the segment contains a message with the given sequence number.
+func (s *diskSegment) Contains(i uint64) bool {
+	return i >= s.Start() && i <= s.End()
+}
+
+// Messages returns all of the messages stored in the diskSegment.
+func (s *diskSegment) Messages() []*m.BroadcastFeedMessage {
+	tmp := make([]*m.BroadcastFeedMessage, len(s.messages))
+	copy(tmp, s.messages)
+	return tmp
+}
+
+// Get reads messages from the given start to end message index.
+func (s *diskSegment) Get(start, end uint64) ([]*m.BroadcastFeedMessage, error) {
+	if start < s.Start() || end > s.End() || start > end {
+		return []*m.BroadcastFeedMessage{}, errOutOfBounds
+	}
+	tmp := make([]*m.BroadcastFeedMessage, end-start+1)
+	copy(tmp, s.messages[start-s.Start():end-s.Start()+1])
+	return tmp, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package backlog
+
+import (
+	"testing"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+)
+
+func openTestDiskBacklog(t *testing.T, config *Config) *DiskBacklog {
+	t.Helper()
+	b := NewDiskBacklog(func() *Config { return config })
+	if err := b.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func appendTestMessages(t *testing.T, b Backlog, start, end arbutil.MessageIndex) {
+	t.Helper()
+	var indexes []arbutil.MessageIndex
+	for i := start; i <= end; i++ {
+		indexes = append(indexes, i)
+	}
+	if err := b.Append(m.CreateDummyBroadcastMessage(indexes)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// backlogSequenceNumbers walks the segments from the backlog's head.
+func backlogSequenceNumbers(b Backlog) []uint64 {
+	var seqNums []uint64
+	for segment := b.Head(); !IsBacklogSegmentNil(segment); segment = segment.Next() {
+		for _, msg := range segment.Messages() {
+			seqNums = append(seqNums, uint64(msg.SequenceNumber))
+		}
+	}
+	return seqNums
+}
+
+func expectSequenceNumbers(t *testing.T, actual []uint64, start, end uint64) {
+	t.Helper()
+	if len(actual) != int(end-start+1) {
+		t.Fatalf("expected messages %d to %d, got %v", start, end, actual)
+	}
+	for i, seqNum := range actual {
+		if seqNum != start+uint64(i) {
+			t.Fatalf("expected messages %d to %d, got %v", start, end, actual)
+		}
+	}
+}
+
+func TestDiskBacklog(t *testing.T) {
+	config := DefaultTestConfig
+	config.Disk = DiskConfig{Enable: true, Dir: t.TempDir(), Retention: 100}
+	b := openTestDiskBacklog(t, &config)
+
+	appendTestMessages(t, b, 0, 9)
+	confirm := &m.BroadcastMessage{ConfirmedSequenceNumberMessage: &m.ConfirmedSequenceNumberMessage{SequenceNumber: 7}}
+	if err := b.Append(confirm); err != nil {
+		t.Fatal(err)
+	}
+	if b.Count() != 2 {
+		t.Fatalf("expected 2 messages left in memory, got %d", b.Count())
+	}
+
+	// confirmed messages are still served, from disk, and followed by the ones in memory
+	expectSequenceNumbers(t, backlogSequenceNumbers(b), 0, 9)
+	segment, err := b.Lookup(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segment.Start() != 4 || !segment.Contains(6) {
+		t.Fatalf("unexpected segment from %d to %d looking up message 4", segment.Start(), segment.End())
+	}
+	bm, err := b.Get(2, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqNums []uint64
+	for _, msg := range bm.Messages {
+		seqNums = append(seqNums, uint64(msg.SequenceNumber))
+	}
+	expectSequenceNumbers(t, seqNums, 2, 9)
+
+	// the messages are served again after a restart
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b = openTestDiskBacklog(t, &config)
+	defer b.Close()
+	expectSequenceNumbers(t, backlogSequenceNumbers(b), 0, 9)
+	appendTestMessages(t, b, 8, 12)
+	expectSequenceNumbers(t, backlogSequenceNumbers(b), 0, 12)
+
+	// only the retention window is kept, on top of the messages in memory
+	config.Disk.Retention = 5
+	appendTestMessages(t, b, 13, 13)
+	if _, err := b.Lookup(5); err == nil {
+		t.Fatal("found pruned message")
+	}
+	expectSequenceNumbers(t, backlogSequenceNumbers(b), 8, 13)
+
+	// a gap drops the older messages, as they no longer lead to the new ones
+	appendTestMessages(t, b, 20, 21)
+	if _, err := b.Lookup(12); err == nil {
+		t.Fatal("found message from before the gap")
+	}
+	expectSequenceNumbers(t, backlogSequenceNumbers(b), 20, 21)
+}
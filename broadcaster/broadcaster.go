@@ -21,19 +21,29 @@ import (
 )
 
 type Broadcaster struct {
-	server     *wsbroadcastserver.WSBroadcastServer
-	backlog    backlog.Backlog
-	chainId    uint64
-	dataSigner signature.DataSignerFunc
+	server      *wsbroadcastserver.WSBroadcastServer
+	backlog     backlog.Backlog
+	diskBacklog *backlog.DiskBacklog
+	chainId     uint64
+	dataSigner  signature.DataSignerFunc
 }
 
 func NewBroadcaster(config wsbroadcastserver.BroadcasterConfigFetcher, chainId uint64, feedErrChan chan error, dataSigner signature.DataSignerFunc) *Broadcaster {
-	bklg := backlog.NewBacklog(func() *backlog.Config { return &config().Backlog })
+	backlogConfig := func() *backlog.Config { return &config().Backlog }
+	var bklg backlog.Backlog
+	var diskBacklog *backlog.DiskBacklog
+	if backlogConfig().Disk.Enable {
+		diskBacklog = backlog.NewDiskBacklog(backlogConfig)
+		bklg = diskBacklog
+	} else {
+		bklg = backlog.NewBacklog(backlogConfig)
+	}
 	return &Broadcaster{
-		server:     wsbroadcastserver.NewWSBroadcastServer(config, bklg, chainId, feedErrChan),
-		backlog:    bklg,
-		chainId:    chainId,
-		dataSigner: dataSigner,
+		server:      wsbroadcastserver.NewWSBroadcastServer(config, bklg, chainId, feedErrChan),
+		backlog:     bklg,
+		diskBacklog: diskBacklog,
+		chainId:     chainId,
+		dataSigner:  dataSigner,
 	}
 }
 
@@ -130,11 +140,20 @@ func (b *Broadcaster) ListenerAddr() net.Addr {
 	return b.server.ListenerAddr()
 }
 
+func (b *Broadcaster) HTTPListenerAddr() net.Addr {
+	return b.server.HTTPListenerAddr()
+}
+
 func (b *Broadcaster) GetCachedMessageCount() int {
 	return int(b.backlog.Count())
 }
 
 func (b *Broadcaster) Initialize() error {
+	if b.diskBacklog != nil {
+		if err := b.diskBacklog.Open(); err != nil {
+			return err
+		}
+	}
 	return b.server.Initialize()
 }
 
@@ -148,6 +167,11 @@ func (b *Broadcaster) StartWithHeader(ctx context.Context, header ws.HandshakeHe
 
 func (b *Broadcaster) StopAndWait() {
 	b.server.StopAndWait()
+	if b.diskBacklog != nil {
+		if err := b.diskBacklog.Close(); err != nil {
+			log.Warn("error closing disk backlog", "err", err)
+		}
+	}
 }
 
 func (b *Broadcaster) Started() bool {
@@ -4,12 +4,20 @@
 package broadcaster
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
 	"github.com/yingdianRao/nitro/util/testhelpers"
 	"github.com/yingdianRao/nitro/wsbroadcastserver"
 )
@@ -106,6 +114,146 @@ func TestBroadcasterMessagesRemovedOnConfirmation(t *testing.T) {
 		"clear all messages after confirmed 1 beyond latest"))
 }
 
+func TestBroadcasterHTTP(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	config := wsbroadcastserver.DefaultTestBroadcasterConfig
+	config.HTTP.Enable = true
+
+	feedErrChan := make(chan error, 10)
+	b := NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config }, 5555, feedErrChan, nil)
+	Require(t, b.Initialize())
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", b.HTTPListenerAddr().(*net.TCPAddr).Port)
+
+	for i := 1; i <= 5; i++ {
+		Require(t, b.BroadcastSingle(arbostypes.EmptyTestMessageWithMetadata, arbutil.MessageIndex(i)))
+	}
+	waitUntilUpdated(t, &messageCountPredicate{b, 5, "after 5 messages", 0})
+
+	getMessages := func(query string, expectedStatus int) (*m.BroadcastMessage, string) {
+		t.Helper()
+		resp, err := http.Get(baseURL + wsbroadcastserver.MessagesURI + "?" + query)
+		Require(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			Fail(t, "request", query, "got status", resp.StatusCode, "expected", expectedStatus)
+		}
+		if expectedStatus != http.StatusOK {
+			return nil, ""
+		}
+		var bm m.BroadcastMessage
+		Require(t, json.NewDecoder(resp.Body).Decode(&bm))
+		return &bm, resp.Header.Get(wsbroadcastserver.HTTPHeaderNextSequenceNumber)
+	}
+	expectSeqNums := func(bm *m.BroadcastMessage, expected ...arbutil.MessageIndex) {
+		t.Helper()
+		if len(bm.Messages) != len(expected) {
+			Fail(t, "got", len(bm.Messages), "messages, expected", expected)
+		}
+		for i, msg := range bm.Messages {
+			if msg.SequenceNumber != expected[i] {
+				Fail(t, "got message", msg.SequenceNumber, "expected", expected[i])
+			}
+		}
+	}
+
+	bm, next := getMessages("from=2&limit=2", http.StatusOK)
+	expectSeqNums(bm, 2, 3)
+	if next != "4" {
+		Fail(t, "next sequence number", next, "expected 4")
+	}
+	bm, next = getMessages("from=9", http.StatusOK)
+	expectSeqNums(bm)
+	if next != "9" {
+		Fail(t, "next sequence number", next, "expected 9")
+	}
+	getMessages("limit=2", http.StatusBadRequest)
+
+	// a catch-up request waiting for the next message, checked once it returned
+	type waitResult struct {
+		bm     *m.BroadcastMessage
+		status int
+		err    error
+	}
+	waited := make(chan waitResult, 1)
+	go func() {
+		var result waitResult
+		resp, err := http.Get(baseURL + wsbroadcastserver.MessagesURI + "?from=6&wait=2s")
+		if err != nil {
+			result.err = err
+		} else {
+			defer resp.Body.Close()
+			result.status = resp.StatusCode
+			result.bm = &m.BroadcastMessage{}
+			result.err = json.NewDecoder(resp.Body).Decode(result.bm)
+		}
+		waited <- result
+	}()
+
+	resp, err := http.Get(baseURL + wsbroadcastserver.StreamURI + "?from=4")
+	Require(t, err)
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		Fail(t, "unexpected stream content type", resp.Header.Get("Content-Type"))
+	}
+	events := bufio.NewReader(resp.Body)
+	nextEvent := func() (string, *m.BroadcastMessage) {
+		t.Helper()
+		var id string
+		var bm m.BroadcastMessage
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil && err != io.EOF {
+				Fail(t, "error reading stream", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" && err == nil {
+				return id, &bm
+			} else if err != nil {
+				Fail(t, "stream ended")
+			}
+			if value, ok := strings.CutPrefix(line, "id: "); ok {
+				id = value
+			} else if value, ok := strings.CutPrefix(line, "data: "); ok {
+				Require(t, json.Unmarshal([]byte(value), &bm))
+			}
+		}
+	}
+	id, bm := nextEvent()
+	expectSeqNums(bm, 4, 5)
+	if id != "5" {
+		Fail(t, "event id", id, "expected 5")
+	}
+	// the stream is registered, and counted with the websocket clients
+	for b.ClientCount() < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	Require(t, b.BroadcastSingle(arbostypes.EmptyTestMessageWithMetadata, 6))
+	result := <-waited
+	Require(t, result.err)
+	if result.status != http.StatusOK {
+		Fail(t, "waiting request got status", result.status)
+	}
+	expectSeqNums(result.bm, 6)
+	id, bm = nextEvent()
+	expectSeqNums(bm, 6)
+	if id != "6" {
+		Fail(t, "event id", id, "expected 6")
+	}
+
+	b.Confirm(5)
+	id, bm = nextEvent()
+	expectSeqNums(bm)
+	if id != "" || bm.ConfirmedSequenceNumberMessage == nil || bm.ConfirmedSequenceNumberMessage.SequenceNumber != 5 {
+		Fail(t, "expected a confirmation event, got id", id, "and", bm)
+	}
+	getMessages("from=2", http.StatusGone)
+}
+
 func Require(t *testing.T, err error, printables ...interface{}) {
 	t.Helper()
 	testhelpers.RequireImpl(t, err, printables...)
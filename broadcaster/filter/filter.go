@@ -0,0 +1,348 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package filter selects the part of the sequencer feed a client is interested in.
+//
+// A filtered feed leaves messages out, so it can't be used to sync a node: the messages passing
+// a filter don't make up the chain, and nothing tells a client the messages left out from the
+// ones it missed. Filters are meant for consumers only following part of the chain's activity,
+// such as indexers of a few contracts.
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbos/util"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/containers"
+)
+
+// MaxAddresses bounds the addresses of a filter, so that it fits in the feed's handshake.
+const MaxAddresses = 64
+
+// L2 message kinds, see arbos/parse_l2.go.
+const (
+	l2MessageKindUnsignedUserTx = 0
+	l2MessageKindContractTx     = 1
+	l2MessageKindBatch          = 3
+	l2MessageKindSignedTx       = 4
+	l2MessageKindHeartbeat      = 6
+
+	maxL2MessageBatchDepth = 16
+)
+
+// decoderCacheSize is the number of messages a Decoder keeps the transactions of,
+// the same messages are matched by every filtered client.
+const decoderCacheSize = 1024
+
+// Filter selects the feed messages sent to a client, it is sent as json in the feed's handshake.
+// A filtered feed can't be used to sync a node, see the package documentation.
+//
+// Confirmations are always sent. Messages are sent if their kind is one of Kinds, and if one of
+// their transactions is sent from one of From or to one of To. Empty lists don't filter anything.
+// Transactions are told apart as ArbOS does: the sender of signed transactions is recovered from
+// their signature, the sender of other transactions is the message's poster, and messages that
+// ArbOS wouldn't find transactions in, or would reject, never pass address filters.
+type Filter struct {
+	// Kinds are L1IncomingMessageHeader kinds, such as arbostypes.L1MessageType_L2Message.
+	Kinds []int            `json:"kinds,omitempty"`
+	From  []common.Address `json:"from,omitempty"`
+	To    []common.Address `json:"to,omitempty"`
+	// ConfirmationsOnly leaves every message out, only ConfirmedSequenceNumberMessages are sent.
+	ConfirmationsOnly bool `json:"confirmationsOnly,omitempty"`
+}
+
+// Parse reads a Filter from its json handshake header value.
+func Parse(value string) (*Filter, error) {
+	var f Filter
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	// a filter the server doesn't fully understand would let through messages the client doesn't expect
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse feed filter: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Header returns the json handshake header value of the filter.
+func (f *Filter) Header() (string, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (f *Filter) Validate() error {
+	for _, kind := range f.Kinds {
+		if kind < 0 || kind > 0xFF {
+			return fmt.Errorf("invalid feed filter message kind %d", kind)
+		}
+	}
+	if addresses := len(f.From) + len(f.To); addresses > MaxAddresses {
+		return fmt.Errorf("feed filter has %d addresses, at most %d are allowed", addresses, MaxAddresses)
+	}
+	return nil
+}
+
+// txAddresses are the addresses of a transaction of a message, as far as filters need them.
+type txAddresses struct {
+	// tx is set for signed transactions, whose sender is only recovered when needed
+	tx   *types.Transaction
+	from common.Address
+	to   *common.Address
+}
+
+func (a *txAddresses) sender(signer types.Signer) (common.Address, error) {
+	if a.tx == nil {
+		return a.from, nil
+	}
+	// the sender is cached in the transaction
+	return types.Sender(signer, a.tx)
+}
+
+// Decoder decodes the transactions of feed messages as far as filters need them.
+// It's safe for concurrent use.
+type Decoder struct {
+	signer types.Signer
+
+	mutex sync.Mutex
+	cache *containers.LruCache[*m.BroadcastFeedMessage, []txAddresses]
+}
+
+func NewDecoder(chainId uint64) *Decoder {
+	return &Decoder{
+		signer: types.LatestSignerForChainID(new(big.Int).SetUint64(chainId)),
+		cache:  containers.NewLruCache[*m.BroadcastFeedMessage, []txAddresses](decoderCacheSize),
+	}
+}
+
+func (d *Decoder) transactions(msg *m.BroadcastFeedMessage) []txAddresses {
+	d.mutex.Lock()
+	txs, ok := d.cache.Get(msg)
+	d.mutex.Unlock()
+	if ok {
+		return txs
+	}
+	txs, err := decodeMessage(msg.Message.Message)
+	if err != nil {
+		log.Debug("feed filters found no transactions in message", "sequenceNumber", msg.SequenceNumber, "err", err)
+	}
+	d.mutex.Lock()
+	d.cache.Add(msg, txs)
+	d.mutex.Unlock()
+	return txs
+}
+
+func addressPointer(address common.Address) *common.Address {
+	if address == (common.Address{}) {
+		// contract creation
+		return nil
+	}
+	return &address
+}
+
+func decodeMessage(msg *arbostypes.L1IncomingMessage) ([]txAddresses, error) {
+	if msg == nil || msg.Header == nil {
+		return nil, errors.New("incomplete message")
+	}
+	if len(msg.L2msg) > arbostypes.MaxL2MessageSize {
+		return nil, errors.New("message too large")
+	}
+	poster := msg.Header.Poster
+	rd := bytes.NewReader(msg.L2msg)
+	switch msg.Header.Kind {
+	case arbostypes.L1MessageType_L2Message:
+		return decodeL2Message(rd, poster, 0)
+	case arbostypes.L1MessageType_L2FundedByL1:
+		var kind [1]byte
+		if _, err := rd.Read(kind[:]); err != nil {
+			return nil, err
+		}
+		tx, err := decodeUnsignedTx(rd, poster, kind[0])
+		if err != nil {
+			return nil, err
+		}
+		return []txAddresses{tx}, nil
+	case arbostypes.L1MessageType_SubmitRetryable:
+		retryTo, err := util.AddressFrom256FromReader(rd)
+		if err != nil {
+			return nil, err
+		}
+		return []txAddresses{{from: poster, to: addressPointer(retryTo)}}, nil
+	case arbostypes.L1MessageType_EthDeposit:
+		to, err := util.AddressFromReader(rd)
+		if err != nil {
+			return nil, err
+		}
+		return []txAddresses{{from: poster, to: &to}}, nil
+	default:
+		// other messages don't hold user transactions
+		return nil, nil
+	}
+}
+
+func decodeL2Message(rd io.Reader, poster common.Address, depth int) ([]txAddresses, error) {
+	var kind [1]byte
+	if _, err := rd.Read(kind[:]); err != nil {
+		return nil, err
+	}
+	switch kind[0] {
+	case l2MessageKindUnsignedUserTx, l2MessageKindContractTx:
+		tx, err := decodeUnsignedTx(rd, poster, kind[0])
+		if err != nil {
+			return nil, err
+		}
+		return []txAddresses{tx}, nil
+	case l2MessageKindBatch:
+		if depth >= maxL2MessageBatchDepth {
+			return nil, errors.New("L2 message batches have a max depth of 16")
+		}
+		var txs []txAddresses
+		for {
+			nextMsg, err := util.BytestringFromReader(rd, arbostypes.MaxL2MessageSize)
+			if err != nil {
+				// an error here means there are no further messages in the batch
+				// nolint:nilerr
+				return txs, nil
+			}
+			nested, err := decodeL2Message(bytes.NewReader(nextMsg), poster, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			txs = append(txs, nested...)
+		}
+	case l2MessageKindSignedTx:
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return []txAddresses{{tx: tx, to: tx.To()}}, nil
+	case l2MessageKindHeartbeat:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported L2 message kind %v", kind[0])
+	}
+}
+
+// decodeUnsignedTx reads the destination of an unsigned transaction, which is sent by the poster.
+func decodeUnsignedTx(rd io.Reader, poster common.Address, kind byte) (txAddresses, error) {
+	// skip the gas limit and max fee per gas, and the nonce of user transactions
+	skipped := 2
+	switch kind {
+	case l2MessageKindUnsignedUserTx:
+		skipped++
+	case l2MessageKindContractTx:
+	default:
+		return txAddresses{}, fmt.Errorf("invalid unsigned transaction kind %v", kind)
+	}
+	for i := 0; i < skipped; i++ {
+		if _, err := util.HashFromReader(rd); err != nil {
+			return txAddresses{}, err
+		}
+	}
+	to, err := util.AddressFrom256FromReader(rd)
+	if err != nil {
+		return txAddresses{}, err
+	}
+	return txAddresses{from: poster, to: addressPointer(to)}, nil
+}
+
+func addressSet(addresses []common.Address) map[common.Address]struct{} {
+	set := make(map[common.Address]struct{}, len(addresses))
+	for _, address := range addresses {
+		set[address] = struct{}{}
+	}
+	return set
+}
+
+// Matcher applies a Filter to feed messages.
+type Matcher struct {
+	confirmationsOnly bool
+	kinds             map[uint8]struct{}
+	from              map[common.Address]struct{}
+	to                map[common.Address]struct{}
+	decoder           *Decoder
+}
+
+// NewMatcher returns a Matcher for a validated filter, decoding messages with decoder.
+func NewMatcher(f *Filter, decoder *Decoder) *Matcher {
+	kinds := make(map[uint8]struct{}, len(f.Kinds))
+	for _, kind := range f.Kinds {
+		kinds[uint8(kind)] = struct{}{}
+	}
+	return &Matcher{
+		confirmationsOnly: f.ConfirmationsOnly,
+		kinds:             kinds,
+		from:              addressSet(f.From),
+		to:                addressSet(f.To),
+		decoder:           decoder,
+	}
+}
+
+// Matches returns whether msg passes the filter.
+func (mt *Matcher) Matches(msg *m.BroadcastFeedMessage) bool {
+	if mt.confirmationsOnly || msg == nil || msg.Message.Message == nil || msg.Message.Message.Header == nil {
+		return false
+	}
+	if len(mt.kinds) > 0 {
+		if _, ok := mt.kinds[msg.Message.Message.Header.Kind]; !ok {
+			return false
+		}
+	}
+	if len(mt.from) == 0 && len(mt.to) == 0 {
+		return true
+	}
+	for _, tx := range mt.decoder.transactions(msg) {
+		if tx.to != nil {
+			if _, ok := mt.to[*tx.to]; ok {
+				return true
+			}
+		}
+		if len(mt.from) > 0 {
+			from, err := tx.sender(mt.decoder.signer)
+			if err != nil {
+				continue
+			}
+			if _, ok := mt.from[from]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply returns the part of bm passing the filter, or nil if nothing is left to send.
+func (mt *Matcher) Apply(bm *m.BroadcastMessage) *m.BroadcastMessage {
+	filtered := &m.BroadcastMessage{
+		Version:                        bm.Version,
+		ConfirmedSequenceNumberMessage: bm.ConfirmedSequenceNumberMessage,
+	}
+	for _, msg := range bm.Messages {
+		if mt.Matches(msg) {
+			filtered.Messages = append(filtered.Messages, msg)
+		}
+	}
+	if len(filtered.Messages) == 0 && filtered.ConfirmedSequenceNumberMessage == nil {
+		return nil
+	}
+	return filtered
+}
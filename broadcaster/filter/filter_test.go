@@ -0,0 +1,170 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package filter
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbos/util"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+)
+
+const testChainId = 412346
+
+func testFeedMessage(kind uint8, poster common.Address, l2msg []byte) *m.BroadcastFeedMessage {
+	return &m.BroadcastFeedMessage{
+		Message: arbostypes.MessageWithMetadata{
+			Message: &arbostypes.L1IncomingMessage{
+				Header: &arbostypes.L1IncomingMessageHeader{Kind: kind, Poster: poster},
+				L2msg:  l2msg,
+			},
+		},
+	}
+}
+
+func signedTxL2Message(t *testing.T, to common.Address) ([]byte, common.Address) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainId := big.NewInt(testChainId)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainId), &types.DynamicFeeTx{
+		ChainID:   chainId,
+		Gas:       21000,
+		GasFeeCap: big.NewInt(1),
+		To:        &to,
+		Value:     common.Big1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte{l2MessageKindSignedTx}, data...), crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func unsignedTxL2Message(to common.Address) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(l2MessageKindUnsignedUserTx)
+	// gas limit, max fee per gas and nonce
+	buf.Write(make([]byte, 3*32))
+	_ = util.AddressTo256ToWriter(to, &buf)
+	// value
+	buf.Write(make([]byte, 32))
+	return buf.Bytes()
+}
+
+func batchL2Message(segments ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(l2MessageKindBatch)
+	for _, segment := range segments {
+		_ = util.BytestringToWriter(segment, &buf)
+	}
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(`{"kinds":[3],"to":["0x0000000000000000000000000000000000000064"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Kinds) != 1 || f.Kinds[0] != arbostypes.L1MessageType_L2Message || len(f.To) != 1 || f.To[0] != common.BigToAddress(big.NewInt(100)) {
+		t.Fatalf("unexpected filter %+v", f)
+	}
+	header, err := f.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(header); err != nil {
+		t.Fatal("filter header doesn't parse back", header, err)
+	}
+
+	for _, invalid := range []string{
+		`{"kinds":[256]}`,
+		`{"senders":["0x0000000000000000000000000000000000000064"]}`,
+		`{"from":[` + strings.Repeat(`"0x0000000000000000000000000000000000000064",`, MaxAddresses) + `"0x0000000000000000000000000000000000000064"]}`,
+		`not json`,
+	} {
+		if _, err := Parse(invalid); err == nil {
+			t.Error("parsed invalid filter", invalid)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	contract := common.BigToAddress(big.NewInt(100))
+	other := common.BigToAddress(big.NewInt(200))
+	poster := common.BigToAddress(big.NewInt(300))
+
+	signedToContract, signer := signedTxL2Message(t, contract)
+	signedToOther, otherSigner := signedTxL2Message(t, other)
+	toContract := testFeedMessage(arbostypes.L1MessageType_L2Message, poster, signedToContract)
+	toOther := testFeedMessage(arbostypes.L1MessageType_L2Message, poster, signedToOther)
+	batched := testFeedMessage(arbostypes.L1MessageType_L2Message, poster, batchL2Message(signedToOther, batchL2Message(signedToContract)))
+	unsigned := testFeedMessage(arbostypes.L1MessageType_L2Message, poster, unsignedTxL2Message(contract))
+	deposit := testFeedMessage(arbostypes.L1MessageType_EthDeposit, poster, append(contract.Bytes(), make([]byte, 32)...))
+	endOfBlock := testFeedMessage(arbostypes.L1MessageType_EndOfBlock, poster, nil)
+	invalid := testFeedMessage(arbostypes.L1MessageType_L2Message, poster, []byte{l2MessageKindSignedTx, 1, 2, 3})
+	msgs := []*m.BroadcastFeedMessage{toContract, toOther, batched, unsigned, deposit, endOfBlock, invalid}
+
+	decoder := NewDecoder(testChainId)
+	for _, test := range []struct {
+		name    string
+		filter  Filter
+		matches []*m.BroadcastFeedMessage
+	}{
+		{"empty", Filter{}, msgs},
+		{"to", Filter{To: []common.Address{contract}}, []*m.BroadcastFeedMessage{toContract, batched, unsigned, deposit}},
+		{"from", Filter{From: []common.Address{signer}}, []*m.BroadcastFeedMessage{toContract, batched}},
+		{"poster", Filter{From: []common.Address{poster}}, []*m.BroadcastFeedMessage{unsigned, deposit}},
+		{"from or to", Filter{From: []common.Address{otherSigner}, To: []common.Address{contract}}, []*m.BroadcastFeedMessage{toContract, toOther, batched, unsigned, deposit}},
+		{"kinds", Filter{Kinds: []int{arbostypes.L1MessageType_EthDeposit, arbostypes.L1MessageType_EndOfBlock}}, []*m.BroadcastFeedMessage{deposit, endOfBlock}},
+		{"kinds and to", Filter{Kinds: []int{arbostypes.L1MessageType_L2Message}, To: []common.Address{contract}}, []*m.BroadcastFeedMessage{toContract, batched, unsigned}},
+		{"confirmations only", Filter{ConfirmationsOnly: true}, nil},
+	} {
+		matcher := NewMatcher(&test.filter, decoder)
+		expected := make(map[*m.BroadcastFeedMessage]bool)
+		for _, msg := range test.matches {
+			expected[msg] = true
+		}
+		for i, msg := range msgs {
+			if matcher.Matches(msg) != expected[msg] {
+				t.Errorf("filter %v: message %d matches %v, expected %v", test.name, i, !expected[msg], expected[msg])
+			}
+		}
+	}
+}
+
+func TestMatcherApply(t *testing.T) {
+	contract := common.BigToAddress(big.NewInt(100))
+	other := common.BigToAddress(big.NewInt(200))
+	matcher := NewMatcher(&Filter{To: []common.Address{contract}}, NewDecoder(testChainId))
+
+	matching := testFeedMessage(arbostypes.L1MessageType_L2Message, common.Address{}, unsignedTxL2Message(contract))
+	notMatching := testFeedMessage(arbostypes.L1MessageType_L2Message, common.Address{}, unsignedTxL2Message(other))
+	confirmation := &m.ConfirmedSequenceNumberMessage{SequenceNumber: 1}
+
+	filtered := matcher.Apply(&m.BroadcastMessage{Version: m.V1, Messages: []*m.BroadcastFeedMessage{notMatching, matching, notMatching}})
+	if filtered == nil || len(filtered.Messages) != 1 || filtered.Messages[0] != matching {
+		t.Fatalf("unexpected filtered message %+v", filtered)
+	}
+	if filtered := matcher.Apply(&m.BroadcastMessage{Version: m.V1, Messages: []*m.BroadcastFeedMessage{notMatching}}); filtered != nil {
+		t.Fatalf("unexpected filtered message %+v", filtered)
+	}
+	filtered = matcher.Apply(&m.BroadcastMessage{Version: m.V1, Messages: []*m.BroadcastFeedMessage{notMatching}, ConfirmedSequenceNumberMessage: confirmation})
+	if filtered == nil || len(filtered.Messages) != 0 || filtered.ConfirmedSequenceNumberMessage != confirmation {
+		t.Fatalf("confirmation wasn't kept, got %+v", filtered)
+	}
+}
@@ -1,7 +1,10 @@
 package message
 
 import (
+	"errors"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/yingdianRao/nitro/arbos/arbostypes"
 	"github.com/yingdianRao/nitro/arbutil"
 )
@@ -10,6 +13,14 @@ const (
 	V1 = 1
 )
 
+// Encodings of the feed, a client asks for one with the Arbitrum-Feed-Encoding handshake header.
+const (
+	// EncodingJSON is sent in text frames, and is what clients not asking for an encoding get.
+	EncodingJSON = "json"
+	// EncodingRLP is sent in binary frames, see BroadcastMessage.MarshalBinary.
+	EncodingRLP = "rlp"
+)
+
 // BroadcastMessage is the base message type for messages to send over the network.
 //
 // Acts as a variant holding the message types. The type of the message is
@@ -36,7 +47,7 @@ type BroadcastFeedMessage struct {
 	Message        arbostypes.MessageWithMetadata `json:"message"`
 	Signature      []byte                         `json:"signature"`
 
-	CumulativeSumMsgSize uint64 `json:"-"`
+	CumulativeSumMsgSize uint64 `json:"-" rlp:"-"`
 }
 
 func (m *BroadcastFeedMessage) Size() uint64 {
@@ -54,3 +65,38 @@ func (m *BroadcastFeedMessage) Hash(chainId uint64) (common.Hash, error) {
 type ConfirmedSequenceNumberMessage struct {
 	SequenceNumber arbutil.MessageIndex `json:"sequenceNumber"`
 }
+
+// rlpBroadcastMessage is the binary encoding of a BroadcastMessage.
+// Unlike the json one it isn't forwards compatible, new fields must be appended with the rlp:"optional" tag.
+type rlpBroadcastMessage struct {
+	Version                        uint64
+	Messages                       []*BroadcastFeedMessage
+	ConfirmedSequenceNumberMessage *ConfirmedSequenceNumberMessage `rlp:"nil"`
+}
+
+// MarshalBinary encodes the message as RLP, which unlike json doesn't base64 wrap the L2 messages.
+func (bm *BroadcastMessage) MarshalBinary() ([]byte, error) {
+	for _, msg := range bm.Messages {
+		if msg == nil || msg.Message.Message == nil || msg.Message.Message.Header == nil {
+			return nil, errors.New("cannot encode incomplete broadcast feed message")
+		}
+	}
+	return rlp.EncodeToBytes(&rlpBroadcastMessage{
+		Version:                        uint64(bm.Version),
+		Messages:                       bm.Messages,
+		ConfirmedSequenceNumberMessage: bm.ConfirmedSequenceNumberMessage,
+	})
+}
+
+func (bm *BroadcastMessage) UnmarshalBinary(data []byte) error {
+	var decoded rlpBroadcastMessage
+	if err := rlp.DecodeBytes(data, &decoded); err != nil {
+		return err
+	}
+	*bm = BroadcastMessage{
+		Version:                        int(decoded.Version),
+		Messages:                       decoded.Messages,
+		ConfirmedSequenceNumberMessage: decoded.ConfirmedSequenceNumberMessage,
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/yingdianRao/nitro/arbos/arbostypes"
@@ -69,3 +70,75 @@ func ExampleBroadcastMessage_confirmedseqnum() {
 	fmt.Println(buf.String())
 	// Output: {"version":1,"confirmedSequenceNumberMessage":{"sequenceNumber":1234}}
 }
+
+func TestBroadcastMessageBinaryRoundTrip(t *testing.T) {
+	var requestId common.Hash
+	batchGasCost := uint64(7)
+	msgs := []BroadcastMessage{
+		{
+			Version: 1,
+			Messages: []*BroadcastFeedMessage{
+				{
+					SequenceNumber: 12345,
+					Message: arbostypes.MessageWithMetadata{
+						Message: &arbostypes.L1IncomingMessage{
+							Header: &arbostypes.L1IncomingMessageHeader{
+								Kind:        3,
+								Poster:      common.HexToAddress("0x1234"),
+								BlockNumber: 10,
+								Timestamp:   20,
+								RequestId:   &requestId,
+								L1BaseFee:   big.NewInt(30),
+							},
+							L2msg:        []byte{0xde, 0xad, 0xbe, 0xef},
+							BatchGasCost: &batchGasCost,
+						},
+						DelayedMessagesRead: 3333,
+					},
+					Signature: []byte{1, 2, 3},
+				},
+			},
+			ConfirmedSequenceNumberMessage: &ConfirmedSequenceNumberMessage{SequenceNumber: 0},
+		},
+		{Version: 1},
+		{Version: 1, ConfirmedSequenceNumberMessage: &ConfirmedSequenceNumberMessage{SequenceNumber: 1234}},
+	}
+	for _, msg := range msgs {
+		data, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded BroadcastMessage
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		// the binary encoding must carry everything the json one does
+		expected, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Fatal("binary round trip changed the message, expected", string(expected), "got", string(actual))
+		}
+		if len(msg.Messages) > 0 {
+			expectedHash, err := msg.Messages[0].Hash(42161)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actualHash, err := decoded.Messages[0].Hash(42161)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expectedHash != actualHash {
+				t.Fatal("binary round trip changed the message hash")
+			}
+		}
+	}
+	if _, err := (&BroadcastMessage{Version: 1, Messages: []*BroadcastFeedMessage{{}}}).MarshalBinary(); err == nil {
+		t.Fatal("encoded a feed message without its L1 message")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// feedtool records sequencer feeds and replays them through a broadcaster to synthetic clients,
+// to reproduce feed incidents and benchmark relay settings such as max-send-queue, workers and client-delay.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/yingdianRao/nitro/cmd/genericconf"
+)
+
+func main() {
+	if err := run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: feedtool [record|replay] ...")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigint
+		log.Info("shutting down because of sigint")
+		cancel()
+	}()
+
+	switch strings.ToLower(args[1]) {
+	case "record":
+		return startRecord(ctx, args[2:])
+	case "replay":
+		return startReplay(ctx, args[2:])
+	default:
+		return fmt.Errorf("unknown tool '%s' specified, valid tools are 'record' and 'replay'", args[1])
+	}
+}
+
+func setupLogging(logLevel int, logType string) error {
+	logFormat, err := genericconf.ParseLogType(logType)
+	if err != nil {
+		return fmt.Errorf("error parsing log type: %w", err)
+	}
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, logFormat))
+	glogger.Verbosity(log.Lvl(logLevel))
+	log.Root().SetHandler(glogger)
+	return nil
+}
+
+func startRecord(ctx context.Context, args []string) error {
+	config, err := parseRecordConfig(args)
+	if err != nil {
+		return err
+	}
+	if err := setupLogging(config.LogLevel, config.LogType); err != nil {
+		return err
+	}
+	return record(ctx, config)
+}
+
+func startReplay(ctx context.Context, args []string) error {
+	config, err := parseReplayConfig(args)
+	if err != nil {
+		return err
+	}
+	if err := setupLogging(config.LogLevel, config.LogType); err != nil {
+		return err
+	}
+	report, err := replay(ctx, config)
+	if err != nil {
+		return err
+	}
+	report.print()
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/broadcastclient"
+	"github.com/yingdianRao/nitro/broadcaster"
+	"github.com/yingdianRao/nitro/util/testhelpers"
+	"github.com/yingdianRao/nitro/wsbroadcastserver"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chainId := uint64(9742)
+	sourceConfig := wsbroadcastserver.DefaultTestBroadcasterConfig
+	feedErrChan := make(chan error, 10)
+	source := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &sourceConfig }, chainId, feedErrChan, nil)
+	testhelpers.RequireImpl(t, source.Initialize())
+	testhelpers.RequireImpl(t, source.Start(ctx))
+	defer source.StopAndWait()
+	// the feed starts after the genesis message
+	for i := 1; i <= 5; i++ {
+		testhelpers.RequireImpl(t, source.BroadcastSingle(arbostypes.TestMessageWithMetadataAndRequestId, arbutil.MessageIndex(i)))
+	}
+
+	recording := filepath.Join(t.TempDir(), "feed.jsonl.gz")
+	recordConfig := DefaultRecordConfig
+	recordConfig.Feed = broadcastclient.DefaultTestConfig
+	recordConfig.Feed.URL = []string{fmt.Sprintf("ws://127.0.0.1:%d/", source.ListenerAddr().(*net.TCPAddr).Port)}
+	recordConfig.ChainId = chainId
+	recordConfig.Output = recording
+	recordConfig.Messages = 5
+	recordConfig.Duration = 10 * time.Second
+	testhelpers.RequireImpl(t, record(ctx, &recordConfig))
+
+	reader, err := openRecording(recording)
+	testhelpers.RequireImpl(t, err)
+	var messages int
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			break
+		}
+		if event.Message != nil {
+			if event.Message.SequenceNumber != arbutil.MessageIndex(messages+1) {
+				t.Fatal("recorded sequence number", event.Message.SequenceNumber, "expected", messages+1)
+			}
+			messages++
+		}
+	}
+	testhelpers.RequireImpl(t, reader.Close())
+	if reader.Header.ChainId != chainId || messages != 5 {
+		t.Fatal("unexpected recording", reader.Header, messages)
+	}
+
+	replayConfig := DefaultReplayConfig
+	replayConfig.Input = recording
+	replayConfig.Output = wsbroadcastserver.DefaultTestBroadcasterConfig
+	replayConfig.Speed = 0
+	replayConfig.Clients = 2
+	replayConfig.SlowClients = 1
+	replayConfig.SlowClientDelay = time.Millisecond
+	replayConfig.UncompressedClients = 1
+	report, err := replay(ctx, &replayConfig)
+	testhelpers.RequireImpl(t, err)
+	if report.Messages != 5 || len(report.Groups) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, group := range report.Groups {
+		if group.Received != group.Expected || group.Disconnects != 0 || group.Max < group.P50 {
+			t.Errorf("unexpected %v clients report %+v", group.Name, group)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/broadcastclient"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/cmd/util/confighelpers"
+)
+
+type RecordConfig struct {
+	Feed     broadcastclient.Config `koanf:"feed"`
+	ChainId  uint64                 `koanf:"chain-id"`
+	Output   string                 `koanf:"output"`
+	Duration time.Duration          `koanf:"duration"`
+	Messages uint64                 `koanf:"messages"`
+	LogLevel int                    `koanf:"log-level"`
+	LogType  string                 `koanf:"log-type"`
+}
+
+var DefaultRecordConfig = RecordConfig{
+	Feed:     broadcastclient.DefaultConfig,
+	ChainId:  0,
+	Output:   "feed.jsonl.gz",
+	Duration: 0,
+	Messages: 0,
+	LogLevel: int(log.LvlInfo),
+	LogType:  "plaintext",
+}
+
+func parseRecordConfig(args []string) (*RecordConfig, error) {
+	f := flag.NewFlagSet("feedtool record", flag.ContinueOnError)
+	broadcastclient.ConfigAddOptions("feed", f)
+	f.Uint64("chain-id", DefaultRecordConfig.ChainId, "L2 chain ID of the feed")
+	f.String("output", DefaultRecordConfig.Output, "file to record the feed to")
+	f.Duration("duration", DefaultRecordConfig.Duration, "duration to record the feed for (0 = until interrupted)")
+	f.Uint64("messages", DefaultRecordConfig.Messages, "number of messages to record (0 = until interrupted)")
+	f.Int("log-level", DefaultRecordConfig.LogLevel, "log level")
+	f.String("log-type", DefaultRecordConfig.LogType, "log type")
+
+	k, err := confighelpers.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+	var config RecordConfig
+	if err := confighelpers.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if !config.Feed.Enable() {
+		return nil, errors.New("--feed.url is required")
+	}
+	if config.ChainId == 0 {
+		return nil, errors.New("--chain-id is required")
+	}
+	return &config, nil
+}
+
+// recorder receives the feed's messages and confirmations as recordedEvents.
+type recorder struct {
+	started time.Time
+	events  chan *recordedEvent
+	// done is closed once events aren't read anymore
+	done <-chan struct{}
+}
+
+func (r *recorder) AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessage) error {
+	offset := time.Since(r.started)
+	for _, msg := range feedMessages {
+		select {
+		case r.events <- &recordedEvent{Offset: offset, Message: msg}:
+		case <-r.done:
+			return nil
+		}
+	}
+	return nil
+}
+
+// record records the feed until ctx is done, or the configured duration or number of messages is reached.
+func record(ctx context.Context, config *RecordConfig) error {
+	url := config.Feed.URL[0]
+	header := &recordingHeader{
+		ChainId: config.ChainId,
+		URL:     url,
+		Started: time.Now(),
+	}
+	writer, err := createRecording(config.Output, header)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			log.Error("error closing recording", "file", config.Output, "err", err)
+		}
+	}()
+
+	if config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+	recordingCtx, stopRecording := context.WithCancel(ctx)
+	defer stopRecording()
+	rec := &recorder{
+		started: header.Started,
+		events:  make(chan *recordedEvent, 1024),
+		done:    recordingCtx.Done(),
+	}
+	confirmations := make(chan arbutil.MessageIndex, 1024)
+	feedErrChan := make(chan error, 1)
+	client, err := broadcastclient.NewBroadcastClient(
+		func() *broadcastclient.Config { return &config.Feed },
+		url,
+		config.ChainId,
+		0,
+		rec,
+		confirmations,
+		feedErrChan,
+		nil,
+		nil,
+		func(int32) {},
+	)
+	if err != nil {
+		return err
+	}
+	client.Start(ctx)
+	defer func() {
+		// for the client not to wait for events to be read
+		stopRecording()
+		client.StopAndWait()
+	}()
+
+	log.Info("recording feed", "url", url, "output", config.Output)
+	var recorded uint64
+	for {
+		var event *recordedEvent
+		select {
+		case <-recordingCtx.Done():
+			log.Info("finished recording feed", "messages", recorded)
+			return nil
+		case err := <-feedErrChan:
+			return fmt.Errorf("error recording feed: %w", err)
+		case event = <-rec.events:
+		case confirmed := <-confirmations:
+			event = &recordedEvent{Offset: time.Since(rec.started), Confirmed: &confirmed}
+		}
+		if err := writer.Write(event); err != nil {
+			return err
+		}
+		if event.Message == nil {
+			continue
+		}
+		recorded++
+		if recorded%1000 == 0 {
+			log.Info("recording feed", "messages", recorded, "sequenceNumber", event.Message.SequenceNumber)
+		}
+		if config.Messages > 0 && recorded >= config.Messages {
+			log.Info("finished recording feed", "messages", recorded)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+)
+
+// A recording is a gzipped file of json lines: a recordingHeader, then a recordedEvent per line.
+
+type recordingHeader struct {
+	ChainId uint64    `json:"chainId"`
+	URL     string    `json:"url"`
+	Started time.Time `json:"started"`
+}
+
+// recordedEvent is a feed message or confirmation, received Offset after the recording started.
+type recordedEvent struct {
+	Offset    time.Duration           `json:"offset"`
+	Message   *m.BroadcastFeedMessage `json:"message,omitempty"`
+	Confirmed *arbutil.MessageIndex   `json:"confirmed,omitempty"`
+}
+
+type recordingWriter struct {
+	file    *os.File
+	buf     *bufio.Writer
+	gz      *gzip.Writer
+	encoder *json.Encoder
+}
+
+func createRecording(path string, header *recordingHeader) (*recordingWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	gz := gzip.NewWriter(buf)
+	w := &recordingWriter{
+		file:    file,
+		buf:     buf,
+		gz:      gz,
+		encoder: json.NewEncoder(gz),
+	}
+	if err := w.encoder.Encode(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *recordingWriter) Write(event *recordedEvent) error {
+	return w.encoder.Encode(event)
+}
+
+func (w *recordingWriter) Close() error {
+	err := w.gz.Close()
+	if err == nil {
+		err = w.buf.Flush()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type recordingReader struct {
+	file    *os.File
+	gz      *gzip.Reader
+	decoder *json.Decoder
+	Header  recordingHeader
+}
+
+func openRecording(path string) (*recordingReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("error reading recording %v: %w", path, err)
+	}
+	r := &recordingReader{
+		file:    file,
+		gz:      gz,
+		decoder: json.NewDecoder(gz),
+	}
+	if err := r.decoder.Decode(&r.Header); err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("error reading recording %v header: %w", path, err)
+	}
+	return r, nil
+}
+
+// Next returns the next event of the recording, or io.EOF once there are none left.
+// A recording cut short, by the recorder being killed, ends at its last complete event.
+func (r *recordingReader) Next() (*recordedEvent, error) {
+	var event recordedEvent
+	err := r.decoder.Decode(&event)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *recordingReader) Close() error {
+	err := r.gz.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,360 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/broadcastclient"
+	"github.com/yingdianRao/nitro/broadcaster"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/cmd/util/confighelpers"
+	"github.com/yingdianRao/nitro/wsbroadcastserver"
+)
+
+type ReplayConfig struct {
+	Input               string                              `koanf:"input"`
+	Output              wsbroadcastserver.BroadcasterConfig `koanf:"output"`
+	Speed               float64                             `koanf:"speed"`
+	Clients             int                                 `koanf:"clients"`
+	SlowClients         int                                 `koanf:"slow-clients"`
+	SlowClientDelay     time.Duration                       `koanf:"slow-client-delay"`
+	UncompressedClients int                                 `koanf:"uncompressed-clients"`
+	ConnectTimeout      time.Duration                       `koanf:"connect-timeout"`
+	Linger              time.Duration                       `koanf:"linger"`
+	LogLevel            int                                 `koanf:"log-level"`
+	LogType             string                              `koanf:"log-type"`
+}
+
+var DefaultReplayConfig = ReplayConfig{
+	Input:               "feed.jsonl.gz",
+	Output:              wsbroadcastserver.DefaultBroadcasterConfig,
+	Speed:               1,
+	Clients:             10,
+	SlowClients:         0,
+	SlowClientDelay:     10 * time.Millisecond,
+	UncompressedClients: 0,
+	ConnectTimeout:      30 * time.Second,
+	Linger:              10 * time.Second,
+	LogLevel:            int(log.LvlInfo),
+	LogType:             "plaintext",
+}
+
+func parseReplayConfig(args []string) (*ReplayConfig, error) {
+	f := flag.NewFlagSet("feedtool replay", flag.ContinueOnError)
+	f.String("input", DefaultReplayConfig.Input, "recording to replay")
+	wsbroadcastserver.BroadcasterConfigAddOptions("output", f)
+	f.Float64("speed", DefaultReplayConfig.Speed, "speed to replay the recording at, relative to the recorded one (0 = as fast as possible)")
+	f.Int("clients", DefaultReplayConfig.Clients, "number of synthetic clients reading the feed as fast as they can")
+	f.Int("slow-clients", DefaultReplayConfig.SlowClients, "number of synthetic clients waiting for slow-client-delay after each message")
+	f.Duration("slow-client-delay", DefaultReplayConfig.SlowClientDelay, "delay slow clients wait for after each message")
+	f.Int("uncompressed-clients", DefaultReplayConfig.UncompressedClients, "number of synthetic clients not negotiating compression")
+	f.Duration("connect-timeout", DefaultReplayConfig.ConnectTimeout, "duration to wait for the synthetic clients to connect before replaying")
+	f.Duration("linger", DefaultReplayConfig.Linger, "duration to wait for the synthetic clients to receive the last message after replaying")
+	f.Int("log-level", DefaultReplayConfig.LogLevel, "log level")
+	f.String("log-type", DefaultReplayConfig.LogType, "log type")
+
+	k, err := confighelpers.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+	var config ReplayConfig
+	if err := confighelpers.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if config.Speed < 0 {
+		return nil, errors.New("--speed must not be negative")
+	}
+	if config.Clients < 0 || config.SlowClients < 0 || config.UncompressedClients < 0 {
+		return nil, errors.New("numbers of clients must not be negative")
+	}
+	if err := config.Output.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// latencySamples is the number of latencies a client group keeps to compute percentiles from.
+const latencySamples = 100_000
+
+// clientGroup is a set of synthetic clients configured alike, whose latencies are reported together.
+type clientGroup struct {
+	name        string
+	config      broadcastclient.Config
+	delay       time.Duration
+	clients     []*syntheticClient
+	disconnects atomic.Int64
+
+	mutex     sync.Mutex
+	received  uint64
+	latencies []time.Duration
+	max       time.Duration
+}
+
+// addLatency records a latency, sampling them uniformly once there are more than latencySamples.
+func (g *clientGroup) addLatency(latency time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.received++
+	if latency > g.max {
+		g.max = latency
+	}
+	if len(g.latencies) < latencySamples {
+		g.latencies = append(g.latencies, latency)
+	} else if i := rand.Int63n(int64(g.received)); i < latencySamples {
+		g.latencies[i] = latency
+	}
+}
+
+// syntheticClient reads the replayed feed, recording the latency of each message.
+type syntheticClient struct {
+	group   *clientGroup
+	sentAt  *sentTimes
+	lastSeq atomic.Uint64
+}
+
+func (c *syntheticClient) AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessage) error {
+	now := time.Now()
+	for _, msg := range feedMessages {
+		if sent, ok := c.sentAt.get(msg.SequenceNumber); ok {
+			c.group.addLatency(now.Sub(sent))
+		}
+		c.lastSeq.Store(uint64(msg.SequenceNumber) + 1)
+		if c.group.delay > 0 {
+			time.Sleep(c.group.delay)
+		}
+	}
+	return nil
+}
+
+func (c *syntheticClient) adjustCount(delta int32) {
+	if delta < 0 {
+		c.group.disconnects.Add(1)
+	}
+}
+
+// sentTimes are the times the replayed messages were broadcast at.
+type sentTimes struct {
+	mutex sync.RWMutex
+	times map[arbutil.MessageIndex]time.Time
+}
+
+func (s *sentTimes) set(seqNum arbutil.MessageIndex, sent time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.times[seqNum] = sent
+}
+
+func (s *sentTimes) get(seqNum arbutil.MessageIndex) (time.Time, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	sent, ok := s.times[seqNum]
+	return sent, ok
+}
+
+type groupReport struct {
+	Name        string
+	Clients     int
+	Expected    uint64
+	Received    uint64
+	Disconnects int64
+	P50         time.Duration
+	P90         time.Duration
+	P99         time.Duration
+	Max         time.Duration
+}
+
+type replayReport struct {
+	Messages uint64
+	Duration time.Duration
+	Groups   []groupReport
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (g *clientGroup) report(messages uint64) groupReport {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	sorted := append([]time.Duration{}, g.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return groupReport{
+		Name:        g.name,
+		Clients:     len(g.clients),
+		Expected:    messages * uint64(len(g.clients)),
+		Received:    g.received,
+		Disconnects: g.disconnects.Load(),
+		P50:         percentile(sorted, 0.5),
+		P90:         percentile(sorted, 0.9),
+		P99:         percentile(sorted, 0.99),
+		Max:         g.max,
+	}
+}
+
+func (r *replayReport) print() {
+	fmt.Printf("replayed %d messages in %v\n", r.Messages, r.Duration)
+	fmt.Printf("%-14s %8s %12s %12s %12s %12s %12s %12s %12s\n", "clients", "count", "received", "expected", "disconnects", "p50", "p90", "p99", "max")
+	for _, g := range r.Groups {
+		fmt.Printf("%-14s %8d %12d %12d %12d %12v %12v %12v %12v\n", g.Name, g.Clients, g.Received, g.Expected, g.Disconnects, g.P50, g.P90, g.P99, g.Max)
+	}
+}
+
+// replay replays the recording through a broadcaster to synthetic clients, and reports their latencies.
+func replay(ctx context.Context, config *ReplayConfig) (*replayReport, error) {
+	reader, err := openRecording(config.Input)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	chainId := reader.Header.ChainId
+
+	feedErrChan := make(chan error, 10)
+	b := broadcaster.NewBroadcaster(func() *wsbroadcastserver.BroadcasterConfig { return &config.Output }, chainId, feedErrChan, nil)
+	if err := b.Initialize(); err != nil {
+		return nil, err
+	}
+	if err := b.Start(ctx); err != nil {
+		return nil, err
+	}
+	defer b.StopAndWait()
+	url := fmt.Sprintf("ws://127.0.0.1:%d/", b.ListenerAddr().(*net.TCPAddr).Port)
+
+	clientConfig := broadcastclient.DefaultConfig
+	uncompressedConfig := clientConfig
+	uncompressedConfig.EnableCompression = false
+	groups := []*clientGroup{
+		{name: "regular", config: clientConfig},
+		{name: "slow", config: clientConfig, delay: config.SlowClientDelay},
+		{name: "uncompressed", config: uncompressedConfig},
+	}
+	sentAt := &sentTimes{times: make(map[arbutil.MessageIndex]time.Time)}
+	var clients []*broadcastclient.BroadcastClient
+	defer func() {
+		for _, client := range clients {
+			client.StopAndWait()
+		}
+	}()
+	for i, count := range []int{config.Clients, config.SlowClients, config.UncompressedClients} {
+		group := groups[i]
+		for j := 0; j < count; j++ {
+			synthetic := &syntheticClient{group: group, sentAt: sentAt}
+			client, err := broadcastclient.NewBroadcastClient(
+				func() *broadcastclient.Config { return &group.config },
+				url,
+				chainId,
+				0,
+				synthetic,
+				nil,
+				feedErrChan,
+				nil,
+				nil,
+				synthetic.adjustCount,
+			)
+			if err != nil {
+				return nil, err
+			}
+			group.clients = append(group.clients, synthetic)
+			clients = append(clients, client)
+			client.Start(ctx)
+		}
+	}
+
+	connectDeadline := time.Now().Add(config.ConnectTimeout)
+	for b.ClientCount() < int32(len(clients)) {
+		if time.Now().After(connectDeadline) {
+			return nil, fmt.Errorf("only %d of %d clients connected", b.ClientCount(), len(clients))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	log.Info("replaying feed", "input", config.Input, "recorded", reader.Header.Started, "url", reader.Header.URL, "clients", len(clients))
+
+	report := &replayReport{}
+	var lastSeq uint64
+	start := time.Now()
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if config.Speed > 0 {
+			if wait := time.Until(start.Add(time.Duration(float64(event.Offset) / config.Speed))); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case err := <-feedErrChan:
+			return nil, err
+		default:
+		}
+		if event.Message != nil {
+			sentAt.set(event.Message.SequenceNumber, time.Now())
+			b.BroadcastSingleFeedMessage(event.Message)
+			report.Messages++
+			lastSeq = uint64(event.Message.SequenceNumber) + 1
+		}
+		if event.Confirmed != nil {
+			b.Confirm(*event.Confirmed)
+		}
+	}
+	report.Duration = time.Since(start)
+
+	// wait for the clients to receive the last message
+	lingerDeadline := time.Now().Add(config.Linger)
+	for _, group := range groups {
+		for _, client := range group.clients {
+			for client.lastSeq.Load() < lastSeq && time.Now().Before(lingerDeadline) {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+	}
+
+	for _, group := range groups {
+		if len(group.clients) > 0 {
+			report.Groups = append(report.Groups, group.report(report.Messages))
+		}
+	}
+	return report, nil
+}
@@ -109,6 +109,37 @@ func TestReloads(t *testing.T) {
 	testUnsafe()
 	update.Node.Staker.Enable = !update.Node.Staker.Enable
 	testUnsafe()
+	update.Node.Feed.Input.Backfill.Quorum++
+	testUnsafe()
+	update.Node.Feed.Input.Backfill.URL = []string{"http://127.0.0.1:8547"}
+	testUnsafe()
+	update.Node.Feed.Input.Backfill.Verify.AcceptSequencer = !update.Node.Feed.Input.Backfill.Verify.AcceptSequencer
+	testUnsafe()
+	update.Node.BalanceMonitor.TopUp.Enable = !update.Node.BalanceMonitor.TopUp.Enable
+	testUnsafe()
+	update.Node.BalanceMonitor.TopUp.RedisUrl = "redis://127.0.0.1:6379"
+	testUnsafe()
+	update.Node.BalanceMonitor.TopUp.ParentChainWallet.Pathname = "other-wallet"
+	testUnsafe()
+
+	// check that the backfill settings re-read by the backfiller can be reloaded
+	update.Node.Feed.Input.Backfill.MaxGap++
+	update.Node.Feed.Input.Backfill.BatchSize++
+	update.Node.Feed.Input.Backfill.Timeout++
+	update.Node.Feed.Input.Backfill.RetryInterval++
+	Require(t, config.CanReload(&update))
+	update = NodeConfigDefault
+
+	// check that the balance thresholds and top up limits re-read by the balance monitor can be reloaded
+	update.Node.BalanceMonitor.CheckInterval++
+	update.Node.BalanceMonitor.Staker.AlertBelow++
+	update.Node.BalanceMonitor.Staker.TopUpBelow++
+	update.Node.BalanceMonitor.Staker.TopUpTo++
+	update.Node.BalanceMonitor.TopUp.MinInterval++
+	update.Node.BalanceMonitor.TopUp.MaxPerWindow++
+	update.Node.BalanceMonitor.TopUp.Window++
+	update.Node.BalanceMonitor.TopUp.GasLimit++
+	Require(t, config.CanReload(&update))
 }
 
 func TestLiveNodeConfig(t *testing.T) {
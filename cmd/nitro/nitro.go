@@ -235,6 +235,7 @@ func mainImpl() int {
 	var dataSigner signature.DataSignerFunc
 	var l1TransactionOptsValidator *bind.TransactOpts
 	var l1TransactionOptsBatchPoster *bind.TransactOpts
+	var l1TransactionOptsTreasury *bind.TransactOpts
 	// If sequencer and signing is enabled or batchposter is enabled without
 	// external signing sequencer will need a key.
 	sequencerNeedsKey := (nodeConfig.Node.Sequencer && !nodeConfig.Node.Feed.Output.DisableSigning) ||
@@ -293,6 +294,19 @@ func mainImpl() int {
 		}
 	}
 
+	topUpConfig := &nodeConfig.Node.BalanceMonitor.TopUp
+	if (nodeConfig.Node.BalanceMonitor.Enable && topUpConfig.Enable && topUpConfig.DataPoster.ExternalSigner.URL == "") || topUpConfig.ParentChainWallet.OnlyCreateKey {
+		topUpConfig.ParentChainWallet.ResolveDirectoryNames(nodeConfig.Persistent.Chain)
+		l1TransactionOptsTreasury, _, err = util.OpenWallet("l1-treasury", &topUpConfig.ParentChainWallet, new(big.Int).SetUint64(nodeConfig.ParentChain.ID))
+		if err != nil {
+			flag.Usage()
+			log.Crit("error opening treasury parent chain wallet", "path", topUpConfig.ParentChainWallet.Pathname, "account", topUpConfig.ParentChainWallet.Account, "err", err)
+		}
+		if topUpConfig.ParentChainWallet.OnlyCreateKey {
+			return 0
+		}
+	}
+
 	combinedL2ChainInfoFile := nodeConfig.Chain.InfoFiles
 	if nodeConfig.Chain.InfoIpfsUrl != "" {
 		l2ChainInfoIpfsFile, err := util.GetL2ChainInfoIpfsFile(ctx, nodeConfig.Chain.InfoIpfsUrl, nodeConfig.Chain.InfoIpfsDownloadPath)
@@ -550,6 +564,7 @@ func mainImpl() int {
 		&rollupAddrs,
 		l1TransactionOptsValidator,
 		l1TransactionOptsBatchPoster,
+		l1TransactionOptsTreasury,
 		dataSigner,
 		fatalErrChan,
 		big.NewInt(int64(nodeConfig.ParentChain.ID)),
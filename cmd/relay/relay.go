@@ -81,7 +81,7 @@ func startup() error {
 
 	// Start up an arbitrum sequencer relay
 	feedErrChan := make(chan error, 10)
-	newRelay, err := relay.NewRelay(relayConfig, feedErrChan)
+	newRelay, err := relay.NewRelay(ctx, relayConfig, feedErrChan)
 	if err != nil {
 		return err
 	}
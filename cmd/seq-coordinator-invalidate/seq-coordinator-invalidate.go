@@ -12,28 +12,30 @@ import (
 
 	"github.com/yingdianRao/nitro/arbnode"
 	"github.com/yingdianRao/nitro/arbutil"
-	"github.com/yingdianRao/nitro/util/redisutil"
+	"github.com/yingdianRao/nitro/util/coordination"
 )
 
 func main() {
-	if len(os.Args) != 4 {
-		fmt.Fprintf(os.Stderr, "Usage: seq-coordinator-invalidate [redis url] [signing key] [msg index]\n")
+	if len(os.Args) != 4 && len(os.Args) != 5 {
+		fmt.Fprintf(os.Stderr, "Usage: seq-coordinator-invalidate [redis url | raft member urls] [signing key] [msg index] [raft jwt secret file]\n")
 		os.Exit(1)
 	}
-	redisUrl := os.Args[1]
+	coordinationUrl := os.Args[1]
 	signingKey := os.Args[2]
 	msgIndex, err := strconv.ParseUint(os.Args[3], 10, 64)
 	if err != nil {
 		panic("Failed to parse msg index: " + err.Error())
 	}
-	redisClient, err := redisutil.RedisClientFromURL(redisUrl)
+	raftJWTSecret := ""
+	if len(os.Args) == 5 {
+		raftJWTSecret = os.Args[4]
+	}
+	store, err := coordination.NewClientStore(coordinationUrl, raftJWTSecret)
 	if err != nil {
 		panic(err)
 	}
-	if redisClient == nil {
-		panic("redis url not defined")
-	}
-	err = arbnode.StandaloneSeqCoordinatorInvalidateMsgIndex(context.Background(), redisClient, signingKey, arbutil.MessageIndex(msgIndex))
+	defer store.Close()
+	err = arbnode.StandaloneSeqCoordinatorInvalidateMsgIndex(context.Background(), store, signingKey, arbutil.MessageIndex(msgIndex))
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,318 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/yingdianRao/nitro/arbnode"
+	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/util/coordination"
+	"github.com/yingdianRao/nitro/util/redisutil"
+)
+
+//go:embed index.html
+var indexPage []byte
+
+type sequencerState struct {
+	Url string `json:"url"`
+	// Priority is the position of the sequencer in the priority list, or -1 if it isn't in it.
+	Priority     int     `json:"priority"`
+	Live         bool    `json:"live"`
+	Chosen       bool    `json:"chosen"`
+	MessageCount *uint64 `json:"messageCount,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+type coordinatorState struct {
+	Priorities []string `json:"priorities"`
+	Chosen     string   `json:"chosen"`
+	// CoordinatorMessageCount is the message count last written by the chosen sequencer, its signature isn't checked.
+	CoordinatorMessageCount *uint64          `json:"coordinatorMessageCount,omitempty"`
+	Sequencers              []sequencerState `json:"sequencers"`
+}
+
+type prioritiesRequest struct {
+	// Expected is the priority list the change was made against, the change is refused if it's no longer current.
+	Expected   []string `json:"expected"`
+	Priorities []string `json:"priorities"`
+}
+
+type invalidateRequest struct {
+	MessageIndex uint64 `json:"messageIndex"`
+}
+
+// adminServer serves the sequencer coordinator admin api, and the web page using it.
+type adminServer struct {
+	config      *ManagerConfig
+	coordinator *coordination.Coordinator
+	tokens      map[string]string
+	audit       *auditLog
+	mux         *http.ServeMux
+
+	// sequencerMessageCount is replaceable by tests
+	sequencerMessageCount func(ctx context.Context, url string) (uint64, error)
+}
+
+func newAdminServer(config *ManagerConfig, store coordination.Store, tokens map[string]string, audit *auditLog) *adminServer {
+	s := &adminServer{
+		config:      config,
+		coordinator: coordination.NewCoordinator(store),
+		tokens:      tokens,
+		audit:       audit,
+		mux:         http.NewServeMux(),
+	}
+	s.sequencerMessageCount = s.queryMessageCount
+	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/api/state", s.authenticated(http.MethodGet, s.handleState))
+	s.mux.HandleFunc("/api/priorities", s.authenticated(http.MethodPut, s.handlePriorities))
+	s.mux.HandleFunc("/api/invalidate", s.authenticated(http.MethodPost, s.handleInvalidate))
+	s.mux.HandleFunc("/api/audit", s.authenticated(http.MethodGet, s.handleAudit))
+	return s
+}
+
+func (s *adminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Warn("failed writing admin api response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// user returns the name the request's bearer token was issued to.
+func (s *adminServer) user(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	var user string
+	for known, name := range s.tokens {
+		// go through every token, so as not to leak which ones share a prefix with the given one
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			user = name
+		}
+	}
+	return user, user != ""
+}
+
+func (s *adminServer) authenticated(method string, handler func(w http.ResponseWriter, r *http.Request, user string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := s.user(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or unknown bearer token"))
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s only serves %s requests", r.URL.Path, method))
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// record adds an operation and its outcome to the audit trail.
+// If the trail can't be written, the operation is still logged, and the response reports the failure.
+func (s *adminServer) record(w http.ResponseWriter, r *http.Request, user string, action string, details interface{}, opErr error) bool {
+	entry := auditEntry{
+		Time:    time.Now().UTC(),
+		User:    user,
+		Remote:  r.RemoteAddr,
+		Action:  action,
+		Details: details,
+	}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	if err := s.audit.Record(entry); err != nil {
+		log.Error("failed recording admin operation in the audit log", "user", user, "action", action, "details", details, "opErr", opErr, "err", err)
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("operation outcome (error: %v) couldn't be recorded: %w", opErr, err))
+		return false
+	}
+	log.Info("admin operation", "user", user, "action", action, "details", details, "err", opErr)
+	return true
+}
+
+func (s *adminServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexPage)
+}
+
+func (s *adminServer) queryMessageCount(ctx context.Context, url string) (uint64, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	blockNumber, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(arbutil.BlockNumberToMessageCount(blockNumber, s.config.GenesisBlockNum)), nil
+}
+
+func (s *adminServer) state(ctx context.Context) (*coordinatorState, error) {
+	priorities, err := s.coordinator.GetPriorities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	liveliness, err := s.coordinator.GetLiveliness(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chosen, err := s.coordinator.CurrentChosenSequencer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state := &coordinatorState{Priorities: priorities, Chosen: chosen, Sequencers: []sequencerState{}}
+	msgCount, err := s.coordinator.Store.Get(ctx, redisutil.MSG_COUNT_KEY)
+	if err != nil && !errors.Is(err, coordination.ErrNotFound) {
+		return nil, err
+	}
+	if len(msgCount) >= 8 {
+		count := binary.BigEndian.Uint64([]byte(msgCount[len(msgCount)-8:]))
+		state.CoordinatorMessageCount = &count
+	}
+
+	positions := make(map[string]int)
+	for i, url := range priorities {
+		positions[url] = len(state.Sequencers)
+		state.Sequencers = append(state.Sequencers, sequencerState{Url: url, Priority: i})
+	}
+	for _, url := range liveliness {
+		pos, ok := positions[url]
+		if !ok {
+			pos = len(state.Sequencers)
+			positions[url] = pos
+			state.Sequencers = append(state.Sequencers, sequencerState{Url: url, Priority: -1})
+		}
+		state.Sequencers[pos].Live = true
+	}
+	if pos, ok := positions[chosen]; ok {
+		state.Sequencers[pos].Chosen = true
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, s.config.SequencerQueryTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := range state.Sequencers {
+		wg.Add(1)
+		go func(sequencer *sequencerState) {
+			defer wg.Done()
+			count, err := s.sequencerMessageCount(queryCtx, sequencer.Url)
+			if err != nil {
+				sequencer.Error = err.Error()
+				return
+			}
+			sequencer.MessageCount = &count
+		}(&state.Sequencers[i])
+	}
+	wg.Wait()
+	return state, nil
+}
+
+func (s *adminServer) handleState(w http.ResponseWriter, r *http.Request, _ string) {
+	state, err := s.state(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func validatePriorities(priorities []string) error {
+	seen := make(map[string]bool)
+	for _, url := range priorities {
+		if url == "" || strings.Contains(url, ",") || strings.TrimSpace(url) != url {
+			return fmt.Errorf("invalid sequencer url %q", url)
+		}
+		if seen[url] {
+			return fmt.Errorf("sequencer url %q appears twice", url)
+		}
+		seen[url] = true
+	}
+	return nil
+}
+
+func (s *adminServer) handlePriorities(w http.ResponseWriter, r *http.Request, user string) {
+	var req prioritiesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if err := validatePriorities(req.Priorities); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	err := s.coordinator.SwapPriorities(r.Context(), req.Expected, req.Priorities)
+	if !s.record(w, r, user, "update-priorities", req, err) {
+		return
+	}
+	if errors.Is(err, coordination.ErrPrioritiesChanged) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, req.Priorities)
+}
+
+func (s *adminServer) handleInvalidate(w http.ResponseWriter, r *http.Request, user string) {
+	var req invalidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	err := arbnode.StandaloneSeqCoordinatorInvalidateMsgIndex(r.Context(), s.coordinator.Store, s.config.SigningKey, arbutil.MessageIndex(req.MessageIndex))
+	if !s.record(w, r, user, "invalidate-message", req, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (s *adminServer) handleAudit(w http.ResponseWriter, r *http.Request, _ string) {
+	limit := 100
+	if param := r.URL.Query().Get("limit"); param != "" {
+		var err error
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", param))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, s.audit.Recent(limit))
+}
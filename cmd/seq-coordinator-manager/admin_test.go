@@ -0,0 +1,156 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yingdianRao/nitro/util/coordination"
+	"github.com/yingdianRao/nitro/util/redisutil"
+	"github.com/yingdianRao/nitro/util/testhelpers"
+)
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
+
+func request(t *testing.T, server http.Handler, method string, path string, token string, body interface{}, result interface{}) int {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		Require(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	if result != nil && recorder.Code == http.StatusOK {
+		Require(t, json.Unmarshal(recorder.Body.Bytes(), result))
+	}
+	return recorder.Code
+}
+
+func TestAdminServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := coordination.CreateTestRaftGroup(t, 1)[0]
+	Require(t, store.Set(ctx, redisutil.CHOSENSEQ_KEY, "seq1", 0))
+	Require(t, store.Set(ctx, redisutil.WantsLockoutKeyFor("seq1"), redisutil.WANTS_LOCKOUT_VAL, 0))
+	Require(t, store.Set(ctx, redisutil.WantsLockoutKeyFor("seq3"), redisutil.WANTS_LOCKOUT_VAL, 0))
+
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := openAuditLog(auditPath)
+	Require(t, err)
+	config := DefaultManagerConfig
+	server := newAdminServer(&config, store, map[string]string{"secret": "alice"}, audit)
+	server.sequencerMessageCount = func(_ context.Context, url string) (uint64, error) {
+		if url == "seq2" {
+			return 0, errors.New("unreachable")
+		}
+		return uint64(len(url)), nil
+	}
+
+	if code := request(t, server, http.MethodGet, "/api/state", "", nil, nil); code != http.StatusUnauthorized {
+		Fail(t, "unauthenticated request got", code)
+	}
+	if code := request(t, server, http.MethodGet, "/api/state", "wrong", nil, nil); code != http.StatusUnauthorized {
+		Fail(t, "request with unknown token got", code)
+	}
+	if code := request(t, server, http.MethodGet, "/", "", nil, nil); code != http.StatusOK {
+		Fail(t, "index page got", code)
+	}
+
+	update := prioritiesRequest{Expected: nil, Priorities: []string{"seq2", "seq1"}}
+	if code := request(t, server, http.MethodPut, "/api/priorities", "secret", update, nil); code != http.StatusOK {
+		Fail(t, "updating priorities got", code)
+	}
+	// a change made against the old list is refused
+	if code := request(t, server, http.MethodPut, "/api/priorities", "secret", update, nil); code != http.StatusConflict {
+		Fail(t, "stale priorities update got", code)
+	}
+	invalid := prioritiesRequest{Expected: update.Priorities, Priorities: []string{"seq1", "seq1"}}
+	if code := request(t, server, http.MethodPut, "/api/priorities", "secret", invalid, nil); code != http.StatusBadRequest {
+		Fail(t, "duplicate priorities update got", code)
+	}
+
+	var state coordinatorState
+	if code := request(t, server, http.MethodGet, "/api/state", "secret", nil, &state); code != http.StatusOK {
+		Fail(t, "getting state got", code)
+	}
+	if strings.Join(state.Priorities, ",") != "seq2,seq1" || state.Chosen != "seq1" {
+		Fail(t, "unexpected state", state)
+	}
+	if len(state.Sequencers) != 3 {
+		Fail(t, "unexpected sequencers", state.Sequencers)
+	}
+	seq2, seq1, seq3 := state.Sequencers[0], state.Sequencers[1], state.Sequencers[2]
+	if seq2.Url != "seq2" || seq2.Live || seq2.Error == "" || seq2.MessageCount != nil {
+		Fail(t, "unexpected seq2 state", seq2)
+	}
+	if seq1.Url != "seq1" || !seq1.Live || !seq1.Chosen || seq1.Priority != 1 || seq1.MessageCount == nil || *seq1.MessageCount != 4 {
+		Fail(t, "unexpected seq1 state", seq1)
+	}
+	if seq3.Url != "seq3" || !seq3.Live || seq3.Priority != -1 {
+		Fail(t, "unexpected seq3 state", seq3)
+	}
+
+	// the audit trail records the failed change too, and survives restarts
+	Require(t, audit.Close())
+	audit, err = openAuditLog(auditPath)
+	Require(t, err)
+	defer audit.Close()
+	server.audit = audit
+	var entries []auditEntry
+	if code := request(t, server, http.MethodGet, "/api/audit", "secret", nil, &entries); code != http.StatusOK {
+		Fail(t, "getting audit trail got", code)
+	}
+	if len(entries) != 2 {
+		Fail(t, "unexpected audit trail", entries)
+	}
+	if entries[0].User != "alice" || entries[0].Action != "update-priorities" || !strings.Contains(entries[0].Error, "changed") {
+		Fail(t, "unexpected latest audit entry", entries[0])
+	}
+	if entries[1].Error != "" {
+		Fail(t, "unexpected first audit entry", entries[1])
+	}
+}
+
+func TestReadAuthTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	Require(t, os.WriteFile(path, []byte("# on-call\nalice: secret1\n\nbob:secret2\n"), 0600))
+	tokens, err := readAuthTokens(path)
+	Require(t, err)
+	if len(tokens) != 2 || tokens["secret1"] != "alice" || tokens["secret2"] != "bob" {
+		Fail(t, "unexpected tokens", tokens)
+	}
+	Require(t, os.WriteFile(path, []byte("alice:secret\nbob:secret\n"), 0600))
+	if _, err := readAuthTokens(path); err == nil {
+		Fail(t, "reused token accepted")
+	}
+	Require(t, os.WriteFile(path, []byte("alice\n"), 0600))
+	if _, err := readAuthTokens(path); err == nil {
+		Fail(t, "token without a name accepted")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditRecentEntries is how many audit entries are kept in memory to be served by the admin api.
+// The audit log file holds the full trail.
+const auditRecentEntries = 1000
+
+type auditEntry struct {
+	Time    time.Time   `json:"time"`
+	User    string      `json:"user"`
+	Remote  string      `json:"remote"`
+	Action  string      `json:"action"`
+	Details interface{} `json:"details,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type auditLog struct {
+	mutex  sync.Mutex
+	file   *os.File
+	recent []auditEntry
+}
+
+// openAuditLog opens the audit log for appending, loading its most recent entries.
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open audit log: %w", err)
+	}
+	a := &auditLog{file: file}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("corrupt audit log entry %q: %w", scanner.Text(), err)
+		}
+		a.remember(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("couldn't read audit log: %w", err)
+	}
+	return a, nil
+}
+
+func (a *auditLog) remember(entry auditEntry) {
+	a.recent = append(a.recent, entry)
+	if len(a.recent) > auditRecentEntries {
+		a.recent = a.recent[len(a.recent)-auditRecentEntries:]
+	}
+}
+
+// Record durably appends entry to the audit log before it's acknowledged.
+func (a *auditLog) Record(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("couldn't write audit log: %w", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync audit log: %w", err)
+	}
+	a.remember(entry)
+	return nil
+}
+
+// Recent returns up to limit of the latest entries, newest first.
+func (a *auditLog) Recent(limit int) []auditEntry {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if limit <= 0 || limit > len(a.recent) {
+		limit = len(a.recent)
+	}
+	entries := make([]auditEntry, 0, limit)
+	for i := len(a.recent) - 1; len(entries) < limit; i-- {
+		entries = append(entries, a.recent[i])
+	}
+	return entries
+}
+
+func (a *auditLog) Close() error {
+	return a.file.Close()
+}
+
+// readAuthTokens reads "name:token" lines, skipping blank lines and # comments, into a map from token to name.
+func readAuthTokens(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read auth tokens: %w", err)
+	}
+	tokens := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, token, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		token = strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("auth tokens line %d isn't a \"name:token\" pair", i+1)
+		}
+		if _, exists := tokens[token]; exists {
+			return nil, fmt.Errorf("auth tokens line %d reuses a token", i+1)
+		}
+		tokens[token] = name
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("no auth tokens configured")
+	}
+	return tokens, nil
+}
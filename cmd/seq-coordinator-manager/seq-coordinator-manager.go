@@ -1,332 +1,188 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
-	"strconv"
-
-	"github.com/enescakir/emoji"
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/gdamore/tcell/v2"
-	"github.com/rivo/tview"
-	"github.com/yingdianRao/nitro/cmd/seq-coordinator-manager/rediscoordinator"
-	"github.com/yingdianRao/nitro/util/redisutil"
-)
+	"os/signal"
+	"syscall"
+	"time"
 
-// Tview
-var pages = tview.NewPages()
-var app = tview.NewApplication()
+	koanfjson "github.com/knadh/koanf/parsers/json"
+	flag "github.com/spf13/pflag"
 
-// Lists
-var prioritySeqList = tview.NewList().ShowSecondaryText(false)
-var nonPrioritySeqList = tview.NewList().ShowSecondaryText(false)
+	"github.com/ethereum/go-ethereum/log"
 
-// Forms
-var addSeqForm = tview.NewForm()
-var priorityForm = tview.NewForm()
-var nonPriorityForm = tview.NewForm()
+	"github.com/yingdianRao/nitro/cmd/genericconf"
+	"github.com/yingdianRao/nitro/cmd/util/confighelpers"
+	"github.com/yingdianRao/nitro/util/coordination"
+)
 
-// Sequencer coordinator management UI data store
-type manager struct {
-	redisCoordinator *rediscoordinator.RedisCoordinator
-	prioritiesSet    map[string]bool
-	livelinessSet    map[string]bool
-	priorityList     []string
-	nonPriorityList  []string
-	maxURLSize       int
+type ManagerConfig struct {
+	CoordinationUrl       string                              `koanf:"coordination-url"`
+	RaftJWTSecret         string                              `koanf:"raft-jwtsecret"`
+	Addr                  string                              `koanf:"addr"`
+	Port                  uint64                              `koanf:"port"`
+	ServerTimeouts        genericconf.HTTPServerTimeoutConfig `koanf:"server-timeouts"`
+	AuthTokensFile        string                              `koanf:"auth-tokens-file"`
+	AuditLog              string                              `koanf:"audit-log"`
+	SigningKey            string                              `koanf:"signing-key"`
+	GenesisBlockNum       uint64                              `koanf:"genesis-block-num"`
+	SequencerQueryTimeout time.Duration                       `koanf:"sequencer-query-timeout"`
+
+	Conf     genericconf.ConfConfig `koanf:"conf"`
+	LogLevel int                    `koanf:"log-level"`
+	LogType  string                 `koanf:"log-type"`
 }
 
-func main() {
-	ctx, cancelFunc := context.WithCancel(context.Background())
-	defer cancelFunc()
+var DefaultManagerConfig = ManagerConfig{
+	CoordinationUrl:       "",
+	RaftJWTSecret:         "",
+	Addr:                  "localhost",
+	Port:                  9880,
+	ServerTimeouts:        genericconf.HTTPServerTimeoutConfigDefault,
+	AuthTokensFile:        "",
+	AuditLog:              "",
+	SigningKey:            "",
+	GenesisBlockNum:       0,
+	SequencerQueryTimeout: 5 * time.Second,
+	Conf:                  genericconf.ConfConfigDefault,
+	LogLevel:              int(log.LvlInfo),
+	LogType:               "plaintext",
+}
 
-	args := os.Args[1:]
-	if len(args) != 1 {
-		fmt.Fprintf(os.Stderr, "Usage: seq-coordinator-manager [redis-url]\n")
-		os.Exit(1)
-	}
-	redisURL := args[0]
-	redisutilCoordinator, err := redisutil.NewRedisCoordinator(redisURL)
+func parseManager(args []string) (*ManagerConfig, error) {
+	f := flag.NewFlagSet("seq-coordinator-manager", flag.ContinueOnError)
+	f.String("coordination-url", DefaultManagerConfig.CoordinationUrl, "redis url, or comma separated raft member urls, of the sequencer coordination state")
+	f.String("raft-jwtsecret", DefaultManagerConfig.RaftJWTSecret, "path to the file with the jwt secret of the raft group, if coordinating through raft")
+	f.String("addr", DefaultManagerConfig.Addr, "admin server listening interface")
+	f.Uint64("port", DefaultManagerConfig.Port, "admin server listening port")
+	genericconf.HTTPServerTimeoutConfigAddOptions("server-timeouts", f)
+	f.String("auth-tokens-file", DefaultManagerConfig.AuthTokensFile, "file with one \"name:token\" pair per line, each token granting access to the admin api as name")
+	f.String("audit-log", DefaultManagerConfig.AuditLog, "file the audit trail of admin operations is appended to (required)")
+	f.String("signing-key", DefaultManagerConfig.SigningKey, "sequencer coordinator signing key used to invalidate messages (empty disables signing)")
+	f.Uint64("genesis-block-num", DefaultManagerConfig.GenesisBlockNum, "genesis block number of the chain, to report sequencer message counts from their block numbers")
+	f.Duration("sequencer-query-timeout", DefaultManagerConfig.SequencerQueryTimeout, "timeout querying a sequencer for its message count")
+	f.Int("log-level", DefaultManagerConfig.LogLevel, "log level; 1: ERROR, 2: WARN, 3: INFO, 4: DEBUG, 5: TRACE")
+	f.String("log-type", DefaultManagerConfig.LogType, "log type (plaintext or json)")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := confighelpers.BeginCommonParse(f, args)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	seqManager := &manager{
-		redisCoordinator: &rediscoordinator.RedisCoordinator{
-			RedisCoordinator: redisutilCoordinator,
-		},
-		prioritiesSet: make(map[string]bool),
-		livelinessSet: make(map[string]bool),
-		// maxURLSize dictates the allowed max length for sequencer urls
-		// urls exceeding this size will be truncated with an ellipsis
-		maxURLSize: 100,
+	var config ManagerConfig
+	if err := confighelpers.EndCommonParse(k, &config); err != nil {
+		return nil, err
 	}
-
-	seqManager.refreshAllLists(ctx)
-	seqManager.populateLists(ctx)
-
-	prioritySeqList.SetSelectedFunc(func(index int, name string, second_name string, shortcut rune) {
-		nonPriorityForm.Clear(true)
-
-		n := len(seqManager.priorityList)
-		priorities := make([]string, n)
-		for i := 0; i < n; i++ {
-			priorities[i] = strconv.Itoa(i)
-		}
-
-		target := index
-		priorityForm.Clear(true)
-		priorityForm.AddDropDown("Change priority to ->", priorities, index, func(priority string, selection int) {
-			target = selection
-		})
-		priorityForm.AddButton("Update", func() {
-			if target != index {
-				seqManager.updatePriorityList(ctx, index, target)
-			}
-			priorityForm.Clear(true)
-			seqManager.populateLists(ctx)
-			pages.SwitchToPage("Menu")
-			app.SetFocus(prioritySeqList)
+	if config.Conf.Dump {
+		err = confighelpers.DumpConfig(k, map[string]interface{}{
+			"signing-key": "",
 		})
-		priorityForm.AddButton("Cancel", func() {
-			priorityForm.Clear(true)
-			pages.SwitchToPage("Menu")
-			app.SetFocus(prioritySeqList)
-		})
-		priorityForm.AddButton("Remove", func() {
-			url := seqManager.priorityList[index]
-			delete(seqManager.prioritiesSet, url)
-			seqManager.updatePriorityList(ctx, index, 0)
-			seqManager.priorityList = seqManager.priorityList[1:]
-
-			priorityForm.Clear(true)
-			seqManager.populateLists(ctx)
-			pages.SwitchToPage("Menu")
-			app.SetFocus(prioritySeqList)
-		})
-		priorityForm.SetFocus(0)
-		app.SetFocus(priorityForm)
-	})
-
-	nonPrioritySeqList.SetSelectedFunc(func(index int, name string, second_name string, shortcut rune) {
-		priorityForm.Clear(true)
-
-		n := len(seqManager.priorityList)
-		priorities := make([]string, n+1)
-		for i := 0; i < n+1; i++ {
-			priorities[i] = strconv.Itoa(i)
+		if err != nil {
+			return nil, fmt.Errorf("error removing extra parameters before dump: %w", err)
 		}
 
-		target := index
-		nonPriorityForm.Clear(true)
-		nonPriorityForm.AddDropDown("Set priority to ->", priorities, index, func(priority string, selection int) {
-			target = selection
-		})
-		nonPriorityForm.AddButton("Update", func() {
-			key := seqManager.nonPriorityList[index]
-			seqManager.priorityList = append(seqManager.priorityList, key)
-			seqManager.prioritiesSet[key] = true
-
-			index = len(seqManager.priorityList) - 1
-			seqManager.updatePriorityList(ctx, index, target)
-
-			nonPriorityForm.Clear(true)
-			seqManager.populateLists(ctx)
-			pages.SwitchToPage("Menu")
-			if len(seqManager.nonPriorityList) > 0 {
-				app.SetFocus(nonPrioritySeqList)
-			} else {
-				app.SetFocus(prioritySeqList)
-			}
-		})
-		nonPriorityForm.AddButton("Cancel", func() {
-			nonPriorityForm.Clear(true)
-			pages.SwitchToPage("Menu")
-			app.SetFocus(nonPrioritySeqList)
-		})
-		nonPriorityForm.SetFocus(0)
-		app.SetFocus(nonPriorityForm)
-	})
-
-	// UI design
-	flex := tview.NewFlex()
-	priorityHeading := tview.NewTextView().
-		SetTextColor(tcell.ColorYellow).
-		SetText("-----Priority List-----")
-	nonPriorityHeading := tview.NewTextView().
-		SetTextColor(tcell.ColorYellow).
-		SetText("-----Not in priority list but online-----")
-	instructions := tview.NewTextView().
-		SetTextColor(tcell.ColorYellow).
-		SetText("(r) to refresh\n(s) to save all changes\n(c) to switch between lists\n(a) to add sequencer\n(q) to quit\n(tab) to navigate")
-
-	flex.SetDirection(tview.FlexRow).
-		AddItem(priorityHeading, 0, 1, false).
-		AddItem(tview.NewFlex().
-			// fixedSize is maxURLSize plus 20 characters to accomodate ellipsis, statuses and emojis
-			AddItem(prioritySeqList, seqManager.maxURLSize+20, 0, true).
-			AddItem(priorityForm, 0, 3, true), 0, 12, true).
-		AddItem(nonPriorityHeading, 0, 1, false).
-		AddItem(tview.NewFlex().
-			AddItem(nonPrioritySeqList, seqManager.maxURLSize+20, 0, true).
-			AddItem(nonPriorityForm, 0, 3, true), 0, 12, true).
-		AddItem(instructions, 0, 3, false).SetBorder(true)
-
-	flex.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Rune() == 114 {
-			seqManager.refreshAllLists(ctx)
-			priorityForm.Clear(true)
-			nonPriorityForm.Clear(true)
-			seqManager.populateLists(ctx)
-			pages.SwitchToPage("Menu")
-			app.SetFocus(prioritySeqList)
-		} else if event.Rune() == 115 {
-			seqManager.pushUpdates(ctx)
-			priorityForm.Clear(true)
-			nonPriorityForm.Clear(true)
-			seqManager.populateLists(ctx)
-			pages.SwitchToPage("Menu")
-			app.SetFocus(prioritySeqList)
-		} else if event.Rune() == 97 {
-			addSeqForm.Clear(true)
-			seqManager.addSeqPriorityForm(ctx)
-			pages.SwitchToPage("Add Sequencer")
-		} else if event.Rune() == 99 {
-			if prioritySeqList.HasFocus() || priorityForm.HasFocus() {
-				priorityForm.Clear(true)
-				app.SetFocus(nonPrioritySeqList)
-			} else {
-				nonPriorityForm.Clear(true)
-				app.SetFocus(prioritySeqList)
-			}
-		} else if event.Rune() == 113 {
-			app.Stop()
+		c, err := k.Marshal(koanfjson.Parser())
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal config file to JSON: %w", err)
 		}
-		return event
-	})
 
-	pages.AddPage("Menu", flex, true, true)
-	pages.AddPage("Add Sequencer", addSeqForm, true, false)
-
-	if err := app.SetRoot(pages, true).EnableMouse(true).Run(); err != nil {
-		panic(err)
+		fmt.Println(string(c))
+		os.Exit(0)
 	}
+	return &config, config.Validate()
 }
 
-// updatePriorityList updates the list by changing the position of seq present at `index` to target
-func (sm *manager) updatePriorityList(ctx context.Context, index int, target int) {
-	for i := index - 1; i >= target; i-- {
-		sm.priorityList[i], sm.priorityList[i+1] = sm.priorityList[i+1], sm.priorityList[i]
+func (c *ManagerConfig) Validate() error {
+	if c.CoordinationUrl == "" {
+		return errors.New("--coordination-url must be set")
+	}
+	if c.AuthTokensFile == "" {
+		return errors.New("--auth-tokens-file must be set, the admin api is never served unauthenticated")
 	}
-	for i := index + 1; i <= target; i++ {
-		sm.priorityList[i], sm.priorityList[i-1] = sm.priorityList[i-1], sm.priorityList[i]
+	if c.AuditLog == "" {
+		return errors.New("--audit-log must be set")
 	}
+	return nil
+}
 
-	urlList := []string{}
-	for url := range sm.livelinessSet {
-		if _, ok := sm.prioritiesSet[url]; !ok {
-			urlList = append(urlList, url)
-		}
+func printSampleUsage(progname string) {
+	fmt.Printf("\n")
+	fmt.Printf("Sample usage:                  %s --coordination-url=<redis url | raft member urls> --auth-tokens-file=<file> --audit-log=<file>\n", progname)
+}
+
+func main() {
+	if err := startup(); err != nil {
+		log.Error("Error running seq-coordinator-manager", "err", err)
+		os.Exit(1)
 	}
-	sm.nonPriorityList = urlList
 }
 
-// populateLists populates seq's in priority list and seq's that are online but not in priority
-func (sm *manager) populateLists(ctx context.Context) {
-	prioritySeqList.Clear()
-	chosen, err := sm.redisCoordinator.CurrentChosenSequencer(ctx)
+func startup() error {
+	config, err := parseManager(os.Args[1:])
 	if err != nil {
-		panic(err)
+		confighelpers.PrintErrorAndExit(err, printSampleUsage)
 	}
-	for index, seqURL := range sm.priorityList {
-		sec := ""
-		if seqURL == chosen {
-			sec = fmt.Sprintf(" %vchosen", emoji.LeftArrow)
-		}
-		status := fmt.Sprintf("(%d) %v ", index, emoji.RedCircle)
-		if _, ok := sm.livelinessSet[seqURL]; ok {
-			status = fmt.Sprintf("(%d) %v ", index, emoji.GreenCircle)
-		}
-		url := seqURL
-		if len(seqURL) > sm.maxURLSize {
-			url = seqURL[:sm.maxURLSize] + "..."
-		}
-		prioritySeqList.AddItem(status+url+sec, "", rune(0), nil).SetSecondaryTextColor(tcell.ColorPurple)
 
+	logFormat, err := genericconf.ParseLogType(config.LogType)
+	if err != nil {
+		flag.Usage()
+		return fmt.Errorf("error parsing log type: %w", err)
 	}
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, logFormat))
+	glogger.Verbosity(log.Lvl(config.LogLevel))
+	log.Root().SetHandler(glogger)
 
-	nonPrioritySeqList.Clear()
-	status := fmt.Sprintf("(-) %v ", emoji.GreenCircle)
-	for _, seqURL := range sm.nonPriorityList {
-		url := seqURL
-		if len(seqURL) > sm.maxURLSize {
-			url = seqURL[:sm.maxURLSize] + "..."
-		}
-		nonPrioritySeqList.AddItem(status+url, "", rune(0), nil)
+	tokens, err := readAuthTokens(config.AuthTokensFile)
+	if err != nil {
+		return err
 	}
-}
-
-// addSeqPriorityForm returns a form with fields to add a new sequencer to priority list
-func (sm *manager) addSeqPriorityForm(ctx context.Context) *tview.Form {
-	URL := ""
-	addSeqForm.AddInputField("Sequencer URL", "", 0, nil, func(url string) {
-		URL = url
-	})
-	addSeqForm.AddButton("Cancel", func() {
-		priorityForm.Clear(true)
-		sm.populateLists(ctx)
-		pages.SwitchToPage("Menu")
-	})
-	addSeqForm.AddButton("Add", func() {
-		// check if url is valid, i.e it doesnt already exist in the priority list
-		if _, ok := sm.prioritiesSet[URL]; !ok && URL != "" {
-			sm.prioritiesSet[URL] = true
-			sm.priorityList = append(sm.priorityList, URL)
-		}
-		sm.populateLists(ctx)
-		pages.SwitchToPage("Menu")
-	})
-	return addSeqForm
-}
-
-// pushUpdates pushes the local changes to the redis server
-func (sm *manager) pushUpdates(ctx context.Context) {
-	err := sm.redisCoordinator.UpdatePriorities(ctx, sm.priorityList)
+	audit, err := openAuditLog(config.AuditLog)
 	if err != nil {
-		log.Warn("Failed to push local changes to the priority list")
+		return err
 	}
-	sm.refreshAllLists(ctx)
-}
-
-// refreshAllLists gets the current status of all the lists displayed in the UI
-func (sm *manager) refreshAllLists(ctx context.Context) {
-	priorityList, err := sm.redisCoordinator.GetPriorities(ctx)
+	defer audit.Close()
+	store, err := coordination.NewClientStore(config.CoordinationUrl, config.RaftJWTSecret)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	sm.priorityList = priorityList
-	sm.prioritiesSet = getMapfromlist(priorityList)
+	defer store.Close()
 
-	livelinessList, err := sm.redisCoordinator.GetLiveliness(ctx)
+	admin := newAdminServer(config, store, tokens, audit)
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", config.Addr, config.Port))
 	if err != nil {
-		panic(err)
+		return err
 	}
-	sm.livelinessSet = getMapfromlist(livelinessList)
-
-	urlList := []string{}
-	for url := range sm.livelinessSet {
-		if _, ok := sm.prioritiesSet[url]; !ok {
-			urlList = append(urlList, url)
-		}
+	server := &http.Server{
+		Handler:           admin,
+		ReadTimeout:       config.ServerTimeouts.ReadTimeout,
+		ReadHeaderTimeout: config.ServerTimeouts.ReadHeaderTimeout,
+		WriteTimeout:      config.ServerTimeouts.WriteTimeout,
+		IdleTimeout:       config.ServerTimeouts.IdleTimeout,
 	}
-	sm.nonPriorityList = urlList
-}
-
-func getMapfromlist(list []string) map[string]bool {
-	mapping := make(map[string]bool)
-	for _, url := range list {
-		mapping[url] = true
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+	log.Info("sequencer coordinator admin server started", "addr", listener.Addr())
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-sigint:
+		log.Info("shutting down because of sigint")
+	case err := <-serverErr:
+		return err
 	}
-	return mapping
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
 }
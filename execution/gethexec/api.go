@@ -35,6 +35,23 @@ func (a *ArbAPI) CheckPublisherHealth(ctx context.Context) error {
 	return a.txPublisher.CheckHealth(ctx)
 }
 
+// SendBundle submits transactions to be sequenced consecutively in one block, or not at all.
+// It returns once the bundle was sequenced, with the hashes of its transactions.
+func (a *ArbAPI) SendBundle(ctx context.Context, args SendBundleArgs) ([]common.Hash, error) {
+	bundle, err := args.ToBundle()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.txPublisher.PublishBundle(ctx, bundle); err != nil {
+		return nil, err
+	}
+	hashes := make([]common.Hash, len(bundle.Txs))
+	for i, tx := range bundle.Txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes, nil
+}
+
 type ArbDebugAPI struct {
 	blockchain        *core.BlockChain
 	blockRangeBound   uint64
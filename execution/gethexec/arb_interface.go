@@ -13,6 +13,7 @@ import (
 
 type TransactionPublisher interface {
 	PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error
+	PublishBundle(ctx context.Context, bundle *TxBundle) error
 	CheckHealth(ctx context.Context) error
 	Initialize(context.Context) error
 	Start(context.Context) error
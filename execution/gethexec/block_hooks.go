@@ -0,0 +1,235 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package gethexec
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+)
+
+var (
+	blockHookTxsCounter     = metrics.NewRegisteredCounter("arb/sequencer/blockhooks/txs", nil)
+	blockHookErrorsCounter  = metrics.NewRegisteredCounter("arb/sequencer/blockhooks/errors", nil)
+	blockHookTimeoutCounter = metrics.NewRegisteredCounter("arb/sequencer/blockhooks/timeouts", nil)
+	blockHookSkippedCounter = metrics.NewRegisteredCounter("arb/sequencer/blockhooks/skipped", nil)
+)
+
+type BlockHooksConfig struct {
+	BeforeBlockTimeout time.Duration `koanf:"before-block-timeout" reload:"hot"`
+	AfterBlockTimeout  time.Duration `koanf:"after-block-timeout" reload:"hot"`
+}
+
+var DefaultBlockHooksConfig = BlockHooksConfig{
+	BeforeBlockTimeout: 50 * time.Millisecond,
+	AfterBlockTimeout:  100 * time.Millisecond,
+}
+
+var TestBlockHooksConfig = BlockHooksConfig{
+	BeforeBlockTimeout: time.Second,
+	AfterBlockTimeout:  time.Second,
+}
+
+func BlockHooksConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".before-block-timeout", DefaultBlockHooksConfig.BeforeBlockTimeout, "how long block production waits for each block hook to provide its transactions, before going on without them")
+	f.Duration(prefix+".after-block-timeout", DefaultBlockHooksConfig.AfterBlockTimeout, "how long block production waits for each block hook to observe a sequenced block")
+}
+
+func (c *BlockHooksConfig) Validate() error {
+	if c.BeforeBlockTimeout <= 0 || c.AfterBlockTimeout <= 0 {
+		return fmt.Errorf("sequencer block hook timeouts must be positive, got %v and %v", c.BeforeBlockTimeout, c.AfterBlockTimeout)
+	}
+	return nil
+}
+
+// NextBlockInfo describes the block the sequencer is about to produce.
+type NextBlockInfo struct {
+	Parent   *types.Header
+	L1Header *arbostypes.L1IncomingMessageHeader
+}
+
+// BlockHook is a sequencer plugin taking part in producing each block.
+// A call taking longer than its timeout is abandoned: block production goes on without it,
+// and the hook is skipped until that call returns.
+type BlockHook interface {
+	// BeforeBlock returns signed transactions to sequence at the start of the next block, ahead of the queued ones.
+	// It isn't called for blocks made of a bundle alone.
+	BeforeBlock(ctx context.Context, next *NextBlockInfo) ([]*types.Transaction, error)
+	// AfterBlock is called once the sequencer has produced a block, which is nil if none of its transactions succeeded.
+	// txErrs holds the results of the transactions the hook's BeforeBlock added to it, if any.
+	// It's also called if producing the block failed after BeforeBlock, with a nil block and that error as results.
+	AfterBlock(ctx context.Context, block *types.Block, txErrs []error)
+}
+
+type blockHookEntry struct {
+	name string
+	hook BlockHook
+	busy atomic.Bool
+}
+
+// blockHookTxs are the transactions a hook added to the block being produced.
+type blockHookTxs struct {
+	entry *blockHookEntry
+	txs   []*types.Transaction
+}
+
+type blockHooks struct {
+	mutex   sync.Mutex
+	entries []*blockHookEntry
+}
+
+func (h *blockHooks) list() []*blockHookEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.entries
+}
+
+// AddBlockHook registers hook with the sequencer, to be called for each block it produces from then on.
+func (s *Sequencer) AddBlockHook(name string, hook BlockHook) {
+	s.blockHooks.mutex.Lock()
+	defer s.blockHooks.mutex.Unlock()
+	entries := make([]*blockHookEntry, len(s.blockHooks.entries), len(s.blockHooks.entries)+1)
+	copy(entries, s.blockHooks.entries)
+	s.blockHooks.entries = append(entries, &blockHookEntry{name: name, hook: hook})
+}
+
+// call runs fn in the background, and waits for it to return for at most timeout.
+// It returns whether fn returned in time, fn must not be looked at otherwise.
+func (e *blockHookEntry) call(ctx context.Context, stage string, timeout time.Duration, fn func(ctx context.Context)) bool {
+	if !e.busy.CompareAndSwap(false, true) {
+		blockHookSkippedCounter.Inc(1)
+		log.Warn("sequencer block hook is still busy with an earlier call, skipping it", "hook", e.name, "stage", stage)
+		return false
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	done := make(chan struct{})
+	go func() {
+		// done is closed once the hook may be called again, and before the context is canceled
+		defer cancel()
+		defer close(done)
+		defer e.busy.Store(false)
+		defer func() {
+			if panicErr := recover(); panicErr != nil {
+				blockHookErrorsCounter.Inc(1)
+				log.Error("sequencer block hook panicked", "hook", e.name, "stage", stage, "panic", panicErr, "backtrace", string(debug.Stack()))
+			}
+		}()
+		fn(hookCtx)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-hookCtx.Done():
+		select {
+		case <-done:
+			// finished right as it ran out of time
+			return true
+		default:
+		}
+		blockHookTimeoutCounter.Inc(1)
+		log.Warn("sequencer block hook timed out", "hook", e.name, "stage", stage, "timeout", timeout)
+		return false
+	}
+}
+
+// beforeBlockHooks collects the transactions of each hook for the next block.
+// Hooks are called concurrently, their transactions are sequenced in the order the hooks were added.
+func (s *Sequencer) beforeBlockHooks(ctx context.Context, config *SequencerConfig, l1Header *arbostypes.L1IncomingMessageHeader) []blockHookTxs {
+	entries := s.blockHooks.list()
+	if len(entries) == 0 {
+		return nil
+	}
+	next := &NextBlockInfo{
+		Parent:   s.execEngine.bc.CurrentBlock(),
+		L1Header: l1Header,
+	}
+	results := make([]blockHookTxs, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *blockHookEntry) {
+			defer wg.Done()
+			var txs []*types.Transaction
+			var err error
+			if !entry.call(ctx, "BeforeBlock", config.BlockHooks.BeforeBlockTimeout, func(ctx context.Context) {
+				txs, err = entry.hook.BeforeBlock(ctx, next)
+			}) {
+				return
+			}
+			if err != nil {
+				blockHookErrorsCounter.Inc(1)
+				log.Warn("sequencer block hook failed to provide its transactions", "hook", entry.name, "err", err)
+				return
+			}
+			results[i] = blockHookTxs{entry: entry, txs: txs}
+		}(i, entry)
+	}
+	wg.Wait()
+
+	var hookTxs []blockHookTxs
+	size := 0
+	for _, result := range results {
+		if len(result.txs) == 0 {
+			continue
+		}
+		resultSize := 0
+		for _, tx := range result.txs {
+			resultSize += int(tx.Size())
+		}
+		if size+resultSize > config.MaxTxDataSize {
+			blockHookErrorsCounter.Inc(1)
+			log.Warn("sequencer block hook transactions don't fit in a block", "hook", result.entry.name, "txs", len(result.txs), "size", resultSize)
+			continue
+		}
+		size += resultSize
+		blockHookTxsCounter.Inc(int64(len(result.txs)))
+		hookTxs = append(hookTxs, result)
+	}
+	return hookTxs
+}
+
+// afterBlockHooks reports a produced block to every hook, along with the results of the transactions each added to it.
+// txErrs holds the results of all the hooks' transactions, in the order of hookTxs.
+func (s *Sequencer) afterBlockHooks(ctx context.Context, config *SequencerConfig, block *types.Block, hookTxs []blockHookTxs, txErrs []error) {
+	entries := s.blockHooks.list()
+	if len(entries) == 0 {
+		return
+	}
+	errsByHook := make(map[*blockHookEntry][]error)
+	for _, result := range hookTxs {
+		errsByHook[result.entry] = txErrs[:len(result.txs)]
+		txErrs = txErrs[len(result.txs):]
+	}
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry *blockHookEntry) {
+			defer wg.Done()
+			errs := errsByHook[entry]
+			entry.call(ctx, "AfterBlock", config.BlockHooks.AfterBlockTimeout, func(ctx context.Context) {
+				entry.hook.AfterBlock(ctx, block, errs)
+			})
+		}(entry)
+	}
+	wg.Wait()
+}
+
+func countBlockHookTxs(hookTxs []blockHookTxs) int {
+	count := 0
+	for _, result := range hookTxs {
+		count += len(result.txs)
+	}
+	return count
+}
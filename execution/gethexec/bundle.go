@@ -0,0 +1,411 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package gethexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/arbitrum"
+	"github.com/ethereum/go-ethereum/arbitrum_types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/arbos/arbosState"
+	"github.com/yingdianRao/nitro/execution"
+)
+
+var (
+	bundleSequencedCounter = metrics.NewRegisteredCounter("arb/sequencer/bundle/sequenced", nil)
+	bundleRejectedCounter  = metrics.NewRegisteredCounter("arb/sequencer/bundle/rejected", nil)
+)
+
+var ErrBundlesDisabled = errors.New("transaction bundles are not enabled")
+
+type BundleConfig struct {
+	Enable    bool `koanf:"enable" reload:"hot"`
+	MaxTxs    int  `koanf:"max-txs" reload:"hot"`
+	QueueSize int  `koanf:"queue-size"`
+}
+
+var DefaultBundleConfig = BundleConfig{
+	Enable:    false,
+	MaxTxs:    16,
+	QueueSize: 64,
+}
+
+var TestBundleConfig = BundleConfig{
+	Enable:    true,
+	MaxTxs:    16,
+	QueueSize: 16,
+}
+
+func BundleConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBundleConfig.Enable, "accept arb_sendBundle bundles, which are sequenced consecutively in a block of their own or not at all")
+	f.Int(prefix+".max-txs", DefaultBundleConfig.MaxTxs, "maximum number of transactions in a bundle")
+	f.Int(prefix+".queue-size", DefaultBundleConfig.QueueSize, "size of the pending bundle queue")
+}
+
+func (c *BundleConfig) Validate() error {
+	if c.MaxTxs < 1 {
+		return fmt.Errorf("sequencer bundle max txs %v must be at least 1", c.MaxTxs)
+	}
+	if c.QueueSize < 1 {
+		return fmt.Errorf("sequencer bundle queue size %v must be at least 1", c.QueueSize)
+	}
+	return nil
+}
+
+// TxBundle is an ordered list of transactions the sequencer includes consecutively in one block, or not at all.
+type TxBundle struct {
+	Txs []*types.Transaction
+	// RevertingTxHashes are the transactions allowed to revert without failing the whole bundle.
+	RevertingTxHashes []common.Hash
+	// Options are the conditions every transaction of the bundle is checked against.
+	Options *arbitrum_types.ConditionalOptions
+}
+
+// SendBundleArgs are the arguments of arb_sendBundle.
+type SendBundleArgs struct {
+	Txs               []hexutil.Bytes                    `json:"txs"`
+	RevertingTxHashes []common.Hash                      `json:"revertingTxHashes,omitempty"`
+	Options           *arbitrum_types.ConditionalOptions `json:"options,omitempty"`
+}
+
+func (a *SendBundleArgs) ToBundle() (*TxBundle, error) {
+	bundle := &TxBundle{
+		Txs:               make([]*types.Transaction, len(a.Txs)),
+		RevertingTxHashes: a.RevertingTxHashes,
+		Options:           a.Options,
+	}
+	for i, data := range a.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("failed to decode bundle transaction %v: %w", i, err)
+		}
+		bundle.Txs[i] = tx
+	}
+	return bundle, nil
+}
+
+func (b *TxBundle) ToArgs() (*SendBundleArgs, error) {
+	args := &SendBundleArgs{
+		Txs:               make([]hexutil.Bytes, len(b.Txs)),
+		RevertingTxHashes: b.RevertingTxHashes,
+		Options:           b.Options,
+	}
+	for i, tx := range b.Txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		args.Txs[i] = data
+	}
+	return args, nil
+}
+
+// BundleTxError tells which transaction of a bundle kept it from being sequenced.
+type BundleTxError struct {
+	Index  int
+	TxHash common.Hash
+	Err    error
+}
+
+func (e *BundleTxError) Error() string {
+	return fmt.Sprintf("bundle transaction %v (%v) failed: %v", e.Index, e.TxHash, e.Err)
+}
+
+func (e *BundleTxError) Unwrap() error {
+	return e.Err
+}
+
+type bundleQueueItem struct {
+	bundle          *TxBundle
+	resultChan      chan<- error
+	returnedResult  bool
+	ctx             context.Context
+	firstAppearance time.Time
+}
+
+func (i *bundleQueueItem) returnResult(err error) {
+	if i.returnedResult {
+		log.Error("attempting to return result to already finished bundle queue item", "err", err)
+		return
+	}
+	i.returnedResult = true
+	i.resultChan <- err
+	close(i.resultChan)
+}
+
+func (s *Sequencer) PublishBundle(parentCtx context.Context, bundle *TxBundle) error {
+	config := s.config()
+	if !config.Bundle.Enable {
+		return ErrBundlesDisabled
+	}
+	if len(bundle.Txs) == 0 {
+		return errors.New("empty bundle")
+	}
+	if len(bundle.Txs) > config.Bundle.MaxTxs {
+		return fmt.Errorf("bundle of %v transactions exceeds the maximum of %v", len(bundle.Txs), config.Bundle.MaxTxs)
+	}
+	sequencerBacklogGauge.Inc(int64(len(bundle.Txs)))
+	defer sequencerBacklogGauge.Dec(int64(len(bundle.Txs)))
+
+	for _, tx := range bundle.Txs {
+		release, err := s.rateLimiter.Acquire(parentCtx, tx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	_, forwarder := s.GetPauseAndForwarder()
+	if forwarder != nil {
+		err := forwarder.PublishBundle(parentCtx, bundle)
+		if !errors.Is(err, ErrNoSequencer) {
+			return err
+		}
+	}
+
+	signer := types.LatestSigner(s.execEngine.bc.Config())
+	for i, tx := range bundle.Txs {
+		if len(s.senderWhitelist) > 0 {
+			sender, err := types.Sender(signer, tx)
+			if err != nil {
+				return &BundleTxError{i, tx.Hash(), err}
+			}
+			if _, authorized := s.senderWhitelist[sender]; !authorized {
+				return &BundleTxError{i, tx.Hash(), errors.New("transaction sender is not on the whitelist")}
+			}
+		}
+		if tx.Type() >= types.ArbitrumDepositTxType || tx.Type() == types.BlobTxType {
+			return &BundleTxError{i, tx.Hash(), types.ErrTxTypeNotSupported}
+		}
+	}
+
+	queueTimeout := config.QueueTimeout
+	queueCtx, cancelFunc := ctxWithTimeout(parentCtx, queueTimeout)
+	defer cancelFunc()
+
+	// Just to be safe, make sure we don't run over twice the queue timeout
+	abortCtx, cancel := ctxWithTimeout(parentCtx, queueTimeout*2)
+	defer cancel()
+
+	resultChan := make(chan error, 1)
+	queueItem := bundleQueueItem{
+		bundle:          bundle,
+		resultChan:      resultChan,
+		ctx:             queueCtx,
+		firstAppearance: time.Now(),
+	}
+	select {
+	case s.bundleQueue <- queueItem:
+	case <-queueCtx.Done():
+		return queueCtx.Err()
+	}
+
+	select {
+	case res := <-resultChan:
+		return res
+	case <-abortCtx.Done():
+		return abortCtx.Err()
+	}
+}
+
+func (s *Sequencer) nextBundle() (bundleQueueItem, bool) {
+	if s.bundleRetries.Len() > 0 {
+		return s.bundleRetries.Pop(), true
+	}
+	select {
+	case item := <-s.bundleQueue:
+		return item, true
+	default:
+		return bundleQueueItem{}, false
+	}
+}
+
+// handleInactiveBundle is handleInactive for bundles.
+func (s *Sequencer) handleInactiveBundle(ctx context.Context, item bundleQueueItem, forwards *sync.WaitGroup) bool {
+	var forwarder *TxForwarder
+	for {
+		var pause chan struct{}
+		pause, forwarder = s.GetPauseAndForwarder()
+		if pause == nil {
+			if forwarder == nil {
+				return false
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			s.bundleRetries.Push(item)
+			return true
+		case <-pause:
+		}
+	}
+	if forwards != nil {
+		forwards.Add(1)
+	}
+	go func() {
+		if forwards != nil {
+			defer forwards.Done()
+		}
+		err := forwarder.PublishBundle(item.ctx, item.bundle)
+		if !errors.Is(err, ErrNoSequencer) {
+			item.returnResult(err)
+			return
+		}
+		select {
+		case s.bundleQueue <- item:
+		case <-item.ctx.Done():
+			item.returnResult(item.ctx.Err())
+		}
+	}()
+	return true
+}
+
+func (s *Sequencer) bundlePostTxFilter(bundle *TxBundle) func(*types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, common.Address, uint64, *core.ExecutionResult) error {
+	allowedReverts := make(map[common.Hash]struct{}, len(bundle.RevertingTxHashes))
+	for _, hash := range bundle.RevertingTxHashes {
+		allowedReverts[hash] = struct{}{}
+	}
+	return func(header *types.Header, statedb *state.StateDB, _ *arbosState.ArbosState, tx *types.Transaction, sender common.Address, _ uint64, result *core.ExecutionResult) error {
+		if err := s.txFilter.CheckPostExecution(tx, sender, statedb); err != nil {
+			return err
+		}
+		if result.Err != nil {
+			if _, allowed := allowedReverts[tx.Hash()]; !allowed {
+				return arbitrum.NewRevertReason(result)
+			}
+		}
+		s.onTxAccepted(header, tx, sender)
+		return nil
+	}
+}
+
+// createBundleBlock sequences a bundle in a block of its own, so that it's included consecutively or not at all.
+func (s *Sequencer) createBundleBlock(ctx context.Context, config *SequencerConfig, item bundleQueueItem) bool {
+	if err := item.ctx.Err(); err != nil {
+		item.returnResult(err)
+		return false
+	}
+	bundle := item.bundle
+	totalSize := 0
+	for i, tx := range bundle.Txs {
+		txBytes, err := tx.MarshalBinary()
+		if err != nil {
+			item.returnResult(&BundleTxError{i, tx.Hash(), err})
+			return false
+		}
+		totalSize += len(txBytes)
+	}
+	if totalSize > config.MaxTxDataSize {
+		item.returnResult(txpool.ErrOversizedData)
+		return false
+	}
+
+	if s.handleInactiveBundle(ctx, item, nil) {
+		return false
+	}
+
+	header := s.nextBlockHeader(config)
+	if header == nil {
+		s.bundleRetries.Push(item)
+		return false
+	}
+
+	hooks := s.makeSequencingHooks()
+	hooks.PostTxFilter = s.bundlePostTxFilter(bundle)
+	hooks.ConditionalOptionsForTx = make([]*arbitrum_types.ConditionalOptions, len(bundle.Txs))
+	for i := range bundle.Txs {
+		hooks.ConditionalOptionsForTx[i] = bundle.Options
+	}
+
+	s.nonceCache.Resize(config.NonceCacheSize)
+	s.nonceCache.BeginNewBlock()
+	start := time.Now()
+	block, err := s.execEngine.SequenceBundle(header, bundle.Txs, hooks)
+	blockCreationTimer.Update(time.Since(start))
+	if errors.Is(err, execution.ErrRetrySequencer) {
+		log.Warn("error sequencing bundle", "err", err)
+		if !s.handleInactiveBundle(ctx, item, nil) {
+			s.bundleRetries.Push(item)
+		}
+		return false
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			s.bundleRetries.Push(item)
+			return true
+		}
+		log.Error("error sequencing bundle", "err", err)
+		item.returnResult(err)
+		return false
+	}
+
+	sequencedTxs := s.newSequencedTxsCollector(block)
+	if block == nil {
+		bundleRejectedCounter.Inc(1)
+		err = errors.New("bundle produced no block")
+		for i, txErr := range hooks.TxErrors {
+			if txErr != nil {
+				err = &BundleTxError{i, bundle.Txs[i].Hash(), txErr}
+				break
+			}
+		}
+		for _, tx := range bundle.Txs {
+			sequencedTxs.add(tx, err)
+		}
+		if sequencedTxs != nil {
+			s.sequencedTxs.send(sequencedTxs.txs)
+		}
+		item.returnResult(err)
+		s.afterBlockHooks(ctx, config, nil, nil, nil)
+		return false
+	}
+
+	successfulBlocksCounter.Inc(1)
+	bundleSequencedCounter.Inc(1)
+	s.nonceCache.Finalize(block)
+	for _, tx := range bundle.Txs {
+		sequencedTxs.add(tx, nil)
+	}
+	if sequencedTxs != nil {
+		s.sequencedTxs.send(sequencedTxs.txs)
+	}
+	item.returnResult(nil)
+	s.afterBlockHooks(ctx, config, block, nil, nil)
+	return true
+}
+
+// drainBundles forwards the bundles still queued when shutting down, or fails them if there's nowhere to forward them to.
+func (s *Sequencer) drainBundles() {
+	_, forwarder := s.GetPauseAndForwarder()
+	for {
+		item, ok := s.nextBundle()
+		if !ok {
+			return
+		}
+		if forwarder == nil {
+			item.returnResult(ErrNoSequencer)
+			continue
+		}
+		err := forwarder.PublishBundle(item.ctx, item.bundle)
+		if err != nil {
+			log.Warn("failed to forward bundle while shutting down", "err", err)
+		}
+		item.returnResult(err)
+	}
+}
@@ -247,7 +247,7 @@ func (s *ExecutionEngine) resequenceReorgedMessages(messages []*arbostypes.Messa
 		}
 		hooks := arbos.NoopSequencingHooks()
 		hooks.DiscardInvalidTxsEarly = true
-		_, err = s.sequenceTransactionsWithBlockMutex(msg.Message.Header, txes, hooks)
+		_, err = s.sequenceTransactionsWithBlockMutex(msg.Message.Header, txes, hooks, false)
 		if err != nil {
 			log.Error("failed to re-sequence old user message removed by reorg", "err", err)
 			return
@@ -287,11 +287,19 @@ func (s *ExecutionEngine) sequencerWrapper(sequencerFunc func() (*types.Block, e
 func (s *ExecutionEngine) SequenceTransactions(header *arbostypes.L1IncomingMessageHeader, txes types.Transactions, hooks *arbos.SequencingHooks) (*types.Block, error) {
 	return s.sequencerWrapper(func() (*types.Block, error) {
 		hooks.TxErrors = nil
-		return s.sequenceTransactionsWithBlockMutex(header, txes, hooks)
+		return s.sequenceTransactionsWithBlockMutex(header, txes, hooks, false)
 	})
 }
 
-func (s *ExecutionEngine) sequenceTransactionsWithBlockMutex(header *arbostypes.L1IncomingMessageHeader, txes types.Transactions, hooks *arbos.SequencingHooks) (*types.Block, error) {
+// SequenceBundle is like SequenceTransactions, except that no block is produced unless every transaction succeeds.
+func (s *ExecutionEngine) SequenceBundle(header *arbostypes.L1IncomingMessageHeader, txes types.Transactions, hooks *arbos.SequencingHooks) (*types.Block, error) {
+	return s.sequencerWrapper(func() (*types.Block, error) {
+		hooks.TxErrors = nil
+		return s.sequenceTransactionsWithBlockMutex(header, txes, hooks, true)
+	})
+}
+
+func (s *ExecutionEngine) sequenceTransactionsWithBlockMutex(header *arbostypes.L1IncomingMessageHeader, txes types.Transactions, hooks *arbos.SequencingHooks, allOrNothing bool) (*types.Block, error) {
 	lastBlockHeader, err := s.getCurrentHeader()
 	if err != nil {
 		return nil, err
@@ -328,13 +336,15 @@ func (s *ExecutionEngine) sequenceTransactionsWithBlockMutex(header *arbostypes.
 	}
 
 	allTxsErrored := true
+	anyTxErrored := false
 	for _, err := range hooks.TxErrors {
 		if err == nil {
 			allTxsErrored = false
-			break
+		} else {
+			anyTxErrored = true
 		}
 	}
-	if allTxsErrored {
+	if allTxsErrored || (allOrNothing && anyTxErrored) {
 		return nil, nil
 	}
 
@@ -15,7 +15,7 @@ import (
 	"time"
 
 	flag "github.com/spf13/pflag"
-	"github.com/yingdianRao/nitro/util/redisutil"
+	"github.com/yingdianRao/nitro/util/coordination"
 	"github.com/yingdianRao/nitro/util/stopwaiter"
 
 	"github.com/ethereum/go-ethereum/arbitrum"
@@ -31,6 +31,8 @@ type ForwarderConfig struct {
 	IdleConnectionTimeout time.Duration `koanf:"idle-connection-timeout"`
 	MaxIdleConnections    int           `koanf:"max-idle-connections"`
 	RedisUrl              string        `koanf:"redis-url"`
+	RaftPeers             []string      `koanf:"raft-peers"`
+	RaftJWTSecret         string        `koanf:"raft-jwtsecret"`
 	UpdateInterval        time.Duration `koanf:"update-interval"`
 	RetryInterval         time.Duration `koanf:"retry-interval"`
 }
@@ -40,6 +42,8 @@ var DefaultTestForwarderConfig = ForwarderConfig{
 	IdleConnectionTimeout: 2 * time.Second,
 	MaxIdleConnections:    1,
 	RedisUrl:              "",
+	RaftPeers:             []string{},
+	RaftJWTSecret:         "",
 	UpdateInterval:        time.Millisecond * 10,
 	RetryInterval:         time.Millisecond * 3,
 }
@@ -49,6 +53,8 @@ var DefaultNodeForwarderConfig = ForwarderConfig{
 	IdleConnectionTimeout: 15 * time.Second,
 	MaxIdleConnections:    1,
 	RedisUrl:              "",
+	RaftPeers:             []string{},
+	RaftJWTSecret:         "",
 	UpdateInterval:        time.Second,
 	RetryInterval:         100 * time.Millisecond,
 }
@@ -58,6 +64,8 @@ var DefaultSequencerForwarderConfig = ForwarderConfig{
 	IdleConnectionTimeout: 60 * time.Second,
 	MaxIdleConnections:    100,
 	RedisUrl:              "",
+	RaftPeers:             []string{},
+	RaftJWTSecret:         "",
 	UpdateInterval:        time.Second,
 	RetryInterval:         100 * time.Millisecond,
 }
@@ -75,6 +83,8 @@ func AddOptionsForForwarderConfigImpl(prefix string, defaultConfig *ForwarderCon
 	f.Duration(prefix+".idle-connection-timeout", defaultConfig.IdleConnectionTimeout, "time until idle connections are closed")
 	f.Int(prefix+".max-idle-connections", defaultConfig.MaxIdleConnections, "maximum number of idle connections to keep open")
 	f.String(prefix+".redis-url", defaultConfig.RedisUrl, "the Redis URL to recomend target via")
+	f.StringSlice(prefix+".raft-peers", defaultConfig.RaftPeers, "the URLs of the sequencers' Raft coordination group members to recommend target via, if not using redis-url")
+	f.String(prefix+".raft-jwtsecret", defaultConfig.RaftJWTSecret, "path to the file with the jwt secret of the sequencers' Raft coordination group")
 	f.Duration(prefix+".update-interval", defaultConfig.UpdateInterval, "forwarding target update interval")
 	f.Duration(prefix+".retry-interval", defaultConfig.RetryInterval, "minimal time between update retries")
 }
@@ -94,6 +104,7 @@ type TxForwarder struct {
 	rpcClients            []*rpc.Client
 	ethClients            []*ethclient.Client
 	tryNewForwarderErrors *regexp.Regexp
+	rateLimiter           *TxRateLimiter
 }
 
 func NewForwarder(targets []string, config *ForwarderConfig) *TxForwarder {
@@ -135,10 +146,22 @@ func (f *TxForwarder) ctxWithTimeout() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(f.ctx, f.timeout)
 }
 
+// SetRateLimiter makes the forwarder apply rateLimiter before forwarding a transaction.
+func (f *TxForwarder) SetRateLimiter(rateLimiter *TxRateLimiter) {
+	f.rateLimiter = rateLimiter
+}
+
 func (f *TxForwarder) PublishTransaction(inctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error {
 	if !f.enabled.Load() {
 		return ErrNoSequencer
 	}
+	if f.rateLimiter != nil {
+		release, err := f.rateLimiter.Acquire(inctx, tx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
 	ctx, cancelFunc := f.ctxWithTimeout()
 	defer cancelFunc()
 	for pos, rpcClient := range f.rpcClients {
@@ -156,6 +179,35 @@ func (f *TxForwarder) PublishTransaction(inctx context.Context, tx *types.Transa
 	return errors.New("failed to publish transaction to any of the forwarding targets")
 }
 
+func (f *TxForwarder) PublishBundle(inctx context.Context, bundle *TxBundle) error {
+	if !f.enabled.Load() {
+		return ErrNoSequencer
+	}
+	if f.rateLimiter != nil {
+		for _, tx := range bundle.Txs {
+			release, err := f.rateLimiter.Acquire(inctx, tx)
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+	}
+	args, err := bundle.ToArgs()
+	if err != nil {
+		return err
+	}
+	ctx, cancelFunc := f.ctxWithTimeout()
+	defer cancelFunc()
+	for pos, rpcClient := range f.rpcClients {
+		err := rpcClient.CallContext(ctx, nil, "arb_sendBundle", args)
+		if err == nil || !f.tryNewForwarderErrors.MatchString(err.Error()) {
+			return err
+		}
+		log.Warn("error forwarding bundle to a backup target", "target", f.targets[pos], "err", err)
+	}
+	return errors.New("failed to publish bundle to any of the forwarding targets")
+}
+
 const cacheUpstreamHealth = 2 * time.Second
 const maxHealthTimeout = 10 * time.Second
 
@@ -252,6 +304,10 @@ func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transactio
 	return txDropperErr
 }
 
+func (f *TxDropper) PublishBundle(ctx context.Context, bundle *TxBundle) error {
+	return txDropperErr
+}
+
 func (f *TxDropper) CheckHealth(ctx context.Context) error {
 	return txDropperErr
 }
@@ -274,10 +330,12 @@ type RedisTxForwarder struct {
 
 	errors           int
 	currentTarget    string
-	redisCoordinator *redisutil.RedisCoordinator
+	redisCoordinator *coordination.Coordinator
 
 	mtx       sync.RWMutex
 	forwarder *TxForwarder
+
+	rateLimiter *TxRateLimiter
 }
 
 func NewRedisTxForwarder(fallbackTarget string, config *ForwarderConfig) *RedisTxForwarder {
@@ -287,6 +345,12 @@ func NewRedisTxForwarder(fallbackTarget string, config *ForwarderConfig) *RedisT
 	}
 }
 
+// SetRateLimiter makes every forwarder created by f apply rateLimiter.
+// It must be called before f is started.
+func (f *RedisTxForwarder) SetRateLimiter(rateLimiter *TxRateLimiter) {
+	f.rateLimiter = rateLimiter
+}
+
 func (f *RedisTxForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error {
 	forwarder := f.getForwarder()
 	if forwarder == nil {
@@ -295,6 +359,14 @@ func (f *RedisTxForwarder) PublishTransaction(ctx context.Context, tx *types.Tra
 	return forwarder.PublishTransaction(ctx, tx, options)
 }
 
+func (f *RedisTxForwarder) PublishBundle(ctx context.Context, bundle *TxBundle) error {
+	forwarder := f.getForwarder()
+	if forwarder == nil {
+		return ErrNoSequencer
+	}
+	return forwarder.PublishBundle(ctx, bundle)
+}
+
 func (f *RedisTxForwarder) CheckHealth(ctx context.Context) error {
 	forwarder := f.getForwarder()
 	if forwarder == nil {
@@ -306,10 +378,16 @@ func (f *RedisTxForwarder) CheckHealth(ctx context.Context) error {
 // not thread safe vs update and itself
 func (f *RedisTxForwarder) Initialize(ctx context.Context) error {
 	var err error
-	f.redisCoordinator, err = redisutil.NewRedisCoordinator(f.config.RedisUrl)
+	var store coordination.Store
+	if f.config.RedisUrl != "" {
+		store, err = coordination.NewRedisStore(f.config.RedisUrl)
+	} else {
+		store, err = coordination.NewRaftClient(f.config.RaftPeers, f.config.RaftJWTSecret)
+	}
 	if err != nil {
-		return fmt.Errorf("unable to create redis coordinator: %w", err)
+		return fmt.Errorf("unable to create coordinator: %w", err)
 	}
+	f.redisCoordinator = coordination.NewCoordinator(store)
 	f.update(ctx)
 	return nil
 }
@@ -386,6 +464,7 @@ func (f *RedisTxForwarder) update(ctx context.Context) time.Duration {
 	var newForwarder *TxForwarder
 	for {
 		newForwarder = NewForwarder([]string{newSequencerUrl}, f.config)
+		newForwarder.SetRateLimiter(f.rateLimiter)
 		err := newForwarder.Initialize(ctx)
 		if err == nil {
 			break
@@ -179,13 +179,19 @@ func CreateExecutionNode(
 		}
 		txPublisher = sequencer
 	} else {
-		if config.Forwarder.RedisUrl != "" {
-			txPublisher = NewRedisTxForwarder(config.forwardingTarget, &config.Forwarder)
+		// non-sequencer nodes apply the sequencer's rate limits before forwarding
+		rateLimiter := NewTxRateLimiter(l2BlockChain.Config(), func() *TxRateLimitConfig { return &configFetcher().Sequencer.RateLimit })
+		if config.Forwarder.RedisUrl != "" || len(config.Forwarder.RaftPeers) > 0 {
+			forwarder := NewRedisTxForwarder(config.forwardingTarget, &config.Forwarder)
+			forwarder.SetRateLimiter(rateLimiter)
+			txPublisher = forwarder
 		} else if config.forwardingTarget == "" {
 			txPublisher = NewTxDropper()
 		} else {
 			targets := append([]string{config.forwardingTarget}, config.SecondaryForwardingTarget...)
-			txPublisher = NewForwarder(targets, &config.Forwarder)
+			forwarder := NewForwarder(targets, &config.Forwarder)
+			forwarder.SetRateLimiter(rateLimiter)
+			txPublisher = forwarder
 		}
 	}
 
@@ -211,6 +217,14 @@ func CreateExecutionNode(
 		Service:   NewArbAPI(txPublisher),
 		Public:    false,
 	}}
+	if sequencer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   NewArbSequencerAPI(sequencer),
+			Public:    false,
+		})
+	}
 	apis = append(apis, rpc.API{
 		Namespace: "arbdebug",
 		Version:   "1.0",
@@ -373,6 +387,13 @@ func (n *ExecutionNode) ForwardTo(url string) error {
 		return errors.New("forwardTo not supported - sequencer not active")
 	}
 }
+func (n *ExecutionNode) ForwardQueue(ctx context.Context) error {
+	if n.Sequencer != nil {
+		return n.Sequencer.ForwardQueue(ctx)
+	} else {
+		return errors.New("forwardQueue not supported - sequencer not active")
+	}
+}
 func (n *ExecutionNode) SetTransactionStreamer(streamer execution.TransactionStreamer) {
 	n.ExecEngine.SetTransactionStreamer(streamer)
 }
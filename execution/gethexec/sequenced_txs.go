@@ -0,0 +1,169 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package gethexec
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var sequencedTxsDroppedCounter = metrics.NewRegisteredCounter("arb/sequencer/sequencedtxs/dropped", nil)
+
+// SequencedTransaction is the soft confirmation pushed to subscribers once the sequencer decided on a transaction.
+// Either the block fields and the receipt are set, or Error holds why the transaction was rejected.
+type SequencedTransaction struct {
+	TxHash           common.Hash    `json:"transactionHash"`
+	BlockNumber      *hexutil.Big   `json:"blockNumber,omitempty"`
+	BlockHash        *common.Hash   `json:"blockHash,omitempty"`
+	TransactionIndex *hexutil.Uint  `json:"transactionIndex,omitempty"`
+	Receipt          *types.Receipt `json:"receipt,omitempty"`
+	Error            string         `json:"error,omitempty"`
+}
+
+// sequencedTxFeed fans out the outcome of each sequenced block to subscribers.
+// Sending never blocks the sequencer: a subscriber too slow to keep up misses notifications.
+type sequencedTxFeed struct {
+	mutex       sync.Mutex
+	subscribers map[chan<- []SequencedTransaction]struct{}
+}
+
+func (f *sequencedTxFeed) hasSubscribers() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.subscribers) > 0
+}
+
+func (f *sequencedTxFeed) subscribe(ch chan<- []SequencedTransaction) func() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan<- []SequencedTransaction]struct{})
+	}
+	f.subscribers[ch] = struct{}{}
+	return func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		delete(f.subscribers, ch)
+	}
+}
+
+func (f *sequencedTxFeed) send(txs []SequencedTransaction) {
+	if len(txs) == 0 {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- txs:
+		default:
+			sequencedTxsDroppedCounter.Inc(1)
+		}
+	}
+}
+
+// sequencedTxsCollector builds the notifications for one sequenced block.
+type sequencedTxsCollector struct {
+	block    *types.Block
+	receipts map[common.Hash]*types.Receipt
+	txs      []SequencedTransaction
+}
+
+func (s *Sequencer) newSequencedTxsCollector(block *types.Block) *sequencedTxsCollector {
+	if !s.sequencedTxs.hasSubscribers() {
+		return nil
+	}
+	collector := &sequencedTxsCollector{block: block}
+	if block != nil {
+		receipts := s.execEngine.bc.GetReceiptsByHash(block.Hash())
+		collector.receipts = make(map[common.Hash]*types.Receipt, len(receipts))
+		for _, receipt := range receipts {
+			collector.receipts[receipt.TxHash] = receipt
+		}
+	}
+	return collector
+}
+
+// add records the final outcome of tx. It's a no-op on a nil collector.
+func (c *sequencedTxsCollector) add(tx *types.Transaction, err error) {
+	if c == nil {
+		return
+	}
+	sequenced := SequencedTransaction{TxHash: tx.Hash()}
+	receipt, included := c.receipts[tx.Hash()]
+	if err == nil && included {
+		blockHash := c.block.Hash()
+		index := hexutil.Uint(receipt.TransactionIndex)
+		sequenced.BlockNumber = (*hexutil.Big)(c.block.Number())
+		sequenced.BlockHash = &blockHash
+		sequenced.TransactionIndex = &index
+		sequenced.Receipt = receipt
+	} else if err != nil {
+		sequenced.Error = err.Error()
+	} else {
+		return
+	}
+	c.txs = append(c.txs, sequenced)
+}
+
+// ArbSequencerAPI holds the arb namespace methods only served by sequencers.
+type ArbSequencerAPI struct {
+	sequencer *Sequencer
+}
+
+func NewArbSequencerAPI(sequencer *Sequencer) *ArbSequencerAPI {
+	return &ArbSequencerAPI{sequencer}
+}
+
+type SequencedTransactionsFilter struct {
+	// Hashes restricts the subscription to the given transactions; if empty, every transaction is reported.
+	Hashes []common.Hash `json:"hashes"`
+}
+
+// SequencedTransactions subscribes to the soft confirmations of transactions as soon as this sequencer sequenced or rejected them.
+func (a *ArbSequencerAPI) SequencedTransactions(ctx context.Context, filter *SequencedTransactionsFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var hashes map[common.Hash]struct{}
+	if filter != nil && len(filter.Hashes) > 0 {
+		hashes = make(map[common.Hash]struct{}, len(filter.Hashes))
+		for _, hash := range filter.Hashes {
+			hashes[hash] = struct{}{}
+		}
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	// subscribe before returning, so transactions sent right after subscribing aren't missed
+	txsChan := make(chan []SequencedTransaction, 128)
+	unsubscribe := a.sequencer.sequencedTxs.subscribe(txsChan)
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case txs := <-txsChan:
+				for i := range txs {
+					if hashes != nil {
+						if _, ok := hashes[txs[i].TxHash]; !ok {
+							continue
+						}
+					}
+					if err := notifier.Notify(rpcSub.ID, &txs[i]); err != nil {
+						return
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
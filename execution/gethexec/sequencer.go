@@ -54,18 +54,23 @@ var (
 )
 
 type SequencerConfig struct {
-	Enable                      bool            `koanf:"enable"`
-	MaxBlockSpeed               time.Duration   `koanf:"max-block-speed" reload:"hot"`
-	MaxRevertGasReject          uint64          `koanf:"max-revert-gas-reject" reload:"hot"`
-	MaxAcceptableTimestampDelta time.Duration   `koanf:"max-acceptable-timestamp-delta" reload:"hot"`
-	SenderWhitelist             string          `koanf:"sender-whitelist"`
-	Forwarder                   ForwarderConfig `koanf:"forwarder"`
-	QueueSize                   int             `koanf:"queue-size"`
-	QueueTimeout                time.Duration   `koanf:"queue-timeout" reload:"hot"`
-	NonceCacheSize              int             `koanf:"nonce-cache-size" reload:"hot"`
-	MaxTxDataSize               int             `koanf:"max-tx-data-size" reload:"hot"`
-	NonceFailureCacheSize       int             `koanf:"nonce-failure-cache-size" reload:"hot"`
-	NonceFailureCacheExpiry     time.Duration   `koanf:"nonce-failure-cache-expiry" reload:"hot"`
+	Enable                      bool              `koanf:"enable"`
+	MaxBlockSpeed               time.Duration     `koanf:"max-block-speed" reload:"hot"`
+	MaxRevertGasReject          uint64            `koanf:"max-revert-gas-reject" reload:"hot"`
+	MaxAcceptableTimestampDelta time.Duration     `koanf:"max-acceptable-timestamp-delta" reload:"hot"`
+	SenderWhitelist             string            `koanf:"sender-whitelist"`
+	Forwarder                   ForwarderConfig   `koanf:"forwarder"`
+	QueueSize                   int               `koanf:"queue-size"`
+	QueueTimeout                time.Duration     `koanf:"queue-timeout" reload:"hot"`
+	NonceCacheSize              int               `koanf:"nonce-cache-size" reload:"hot"`
+	MaxTxDataSize               int               `koanf:"max-tx-data-size" reload:"hot"`
+	NonceFailureCacheSize       int               `koanf:"nonce-failure-cache-size" reload:"hot"`
+	NonceFailureCacheExpiry     time.Duration     `koanf:"nonce-failure-cache-expiry" reload:"hot"`
+	Ordering                    TxOrderingConfig  `koanf:"ordering" reload:"hot"`
+	RateLimit                   TxRateLimitConfig `koanf:"rate-limit" reload:"hot"`
+	TxFilter                    TxFilterConfig    `koanf:"tx-filter" reload:"hot"`
+	Bundle                      BundleConfig      `koanf:"bundle" reload:"hot"`
+	BlockHooks                  BlockHooksConfig  `koanf:"block-hooks" reload:"hot"`
 }
 
 func (c *SequencerConfig) Validate() error {
@@ -78,7 +83,19 @@ func (c *SequencerConfig) Validate() error {
 			return fmt.Errorf("sequencer sender whitelist entry \"%v\" is not a valid address", address)
 		}
 	}
-	return nil
+	if err := c.Ordering.Validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.Validate(); err != nil {
+		return err
+	}
+	if err := c.TxFilter.Validate(); err != nil {
+		return err
+	}
+	if err := c.Bundle.Validate(); err != nil {
+		return err
+	}
+	return c.BlockHooks.Validate()
 }
 
 type SequencerConfigFetcher func() *SequencerConfig
@@ -97,6 +114,11 @@ var DefaultSequencerConfig = SequencerConfig{
 	MaxTxDataSize:           95000,
 	NonceFailureCacheSize:   1024,
 	NonceFailureCacheExpiry: time.Second,
+	Ordering:                DefaultTxOrderingConfig,
+	RateLimit:               DefaultTxRateLimitConfig,
+	TxFilter:                DefaultTxFilterConfig,
+	Bundle:                  DefaultBundleConfig,
+	BlockHooks:              DefaultBlockHooksConfig,
 }
 
 var TestSequencerConfig = SequencerConfig{
@@ -112,6 +134,11 @@ var TestSequencerConfig = SequencerConfig{
 	MaxTxDataSize:               95000,
 	NonceFailureCacheSize:       1024,
 	NonceFailureCacheExpiry:     time.Second,
+	Ordering:                    DefaultTxOrderingConfig,
+	RateLimit:                   DefaultTxRateLimitConfig,
+	TxFilter:                    DefaultTxFilterConfig,
+	Bundle:                      TestBundleConfig,
+	BlockHooks:                  TestBlockHooksConfig,
 }
 
 func SequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -127,6 +154,11 @@ func SequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Int(prefix+".max-tx-data-size", DefaultSequencerConfig.MaxTxDataSize, "maximum transaction size the sequencer will accept")
 	f.Int(prefix+".nonce-failure-cache-size", DefaultSequencerConfig.NonceFailureCacheSize, "number of transactions with too high of a nonce to keep in memory while waiting for their predecessor")
 	f.Duration(prefix+".nonce-failure-cache-expiry", DefaultSequencerConfig.NonceFailureCacheExpiry, "maximum amount of time to wait for a predecessor before rejecting a tx with nonce too high")
+	TxOrderingConfigAddOptions(prefix+".ordering", f)
+	TxRateLimitConfigAddOptions(prefix+".rate-limit", f)
+	TxFilterConfigAddOptions(prefix+".tx-filter", f)
+	BundleConfigAddOptions(prefix+".bundle", f)
+	BlockHooksConfigAddOptions(prefix+".block-hooks", f)
 }
 
 type txQueueItem struct {
@@ -242,6 +274,7 @@ type nonceFailure struct {
 type nonceFailureCache struct {
 	*containers.LruCache[addressAndNonce, *nonceFailure]
 	getExpiry func() time.Duration
+	reject    func(queueItem *txQueueItem, err error)
 }
 
 func (c nonceFailureCache) Contains(err NonceError) bool {
@@ -252,7 +285,7 @@ func (c nonceFailureCache) Contains(err NonceError) bool {
 func (c nonceFailureCache) Add(err NonceError, queueItem txQueueItem) {
 	expiry := queueItem.firstAppearance.Add(c.getExpiry())
 	if c.Contains(err) || time.Now().After(expiry) {
-		queueItem.returnResult(err)
+		c.reject(&queueItem, err)
 		return
 	}
 	key := addressAndNonce{err.sender, err.txNonce}
@@ -274,12 +307,19 @@ type Sequencer struct {
 	execEngine      *ExecutionEngine
 	txQueue         chan txQueueItem
 	txRetryQueue    containers.Queue[txQueueItem]
+	bundleQueue     chan bundleQueueItem
+	bundleRetries   containers.Queue[bundleQueueItem]
 	l1Reader        *headerreader.HeaderReader
 	config          SequencerConfigFetcher
 	senderWhitelist map[common.Address]struct{}
+	rateLimiter     *TxRateLimiter
+	txFilter        *TxFilter
 	nonceCache      *nonceCache
 	nonceFailures   *nonceFailureCache
 	onForwarderSet  chan struct{}
+	handoffRequests chan chan *sync.WaitGroup
+	sequencedTxs    sequencedTxFeed
+	blockHooks      blockHooks
 
 	L1BlockAndTimeMutex sync.Mutex
 	l1BlockNumber       uint64
@@ -309,24 +349,36 @@ func NewSequencer(execEngine *ExecutionEngine, l1Reader *headerreader.HeaderRead
 	s := &Sequencer{
 		execEngine:      execEngine,
 		txQueue:         make(chan txQueueItem, config.QueueSize),
+		bundleQueue:     make(chan bundleQueueItem, config.Bundle.QueueSize),
 		l1Reader:        l1Reader,
 		config:          configFetcher,
 		senderWhitelist: senderWhitelist,
+		rateLimiter:     NewTxRateLimiter(execEngine.bc.Config(), func() *TxRateLimitConfig { return &configFetcher().RateLimit }),
+		txFilter:        NewTxFilter(func() *TxFilterConfig { return &configFetcher().TxFilter }),
 		nonceCache:      newNonceCache(config.NonceCacheSize),
 		l1BlockNumber:   0,
 		l1Timestamp:     0,
 		pauseChan:       nil,
 		onForwarderSet:  make(chan struct{}, 1),
+		handoffRequests: make(chan chan *sync.WaitGroup),
 	}
 	s.nonceFailures = &nonceFailureCache{
 		containers.NewLruCacheWithOnEvict(config.NonceCacheSize, s.onNonceFailureEvict),
 		func() time.Duration { return configFetcher().NonceFailureCacheExpiry },
+		s.rejectQueueItem,
 	}
 	s.Pause()
 	execEngine.EnableReorgSequencing()
 	return s, nil
 }
 
+// rejectQueueItem returns err to the sender of a queued transaction the sequencer gave up on,
+// and reports the rejection to the sequenced transactions subscribers.
+func (s *Sequencer) rejectQueueItem(queueItem *txQueueItem, err error) {
+	queueItem.returnResult(err)
+	s.sequencedTxs.send([]SequencedTransaction{{TxHash: queueItem.tx.Hash(), Error: err.Error()}})
+}
+
 func (s *Sequencer) onNonceFailureEvict(_ addressAndNonce, failure *nonceFailure) {
 	if failure.revived {
 		return
@@ -334,7 +386,7 @@ func (s *Sequencer) onNonceFailureEvict(_ addressAndNonce, failure *nonceFailure
 	queueItem := failure.queueItem
 	err := queueItem.ctx.Err()
 	if err != nil {
-		queueItem.returnResult(err)
+		s.rejectQueueItem(&queueItem, err)
 		return
 	}
 	_, forwarder := s.GetPauseAndForwarder()
@@ -351,7 +403,7 @@ func (s *Sequencer) onNonceFailureEvict(_ addressAndNonce, failure *nonceFailure
 			queueItem.returnResult(err)
 		})
 	} else {
-		queueItem.returnResult(failure.nonceErr)
+		s.rejectQueueItem(&queueItem, failure.nonceErr)
 	}
 }
 
@@ -367,6 +419,12 @@ func (s *Sequencer) PublishTransaction(parentCtx context.Context, tx *types.Tran
 	sequencerBacklogGauge.Inc(1)
 	defer sequencerBacklogGauge.Dec(1)
 
+	release, err := s.rateLimiter.Acquire(parentCtx, tx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	_, forwarder := s.GetPauseAndForwarder()
 	if forwarder != nil {
 		err := forwarder.PublishTransaction(parentCtx, tx, options)
@@ -431,6 +489,9 @@ func (s *Sequencer) PublishTransaction(parentCtx context.Context, tx *types.Tran
 }
 
 func (s *Sequencer) preTxFilter(_ *params.ChainConfig, header *types.Header, statedb *state.StateDB, _ *arbosState.ArbosState, tx *types.Transaction, options *arbitrum_types.ConditionalOptions, sender common.Address, l1Info *arbos.L1Info) error {
+	if err := s.txFilter.CheckPreExecution(tx, sender); err != nil {
+		return err
+	}
 	if s.nonceCache.Caching() {
 		stateNonce := s.nonceCache.Get(header, statedb, sender)
 		err := MakeNonceError(sender, tx.Nonce(), stateNonce)
@@ -450,10 +511,20 @@ func (s *Sequencer) preTxFilter(_ *params.ChainConfig, header *types.Header, sta
 	return nil
 }
 
-func (s *Sequencer) postTxFilter(header *types.Header, _ *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, result *core.ExecutionResult) error {
+func (s *Sequencer) postTxFilter(header *types.Header, statedb *state.StateDB, _ *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, result *core.ExecutionResult) error {
+	if err := s.txFilter.CheckPostExecution(tx, sender, statedb); err != nil {
+		return err
+	}
 	if result.Err != nil && result.UsedGas > dataGas && result.UsedGas-dataGas <= s.config().MaxRevertGasReject {
 		return arbitrum.NewRevertReason(result)
 	}
+	s.onTxAccepted(header, tx, sender)
+	return nil
+}
+
+// onTxAccepted updates the nonce cache for a transaction that made it into the block,
+// and re-enqueues any transaction that was waiting for it.
+func (s *Sequencer) onTxAccepted(header *types.Header, tx *types.Transaction, sender common.Address) {
 	newNonce := tx.Nonce() + 1
 	s.nonceCache.Update(header, sender, newNonce)
 	newAddrAndNonce := addressAndNonce{sender, newNonce}
@@ -464,13 +535,12 @@ func (s *Sequencer) postTxFilter(header *types.Header, _ *arbosState.ArbosState,
 		// Immediately check if the transaction submission has been canceled
 		err := nonceFailure.queueItem.ctx.Err()
 		if err != nil {
-			nonceFailure.queueItem.returnResult(err)
+			s.rejectQueueItem(&nonceFailure.queueItem, err)
 		} else {
 			// Add this transaction (whose nonce is now correct) back into the queue
 			s.txRetryQueue.Push(nonceFailure.queueItem)
 		}
 	}
-	return nil
 }
 
 func (s *Sequencer) CheckHealth(ctx context.Context) error {
@@ -557,7 +627,8 @@ func (s *Sequencer) GetPauseAndForwarder() (chan struct{}, *TxForwarder) {
 }
 
 // only called from createBlock, may be paused
-func (s *Sequencer) handleInactive(ctx context.Context, queueItems []txQueueItem) bool {
+// If forwards is set, it tracks the forwarded items until the forwarding target returned their results.
+func (s *Sequencer) handleInactive(ctx context.Context, queueItems []txQueueItem, forwards *sync.WaitGroup) bool {
 	var forwarder *TxForwarder
 	for {
 		var pause chan struct{}
@@ -576,30 +647,107 @@ func (s *Sequencer) handleInactive(ctx context.Context, queueItems []txQueueItem
 		case <-pause:
 		}
 	}
-	publishResults := make(chan *txQueueItem, len(queueItems))
+	// We don't wait for the forwarded results here: the forwarding target might only sequence them
+	// once we released the lockout, which a handoff does after waiting on forwards for a while.
 	for _, item := range queueItems {
 		item := item
+		if forwards != nil {
+			forwards.Add(1)
+		}
 		go func() {
+			if forwards != nil {
+				defer forwards.Done()
+			}
 			res := forwarder.PublishTransaction(item.ctx, item.tx, item.options)
 			if errors.Is(res, ErrNoSequencer) {
-				publishResults <- &item
+				s.requeue(item)
 			} else {
-				publishResults <- nil
 				item.returnResult(res)
 			}
 		}()
 	}
-	for range queueItems {
-		remainingItem := <-publishResults
-		if remainingItem != nil {
-			s.txRetryQueue.Push(*remainingItem)
-		}
-	}
 	// Evict any leftover nonce failures, forwarding them
 	s.nonceFailures.Clear()
 	return true
 }
 
+// requeue puts an item the forwarder couldn't take back into the queue. It may be called from any thread.
+func (s *Sequencer) requeue(item txQueueItem) {
+	select {
+	case s.txQueue <- item:
+	case <-item.ctx.Done():
+		item.returnResult(item.ctx.Err())
+	}
+}
+
+// ForwardQueue hands everything queued in this sequencer to the target set by ForwardTo, and waits
+// until the target returned the results of all of it, or ctx is done. A target about to take over the
+// lockout may only sequence them once the caller released it, so forwards still pending there
+// when ctx is done are left to complete in the background.
+// It's meant to be called by a sequencer handing off the lockout, before releasing it.
+func (s *Sequencer) ForwardQueue(ctx context.Context) error {
+	if _, forwarder := s.GetPauseAndForwarder(); forwarder == nil {
+		return errors.New("sequencer isn't forwarding")
+	}
+	done := make(chan *sync.WaitGroup, 1)
+	select {
+	case s.handoffRequests <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var forwards *sync.WaitGroup
+	select {
+	case forwards = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	returned := make(chan struct{})
+	go func() {
+		forwards.Wait()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("forwarded transactions still pending: %w", ctx.Err())
+	}
+}
+
+// forwardQueued is the createBlock thread's side of ForwardQueue.
+// It returns the forwards it started, for ForwardQueue to wait on.
+func (s *Sequencer) forwardQueued(ctx context.Context) *sync.WaitGroup {
+	forwards := &sync.WaitGroup{}
+	var queueItems []txQueueItem
+	for s.txRetryQueue.Len() > 0 {
+		queueItems = append(queueItems, s.txRetryQueue.Pop())
+	}
+	for done := false; !done; {
+		select {
+		case item := <-s.txQueue:
+			queueItems = append(queueItems, item)
+		default:
+			done = true
+		}
+	}
+	log.Info("forwarding queued transactions for handoff", "count", len(queueItems))
+	if !s.handleInactive(ctx, queueItems, forwards) {
+		// we were activated again in the meantime
+		for _, item := range queueItems {
+			s.txRetryQueue.Push(item)
+		}
+		return forwards
+	}
+	for {
+		bundle, ok := s.nextBundle()
+		if !ok {
+			break
+		}
+		s.handleInactiveBundle(ctx, bundle, forwards)
+	}
+	return forwards
+}
+
 var sequencerInternalError = errors.New("sequencer internal error")
 
 func (s *Sequencer) makeSequencingHooks() *arbos.SequencingHooks {
@@ -656,7 +804,7 @@ func (s *Sequencer) precheckNonces(queueItems []txQueueItem) []txQueueItem {
 		tx := queueItem.tx
 		sender, err := types.Sender(signer, tx)
 		if err != nil {
-			queueItem.returnResult(err)
+			s.rejectQueueItem(&queueItem, err)
 			continue
 		}
 		stateNonce := s.nonceCache.Get(latestHeader, latestState, sender)
@@ -676,7 +824,7 @@ func (s *Sequencer) precheckNonces(queueItems []txQueueItem) []txQueueItem {
 				s.nonceFailures.Remove(nextKey)
 				err := revivingFailure.queueItem.ctx.Err()
 				if err != nil {
-					revivingFailure.queueItem.returnResult(err)
+					s.rejectQueueItem(&revivingFailure.queueItem, err)
 				} else {
 					nextQueueItem = &revivingFailure.queueItem
 				}
@@ -697,7 +845,7 @@ func (s *Sequencer) precheckNonces(queueItems []txQueueItem) []txQueueItem {
 				continue
 			} else if err != nil {
 				nonceCacheRejectedCounter.Inc(1)
-				queueItem.returnResult(err)
+				s.rejectQueueItem(&queueItem, err)
 				continue
 			} else {
 				log.Warn("unreachable nonce err == nil condition hit in precheckNonces")
@@ -712,6 +860,64 @@ func (s *Sequencer) precheckNonces(queueItems []txQueueItem) []txQueueItem {
 	return outputQueueItems
 }
 
+// orderQueueItems applies the ordering policy to the items about to be sequenced.
+func (s *Sequencer) orderQueueItems(ordering TxOrderingPolicy, queueItems []txQueueItem) []txQueueItem {
+	if _, isFCFS := ordering.(fcfsOrdering); isFCFS || len(queueItems) < 2 {
+		return queueItems
+	}
+	bc := s.execEngine.bc
+	latestHeader := bc.CurrentBlock()
+	signer := types.MakeSigner(bc.Config(), arbmath.BigAdd(latestHeader.Number, common.Big1), latestHeader.Time)
+	orderable := make([]OrderableTx, len(queueItems))
+	for i, item := range queueItems {
+		// precheckNonces already recovered the sender, so this hits the signature cache
+		sender, err := types.Sender(signer, item.tx)
+		if err != nil {
+			log.Warn("failed to recover sender for ordering, keeping arrival order", "err", err)
+			return queueItems
+		}
+		orderable[i] = OrderableTx{Tx: item.tx, Sender: sender, Arrival: item.firstAppearance}
+	}
+	order := ordering.Order(orderable, latestHeader.BaseFee)
+	if len(order) != len(queueItems) {
+		log.Error("sequencer ordering policy dropped transactions, keeping arrival order", "expected", len(queueItems), "got", len(order))
+		return queueItems
+	}
+	ordered := make([]txQueueItem, len(order))
+	for i, index := range order {
+		ordered[i] = queueItems[index]
+	}
+	return ordered
+}
+
+// nextBlockHeader returns the message header for the next sequenced block, or nil if the sequencer can't sequence right now.
+func (s *Sequencer) nextBlockHeader(config *SequencerConfig) *arbostypes.L1IncomingMessageHeader {
+	timestamp := time.Now().Unix()
+	s.L1BlockAndTimeMutex.Lock()
+	l1Block := s.l1BlockNumber
+	l1Timestamp := s.l1Timestamp
+	s.L1BlockAndTimeMutex.Unlock()
+
+	if s.l1Reader != nil && (l1Block == 0 || math.Abs(float64(l1Timestamp)-float64(timestamp)) > config.MaxAcceptableTimestampDelta.Seconds()) {
+		log.Error(
+			"cannot sequence: unknown L1 block or L1 timestamp too far from local clock time",
+			"l1Block", l1Block,
+			"l1Timestamp", time.Unix(int64(l1Timestamp), 0),
+			"localTimestamp", time.Unix(int64(timestamp), 0),
+		)
+		return nil
+	}
+
+	return &arbostypes.L1IncomingMessageHeader{
+		Kind:        arbostypes.L1MessageType_L2Message,
+		Poster:      l1pricing.BatchPosterAddress,
+		BlockNumber: l1Block,
+		Timestamp:   uint64(timestamp),
+		RequestId:   nil,
+		L1BaseFee:   nil,
+	}
+}
+
 func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 	var queueItems []txQueueItem
 	var totalBatchSize int
@@ -733,6 +939,12 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 	defer nonceFailureCacheSizeGauge.Update(int64(s.nonceFailures.Len()))
 
 	config := s.config()
+	ordering, err := NewTxOrderingPolicy(&config.Ordering)
+	if err != nil {
+		log.Error("invalid sequencer ordering policy, falling back to first come first served", "err", err)
+		ordering = fcfsOrdering{}
+	}
+	var collectUntil time.Time
 
 	// Clear out old nonceFailures
 	s.nonceFailures.Resize(config.NonceFailureCacheSize)
@@ -744,6 +956,11 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		}
 	}()
 
+	// bundles get a block of their own, ahead of the queued transactions
+	if bundle, ok := s.nextBundle(); ok {
+		return s.createBundleBlock(ctx, config, bundle)
+	}
+
 	for {
 		var queueItem txQueueItem
 		if s.txRetryQueue.Len() > 0 {
@@ -755,6 +972,11 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 			}
 			select {
 			case queueItem = <-s.txQueue:
+			case bundle := <-s.bundleQueue:
+				return s.createBundleBlock(ctx, config, bundle)
+			case done := <-s.handoffRequests:
+				done <- s.forwardQueued(ctx)
+				continue
 			case <-nextNonceExpiryChan:
 				// No need to stop the previous timer since it already elapsed
 				nextNonceExpiryTimer = s.expireNonceFailures()
@@ -769,6 +991,21 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 			case <-ctx.Done():
 				return false
 			}
+		} else if wait := time.Until(collectUntil); wait > 0 {
+			// the ordering policy wants to see more transactions before ordering them
+			done := false
+			timer := time.NewTimer(wait)
+			select {
+			case queueItem = <-s.txQueue:
+			case <-timer.C:
+				done = true
+			case <-ctx.Done():
+				done = true
+			}
+			timer.Stop()
+			if done {
+				break
+			}
 		} else {
 			done := false
 			select {
@@ -780,19 +1017,24 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 				break
 			}
 		}
+		if len(queueItems) == 0 && collectUntil.IsZero() {
+			if window := ordering.CollectionWindow(); window > 0 {
+				collectUntil = time.Now().Add(window)
+			}
+		}
 		err := queueItem.ctx.Err()
 		if err != nil {
-			queueItem.returnResult(err)
+			s.rejectQueueItem(&queueItem, err)
 			continue
 		}
 		txBytes, err := queueItem.tx.MarshalBinary()
 		if err != nil {
-			queueItem.returnResult(err)
+			s.rejectQueueItem(&queueItem, err)
 			continue
 		}
 		if len(txBytes) > config.MaxTxDataSize {
 			// This tx is too large
-			queueItem.returnResult(txpool.ErrOversizedData)
+			s.rejectQueueItem(&queueItem, txpool.ErrOversizedData)
 			continue
 		}
 		if totalBatchSize+len(txBytes) > config.MaxTxDataSize {
@@ -808,45 +1050,55 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 	s.nonceCache.Resize(config.NonceCacheSize) // Would probably be better in a config hook but this is basically free
 	s.nonceCache.BeginNewBlock()
 	queueItems = s.precheckNonces(queueItems)
-	txes := make([]*types.Transaction, len(queueItems))
-	hooks := s.makeSequencingHooks()
-	hooks.ConditionalOptionsForTx = make([]*arbitrum_types.ConditionalOptions, len(queueItems))
-	for i, queueItem := range queueItems {
-		txes[i] = queueItem.tx
-		hooks.ConditionalOptionsForTx[i] = queueItem.options
-	}
+	queueItems = s.orderQueueItems(ordering, queueItems)
 
-	if s.handleInactive(ctx, queueItems) {
+	if s.handleInactive(ctx, queueItems, nil) {
 		return false
 	}
 
-	timestamp := time.Now().Unix()
-	s.L1BlockAndTimeMutex.Lock()
-	l1Block := s.l1BlockNumber
-	l1Timestamp := s.l1Timestamp
-	s.L1BlockAndTimeMutex.Unlock()
-
-	if s.l1Reader != nil && (l1Block == 0 || math.Abs(float64(l1Timestamp)-float64(timestamp)) > config.MaxAcceptableTimestampDelta.Seconds()) {
-		log.Error(
-			"cannot sequence: unknown L1 block or L1 timestamp too far from local clock time",
-			"l1Block", l1Block,
-			"l1Timestamp", time.Unix(int64(l1Timestamp), 0),
-			"localTimestamp", time.Unix(int64(timestamp), 0),
-		)
+	header := s.nextBlockHeader(config)
+	if header == nil {
 		return false
 	}
 
-	header := &arbostypes.L1IncomingMessageHeader{
-		Kind:        arbostypes.L1MessageType_L2Message,
-		Poster:      l1pricing.BatchPosterAddress,
-		BlockNumber: l1Block,
-		Timestamp:   uint64(timestamp),
-		RequestId:   nil,
-		L1BaseFee:   nil,
+	// transactions added by block hooks go first, pushing queued ones that no longer fit to the next block
+	hookTxs := s.beforeBlockHooks(ctx, config, header)
+	numHookTxs := countBlockHookTxs(hookTxs)
+	// the hooks are told how the block went however it ends, with the sequencing error as their
+	// transactions' results if it failed, for them not to hold on to state from BeforeBlock
+	var block *types.Block
+	hookTxErrs := make([]error, numHookTxs)
+	defer func() {
+		s.afterBlockHooks(ctx, config, block, hookTxs, hookTxErrs)
+	}()
+	txes := make([]*types.Transaction, 0, numHookTxs+len(queueItems))
+	if numHookTxs > 0 {
+		batchSize := 0
+		for _, result := range hookTxs {
+			for _, tx := range result.txs {
+				txes = append(txes, tx)
+				batchSize += int(tx.Size())
+			}
+		}
+		for _, queueItem := range queueItems {
+			batchSize += int(queueItem.tx.Size())
+		}
+		for batchSize > config.MaxTxDataSize && len(queueItems) > 0 {
+			last := queueItems[len(queueItems)-1]
+			queueItems = queueItems[:len(queueItems)-1]
+			batchSize -= int(last.tx.Size())
+			s.txRetryQueue.Push(last)
+		}
+	}
+	hooks := s.makeSequencingHooks()
+	hooks.ConditionalOptionsForTx = make([]*arbitrum_types.ConditionalOptions, numHookTxs, numHookTxs+len(queueItems))
+	for _, queueItem := range queueItems {
+		txes = append(txes, queueItem.tx)
+		hooks.ConditionalOptionsForTx = append(hooks.ConditionalOptionsForTx, queueItem.options)
 	}
 
 	start := time.Now()
-	block, err := s.execEngine.SequenceTransactions(header, txes, hooks)
+	block, err = s.execEngine.SequenceTransactions(header, txes, hooks)
 	elapsed := time.Since(start)
 	blockCreationTimer.Update(elapsed)
 	if elapsed >= time.Second*5 {
@@ -859,11 +1111,19 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 	if err == nil && len(hooks.TxErrors) != len(txes) {
 		err = fmt.Errorf("unexpected number of error results: %v vs number of txes %v", len(hooks.TxErrors), len(txes))
 	}
+	if err != nil {
+		block = nil
+		for i := range hookTxErrs {
+			hookTxErrs[i] = err
+		}
+	} else {
+		copy(hookTxErrs, hooks.TxErrors[:numHookTxs])
+	}
 	if errors.Is(err, execution.ErrRetrySequencer) {
 		log.Warn("error sequencing transactions", "err", err)
 		// we changed roles
 		// forward if we have where to
-		if s.handleInactive(ctx, queueItems) {
+		if s.handleInactive(ctx, queueItems, nil) {
 			return false
 		}
 		// try to add back to queue otherwise
@@ -882,7 +1142,7 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		}
 		log.Error("error sequencing transactions", "err", err)
 		for _, queueItem := range queueItems {
-			queueItem.returnResult(err)
+			s.rejectQueueItem(&queueItem, err)
 		}
 		return false
 	}
@@ -892,8 +1152,15 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		s.nonceCache.Finalize(block)
 	}
 
+	sequencedTxs := s.newSequencedTxsCollector(block)
 	madeBlock := false
-	for i, err := range hooks.TxErrors {
+	for i, err := range hooks.TxErrors[:numHookTxs] {
+		if err == nil {
+			madeBlock = true
+		}
+		sequencedTxs.add(txes[i], err)
+	}
+	for i, err := range hooks.TxErrors[numHookTxs:] {
 		if err == nil {
 			madeBlock = true
 		}
@@ -915,8 +1182,12 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 			s.nonceFailures.Add(nonceError, queueItem)
 			continue
 		}
+		sequencedTxs.add(queueItem.tx, err)
 		queueItem.returnResult(err)
 	}
+	if sequencedTxs != nil {
+		s.sequencedTxs.send(sequencedTxs.txs)
+	}
 	return madeBlock
 }
 
@@ -946,6 +1217,10 @@ func (s *Sequencer) Initialize(ctx context.Context) error {
 
 func (s *Sequencer) Start(ctxIn context.Context) error {
 	s.StopWaiter.Start(ctxIn, s)
+	if err := s.txFilter.Initialize(ctxIn); err != nil {
+		return err
+	}
+	s.CallIteratively(s.txFilter.update)
 	if s.l1Reader != nil {
 		initialBlockNr := atomic.LoadUint64(&s.l1BlockNumber)
 		if initialBlockNr == 0 {
@@ -987,6 +1262,7 @@ func (s *Sequencer) Start(ctxIn context.Context) error {
 
 func (s *Sequencer) StopAndWait() {
 	s.StopWaiter.StopAndWait()
+	s.drainBundles()
 	if s.txRetryQueue.Len() == 0 && len(s.txQueue) == 0 && s.nonceFailures.Len() == 0 {
 		return
 	}
@@ -0,0 +1,368 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package gethexec
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	txFilterRejectedCounter       = metrics.NewRegisteredCounter("arb/sequencer/txfilter/rejected", nil)
+	txFilterReloadCounter         = metrics.NewRegisteredCounter("arb/sequencer/txfilter/reload/success", nil)
+	txFilterReloadFailuresCounter = metrics.NewRegisteredCounter("arb/sequencer/txfilter/reload/failures", nil)
+	txFilterRulesGauge            = metrics.NewRegisteredGauge("arb/sequencer/txfilter/rules", nil)
+)
+
+// ErrTxDenied is returned for transactions rejected by the sequencer's transaction filter.
+var ErrTxDenied = errors.New("transaction denied by sequencer filter")
+
+const txFilterFetchTimeout = 10 * time.Second
+
+type TxFilterConfig struct {
+	Enable         bool          `koanf:"enable" reload:"hot"`
+	Source         string        `koanf:"source" reload:"hot"`
+	ReloadInterval time.Duration `koanf:"reload-interval" reload:"hot"`
+	AuditLog       string        `koanf:"audit-log" reload:"hot"`
+}
+
+var DefaultTxFilterConfig = TxFilterConfig{
+	Enable:         false,
+	Source:         "",
+	ReloadInterval: time.Minute,
+	AuditLog:       "",
+}
+
+func TxFilterConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultTxFilterConfig.Enable, "reject transactions matching the deny rules loaded from source")
+	f.String(prefix+".source", DefaultTxFilterConfig.Source, "path or http(s) url of the JSON file with the deny rules")
+	f.Duration(prefix+".reload-interval", DefaultTxFilterConfig.ReloadInterval, "how often to check source for updated rules")
+	f.String(prefix+".audit-log", DefaultTxFilterConfig.AuditLog, "file to append a JSON line to for every rejected transaction (if empty, rejections are only logged)")
+}
+
+func (c *TxFilterConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Source == "" {
+		return errors.New("sequencer tx filter enabled without a source")
+	}
+	if c.ReloadInterval <= 0 {
+		return fmt.Errorf("sequencer tx filter reload interval %v must be positive", c.ReloadInterval)
+	}
+	return nil
+}
+
+// TxFilterRuleJSON is a single deny rule as found in the rules file.
+// A rule matches a transaction if every field it sets matches; a list field matches if any of its entries does.
+type TxFilterRuleJSON struct {
+	Name      string           `json:"name"`
+	Senders   []common.Address `json:"senders,omitempty"`
+	To        []common.Address `json:"to,omitempty"`
+	Selectors []hexutil.Bytes  `json:"selectors,omitempty"`
+	MinValue  *hexutil.Big     `json:"minValue,omitempty"`
+	// Touched matches addresses accessed during execution, so rules setting it are only checked after the transaction ran.
+	Touched []common.Address `json:"touched,omitempty"`
+}
+
+type TxFilterRulesJSON struct {
+	Rules []TxFilterRuleJSON `json:"rules"`
+}
+
+type txFilterRule struct {
+	name      string
+	senders   map[common.Address]struct{}
+	to        map[common.Address]struct{}
+	selectors map[[4]byte]struct{}
+	minValue  *big.Int
+	touched   []common.Address
+	rejected  metrics.Counter
+}
+
+func addressSet(addresses []common.Address) map[common.Address]struct{} {
+	if len(addresses) == 0 {
+		return nil
+	}
+	set := make(map[common.Address]struct{}, len(addresses))
+	for _, address := range addresses {
+		set[address] = struct{}{}
+	}
+	return set
+}
+
+func parseTxFilterRules(data []byte) ([]*txFilterRule, error) {
+	var parsed TxFilterRulesJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse tx filter rules: %w", err)
+	}
+	rules := make([]*txFilterRule, 0, len(parsed.Rules))
+	names := make(map[string]struct{})
+	for i, r := range parsed.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("tx filter rule %v has no name", i)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate tx filter rule name \"%v\"", r.Name)
+		}
+		names[r.Name] = struct{}{}
+		if len(r.Senders) == 0 && len(r.To) == 0 && len(r.Selectors) == 0 && r.MinValue == nil && len(r.Touched) == 0 {
+			return nil, fmt.Errorf("tx filter rule \"%v\" would match every transaction", r.Name)
+		}
+		rule := &txFilterRule{
+			name:     r.Name,
+			senders:  addressSet(r.Senders),
+			to:       addressSet(r.To),
+			touched:  r.Touched,
+			rejected: metrics.GetOrRegisterCounter("arb/sequencer/txfilter/rejected/"+r.Name, nil),
+		}
+		if len(r.Selectors) > 0 {
+			rule.selectors = make(map[[4]byte]struct{}, len(r.Selectors))
+			for _, selector := range r.Selectors {
+				if len(selector) != 4 {
+					return nil, fmt.Errorf("tx filter rule \"%v\" selector %v isn't 4 bytes", r.Name, selector)
+				}
+				rule.selectors[[4]byte(selector)] = struct{}{}
+			}
+		}
+		if r.MinValue != nil {
+			rule.minValue = r.MinValue.ToInt()
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchesTx checks every condition of the rule but touched.
+func (r *txFilterRule) matchesTx(tx *types.Transaction, sender common.Address) bool {
+	if r.senders != nil {
+		if _, ok := r.senders[sender]; !ok {
+			return false
+		}
+	}
+	if r.to != nil {
+		if tx.To() == nil {
+			return false
+		}
+		if _, ok := r.to[*tx.To()]; !ok {
+			return false
+		}
+	}
+	if r.selectors != nil {
+		if len(tx.Data()) < 4 {
+			return false
+		}
+		if _, ok := r.selectors[[4]byte(tx.Data()[:4])]; !ok {
+			return false
+		}
+	}
+	if r.minValue != nil && tx.Value().Cmp(r.minValue) < 0 {
+		return false
+	}
+	return true
+}
+
+func (r *txFilterRule) touchedBy(statedb *state.StateDB) bool {
+	for _, address := range r.touched {
+		// after execution, the access list holds every address the transaction accessed
+		if statedb.AddressInAccessList(address) {
+			return true
+		}
+	}
+	return false
+}
+
+type txFilterAuditEntry struct {
+	Time   time.Time       `json:"time"`
+	TxHash common.Hash     `json:"txHash"`
+	Sender common.Address  `json:"sender"`
+	To     *common.Address `json:"to,omitempty"`
+	Rule   string          `json:"rule"`
+	Stage  string          `json:"stage"`
+}
+
+// TxFilter rejects sequencer transactions matching a hot-reloaded set of deny rules.
+// It's only consulted through the sequencer's hooks, so it never affects replay or validation.
+type TxFilter struct {
+	config func() *TxFilterConfig
+	rules  atomic.Pointer[[]*txFilterRule]
+
+	// only accessed by update
+	loadedSource string
+	loadedHash   common.Hash
+	lastLoad     time.Time
+
+	auditMutex sync.Mutex
+}
+
+func NewTxFilter(config func() *TxFilterConfig) *TxFilter {
+	return &TxFilter{config: config}
+}
+
+func readTxFilterSource(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	ctx, cancel := context.WithTimeout(ctx, txFilterFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching tx filter rules from %v returned status %v", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// load fetches the rules from the configured source, and swaps them in if they changed.
+func (f *TxFilter) load(ctx context.Context) error {
+	config := f.config()
+	data, err := readTxFilterSource(ctx, config.Source)
+	if err != nil {
+		return err
+	}
+	hash := common.Hash(sha256.Sum256(data))
+	if config.Source == f.loadedSource && hash == f.loadedHash && f.rules.Load() != nil {
+		return nil
+	}
+	rules, err := parseTxFilterRules(data)
+	if err != nil {
+		return err
+	}
+	f.rules.Store(&rules)
+	f.loadedSource = config.Source
+	f.loadedHash = hash
+	txFilterRulesGauge.Update(int64(len(rules)))
+	log.Info("loaded sequencer tx filter rules", "source", config.Source, "rules", len(rules), "hash", hash)
+	return nil
+}
+
+// Initialize loads the rules once, failing if the filter is enabled and they can't be loaded.
+func (f *TxFilter) Initialize(ctx context.Context) error {
+	config := f.config()
+	if !config.Enable {
+		return nil
+	}
+	if err := f.load(ctx); err != nil {
+		return fmt.Errorf("failed to load sequencer tx filter rules from %v: %w", config.Source, err)
+	}
+	f.lastLoad = time.Now()
+	return nil
+}
+
+// update is meant to be called iteratively, and reloads the rules when due.
+func (f *TxFilter) update(ctx context.Context) time.Duration {
+	config := f.config()
+	if !config.Enable {
+		return time.Second
+	}
+	if config.Source == f.loadedSource && time.Since(f.lastLoad) < config.ReloadInterval {
+		return time.Second
+	}
+	f.lastLoad = time.Now()
+	if err := f.load(ctx); err != nil {
+		txFilterReloadFailuresCounter.Inc(1)
+		log.Error("failed to reload sequencer tx filter rules, keeping the previous ones", "source", config.Source, "err", err)
+		return time.Second
+	}
+	txFilterReloadCounter.Inc(1)
+	return time.Second
+}
+
+func (f *TxFilter) activeRules() []*txFilterRule {
+	if !f.config().Enable {
+		return nil
+	}
+	rules := f.rules.Load()
+	if rules == nil {
+		return nil
+	}
+	return *rules
+}
+
+// CheckPreExecution returns ErrTxDenied if a rule not depending on execution matches tx.
+func (f *TxFilter) CheckPreExecution(tx *types.Transaction, sender common.Address) error {
+	for _, rule := range f.activeRules() {
+		if len(rule.touched) == 0 && rule.matchesTx(tx, sender) {
+			return f.reject(rule, tx, sender, "pre-execution")
+		}
+	}
+	return nil
+}
+
+// CheckPostExecution returns ErrTxDenied if a rule matching on touched addresses matches the executed tx.
+func (f *TxFilter) CheckPostExecution(tx *types.Transaction, sender common.Address, statedb *state.StateDB) error {
+	for _, rule := range f.activeRules() {
+		if len(rule.touched) > 0 && rule.matchesTx(tx, sender) && rule.touchedBy(statedb) {
+			return f.reject(rule, tx, sender, "post-execution")
+		}
+	}
+	return nil
+}
+
+func (f *TxFilter) reject(rule *txFilterRule, tx *types.Transaction, sender common.Address, stage string) error {
+	txFilterRejectedCounter.Inc(1)
+	rule.rejected.Inc(1)
+	log.Warn("sequencer tx filter rejected transaction", "txHash", tx.Hash(), "sender", sender, "to", tx.To(), "rule", rule.name, "stage", stage)
+	if path := f.config().AuditLog; path != "" {
+		entry := txFilterAuditEntry{
+			Time:   time.Now().UTC(),
+			TxHash: tx.Hash(),
+			Sender: sender,
+			To:     tx.To(),
+			Rule:   rule.name,
+			Stage:  stage,
+		}
+		if err := f.appendAuditLog(path, &entry); err != nil {
+			log.Error("failed to write sequencer tx filter audit log", "path", path, "err", err)
+		}
+	}
+	return ErrTxDenied
+}
+
+func (f *TxFilter) appendAuditLog(path string, entry *txFilterAuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f.auditMutex.Lock()
+	defer f.auditMutex.Unlock()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
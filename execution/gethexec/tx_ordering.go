@@ -0,0 +1,175 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package gethexec
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	TxOrderingFCFS        = "fcfs"
+	TxOrderingPriorityFee = "priority-fee"
+)
+
+type TxOrderingConfig struct {
+	Policy string        `koanf:"policy" reload:"hot"`
+	Window time.Duration `koanf:"window" reload:"hot"`
+}
+
+var DefaultTxOrderingConfig = TxOrderingConfig{
+	Policy: TxOrderingFCFS,
+	Window: 0,
+}
+
+func TxOrderingConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".policy", DefaultTxOrderingConfig.Policy, "order the transactions of a block are sequenced in ("+strings.Join(txOrderingPolicyNames(), ", ")+")")
+	f.Duration(prefix+".window", DefaultTxOrderingConfig.Window, "how long to keep collecting queued transactions after the first one arrives before ordering them, for policies other than fcfs (0 orders whatever is queued when the block is started)")
+}
+
+func (c *TxOrderingConfig) Validate() error {
+	if _, ok := txOrderingPolicies[c.Policy]; !ok {
+		return fmt.Errorf("unknown sequencer ordering policy \"%v\", expected one of %v", c.Policy, txOrderingPolicyNames())
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("sequencer ordering window %v must not be negative", c.Window)
+	}
+	return nil
+}
+
+// OrderableTx is what a TxOrderingPolicy gets to see of a queued transaction.
+type OrderableTx struct {
+	Tx      *types.Transaction
+	Sender  common.Address
+	Arrival time.Time
+}
+
+// TxOrderingPolicy decides the order in which the transactions collected for a block are sequenced.
+type TxOrderingPolicy interface {
+	// CollectionWindow is how long to keep collecting transactions once the first one arrives.
+	CollectionWindow() time.Duration
+	// Order returns the indices of txs in the order they should be sequenced.
+	// It must return a permutation of all indices, and must keep each sender's transactions in the order given.
+	Order(txs []OrderableTx, baseFee *big.Int) []int
+}
+
+var txOrderingPolicies = map[string]func(*TxOrderingConfig) TxOrderingPolicy{
+	TxOrderingFCFS:        func(*TxOrderingConfig) TxOrderingPolicy { return fcfsOrdering{} },
+	TxOrderingPriorityFee: func(c *TxOrderingConfig) TxOrderingPolicy { return priorityFeeOrdering{window: c.Window} },
+}
+
+func txOrderingPolicyNames() []string {
+	names := make([]string, 0, len(txOrderingPolicies))
+	for name := range txOrderingPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewTxOrderingPolicy(config *TxOrderingConfig) (TxOrderingPolicy, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return txOrderingPolicies[config.Policy](config), nil
+}
+
+// fcfsOrdering sequences transactions in the order they arrived.
+type fcfsOrdering struct{}
+
+func (fcfsOrdering) CollectionWindow() time.Duration { return 0 }
+
+func (fcfsOrdering) Order(txs []OrderableTx, _ *big.Int) []int {
+	order := make([]int, len(txs))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// priorityFeeOrdering sequences transactions by descending effective priority fee,
+// while keeping each sender's transactions in nonce order. Ties go to the earlier arrival.
+type priorityFeeOrdering struct {
+	window time.Duration
+}
+
+func (o priorityFeeOrdering) CollectionWindow() time.Duration { return o.window }
+
+func (priorityFeeOrdering) Order(txs []OrderableTx, baseFee *big.Int) []int {
+	bySender := make(map[common.Address][]int)
+	var senders []common.Address
+	for i, tx := range txs {
+		if _, ok := bySender[tx.Sender]; !ok {
+			senders = append(senders, tx.Sender)
+		}
+		bySender[tx.Sender] = append(bySender[tx.Sender], i)
+	}
+	h := &senderTipHeap{txs: txs, baseFee: baseFee}
+	for _, sender := range senders {
+		indices := bySender[sender]
+		// the queue may hold a sender's transactions out of nonce order, e.g. after a nonce failure was revived
+		sort.SliceStable(indices, func(a, b int) bool {
+			return txs[indices[a]].Tx.Nonce() < txs[indices[b]].Tx.Nonce()
+		})
+		h.heads = append(h.heads, indices)
+	}
+	heap.Init(h)
+	order := make([]int, 0, len(txs))
+	for h.Len() > 0 {
+		indices := h.heads[0]
+		order = append(order, indices[0])
+		if len(indices) > 1 {
+			h.heads[0] = indices[1:]
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return order
+}
+
+// senderTipHeap is a max-heap of per-sender transaction lists, keyed by the tip of each list's first transaction.
+type senderTipHeap struct {
+	txs     []OrderableTx
+	baseFee *big.Int
+	heads   [][]int
+}
+
+func (h *senderTipHeap) Len() int { return len(h.heads) }
+
+func (h *senderTipHeap) Less(i, j int) bool {
+	a, b := h.heads[i][0], h.heads[j][0]
+	var cmp int
+	if h.baseFee != nil {
+		cmp = h.txs[a].Tx.EffectiveGasTipCmp(h.txs[b].Tx, h.baseFee)
+	} else {
+		cmp = h.txs[a].Tx.GasTipCapCmp(h.txs[b].Tx)
+	}
+	if cmp != 0 {
+		return cmp > 0
+	}
+	if !h.txs[a].Arrival.Equal(h.txs[b].Arrival) {
+		return h.txs[a].Arrival.Before(h.txs[b].Arrival)
+	}
+	return a < b
+}
+
+func (h *senderTipHeap) Swap(i, j int) { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *senderTipHeap) Push(x any) { h.heads = append(h.heads, x.([]int)) }
+
+func (h *senderTipHeap) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
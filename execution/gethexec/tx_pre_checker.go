@@ -5,9 +5,11 @@ package gethexec
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/ethereum/go-ethereum/arbitrum"
 	"github.com/ethereum/go-ethereum/arbitrum_types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -16,7 +18,9 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 	flag "github.com/spf13/pflag"
+	"github.com/yingdianRao/nitro/arbos"
 	"github.com/yingdianRao/nitro/arbos/arbosState"
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
 	"github.com/yingdianRao/nitro/arbos/l1pricing"
 	"github.com/yingdianRao/nitro/util/arbmath"
 	"github.com/yingdianRao/nitro/util/headerreader"
@@ -27,17 +31,22 @@ var (
 	conditionalTxAcceptedByTxPreCheckerCurrentStateCounter = metrics.NewRegisteredCounter("arb/txprechecker/condtionaltx/currentstate/accepted", nil)
 	conditionalTxRejectedByTxPreCheckerOldStateCounter     = metrics.NewRegisteredCounter("arb/txprechecker/condtionaltx/oldstate/rejected", nil)
 	conditionalTxAcceptedByTxPreCheckerOldStateCounter     = metrics.NewRegisteredCounter("arb/txprechecker/condtionaltx/oldstate/accepted", nil)
+	simulatedTxRejectedByTxPreCheckerCounter               = metrics.NewRegisteredCounter("arb/txprechecker/simulation/rejected", nil)
+	simulatedTxAcceptedByTxPreCheckerCounter               = metrics.NewRegisteredCounter("arb/txprechecker/simulation/accepted", nil)
 )
 
 const TxPreCheckerStrictnessNone uint = 0
 const TxPreCheckerStrictnessAlwaysCompatible uint = 10
 const TxPreCheckerStrictnessLikelyCompatible uint = 20
 const TxPreCheckerStrictnessFullValidation uint = 30
+const TxPreCheckerStrictnessSimulation uint = 40
 
 type TxPreCheckerConfig struct {
 	Strictness             uint  `koanf:"strictness" reload:"hot"`
 	RequiredStateAge       int64 `koanf:"required-state-age" reload:"hot"`
 	RequiredStateMaxBlocks uint  `koanf:"required-state-max-blocks" reload:"hot"`
+	// MaxRevertGasReject mirrors the sequencer option: reverts executing up to this much gas are left for the sequencer to reject.
+	MaxRevertGasReject uint64 `koanf:"max-revert-gas-reject" reload:"hot"`
 }
 
 type TxPreCheckerConfigFetcher func() *TxPreCheckerConfig
@@ -46,14 +55,16 @@ var DefaultTxPreCheckerConfig = TxPreCheckerConfig{
 	Strictness:             TxPreCheckerStrictnessNone,
 	RequiredStateAge:       2,
 	RequiredStateMaxBlocks: 4,
+	MaxRevertGasReject:     params.TxGas + 10000,
 }
 
 func TxPreCheckerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Uint(prefix+".strictness", DefaultTxPreCheckerConfig.Strictness, "how strict to be when checking txs before forwarding them. 0 = accept anything, "+
 		"10 = should never reject anything that'd succeed, 20 = likely won't reject anything that'd succeed, "+
-		"30 = full validation which may reject txs that would succeed")
+		"30 = full validation which may reject txs that would succeed, 40 = full validation and simulation against the latest state, rejecting txs that would revert")
 	f.Int64(prefix+".required-state-age", DefaultTxPreCheckerConfig.RequiredStateAge, "how long ago should the storage conditions from eth_SendRawTransactionConditional be true, 0 = don't check old state")
 	f.Uint(prefix+".required-state-max-blocks", DefaultTxPreCheckerConfig.RequiredStateMaxBlocks, "maximum number of blocks to look back while looking for the <required-state-age> seconds old state, 0 = don't limit the search")
+	f.Uint64(prefix+".max-revert-gas-reject", DefaultTxPreCheckerConfig.MaxRevertGasReject, "with simulation strictness, reject txs reverting after executing more than this much gas (cheaper reverts are rejected by the sequencer itself, see the sequencer's max-revert-gas-reject)")
 }
 
 type TxPreChecker struct {
@@ -202,9 +213,62 @@ func PreCheckTx(bc *core.BlockChain, chainConfig *params.ChainConfig, header *ty
 	if tx.Gas() < intrinsic+dataGas.Uint64() {
 		return core.ErrIntrinsicGas
 	}
+	if config.Strictness < TxPreCheckerStrictnessSimulation {
+		return nil
+	}
+	result, execDataGas, err := SimulateTx(bc, chainConfig, header, statedb, tx)
+	if err != nil {
+		simulatedTxRejectedByTxPreCheckerCounter.Inc(1)
+		return err
+	}
+	if result.Err != nil && result.UsedGas > execDataGas && result.UsedGas-execDataGas > config.MaxRevertGasReject {
+		simulatedTxRejectedByTxPreCheckerCounter.Inc(1)
+		return arbitrum.NewRevertReason(result)
+	}
+	simulatedTxAcceptedByTxPreCheckerCounter.Inc(1)
 	return nil
 }
 
+// SimulateTx executes tx as the sequencer would in a block following header, without modifying statedb.
+// It returns the execution result, and the part of the gas used that paid for the tx's L1 data.
+func SimulateTx(bc *core.BlockChain, chainConfig *params.ChainConfig, header *types.Header, statedb *state.StateDB, tx *types.Transaction) (*core.ExecutionResult, uint64, error) {
+	timestamp := uint64(time.Now().Unix())
+	if timestamp < header.Time {
+		timestamp = header.Time
+	}
+	l1Header := &arbostypes.L1IncomingMessageHeader{
+		Kind:        arbostypes.L1MessageType_L2Message,
+		Poster:      l1pricing.BatchPosterAddress,
+		BlockNumber: types.DeserializeHeaderExtraInformation(header).L1BlockNumber,
+		Timestamp:   timestamp,
+		RequestId:   nil,
+		L1BaseFee:   nil,
+	}
+	var result *core.ExecutionResult
+	var dataGas uint64
+	hooks := arbos.NoopSequencingHooks()
+	hooks.DiscardInvalidTxsEarly = true
+	hooks.PostTxFilter = func(_ *types.Header, _ *state.StateDB, _ *arbosState.ArbosState, _ *types.Transaction, _ common.Address, txDataGas uint64, txResult *core.ExecutionResult) error {
+		result = txResult
+		dataGas = txDataGas
+		return nil
+	}
+	_, _, err := arbos.ProduceBlockAdvanced(l1Header, types.Transactions{tx}, header.Nonce.Uint64(), header, statedb.Copy(), bc, chainConfig, hooks)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(hooks.TxErrors) != 1 {
+		return nil, 0, fmt.Errorf("simulated %d txs instead of 1", len(hooks.TxErrors))
+	}
+	if hooks.TxErrors[0] != nil {
+		return nil, 0, hooks.TxErrors[0]
+	}
+	if result == nil {
+		return nil, 0, errors.New("tx simulation produced no result")
+	}
+	return result, dataGas, nil
+}
+
 func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbitrum_types.ConditionalOptions) error {
 	block := c.bc.CurrentBlock()
 	statedb, err := c.bc.StateAt(block.Root)
@@ -221,3 +285,9 @@ func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transac
 	}
 	return c.TransactionPublisher.PublishTransaction(ctx, tx, options)
 }
+
+// PublishBundle doesn't pre-check the bundle's transactions against the current state,
+// as later transactions of a bundle may depend on the earlier ones.
+func (c *TxPreChecker) PublishBundle(ctx context.Context, bundle *TxBundle) error {
+	return c.TransactionPublisher.PublishBundle(ctx, bundle)
+}
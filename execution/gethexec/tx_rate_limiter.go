@@ -0,0 +1,363 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package gethexec
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	txRateLimitedSenderCounter = metrics.NewRegisteredCounter("arb/sequencer/ratelimit/sender", nil)
+	txRateLimitedIPCounter     = metrics.NewRegisteredCounter("arb/sequencer/ratelimit/ip", nil)
+	txQueueQuotaExceedCounter  = metrics.NewRegisteredCounter("arb/sequencer/ratelimit/queue_quota", nil)
+	txRateLimitBucketsGauge    = metrics.NewRegisteredGauge("arb/sequencer/ratelimit/buckets", nil)
+)
+
+// TxRateLimitErrorCode is the JSON-RPC error code returned when a transaction is rejected by rate limiting.
+// It's the "limit exceeded" code of EIP-1474.
+const TxRateLimitErrorCode = -32005
+
+const txRateLimitCleanupInterval = time.Minute
+
+type TxRateLimitConfig struct {
+	Enable             bool    `koanf:"enable" reload:"hot"`
+	PerSenderRate      float64 `koanf:"per-sender-rate" reload:"hot"`
+	PerSenderBurst     int     `koanf:"per-sender-burst" reload:"hot"`
+	PerIPRate          float64 `koanf:"per-ip-rate" reload:"hot"`
+	PerIPBurst         int     `koanf:"per-ip-burst" reload:"hot"`
+	MaxQueuedPerSender int     `koanf:"max-queued-per-sender" reload:"hot"`
+	MaxQueuedPerIP     int     `koanf:"max-queued-per-ip" reload:"hot"`
+	SenderAllowlist    string  `koanf:"sender-allowlist" reload:"hot"`
+	IPAllowlist        string  `koanf:"ip-allowlist" reload:"hot"`
+}
+
+var DefaultTxRateLimitConfig = TxRateLimitConfig{
+	Enable:             false,
+	PerSenderRate:      10,
+	PerSenderBurst:     50,
+	PerIPRate:          50,
+	PerIPBurst:         200,
+	MaxQueuedPerSender: 64,
+	MaxQueuedPerIP:     256,
+	SenderAllowlist:    "",
+	IPAllowlist:        "",
+}
+
+func TxRateLimitConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultTxRateLimitConfig.Enable, "enable per-sender and per-ip rate limits on submitted transactions (also applied by non-sequencer nodes before forwarding)")
+	f.Float64(prefix+".per-sender-rate", DefaultTxRateLimitConfig.PerSenderRate, "transactions per second a single sender may submit (0 = unlimited)")
+	f.Int(prefix+".per-sender-burst", DefaultTxRateLimitConfig.PerSenderBurst, "number of transactions a single sender may submit at once before per-sender-rate applies")
+	f.Float64(prefix+".per-ip-rate", DefaultTxRateLimitConfig.PerIPRate, "transactions per second a single client ip may submit (0 = unlimited)")
+	f.Int(prefix+".per-ip-burst", DefaultTxRateLimitConfig.PerIPBurst, "number of transactions a single client ip may submit at once before per-ip-rate applies")
+	f.Int(prefix+".max-queued-per-sender", DefaultTxRateLimitConfig.MaxQueuedPerSender, "maximum transactions of a single sender waiting to be sequenced at once (0 = unlimited)")
+	f.Int(prefix+".max-queued-per-ip", DefaultTxRateLimitConfig.MaxQueuedPerIP, "maximum transactions of a single client ip waiting to be sequenced at once (0 = unlimited)")
+	f.String(prefix+".sender-allowlist", DefaultTxRateLimitConfig.SenderAllowlist, "comma separated senders exempt from rate limits")
+	f.String(prefix+".ip-allowlist", DefaultTxRateLimitConfig.IPAllowlist, "comma separated client ips or CIDR ranges exempt from rate limits (e.g. the nodes forwarding to the sequencer)")
+}
+
+func (c *TxRateLimitConfig) Validate() error {
+	if c.PerSenderRate < 0 || c.PerIPRate < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+	if (c.PerSenderRate > 0 && c.PerSenderBurst < 1) || (c.PerIPRate > 0 && c.PerIPBurst < 1) {
+		return fmt.Errorf("rate limit burst must be at least 1")
+	}
+	if _, err := parseSenderAllowlist(c.SenderAllowlist); err != nil {
+		return err
+	}
+	if _, err := parseIPAllowlist(c.IPAllowlist); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseSenderAllowlist(list string) (map[common.Address]struct{}, error) {
+	allowlist := make(map[common.Address]struct{})
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("rate limit sender allowlist entry \"%v\" is not a valid address", entry)
+		}
+		allowlist[common.HexToAddress(entry)] = struct{}{}
+	}
+	return allowlist, nil
+}
+
+func parseIPAllowlist(list string) ([]*net.IPNet, error) {
+	var allowlist []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("rate limit ip allowlist entry \"%v\" is not a valid ip", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			allowlist = append(allowlist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit ip allowlist entry \"%v\" is not a valid CIDR range: %w", entry, err)
+		}
+		allowlist = append(allowlist, ipNet)
+	}
+	return allowlist, nil
+}
+
+// TxRateLimitError is returned to clients whose transaction was rejected by the rate limiter.
+type TxRateLimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+type txRateLimitErrorData struct {
+	Reason     string  `json:"reason"`
+	RetryAfter float64 `json:"retryAfter,omitempty"`
+}
+
+func (e *TxRateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("transaction rate limited: %v, retry after %v", e.Reason, e.RetryAfter)
+	}
+	return fmt.Sprintf("transaction rate limited: %v", e.Reason)
+}
+
+func (e *TxRateLimitError) ErrorCode() int { return TxRateLimitErrorCode }
+
+func (e *TxRateLimitError) ErrorData() interface{} {
+	return txRateLimitErrorData{Reason: e.Reason, RetryAfter: e.RetryAfter.Seconds()}
+}
+
+var _ rpc.Error = (*TxRateLimitError)(nil)
+var _ rpc.DataError = (*TxRateLimitError)(nil)
+
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	inFlight int
+}
+
+// take refills the bucket and takes a token if available, or returns how long until one is.
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// idle is true if the bucket is full and unused, so forgetting it changes nothing.
+func (b *tokenBucket) idle(now time.Time, rate float64, burst int) bool {
+	if b.inFlight > 0 {
+		return false
+	}
+	if rate <= 0 {
+		return true
+	}
+	return b.tokens+now.Sub(b.updated).Seconds()*rate >= float64(burst)
+}
+
+// TxRateLimiter applies token bucket rate limits and queue quotas per transaction sender and per client ip.
+type TxRateLimiter struct {
+	config func() *TxRateLimitConfig
+	signer types.Signer
+
+	mutex       sync.Mutex
+	senders     map[common.Address]*tokenBucket
+	ips         map[string]*tokenBucket
+	lastCleanup time.Time
+
+	// parsed allowlists, re-parsed when the config changes
+	senderAllowlistRaw string
+	senderAllowlist    map[common.Address]struct{}
+	ipAllowlistRaw     string
+	ipAllowlist        []*net.IPNet
+}
+
+func NewTxRateLimiter(chainConfig *params.ChainConfig, config func() *TxRateLimitConfig) *TxRateLimiter {
+	return &TxRateLimiter{
+		config:      config,
+		signer:      types.LatestSigner(chainConfig),
+		senders:     make(map[common.Address]*tokenBucket),
+		ips:         make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	remoteAddr := rpc.PeerInfoFromContext(ctx).RemoteAddr
+	if remoteAddr == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+func (l *TxRateLimiter) updateAllowlists(config *TxRateLimitConfig) {
+	if l.senderAllowlist == nil || config.SenderAllowlist != l.senderAllowlistRaw {
+		allowlist, err := parseSenderAllowlist(config.SenderAllowlist)
+		if err != nil {
+			log.Error("invalid rate limit sender allowlist, keeping the previous one", "err", err)
+		} else {
+			l.senderAllowlist = allowlist
+		}
+		l.senderAllowlistRaw = config.SenderAllowlist
+	}
+	if config.IPAllowlist != l.ipAllowlistRaw {
+		allowlist, err := parseIPAllowlist(config.IPAllowlist)
+		if err != nil {
+			log.Error("invalid rate limit ip allowlist, keeping the previous one", "err", err)
+		} else {
+			l.ipAllowlist = allowlist
+		}
+		l.ipAllowlistRaw = config.IPAllowlist
+	}
+}
+
+func (l *TxRateLimiter) ipAllowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range l.ipAllowlist {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *TxRateLimiter) cleanup(now time.Time, config *TxRateLimitConfig) {
+	if now.Sub(l.lastCleanup) < txRateLimitCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	for sender, bucket := range l.senders {
+		if bucket.idle(now, config.PerSenderRate, config.PerSenderBurst) {
+			delete(l.senders, sender)
+		}
+	}
+	for ip, bucket := range l.ips {
+		if bucket.idle(now, config.PerIPRate, config.PerIPBurst) {
+			delete(l.ips, ip)
+		}
+	}
+	txRateLimitBucketsGauge.Update(int64(len(l.senders) + len(l.ips)))
+}
+
+func newTokenBucket(now time.Time, burst int) *tokenBucket {
+	return &tokenBucket{tokens: float64(burst), updated: now}
+}
+
+// Acquire checks tx against the limits of its sender and the client ip found in ctx.
+// On success, the returned release func must be called once the transaction left the queue.
+func (l *TxRateLimiter) Acquire(ctx context.Context, tx *types.Transaction) (func(), error) {
+	config := l.config()
+	if !config.Enable {
+		return func() {}, nil
+	}
+	sender, err := types.Sender(l.signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	ip := clientIPFromContext(ctx)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	l.updateAllowlists(config)
+	l.cleanup(now, config)
+
+	var senderBucket, ipBucket *tokenBucket
+	if _, allowed := l.senderAllowlist[sender]; !allowed {
+		senderBucket = l.senders[sender]
+		if senderBucket == nil {
+			senderBucket = newTokenBucket(now, config.PerSenderBurst)
+			l.senders[sender] = senderBucket
+		}
+		if config.MaxQueuedPerSender > 0 && senderBucket.inFlight >= config.MaxQueuedPerSender {
+			txQueueQuotaExceedCounter.Inc(1)
+			return nil, &TxRateLimitError{Reason: fmt.Sprintf("sender %v has too many queued transactions", sender)}
+		}
+	}
+	if ip != "" && !l.ipAllowed(ip) {
+		ipBucket = l.ips[ip]
+		if ipBucket == nil {
+			ipBucket = newTokenBucket(now, config.PerIPBurst)
+			l.ips[ip] = ipBucket
+		}
+		if config.MaxQueuedPerIP > 0 && ipBucket.inFlight >= config.MaxQueuedPerIP {
+			txQueueQuotaExceedCounter.Inc(1)
+			return nil, &TxRateLimitError{Reason: "client has too many queued transactions"}
+		}
+	}
+	// check both buckets before taking from either, so a rejection doesn't cost the other a token
+	if senderBucket != nil {
+		check := *senderBucket
+		if wait := check.take(now, config.PerSenderRate, config.PerSenderBurst); wait > 0 {
+			txRateLimitedSenderCounter.Inc(1)
+			return nil, &TxRateLimitError{Reason: fmt.Sprintf("sender %v exceeded its rate limit", sender), RetryAfter: wait}
+		}
+	}
+	if ipBucket != nil {
+		if wait := ipBucket.take(now, config.PerIPRate, config.PerIPBurst); wait > 0 {
+			txRateLimitedIPCounter.Inc(1)
+			return nil, &TxRateLimitError{Reason: "client exceeded its rate limit", RetryAfter: wait}
+		}
+		ipBucket.inFlight++
+	}
+	if senderBucket != nil {
+		senderBucket.take(now, config.PerSenderRate, config.PerSenderBurst)
+		senderBucket.inFlight++
+	}
+	txRateLimitBucketsGauge.Update(int64(len(l.senders) + len(l.ips)))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			if senderBucket != nil {
+				senderBucket.inFlight--
+			}
+			if ipBucket != nil {
+				ipBucket.inFlight--
+			}
+		})
+	}, nil
+}
@@ -52,6 +52,7 @@ type ExecutionSequencer interface {
 	Pause()
 	Activate()
 	ForwardTo(url string) error
+	ForwardQueue(ctx context.Context) error
 	SequenceDelayedMessage(message *arbostypes.L1IncomingMessage, delayedSeqNum uint64) error
 	NextDelayedMessageNumber() (uint64, error)
 	SetTransactionStreamer(streamer TransactionStreamer)
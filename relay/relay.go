@@ -19,6 +19,7 @@ import (
 	m "github.com/yingdianRao/nitro/broadcaster/message"
 	"github.com/yingdianRao/nitro/cmd/genericconf"
 	"github.com/yingdianRao/nitro/cmd/util/confighelpers"
+	"github.com/yingdianRao/nitro/relay/sink"
 	"github.com/yingdianRao/nitro/util/sharedmetrics"
 	"github.com/yingdianRao/nitro/util/stopwaiter"
 	"github.com/yingdianRao/nitro/wsbroadcastserver"
@@ -30,6 +31,7 @@ type Relay struct {
 	broadcaster                 *broadcaster.Broadcaster
 	confirmedSequenceNumberChan chan arbutil.MessageIndex
 	messageChan                 chan m.BroadcastFeedMessage
+	sinks                       []*sink.Sink
 }
 
 type MessageQueue struct {
@@ -44,20 +46,40 @@ func (q *MessageQueue) AddBroadcastMessages(feedMessages []*m.BroadcastFeedMessa
 	return nil
 }
 
-func NewRelay(config *Config, feedErrChan chan error) (*Relay, error) {
+func NewRelay(ctx context.Context, config *Config, feedErrChan chan error) (*Relay, error) {
 
 	q := MessageQueue{make(chan m.BroadcastFeedMessage, config.Queue)}
 
 	confirmedSequenceNumberListener := make(chan arbutil.MessageIndex, config.Queue)
 
+	sinks, err := sink.NewSinks(&config.Sinks)
+	if err != nil {
+		return nil, err
+	}
+	// resume the feed from the first message a sink is missing, for sinks to publish every message
+	var resumeFrom arbutil.MessageIndex
+	for i, s := range sinks {
+		if err := s.Initialize(ctx); err != nil {
+			return nil, err
+		}
+		next, ok := s.ResumeFrom()
+		if !ok {
+			next = 0
+		}
+		if i == 0 || next < resumeFrom {
+			resumeFrom = next
+		}
+	}
+
 	clients, err := broadcastclients.NewBroadcastClients(
 		func() *broadcastclient.Config { return &config.Node.Feed.Input },
 		config.Chain.ID,
-		0,
+		resumeFrom,
 		&q,
 		confirmedSequenceNumberListener,
 		feedErrChan,
 		nil,
+		nil,
 	)
 	if err != nil {
 		return nil, err
@@ -74,6 +96,7 @@ func NewRelay(config *Config, feedErrChan chan error) (*Relay, error) {
 		broadcastClients:            clients,
 		confirmedSequenceNumberChan: confirmedSequenceNumberListener,
 		messageChan:                 q.queue,
+		sinks:                       sinks,
 	}, nil
 }
 
@@ -88,6 +111,9 @@ func (r *Relay) Start(ctx context.Context) error {
 		return errors.New("broadcast unable to start")
 	}
 
+	for _, s := range r.sinks {
+		s.Start(ctx)
+	}
 	r.broadcastClients.Start(ctx)
 
 	r.LaunchThread(func(ctx context.Context) {
@@ -98,6 +124,9 @@ func (r *Relay) Start(ctx context.Context) error {
 			case msg := <-r.messageChan:
 				sharedmetrics.UpdateSequenceNumberGauge(msg.SequenceNumber)
 				r.broadcaster.BroadcastSingleFeedMessage(&msg)
+				for _, s := range r.sinks {
+					s.Add(ctx, &msg)
+				}
 			case cs := <-r.confirmedSequenceNumberChan:
 				r.broadcaster.Confirm(cs)
 			}
@@ -115,6 +144,9 @@ func (r *Relay) StopAndWait() {
 	r.StopWaiter.StopAndWait()
 	r.broadcastClients.StopAndWait()
 	r.broadcaster.StopAndWait()
+	for _, s := range r.sinks {
+		s.StopAndWait()
+	}
 }
 
 type Config struct {
@@ -128,6 +160,7 @@ type Config struct {
 	PprofCfg      genericconf.PProf               `koanf:"pprof-cfg"`
 	Node          NodeConfig                      `koanf:"node"`
 	Queue         int                             `koanf:"queue"`
+	Sinks         sink.Config                     `koanf:"sinks"`
 }
 
 var ConfigDefault = Config{
@@ -141,6 +174,7 @@ var ConfigDefault = Config{
 	PprofCfg:      genericconf.PProfDefault,
 	Node:          NodeConfigDefault,
 	Queue:         1024,
+	Sinks:         sink.DefaultConfig,
 }
 
 func ConfigAddOptions(f *flag.FlagSet) {
@@ -154,6 +188,7 @@ func ConfigAddOptions(f *flag.FlagSet) {
 	genericconf.PProfAddOptions("pprof-cfg", f)
 	NodeConfigAddOptions("node", f)
 	f.Int("queue", ConfigDefault.Queue, "queue for incoming messages from sequencer")
+	sink.ConfigAddOptions("sinks", f)
 }
 
 type NodeConfig struct {
@@ -194,6 +229,9 @@ func ParseRelay(_ context.Context, args []string) (*Config, error) {
 	if err := confighelpers.EndCommonParse(k, &relayConfig); err != nil {
 		return nil, err
 	}
+	if err := relayConfig.Sinks.Validate(); err != nil {
+		return nil, err
+	}
 
 	if relayConfig.Conf.Dump {
 		err = confighelpers.DumpConfig(k, map[string]interface{}{})
@@ -0,0 +1,133 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	flag "github.com/spf13/pflag"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	"github.com/yingdianRao/nitro/util/redisutil"
+)
+
+type RedisStreamConfig struct {
+	Enable bool   `koanf:"enable"`
+	URL    string `koanf:"url"`
+	Stream string `koanf:"stream"`
+	MaxLen int64  `koanf:"max-len"`
+	// Queue is the number of messages queued for the stream before the relay waits for it
+	Queue         int           `koanf:"queue"`
+	RetryInterval time.Duration `koanf:"retry-interval"`
+}
+
+var DefaultRedisStreamConfig = RedisStreamConfig{
+	Enable:        false,
+	URL:           "",
+	Stream:        "sequencer-feed",
+	MaxLen:        1_000_000,
+	Queue:         1024,
+	RetryInterval: time.Second,
+}
+
+func RedisStreamConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultRedisStreamConfig.Enable, "publish feed messages to a Redis stream")
+	f.String(prefix+".url", DefaultRedisStreamConfig.URL, "the Redis URL to publish feed messages to")
+	f.String(prefix+".stream", DefaultRedisStreamConfig.Stream, "the Redis stream to publish feed messages to, its entry IDs are the messages' sequence numbers")
+	f.Int64(prefix+".max-len", DefaultRedisStreamConfig.MaxLen, "approximate number of messages the Redis stream is trimmed to (0 = unlimited)")
+	f.Int(prefix+".queue", DefaultRedisStreamConfig.Queue, "number of messages queued for the Redis stream before the relay waits for it")
+	f.Duration(prefix+".retry-interval", DefaultRedisStreamConfig.RetryInterval, "duration to wait before publishing a message to the Redis stream again after failing to")
+}
+
+func (c *RedisStreamConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.URL == "" {
+		return errors.New("redis stream sink enabled without a url")
+	}
+	if c.Stream == "" {
+		return errors.New("redis stream sink enabled without a stream")
+	}
+	if c.MaxLen < 0 {
+		return errors.New("redis stream sink max-len must not be negative")
+	}
+	if c.Queue <= 0 {
+		return errors.New("redis stream sink queue must be positive")
+	}
+	if c.RetryInterval <= 0 {
+		return errors.New("redis stream sink retry-interval must be positive")
+	}
+	return nil
+}
+
+// errStreamIDTooSmall is the error redis returns when adding an entry to a stream with an ID not after its last one's.
+const errStreamIDTooSmall = "ERR The ID specified in XADD is equal or smaller than the target stream top item"
+
+// RedisStreamPublisher publishes feed messages to a Redis stream. The ID of the entry of a message is
+// "<sequence number>-1", and the entry holds the message's json under "message".
+type RedisStreamPublisher struct {
+	client redis.UniversalClient
+	config *RedisStreamConfig
+}
+
+func NewRedisStreamPublisher(config *RedisStreamConfig) (*RedisStreamPublisher, error) {
+	client, err := redisutil.RedisClientFromURL(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errors.New("redis stream sink enabled without a url")
+	}
+	return &RedisStreamPublisher{
+		client: client,
+		config: config,
+	}, nil
+}
+
+func redisStreamID(seqNum arbutil.MessageIndex) string {
+	// 0-0 isn't a valid stream entry ID
+	return strconv.FormatUint(uint64(seqNum), 10) + "-1"
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, seqNum arbutil.MessageIndex, data []byte) error {
+	err := p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.config.Stream,
+		MaxLen: p.config.MaxLen,
+		Approx: true,
+		ID:     redisStreamID(seqNum),
+		Values: map[string]interface{}{
+			"sequenceNumber": uint64(seqNum),
+			"message":        data,
+		},
+	}).Err()
+	if err != nil && strings.HasPrefix(err.Error(), errStreamIDTooSmall) {
+		// the stream has the message already
+		return nil
+	}
+	return err
+}
+
+func (p *RedisStreamPublisher) LastAcknowledged(ctx context.Context) (arbutil.MessageIndex, bool, error) {
+	entries, err := p.client.XRevRangeN(ctx, p.config.Stream, "+", "-", 1).Result()
+	if err != nil || len(entries) == 0 {
+		return 0, false, err
+	}
+	seqNum, _, _ := strings.Cut(entries[0].ID, "-")
+	parsed, err := strconv.ParseUint(seqNum, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("unexpected entry ID %v in redis stream %v: %w", entries[0].ID, p.config.Stream, err)
+	}
+	return arbutil.MessageIndex(parsed), true, nil
+}
+
+func (p *RedisStreamPublisher) Close() error {
+	return p.client.Close()
+}
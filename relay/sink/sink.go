@@ -0,0 +1,193 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package sink publishes the relay's feed messages to message buses, beside its websocket output.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/stopwaiter"
+)
+
+// Publisher publishes feed messages to a message bus.
+type Publisher interface {
+	// Publish publishes data, the json of the message with sequence number seqNum, keyed by seqNum.
+	// It returns once the bus acknowledged the message, publishing a message again must not fail.
+	Publish(ctx context.Context, seqNum arbutil.MessageIndex, data []byte) error
+	// LastAcknowledged returns the sequence number of the last message the bus acknowledged, if any.
+	LastAcknowledged(ctx context.Context) (arbutil.MessageIndex, bool, error)
+	Close() error
+}
+
+type Config struct {
+	RedisStream RedisStreamConfig `koanf:"redis-stream"`
+}
+
+var DefaultConfig = Config{
+	RedisStream: DefaultRedisStreamConfig,
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	RedisStreamConfigAddOptions(prefix+".redis-stream", f)
+}
+
+func (c *Config) Validate() error {
+	return c.RedisStream.Validate()
+}
+
+// NewSinks creates the sinks enabled in config.
+func NewSinks(config *Config) ([]*Sink, error) {
+	var sinks []*Sink
+	if config.RedisStream.Enable {
+		publisher, err := NewRedisStreamPublisher(&config.RedisStream)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, NewSink("redis-stream", publisher, config.RedisStream.Queue, config.RedisStream.RetryInterval))
+	}
+	return sinks, nil
+}
+
+// Sink publishes feed messages in order to a Publisher, waiting for each to be acknowledged
+// before publishing the next one, and retrying it until it is. A sink that can't keep up
+// slows the relay down once its queue is full rather than leaving messages out.
+type Sink struct {
+	stopwaiter.StopWaiter
+	name      string
+	publisher Publisher
+	queue     chan *m.BroadcastFeedMessage
+	// retryInterval is waited for before publishing a message again after failing to
+	retryInterval time.Duration
+
+	// nextSeqNum is the sequence number following the last message acknowledged, if any was
+	nextSeqNum   arbutil.MessageIndex
+	acknowledged bool
+
+	publishedCounter    metrics.Counter
+	failuresCounter     metrics.Counter
+	acknowledgedGauge   metrics.Gauge
+	queuedMessagesGauge metrics.Gauge
+}
+
+func NewSink(name string, publisher Publisher, queue int, retryInterval time.Duration) *Sink {
+	return &Sink{
+		name:                name,
+		publisher:           publisher,
+		queue:               make(chan *m.BroadcastFeedMessage, queue),
+		retryInterval:       retryInterval,
+		publishedCounter:    metrics.GetOrRegisterCounter("arb/relay/sink/"+name+"/published", nil),
+		failuresCounter:     metrics.GetOrRegisterCounter("arb/relay/sink/"+name+"/failures", nil),
+		acknowledgedGauge:   metrics.GetOrRegisterGauge("arb/relay/sink/"+name+"/acknowledged", nil),
+		queuedMessagesGauge: metrics.GetOrRegisterGauge("arb/relay/sink/"+name+"/queued", nil),
+	}
+}
+
+func (s *Sink) Name() string {
+	return s.name
+}
+
+// Initialize reads the last message the bus acknowledged, to resume publishing after it.
+func (s *Sink) Initialize(ctx context.Context) error {
+	last, ok, err := s.publisher.LastAcknowledged(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading the last message acknowledged by sink %v: %w", s.name, err)
+	}
+	if ok {
+		s.nextSeqNum = last + 1
+		s.acknowledged = true
+		s.acknowledgedGauge.Update(int64(last))
+		log.Info("resuming sink", "sink", s.name, "sequenceNumber", s.nextSeqNum)
+	}
+	return nil
+}
+
+// ResumeFrom returns the sequence number the sink resumes publishing from, if it published any message.
+func (s *Sink) ResumeFrom() (arbutil.MessageIndex, bool) {
+	return s.nextSeqNum, s.acknowledged
+}
+
+// Add queues msg to be published, waiting while the queue is full.
+func (s *Sink) Add(ctx context.Context, msg *m.BroadcastFeedMessage) {
+	select {
+	case s.queue <- msg:
+	default:
+		log.Warn("sink queue is full, waiting for it", "sink", s.name, "sequenceNumber", msg.SequenceNumber)
+		select {
+		case s.queue <- msg:
+		case <-ctx.Done():
+		}
+	}
+	s.queuedMessagesGauge.Update(int64(len(s.queue)))
+}
+
+func (s *Sink) Start(ctxIn context.Context) {
+	s.StopWaiter.Start(ctxIn, s)
+	s.LaunchThread(func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-s.queue:
+				s.queuedMessagesGauge.Update(int64(len(s.queue)))
+				if err := s.publish(ctx, msg); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// publish publishes msg until it's acknowledged, it only fails once ctx is done.
+func (s *Sink) publish(ctx context.Context, msg *m.BroadcastFeedMessage) error {
+	if s.acknowledged && msg.SequenceNumber < s.nextSeqNum {
+		// the bus has it already, the feed sent it again after reconnecting
+		return nil
+	}
+	if s.acknowledged && msg.SequenceNumber > s.nextSeqNum {
+		log.Warn("sink missed messages", "sink", s.name, "expected", s.nextSeqNum, "got", msg.SequenceNumber)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		// can't happen, the feed marshals the same messages
+		log.Error("error marshalling feed message for sink", "sink", s.name, "sequenceNumber", msg.SequenceNumber, "err", err)
+		return nil
+	}
+	for {
+		err := s.publisher.Publish(ctx, msg.SequenceNumber, data)
+		if err == nil {
+			break
+		}
+		s.failuresCounter.Inc(1)
+		log.Warn("error publishing feed message to sink, retrying", "sink", s.name, "sequenceNumber", msg.SequenceNumber, "err", err)
+		timer := time.NewTimer(s.retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	s.nextSeqNum = msg.SequenceNumber + 1
+	s.acknowledged = true
+	s.publishedCounter.Inc(1)
+	s.acknowledgedGauge.Update(int64(msg.SequenceNumber))
+	return nil
+}
+
+func (s *Sink) StopAndWait() {
+	s.StopWaiter.StopAndWait()
+	if err := s.publisher.Close(); err != nil {
+		log.Warn("error closing sink", "sink", s.name, "err", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yingdianRao/nitro/arbos/arbostypes"
+	"github.com/yingdianRao/nitro/arbutil"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+	"github.com/yingdianRao/nitro/util/redisutil"
+	"github.com/yingdianRao/nitro/util/testhelpers"
+)
+
+// testPublisher is a bus acknowledging messages once failures is down to 0.
+type testPublisher struct {
+	mutex     sync.Mutex
+	failures  int
+	published []arbutil.MessageIndex
+}
+
+func (p *testPublisher) Publish(_ context.Context, seqNum arbutil.MessageIndex, _ []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("bus unavailable")
+	}
+	p.published = append(p.published, seqNum)
+	return nil
+}
+
+func (p *testPublisher) LastAcknowledged(context.Context) (arbutil.MessageIndex, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.published) == 0 {
+		return 0, false, nil
+	}
+	return p.published[len(p.published)-1], true, nil
+}
+
+func (p *testPublisher) Close() error {
+	return nil
+}
+
+func (p *testPublisher) Published() []arbutil.MessageIndex {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]arbutil.MessageIndex{}, p.published...)
+}
+
+func testMessage(seqNum arbutil.MessageIndex) *m.BroadcastFeedMessage {
+	return &m.BroadcastFeedMessage{
+		SequenceNumber: seqNum,
+		Message:        arbostypes.TestMessageWithMetadataAndRequestId,
+	}
+}
+
+func waitForPublished(t *testing.T, publisher *testPublisher, expected []arbutil.MessageIndex) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		published := publisher.Published()
+		if len(published) >= len(expected) {
+			for j := range expected {
+				if published[j] != expected[j] {
+					t.Fatalf("published %v, expected %v", published, expected)
+				}
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("published %v, expected %v", publisher.Published(), expected)
+}
+
+func TestSinkRetriesInOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	publisher := &testPublisher{failures: 3}
+	s := NewSink("test", publisher, 1, time.Millisecond)
+	testhelpers.RequireImpl(t, s.Initialize(ctx))
+	if _, ok := s.ResumeFrom(); ok {
+		t.Fatal("resuming an empty bus")
+	}
+	s.Start(ctx)
+	for i := 0; i < 5; i++ {
+		s.Add(ctx, testMessage(arbutil.MessageIndex(i)))
+	}
+	waitForPublished(t, publisher, []arbutil.MessageIndex{0, 1, 2, 3, 4})
+	s.StopAndWait()
+
+	// a new sink resumes after the last message acknowledged, skipping the ones the bus has
+	s = NewSink("test", publisher, 10, time.Millisecond)
+	testhelpers.RequireImpl(t, s.Initialize(ctx))
+	if next, ok := s.ResumeFrom(); !ok || next != 5 {
+		t.Fatal("unexpected resume sequence number", next, ok)
+	}
+	s.Start(ctx)
+	defer s.StopAndWait()
+	for i := 3; i < 7; i++ {
+		s.Add(ctx, testMessage(arbutil.MessageIndex(i)))
+	}
+	waitForPublished(t, publisher, []arbutil.MessageIndex{0, 1, 2, 3, 4, 5, 6})
+	time.Sleep(20 * time.Millisecond)
+	if published := publisher.Published(); len(published) != 7 {
+		t.Fatal("messages published again", published)
+	}
+}
+
+func TestRedisStreamPublisher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultRedisStreamConfig
+	config.Enable = true
+	config.URL = redisutil.CreateTestRedis(ctx, t)
+	testhelpers.RequireImpl(t, config.Validate())
+	publisher, err := NewRedisStreamPublisher(&config)
+	testhelpers.RequireImpl(t, err)
+	defer publisher.Close()
+
+	if _, ok, err := publisher.LastAcknowledged(ctx); err != nil || ok {
+		t.Fatal("unexpected last acknowledged message in empty stream", ok, err)
+	}
+	for _, seqNum := range []arbutil.MessageIndex{0, 1, 2, 1, 2} {
+		data, err := json.Marshal(testMessage(seqNum))
+		testhelpers.RequireImpl(t, err)
+		// publishing a message again succeeds without adding it
+		testhelpers.RequireImpl(t, publisher.Publish(ctx, seqNum, data))
+	}
+	last, ok, err := publisher.LastAcknowledged(ctx)
+	testhelpers.RequireImpl(t, err)
+	if !ok || last != 2 {
+		t.Fatal("unexpected last acknowledged message", last, ok)
+	}
+
+	entries, err := publisher.client.XRange(ctx, config.Stream, "-", "+").Result()
+	testhelpers.RequireImpl(t, err)
+	if len(entries) != 3 {
+		t.Fatal("expected 3 stream entries, got", len(entries))
+	}
+	for i, entry := range entries {
+		var msg m.BroadcastFeedMessage
+		testhelpers.RequireImpl(t, json.Unmarshal([]byte(entry.Values["message"].(string)), &msg))
+		if entry.ID != redisStreamID(arbutil.MessageIndex(i)) || msg.SequenceNumber != arbutil.MessageIndex(i) {
+			t.Fatal("unexpected stream entry", entry.ID, msg.SequenceNumber)
+		}
+	}
+}
@@ -35,6 +35,8 @@ var (
 	validatorFailedValidationsCounter = metrics.NewRegisteredCounter("arb/validator/validations/failed", nil)
 	validatorMsgCountCurrentBatch     = metrics.NewRegisteredGauge("arb/validator/msg_count_current_batch", nil)
 	validatorMsgCountValidatedGauge   = metrics.NewRegisteredGauge("arb/validator/msg_count_validated", nil)
+	validatorRunningValidationsGauge  = metrics.NewRegisteredGauge("arb/validator/validations/running", nil)
+	validatorCatchingUpGauge          = metrics.NewRegisteredGauge("arb/validator/catching_up", nil)
 )
 
 type BlockValidator struct {
@@ -68,6 +70,13 @@ type BlockValidator struct {
 	validatedA  uint64
 	validations containers.SyncMap[arbutil.MessageIndex, *validationStatus]
 
+	// set by the recording thread, read by anyone
+	catchingUp atomic.Bool
+	// number of validation runs in flight on each of validationSpawners, and the most
+	// there ever were at once, accessed atomically
+	spawnerRunning []int32
+	spawnerPeak    []int32
+
 	config BlockValidatorConfigFetcher
 
 	createNodesChan         chan struct{}
@@ -95,6 +104,7 @@ type BlockValidatorConfig struct {
 	Dangerous                   BlockValidatorDangerousConfig `koanf:"dangerous"`
 	MemoryFreeLimit             string                        `koanf:"memory-free-limit" reload:"hot"`
 	ValidationServerConfigsList string                        `koanf:"validation-server-configs-list" reload:"hot"`
+	CatchUp                     BlockValidatorCatchUpConfig   `koanf:"catch-up" reload:"hot"`
 
 	memoryFreeLimit int
 }
@@ -128,9 +138,42 @@ func (c *BlockValidatorConfig) Validate() error {
 			return fmt.Errorf("failed to validate one of the block-validator validation-server-configs. url: %s, err: %w", serverConfig.URL, err)
 		}
 	}
+	if c.CatchUp.Enable && c.CatchUp.PrerecordedPerWorker == 0 {
+		return errors.New("block-validator catch-up prerecorded-per-worker must be positive")
+	}
 	return nil
 }
 
+// BlockValidatorCatchUpConfig widens the creation and recording windows while validation is
+// far behind the chain, so that every validation worker across all servers can be kept busy.
+type BlockValidatorCatchUpConfig struct {
+	Enable               bool   `koanf:"enable"`
+	Threshold            uint64 `koanf:"threshold"`
+	PrerecordedPerWorker uint64 `koanf:"prerecorded-per-worker"`
+	MaxPrerecordedBlocks uint64 `koanf:"max-prerecorded-blocks"`
+}
+
+var DefaultBlockValidatorCatchUpConfig = BlockValidatorCatchUpConfig{
+	Enable:               true,
+	Threshold:            1024,
+	PrerecordedPerWorker: 2,
+	MaxPrerecordedBlocks: 4096,
+}
+
+var TestBlockValidatorCatchUpConfig = BlockValidatorCatchUpConfig{
+	Enable:               true,
+	Threshold:            64,
+	PrerecordedPerWorker: 2,
+	MaxPrerecordedBlocks: 256,
+}
+
+func BlockValidatorCatchUpConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBlockValidatorCatchUpConfig.Enable, "while far behind the chain, record and validate as many blocks in parallel as the validation servers have workers")
+	f.Uint64(prefix+".threshold", DefaultBlockValidatorCatchUpConfig.Threshold, "number of processed but unvalidated messages above which validation is considered catching up")
+	f.Uint64(prefix+".prerecorded-per-worker", DefaultBlockValidatorCatchUpConfig.PrerecordedPerWorker, "while catching up, record that many blocks ahead of validation per validation worker")
+	f.Uint64(prefix+".max-prerecorded-blocks", DefaultBlockValidatorCatchUpConfig.MaxPrerecordedBlocks, "upper bound on blocks recorded ahead of validation while catching up (0 for no bound besides memory-free-limit)")
+}
+
 type BlockValidatorDangerousConfig struct {
 	ResetBlockValidation bool `koanf:"reset-block-validation"`
 }
@@ -149,6 +192,7 @@ func BlockValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".failure-is-fatal", DefaultBlockValidatorConfig.FailureIsFatal, "failing a validation is treated as a fatal error")
 	BlockValidatorDangerousConfigAddOptions(prefix+".dangerous", f)
 	f.String(prefix+".memory-free-limit", DefaultBlockValidatorConfig.MemoryFreeLimit, "minimum free-memory limit after reaching which the blockvalidator pauses validation. Enabled by default as 1GB, to disable provide empty string")
+	BlockValidatorCatchUpConfigAddOptions(prefix+".catch-up", f)
 }
 
 func BlockValidatorDangerousConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -167,6 +211,7 @@ var DefaultBlockValidatorConfig = BlockValidatorConfig{
 	FailureIsFatal:              true,
 	Dangerous:                   DefaultBlockValidatorDangerousConfig,
 	MemoryFreeLimit:             "default",
+	CatchUp:                     DefaultBlockValidatorCatchUpConfig,
 }
 
 var TestBlockValidatorConfig = BlockValidatorConfig{
@@ -181,6 +226,7 @@ var TestBlockValidatorConfig = BlockValidatorConfig{
 	FailureIsFatal:           true,
 	Dangerous:                DefaultBlockValidatorDangerousConfig,
 	MemoryFreeLimit:          "default",
+	CatchUp:                  TestBlockValidatorCatchUpConfig,
 }
 
 var DefaultBlockValidatorDangerousConfig = BlockValidatorDangerousConfig{
@@ -226,6 +272,8 @@ func NewBlockValidator(
 		createNodesChan:         make(chan struct{}, 1),
 		sendRecordChan:          make(chan struct{}, 1),
 		progressValidationsChan: make(chan struct{}, 1),
+		spawnerRunning:          make([]int32, len(statelessBlockValidator.validationSpawners)),
+		spawnerPeak:             make([]int32, len(statelessBlockValidator.validationSpawners)),
 		config:                  config,
 		fatalErr:                fatalErr,
 	}
@@ -504,7 +552,7 @@ func (v *BlockValidator) createNextValidationEntry(ctx context.Context) (bool, e
 	v.reorgMutex.RLock()
 	defer v.reorgMutex.RUnlock()
 	pos := v.created()
-	if pos > v.validated()+arbutil.MessageIndex(v.config().ForwardBlocks) {
+	if pos > v.validated()+v.forwardBlocks() {
 		log.Trace("create validation entry: nothing to do", "pos", pos, "validated", v.validated())
 		return false, nil
 	}
@@ -588,6 +636,77 @@ func (v *BlockValidator) isMemoryLimitExceeded() bool {
 	return exceeded
 }
 
+// validationCapacity returns how many validation runs all spawners can handle concurrently.
+// A spawner's room only counts what's left besides the runs in flight on it.
+func (v *BlockValidator) validationCapacity() int {
+	total := 0
+	for i, spawner := range v.validationSpawners {
+		total += spawner.Room() + int(atomic.LoadInt32(&v.spawnerRunning[i]))
+	}
+	return total
+}
+
+// PeakRunningValidations returns the most validation runs there were in flight at once on each spawner.
+func (v *BlockValidator) PeakRunningValidations() []int {
+	peaks := make([]int, len(v.spawnerPeak))
+	for i := range v.spawnerPeak {
+		peaks[i] = int(atomic.LoadInt32(&v.spawnerPeak[i]))
+	}
+	return peaks
+}
+
+func (v *BlockValidator) updateCatchingUp(validated arbutil.MessageIndex) {
+	config := &v.config().CatchUp
+	catchingUp := false
+	if config.Enable {
+		processed, err := v.streamer.GetProcessedMessageCount()
+		if err != nil {
+			log.Warn("error getting processed message count", "err", err)
+			return
+		}
+		catchingUp = processed > validated+arbutil.MessageIndex(config.Threshold)
+	}
+	if v.catchingUp.Swap(catchingUp) != catchingUp {
+		if catchingUp {
+			log.Info("block validator catching up, validating in parallel", "validated", validated, "prerecorded", v.prerecordedBlocks())
+			validatorCatchingUpGauge.Update(1)
+		} else {
+			log.Info("block validator caught up", "validated", validated)
+			validatorCatchingUpGauge.Update(0)
+		}
+	}
+}
+
+// prerecordedBlocks returns how many blocks ahead of validation get recorded.
+// While catching up that's enough to keep every worker of every validation server busy.
+func (v *BlockValidator) prerecordedBlocks() arbutil.MessageIndex {
+	config := v.config()
+	prerecorded := config.PrerecordedBlocks
+	if v.catchingUp.Load() {
+		roots := len(v.GetModuleRootsToValidate())
+		if roots == 0 {
+			roots = 1
+		}
+		catchUp := uint64(v.validationCapacity()/roots) * config.CatchUp.PrerecordedPerWorker
+		if config.CatchUp.MaxPrerecordedBlocks > 0 && catchUp > config.CatchUp.MaxPrerecordedBlocks {
+			catchUp = config.CatchUp.MaxPrerecordedBlocks
+		}
+		if catchUp > prerecorded {
+			prerecorded = catchUp
+		}
+	}
+	return arbutil.MessageIndex(prerecorded)
+}
+
+// forwardBlocks returns how many blocks ahead of validation entries get created.
+func (v *BlockValidator) forwardBlocks() arbutil.MessageIndex {
+	forward := arbutil.MessageIndex(v.config().ForwardBlocks)
+	if prerecorded := v.prerecordedBlocks(); prerecorded > forward {
+		return prerecorded
+	}
+	return forward
+}
+
 func (v *BlockValidator) sendNextRecordRequests(ctx context.Context) (bool, error) {
 	if v.isMemoryLimitExceeded() {
 		log.Warn("sendNextRecordRequests: aborting due to running low on memory")
@@ -599,7 +718,8 @@ func (v *BlockValidator) sendNextRecordRequests(ctx context.Context) (bool, erro
 	validated := v.validated()
 	v.reorgMutex.RUnlock()
 
-	recordUntil := validated + arbutil.MessageIndex(v.config().PrerecordedBlocks) - 1
+	v.updateCatchingUp(validated)
+	recordUntil := validated + v.prerecordedBlocks() - 1
 	if recordUntil > created-1 {
 		recordUntil = created - 1
 	}
@@ -693,13 +813,14 @@ func (v *BlockValidator) advanceValidations(ctx context.Context) (*arbutil.Messa
 	defer v.reorgMutex.RUnlock()
 
 	wasmRoots := v.GetModuleRootsToValidate()
+	// each module root of an entry is an independent run, which may go to any spawner with room
 	rooms := make([]int, len(v.validationSpawners))
-	currentSpawnerIndex := 0
+	totalRoom, totalRunning := 0, 0
 	for i, spawner := range v.validationSpawners {
-		here := spawner.Room() / len(wasmRoots)
-		if here > 0 {
-			rooms[i] = here
-		}
+		totalRunning += int(atomic.LoadInt32(&v.spawnerRunning[i]))
+		// the spawner's room already leaves out the runs in flight on it
+		rooms[i] = spawner.Room()
+		totalRoom += rooms[i]
 	}
 	pos := v.validated() - 1 // to reverse the first +1 in the loop
 validationsLoop:
@@ -769,13 +890,8 @@ validationsLoop:
 			log.Trace("result validated", "count", v.validated(), "blockHash", v.lastValidGS.BlockHash)
 			continue
 		}
-		for currentSpawnerIndex < len(rooms) {
-			if rooms[currentSpawnerIndex] > 0 {
-				break
-			}
-			currentSpawnerIndex++
-		}
-		if currentSpawnerIndex == len(rooms) {
+		// if nothing is running, launch even if spawners can't fit all roots at once
+		if totalRoom < len(wasmRoots) && (totalRoom == 0 || totalRunning > 0) {
 			log.Trace("advanceValidations: no more room", "pos", pos)
 			return nil, nil
 		}
@@ -796,10 +912,25 @@ validationsLoop:
 			validatorPendingValidationsGauge.Inc(1)
 			defer validatorPendingValidationsGauge.Dec(1)
 			var runs []validator.ValidationRun
+			var runSpawners []int
 			for _, moduleRoot := range wasmRoots {
-				run := v.validationSpawners[currentSpawnerIndex].Launch(input, moduleRoot)
-				log.Trace("advanceValidations: launched", "pos", validationStatus.Entry.Pos, "moduleRoot", moduleRoot, "spawner", currentSpawnerIndex)
+				spawnerIndex := roomiestSpawner(rooms)
+				run := v.validationSpawners[spawnerIndex].Launch(input, moduleRoot)
+				log.Trace("advanceValidations: launched", "pos", validationStatus.Entry.Pos, "moduleRoot", moduleRoot, "spawner", spawnerIndex)
 				runs = append(runs, run)
+				runSpawners = append(runSpawners, spawnerIndex)
+				if rooms[spawnerIndex] > 0 {
+					rooms[spawnerIndex]--
+					totalRoom--
+				}
+				totalRunning++
+				running := atomic.AddInt32(&v.spawnerRunning[spawnerIndex], 1)
+				for peak := atomic.LoadInt32(&v.spawnerPeak[spawnerIndex]); running > peak; peak = atomic.LoadInt32(&v.spawnerPeak[spawnerIndex]) {
+					if atomic.CompareAndSwapInt32(&v.spawnerPeak[spawnerIndex], peak, running) {
+						break
+					}
+				}
+				validatorRunningValidationsGauge.Inc(1)
 			}
 			validationCtx, cancel := context.WithCancel(ctx)
 			validationStatus.Runs = runs
@@ -813,22 +944,33 @@ validationsLoop:
 
 				// validationStatus might be removed from under us
 				// trigger validation progress when done
-				for _, run := range runs {
-					_, err := run.Await(validationCtx)
-					if err != nil {
-						return
+				var err error
+				for i, run := range runs {
+					if err == nil {
+						_, err = run.Await(validationCtx)
 					}
+					atomic.AddInt32(&v.spawnerRunning[runSpawners[i]], -1)
+					validatorRunningValidationsGauge.Dec(1)
+				}
+				if err == nil {
+					nonBlockingTrigger(v.progressValidationsChan)
 				}
-				nonBlockingTrigger(v.progressValidationsChan)
 			})
-			rooms[currentSpawnerIndex]--
-			if rooms[currentSpawnerIndex] == 0 {
-				currentSpawnerIndex++
-			}
 		}
 	}
 }
 
+// roomiestSpawner returns the index of the spawner with the most room left.
+func roomiestSpawner(rooms []int) int {
+	best := 0
+	for i, room := range rooms {
+		if room > rooms[best] {
+			best = i
+		}
+	}
+	return best
+}
+
 func (v *BlockValidator) iterativeValidationProgress(ctx context.Context, ignored struct{}) time.Duration {
 	reorg, err := v.advanceValidations(ctx)
 	if err != nil {
@@ -0,0 +1,298 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package staker
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/yingdianRao/nitro/solgen/go/mocksgen"
+	"github.com/yingdianRao/nitro/util/containers"
+	"github.com/yingdianRao/nitro/validator"
+	"github.com/yingdianRao/nitro/validator/server_arb"
+)
+
+// challengeBehaviour scripts how a harnessed participant responds when it is its turn.
+type challengeBehaviour int
+
+const (
+	// behaveHonest lets the ChallengeManager act normally.
+	behaveHonest challengeBehaviour = iota
+	// behaveStall never moves, so the opponent eventually wins by timeout.
+	behaveStall
+	// behaveWrongBisection reports corrupted hashes for every step from LieFrom onwards,
+	// so the segments it posts while bisecting don't match its own machine.
+	behaveWrongBisection
+)
+
+type challengeParticipantConfig struct {
+	Machine   server_arb.MachineInterface
+	Behaviour challengeBehaviour
+	LieFrom   uint64
+	TimeLeft  time.Duration
+}
+
+type challengeHarnessConfig struct {
+	Asserter        challengeParticipantConfig
+	Challenger      challengeParticipantConfig
+	MaxInboxMessage uint64
+	MaxRounds       int
+}
+
+var defaultChallengeParticipantTimeLeft = time.Hour
+
+type challengeParticipant struct {
+	name      string
+	auth      *bind.TransactOpts
+	manager   *ChallengeManager
+	behaviour challengeBehaviour
+	timeLeft  time.Duration
+	// set once the participant hit a move it can't make, after which it can only time out
+	gaveUp error
+}
+
+// challengeHarness deploys a single execution challenge on a simulated parent chain
+// and drives two ChallengeManagers against each other until the result receiver
+// records a winner. It only covers the execution challenge, whose clock it can move
+// and whose moves it can corrupt; stakers challenging each other over a rollup are
+// run by the stakerChallengeHarness of the system tests.
+type challengeHarness struct {
+	t              *testing.T
+	ctx            context.Context
+	backend        *backends.SimulatedBackend
+	resultReceiver *mocksgen.MockResultReceiver
+	asserter       *challengeParticipant
+	challenger     *challengeParticipant
+	maxRounds      int
+}
+
+func newChallengeHarness(t *testing.T, ctx context.Context, config challengeHarnessConfig) *challengeHarness {
+	t.Helper()
+	deployer := createTransactOpts(t)
+	asserterAuth := createTransactOpts(t)
+	challengerAuth := createTransactOpts(t)
+	backend := backends.NewSimulatedBackend(createGenesisAlloc(deployer, asserterAuth, challengerAuth), 1_000_000_000)
+	backend.Commit()
+
+	ospEntry := DeployOneStepProofEntry(t, deployer, backend)
+	backend.Commit()
+
+	for _, participant := range []*challengeParticipantConfig{&config.Asserter, &config.Challenger} {
+		if participant.TimeLeft == 0 {
+			participant.TimeLeft = defaultChallengeParticipantTimeLeft
+		}
+	}
+	if config.MaxRounds == 0 {
+		config.MaxRounds = 200
+	}
+
+	resultReceiverAddr, _, resultReceiver, err := mocksgen.DeployMockResultReceiver(deployer, backend, common.Address{})
+	Require(t, err)
+
+	machine := config.Asserter.Machine.CloneMachineInterface()
+	startMachineHash := machine.Hash()
+	Require(t, machine.Step(ctx, ^uint64(0)))
+	endMachineHash := machine.Hash()
+	endMachineSteps := machine.GetStepCount()
+
+	challengeAddr, _, _, err := mocksgen.DeploySingleExecutionChallenge(
+		deployer,
+		backend,
+		ospEntry,
+		resultReceiverAddr,
+		config.MaxInboxMessage,
+		[2][32]byte{startMachineHash, endMachineHash},
+		new(big.Int).SetUint64(endMachineSteps),
+		asserterAuth.From,
+		challengerAuth.From,
+		big.NewInt(int64(config.Asserter.TimeLeft/time.Second)),
+		big.NewInt(int64(config.Challenger.TimeLeft/time.Second)),
+	)
+	Require(t, err)
+	backend.Commit()
+
+	h := &challengeHarness{
+		t:              t,
+		ctx:            ctx,
+		backend:        backend,
+		resultReceiver: resultReceiver,
+		maxRounds:      config.MaxRounds,
+	}
+	h.asserter = h.newParticipant("asserter", asserterAuth, challengeAddr, &config.Asserter)
+	h.challenger = h.newParticipant("challenger", challengerAuth, challengeAddr, &config.Challenger)
+	return h
+}
+
+func (h *challengeHarness) newParticipant(name string, auth *bind.TransactOpts, challengeAddr common.Address, config *challengeParticipantConfig) *challengeParticipant {
+	machine := config.Machine
+	run, err := server_arb.NewExecutionRun(h.ctx,
+		func(context.Context) (server_arb.MachineInterface, error) { return machine, nil },
+		&server_arb.DefaultMachineCacheConfig)
+	Require(h.t, err)
+	var execRun validator.ExecutionRun = run
+	if config.Behaviour == behaveWrongBisection {
+		execRun = &lyingExecutionRun{ExecutionRun: run, lieFrom: config.LieFrom}
+	}
+	manager, err := NewExecutionChallengeManager(h.backend, auth, challengeAddr, 1, execRun, 0, 12)
+	Require(h.t, err)
+	return &challengeParticipant{
+		name:      name,
+		auth:      auth,
+		manager:   manager,
+		behaviour: config.Behaviour,
+		timeLeft:  config.TimeLeft,
+	}
+}
+
+func (h *challengeHarness) opponent(p *challengeParticipant) *challengeParticipant {
+	if p == h.asserter {
+		return h.challenger
+	}
+	return h.asserter
+}
+
+func (h *challengeHarness) currentResponder() *challengeParticipant {
+	responder, err := h.asserter.manager.con.CurrentResponder(&bind.CallOpts{Context: h.ctx}, h.asserter.manager.challengeIndex)
+	Require(h.t, err)
+	switch responder {
+	case h.asserter.auth.From:
+		return h.asserter
+	case h.challenger.auth.From:
+		return h.challenger
+	default:
+		Fail(h.t, "unexpected current responder", responder)
+		return nil
+	}
+}
+
+// timeOut lets the stalled participant's clock run out and has its opponent claim the win.
+func (h *challengeHarness) timeOut(stalled *challengeParticipant) {
+	Require(h.t, h.backend.AdjustTime(stalled.timeLeft+time.Second))
+	h.backend.Commit()
+	winner := h.opponent(stalled)
+	_, err := winner.manager.con.Timeout(winner.auth, winner.manager.challengeIndex)
+	Require(h.t, err, "error timing out", stalled.name)
+	h.backend.Commit()
+}
+
+// Run drives the challenge to completion and returns the winner's address.
+func (h *challengeHarness) Run() common.Address {
+	for round := 0; round < h.maxRounds; round++ {
+		winner, err := h.resultReceiver.Winner(&bind.CallOpts{Context: h.ctx})
+		Require(h.t, err)
+		if winner != (common.Address{}) {
+			return winner
+		}
+		current := h.currentResponder()
+		if current.behaviour == behaveStall || current.gaveUp != nil {
+			h.t.Log(current.name, "not moving, timing out")
+			h.timeOut(current)
+			continue
+		}
+		_, err = current.manager.Act(h.ctx)
+		if err != nil {
+			if !isLosingMoveError(err) {
+				Fail(h.t, current.name, "hit unexpected error:", err)
+			}
+			h.t.Log(current.name, "can't move:", err)
+			current.gaveUp = err
+			continue
+		}
+		h.backend.Commit()
+	}
+	Fail(h.t, "challenge didn't complete within", h.maxRounds, "rounds")
+	return common.Address{}
+}
+
+func isLosingMoveError(err error) bool {
+	return strings.Contains(err.Error(), "lost challenge") ||
+		strings.Contains(err.Error(), "SAME_OSP_END") ||
+		strings.Contains(err.Error(), "agreed with entire challenge")
+}
+
+// lyingExecutionRun corrupts the hash of every step from lieFrom onwards while
+// leaving proofs untouched.
+type lyingExecutionRun struct {
+	validator.ExecutionRun
+	lieFrom uint64
+}
+
+func (r *lyingExecutionRun) GetStepAt(position uint64) containers.PromiseInterface[*validator.MachineStepResult] {
+	inner := r.ExecutionRun.GetStepAt(position)
+	if position < r.lieFrom {
+		return inner
+	}
+	promise := containers.NewPromise[*validator.MachineStepResult](nil)
+	go func() {
+		res, err := inner.Await(context.Background())
+		if err != nil {
+			promise.ProduceError(err)
+			return
+		}
+		lie := *res
+		lie.Hash = crypto.Keccak256Hash(res.Hash[:], []byte("wrong bisection"))
+		promise.Produce(&lie)
+	}()
+	return &promise
+}
+
+func machineStepCount(t *testing.T, ctx context.Context, machine server_arb.MachineInterface) uint64 {
+	clone := machine.CloneMachineInterface()
+	Require(t, clone.Step(ctx, ^uint64(0)))
+	return clone.GetStepCount()
+}
+
+func TestChallengeHarnessFaultyAsserter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	machine := createBaseMachine(t, "global-state.wasm", []string{"global-state-wrapper.wasm"})
+	h := newChallengeHarness(t, ctx, challengeHarnessConfig{
+		Asserter:   challengeParticipantConfig{Machine: server_arb.NewIncorrectMachine(machine, 200)},
+		Challenger: challengeParticipantConfig{Machine: machine.Clone()},
+	})
+	if winner := h.Run(); winner != h.challenger.auth.From {
+		Fail(t, "expected challenger to win, got", winner)
+	}
+}
+
+func TestChallengeHarnessStalledAsserter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	machine := createBaseMachine(t, "global-state.wasm", []string{"global-state-wrapper.wasm"})
+	h := newChallengeHarness(t, ctx, challengeHarnessConfig{
+		Asserter: challengeParticipantConfig{
+			Machine:   machine.Clone(),
+			Behaviour: behaveStall,
+			TimeLeft:  time.Minute,
+		},
+		Challenger: challengeParticipantConfig{Machine: server_arb.NewIncorrectMachine(machine, 200)},
+	})
+	if winner := h.Run(); winner != h.challenger.auth.From {
+		Fail(t, "expected stalled asserter to lose by timeout, got winner", winner)
+	}
+}
+
+func TestChallengeHarnessWrongBisection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	machine := createBaseMachine(t, "global-state.wasm", []string{"global-state-wrapper.wasm"})
+	h := newChallengeHarness(t, ctx, challengeHarnessConfig{
+		Asserter: challengeParticipantConfig{Machine: machine.Clone()},
+		Challenger: challengeParticipantConfig{
+			Machine:   machine.Clone(),
+			Behaviour: behaveWrongBisection,
+			LieFrom:   machineStepCount(t, ctx, machine) / 2,
+		},
+	})
+	if winner := h.Run(); winner != h.asserter.auth.From {
+		Fail(t, "expected asserter to beat wrong bisections, got winner", winner)
+	}
+}
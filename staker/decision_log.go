@@ -0,0 +1,193 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package staker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	decisionLogEntryPrefix  = []byte("e")          // maps a staker cycle number to an rlp encoded StakerDecision
+	decisionLogNextCycleKey = []byte("_nextCycle") // contains the number of the next staker cycle to be recorded
+)
+
+// Actions recorded in a StakerDecision
+const (
+	StakerActionDelayedForGas       = "delayed-for-high-gas"
+	StakerActionTimeoutChallenges   = "timeout-challenges"
+	StakerActionConfirmNode         = "confirm-node"
+	StakerActionRejectNode          = "reject-node"
+	StakerActionReturnOldDeposit    = "return-old-deposit"
+	StakerActionWithdrawFunds       = "withdraw-staker-funds"
+	StakerActionChallengeMove       = "challenge-move"
+	StakerActionStakeOnNewNode      = "stake-on-new-node"
+	StakerActionStakeOnExistingNode = "stake-on-existing-node"
+	StakerActionPlaceNewStake       = "place-new-stake"
+	StakerActionCreateChallenge     = "create-challenge"
+	StakerActionSweepWallet         = "sweep-wallet"
+)
+
+// StakerDecision records the inputs a single Staker.Act cycle saw and what it decided to do.
+type StakerDecision struct {
+	Cycle               uint64        `json:"cycle"`
+	Timestamp           uint64        `json:"timestamp"`
+	DryRun              bool          `json:"dryRun"`
+	Strategy            string        `json:"strategy"`
+	EffectiveStrategy   string        `json:"effectiveStrategy"`
+	LatestStakedNode    uint64        `json:"latestStakedNode"`
+	LatestConfirmedNode uint64        `json:"latestConfirmedNode"`
+	ValidatedCount      uint64        `json:"validatedCount"`
+	WalletBalance       *big.Int      `json:"walletBalance"`
+	Actions             []string      `json:"actions"`
+	Transactions        []common.Hash `json:"transactions"`
+	Error               string        `json:"error"`
+}
+
+func (d *StakerDecision) addAction(action string) {
+	if d == nil {
+		return
+	}
+	d.Actions = append(d.Actions, action)
+}
+
+// StakerDryRunResult is what Staker.DryRunAct would have done in a real cycle.
+// When using a smart contract wallet, Transactions are the calls that would be batched through the wallet.
+type StakerDryRunResult struct {
+	Decision     StakerDecision       `json:"decision"`
+	Transactions []*types.Transaction `json:"transactions"`
+}
+
+// decisionLog persists the last maxEntries staker decisions, keyed by cycle number.
+type decisionLog struct {
+	mutex      sync.Mutex
+	db         ethdb.Database
+	maxEntries uint64
+	nextCycle  uint64
+}
+
+func newDecisionLog(db ethdb.Database, maxEntries uint64) (*decisionLog, error) {
+	l := &decisionLog{
+		db:         db,
+		maxEntries: maxEntries,
+	}
+	exists, err := db.Has(decisionLogNextCycleKey)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		nextCycleBytes, err := db.Get(decisionLogNextCycleKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(nextCycleBytes) != 8 {
+			return nil, errors.New("invalid staker decision log cycle count")
+		}
+		l.nextCycle = binary.BigEndian.Uint64(nextCycleBytes)
+	}
+	return l, nil
+}
+
+func decisionLogEntryKey(cycle uint64) []byte {
+	key := make([]byte, len(decisionLogEntryPrefix)+8)
+	copy(key, decisionLogEntryPrefix)
+	binary.BigEndian.PutUint64(key[len(decisionLogEntryPrefix):], cycle)
+	return key
+}
+
+// NextCycle returns the cycle number the next recorded decision will get.
+func (l *decisionLog) NextCycle() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.nextCycle
+}
+
+// Append assigns the decision a cycle number and persists it, pruning entries past maxEntries.
+func (l *decisionLog) Append(decision *StakerDecision) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	decision.Cycle = l.nextCycle
+	encoded, err := rlp.EncodeToBytes(decision)
+	if err != nil {
+		return err
+	}
+	batch := l.db.NewBatch()
+	if err := batch.Put(decisionLogEntryKey(decision.Cycle), encoded); err != nil {
+		return err
+	}
+	if decision.Cycle >= l.maxEntries {
+		if err := l.pruneBefore(batch, decision.Cycle-l.maxEntries+1); err != nil {
+			return err
+		}
+	}
+	var nextCycleBytes [8]byte
+	binary.BigEndian.PutUint64(nextCycleBytes[:], decision.Cycle+1)
+	if err := batch.Put(decisionLogNextCycleKey, nextCycleBytes[:]); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	l.nextCycle = decision.Cycle + 1
+	return nil
+}
+
+// pruneBefore adds deleting every entry before cycle to batch. Usually that's only the entry maxEntries
+// cycles back, but a restart with a smaller maxEntries leaves more of them.
+func (l *decisionLog) pruneBefore(batch ethdb.Batch, cycle uint64) error {
+	iter := l.db.NewIterator(decisionLogEntryPrefix, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := bytes.TrimPrefix(iter.Key(), decisionLogEntryPrefix)
+		if len(key) != 8 || binary.BigEndian.Uint64(key) >= cycle {
+			break
+		}
+		if err := batch.Delete(iter.Key()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// Latest returns up to count of the most recent decisions, newest first.
+func (l *decisionLog) Latest(count uint64) ([]StakerDecision, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if count > l.maxEntries {
+		count = l.maxEntries
+	}
+	if count > l.nextCycle {
+		count = l.nextCycle
+	}
+	decisions := make([]StakerDecision, 0, count)
+	for cycle := l.nextCycle; cycle > l.nextCycle-count; cycle-- {
+		key := decisionLogEntryKey(cycle - 1)
+		has, err := l.db.Has(key)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			// older entries were pruned while the log was smaller
+			break
+		}
+		encoded, err := l.db.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		var decision StakerDecision
+		if err := rlp.DecodeBytes(encoded, &decision); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, nil
+}
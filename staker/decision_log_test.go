@@ -0,0 +1,93 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package staker
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+func TestDecisionLogPrunesAndPersists(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	decisions, err := newDecisionLog(db, 3)
+	Require(t, err)
+	for i := uint64(0); i < 5; i++ {
+		Require(t, decisions.Append(&StakerDecision{LatestStakedNode: i, Actions: []string{StakerActionConfirmNode}}))
+	}
+
+	latest, err := decisions.Latest(10)
+	Require(t, err)
+	if len(latest) != 3 {
+		Fail(t, "expected 3 decisions, got", len(latest))
+	}
+	for i, decision := range latest {
+		expected := uint64(4 - i)
+		if decision.Cycle != expected || decision.LatestStakedNode != expected {
+			Fail(t, "decision", i, "has cycle", decision.Cycle, "and staked node", decision.LatestStakedNode, "expected", expected)
+		}
+	}
+	if has, err := db.Has(decisionLogEntryKey(1)); err != nil || has {
+		Fail(t, "expected cycle 1 to be pruned", has, err)
+	}
+
+	reopened, err := newDecisionLog(db, 3)
+	Require(t, err)
+	if reopened.NextCycle() != 5 {
+		Fail(t, "reopened decision log at cycle", reopened.NextCycle())
+	}
+	latest, err = reopened.Latest(1)
+	Require(t, err)
+	if len(latest) != 1 || len(latest[0].Actions) != 1 || latest[0].Actions[0] != StakerActionConfirmNode {
+		Fail(t, "unexpected decision after reopening", latest)
+	}
+}
+
+func TestDecisionLogShrunk(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	decisions, err := newDecisionLog(db, 5)
+	Require(t, err)
+	for i := uint64(0); i < 5; i++ {
+		Require(t, decisions.Append(&StakerDecision{LatestStakedNode: i}))
+	}
+
+	// the decision log size was lowered, the next decision prunes all the entries past it
+	shrunk, err := newDecisionLog(db, 2)
+	Require(t, err)
+	Require(t, shrunk.Append(&StakerDecision{LatestStakedNode: 5}))
+	for cycle := uint64(0); cycle < 4; cycle++ {
+		if has, err := db.Has(decisionLogEntryKey(cycle)); err != nil || has {
+			Fail(t, "expected cycle", cycle, "to be pruned", has, err)
+		}
+	}
+	latest, err := shrunk.Latest(10)
+	Require(t, err)
+	if len(latest) != 2 || latest[0].Cycle != 5 || latest[1].Cycle != 4 {
+		Fail(t, "unexpected decisions after shrinking the log", latest)
+	}
+}
+
+func TestDecisionLogGrownAfterPruning(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	decisions, err := newDecisionLog(db, 2)
+	Require(t, err)
+	for i := uint64(0); i < 5; i++ {
+		Require(t, decisions.Append(&StakerDecision{LatestStakedNode: i}))
+	}
+
+	// the decision log size was raised, but the older decisions are already pruned
+	grown, err := newDecisionLog(db, 10)
+	Require(t, err)
+	latest, err := grown.Latest(10)
+	Require(t, err)
+	if len(latest) != 2 || latest[0].Cycle != 4 || latest[1].Cycle != 3 {
+		Fail(t, "unexpected decisions after growing the log", latest)
+	}
+	Require(t, grown.Append(&StakerDecision{LatestStakedNode: 5}))
+	latest, err = grown.Latest(10)
+	Require(t, err)
+	if len(latest) != 3 || latest[0].Cycle != 5 {
+		Fail(t, "unexpected decisions after appending to the grown log", latest)
+	}
+}
@@ -17,7 +17,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/yingdianRao/nitro/arbutil"
@@ -131,16 +130,9 @@ func (v *L1Validator) updateBlockValidatorModuleRoot(ctx context.Context) error
 	return nil
 }
 
-func (v *L1Validator) resolveTimedOutChallenges(ctx context.Context) (*types.Transaction, error) {
+func (v *L1Validator) timedOutChallenges(ctx context.Context) ([]uint64, error) {
 	challengesToEliminate, _, err := v.validatorUtils.TimedOutChallenges(v.getCallOpts(ctx), v.rollupAddress, 0, 10)
-	if err != nil {
-		return nil, err
-	}
-	if len(challengesToEliminate) == 0 {
-		return nil, nil
-	}
-	log.Info("timing out challenges", "count", len(challengesToEliminate))
-	return v.wallet.TimeoutChallenges(ctx, challengesToEliminate)
+	return challengesToEliminate, err
 }
 
 func (v *L1Validator) resolveNextNode(ctx context.Context, info *StakerInfo, latestConfirmedNode *uint64) (bool, error) {
@@ -0,0 +1,176 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package staker
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/util/arbmath"
+)
+
+var (
+	stakerRetireStageGauge         = metrics.NewRegisteredGauge("arb/staker/retire/stage", nil)
+	stakerRetireWalletBalanceGauge = metrics.NewRegisteredGaugeFloat64("arb/staker/retire/wallet_balance", nil)
+)
+
+// retireStage is reported through stakerRetireStageGauge while running the retire strategy.
+type retireStage int64
+
+const (
+	// Defending our stake in an ongoing challenge
+	retireStageChallenge retireStage = iota + 1
+	// Our latest staked node isn't confirmed yet
+	retireStageWaitingForConfirmation
+	// Returning our stake now that it's refundable
+	retireStageWithdrawingStake
+	// Withdrawing staker funds held by the rollup
+	retireStageWithdrawingFunds
+	// Sweeping the smart contract wallet's balance to the configured address
+	retireStageSweeping
+	// Nothing left to do
+	retireStageRetired
+)
+
+// walletSweeper is implemented by validator wallets which hold funds of their own.
+type walletSweeper interface {
+	WithdrawEth(ctx context.Context, amount *big.Int, destination common.Address) (*types.Transaction, error)
+}
+
+func (s *Staker) setRetireStage(stage retireStage) {
+	stakerRetireStageGauge.Update(int64(stage))
+}
+
+// retire is Act for the retire strategy. It never makes or stakes on assertions; it waits until
+// our stake is refundable, withdraws it along with any staker funds, and then sweeps the smart
+// contract wallet (if any) to the configured address.
+func (s *Staker) retire(ctx context.Context, decision *StakerDecision, rawInfo *StakerInfo, dryRun bool) (*types.Transaction, error) {
+	decision.EffectiveStrategy = RetireStrategy.String()
+	walletAddressOrZero := s.wallet.AddressOrZero()
+	if walletAddressOrZero == (common.Address{}) {
+		s.setRetireStage(retireStageRetired)
+		return nil, nil
+	}
+	callOpts := s.getCallOpts(ctx)
+	latestConfirmedNode, err := s.rollup.LatestConfirmed(callOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting latest confirmed node: %w", err)
+	}
+	decision.LatestConfirmedNode = latestConfirmedNode
+
+	var withdrawable, sweepable *big.Int
+	sweeper, isSweeper := s.wallet.(walletSweeper)
+	sweepTo := s.config.retireSweepAddress
+	if rawInfo == nil {
+		withdrawable, err = s.rollup.WithdrawableFunds(callOpts, walletAddressOrZero)
+		if err != nil {
+			return nil, fmt.Errorf("error checking withdrawable funds of our staker %v: %w", walletAddressOrZero, err)
+		}
+		if withdrawable.Sign() == 0 && isSweeper && sweepTo != (common.Address{}) {
+			sweepable, err = s.client.BalanceAt(ctx, walletAddressOrZero, nil)
+			if err != nil {
+				return nil, fmt.Errorf("error getting balance of validator wallet %v: %w", walletAddressOrZero, err)
+			}
+			stakerRetireWalletBalanceGauge.Update(arbmath.BalancePerEther(sweepable))
+		}
+	}
+
+	stage := retireStep(rawInfo, latestConfirmedNode, withdrawable, sweepable)
+	s.setRetireStage(stage)
+	switch stage {
+	case retireStageChallenge:
+		if err := s.handleConflict(ctx, rawInfo); err != nil {
+			return nil, fmt.Errorf("error handling conflict: %w", err)
+		}
+		return s.executeTransactions(ctx, dryRun)
+	case retireStageWaitingForConfirmation:
+		// Help our staked node get confirmed by resolving the nodes before it.
+		resolvingNode, err := s.resolveNextNode(ctx, rawInfo, &latestConfirmedNode)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving node %v: %w", latestConfirmedNode+1, err)
+		}
+		if resolvingNode {
+			if latestConfirmedNode == decision.LatestConfirmedNode {
+				decision.addAction(StakerActionRejectNode)
+			} else {
+				decision.addAction(StakerActionConfirmNode)
+			}
+		}
+		log.Info("retiring validator waiting for staked node to be confirmed", "stakedNode", rawInfo.LatestStakedNode, "latestConfirmed", latestConfirmedNode)
+		return s.executeTransactions(ctx, dryRun)
+	case retireStageWithdrawingStake:
+		auth, err := s.builder.Auth(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.rollup.ReturnOldDeposit(auth, walletAddressOrZero)
+		if err != nil {
+			return nil, fmt.Errorf("error returning old deposit (from our staker %v): %w", walletAddressOrZero, err)
+		}
+		decision.addAction(StakerActionReturnOldDeposit)
+		if s.wallet.CanBatchTxs() {
+			auth, err = s.builder.Auth(ctx)
+			if err != nil {
+				return nil, err
+			}
+			_, err = s.rollup.WithdrawStakerFunds(auth)
+			if err != nil {
+				return nil, fmt.Errorf("error withdrawing staker funds from our staker %v: %w", walletAddressOrZero, err)
+			}
+			decision.addAction(StakerActionWithdrawFunds)
+		}
+		log.Info("retiring validator returning stake", "stakedNode", rawInfo.LatestStakedNode)
+		return s.executeTransactions(ctx, dryRun)
+	case retireStageWithdrawingFunds:
+		auth, err := s.builder.Auth(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.rollup.WithdrawStakerFunds(auth)
+		if err != nil {
+			return nil, fmt.Errorf("error withdrawing our staker %v funds: %w", walletAddressOrZero, err)
+		}
+		decision.addAction(StakerActionWithdrawFunds)
+		log.Info("retiring validator withdrawing staker funds", "amount", withdrawable)
+		return s.executeTransactions(ctx, dryRun)
+	case retireStageSweeping:
+		decision.addAction(StakerActionSweepWallet)
+		if dryRun {
+			return nil, nil
+		}
+		log.Info("retiring validator sweeping wallet funds", "wallet", walletAddressOrZero, "to", sweepTo, "amount", sweepable)
+		return sweeper.WithdrawEth(ctx, sweepable, sweepTo)
+	}
+
+	log.Info("validator retired; it's now safe to shut down", "wallet", walletAddressOrZero)
+	return nil, nil
+}
+
+// retireStep returns the retire strategy's next stage, given our stake (nil if we aren't staked),
+// the staker funds withdrawable from the rollup and the balance to sweep from the smart contract
+// wallet (nil if it isn't swept). The funds and balance are only needed once we're no longer staked.
+func retireStep(rawInfo *StakerInfo, latestConfirmedNode uint64, withdrawable *big.Int, sweepable *big.Int) retireStage {
+	if rawInfo != nil {
+		if rawInfo.CurrentChallenge != nil {
+			return retireStageChallenge
+		}
+		if rawInfo.LatestStakedNode > latestConfirmedNode {
+			return retireStageWaitingForConfirmation
+		}
+		return retireStageWithdrawingStake
+	}
+	if withdrawable != nil && withdrawable.Sign() > 0 {
+		return retireStageWithdrawingFunds
+	}
+	if sweepable != nil && sweepable.Sign() > 0 {
+		return retireStageSweeping
+	}
+	return retireStageRetired
+}
@@ -0,0 +1,77 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package staker
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRetireStep(t *testing.T) {
+	challenge := uint64(3)
+	tests := []struct {
+		name                string
+		rawInfo             *StakerInfo
+		latestConfirmedNode uint64
+		withdrawable        *big.Int
+		sweepable           *big.Int
+		expected            retireStage
+	}{
+		{
+			name:                "defending our stake",
+			rawInfo:             &StakerInfo{LatestStakedNode: 5, CurrentChallenge: &challenge},
+			latestConfirmedNode: 5,
+			expected:            retireStageChallenge,
+		},
+		{
+			name:                "staked node not confirmed",
+			rawInfo:             &StakerInfo{LatestStakedNode: 6},
+			latestConfirmedNode: 5,
+			expected:            retireStageWaitingForConfirmation,
+		},
+		{
+			name:                "staked node confirmed",
+			rawInfo:             &StakerInfo{LatestStakedNode: 5},
+			latestConfirmedNode: 5,
+			expected:            retireStageWithdrawingStake,
+		},
+		{
+			name:                "staked on an older node",
+			rawInfo:             &StakerInfo{LatestStakedNode: 2},
+			latestConfirmedNode: 5,
+			expected:            retireStageWithdrawingStake,
+		},
+		{
+			name:         "staker funds left",
+			withdrawable: big.NewInt(1),
+			sweepable:    big.NewInt(1),
+			expected:     retireStageWithdrawingFunds,
+		},
+		{
+			name:         "wallet balance left",
+			withdrawable: common.Big0,
+			sweepable:    big.NewInt(1),
+			expected:     retireStageSweeping,
+		},
+		{
+			name:         "wallet not swept",
+			withdrawable: common.Big0,
+			expected:     retireStageRetired,
+		},
+		{
+			name:         "wallet swept",
+			withdrawable: common.Big0,
+			sweepable:    common.Big0,
+			expected:     retireStageRetired,
+		},
+	}
+	for _, test := range tests {
+		stage := retireStep(test.rawInfo, test.latestConfirmedNode, test.withdrawable, test.sweepable)
+		if stage != test.expected {
+			Fail(t, test.name, "got retire stage", stage, "expected", test.expected)
+		}
+	}
+}
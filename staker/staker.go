@@ -10,11 +10,13 @@ import (
 	"math/big"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -55,8 +57,29 @@ const (
 	ResolveNodesStrategy
 	// Make nodes: continually create new nodes, challenging bad assertions
 	MakeNodesStrategy
+	// Retire: stop making assertions, withdraw the stake once possible, and sweep the wallet's funds
+	RetireStrategy
 )
 
+func (s StakerStrategy) String() string {
+	switch s {
+	case WatchtowerStrategy:
+		return "Watchtower"
+	case DefensiveStrategy:
+		return "Defensive"
+	case StakeLatestStrategy:
+		return "StakeLatest"
+	case ResolveNodesStrategy:
+		return "ResolveNodes"
+	case MakeNodesStrategy:
+		return "MakeNodes"
+	case RetireStrategy:
+		return "Retire"
+	default:
+		return fmt.Sprintf("StakerStrategy(%d)", uint8(s))
+	}
+}
+
 type L1PostingStrategy struct {
 	HighGasThreshold   float64 `koanf:"high-gas-threshold"`
 	HighGasDelayBlocks int64   `koanf:"high-gas-delay-blocks"`
@@ -85,14 +108,17 @@ type L1ValidatorConfig struct {
 	StartValidationFromStaked bool                        `koanf:"start-validation-from-staked"`
 	ContractWalletAddress     string                      `koanf:"contract-wallet-address"`
 	GasRefunderAddress        string                      `koanf:"gas-refunder-address"`
+	RetireSweepAddress        string                      `koanf:"retire-sweep-address"`
 	DataPoster                dataposter.DataPosterConfig `koanf:"data-poster" reload:"hot"`
 	RedisUrl                  string                      `koanf:"redis-url"`
 	ExtraGas                  uint64                      `koanf:"extra-gas" reload:"hot"`
+	DecisionLogSize           uint64                      `koanf:"decision-log-size"`
 	Dangerous                 DangerousConfig             `koanf:"dangerous"`
 	ParentChainWallet         genericconf.WalletConfig    `koanf:"parent-chain-wallet"`
 
-	strategy    StakerStrategy
-	gasRefunder common.Address
+	strategy           StakerStrategy
+	gasRefunder        common.Address
+	retireSweepAddress common.Address
 }
 
 func (c *L1ValidatorConfig) ParseStrategy() (StakerStrategy, error) {
@@ -107,6 +133,8 @@ func (c *L1ValidatorConfig) ParseStrategy() (StakerStrategy, error) {
 		return ResolveNodesStrategy, nil
 	case "makenodes":
 		return MakeNodesStrategy, nil
+	case "retire":
+		return RetireStrategy, nil
 	default:
 		return WatchtowerStrategy, fmt.Errorf("unknown staker strategy \"%v\"", c.Strategy)
 	}
@@ -135,6 +163,10 @@ func (c *L1ValidatorConfig) Validate() error {
 		return errors.New("invalid validator gas refunder address")
 	}
 	c.gasRefunder = common.HexToAddress(c.GasRefunderAddress)
+	if len(c.RetireSweepAddress) > 0 && !common.IsHexAddress(c.RetireSweepAddress) {
+		return errors.New("invalid validator retire sweep address")
+	}
+	c.retireSweepAddress = common.HexToAddress(c.RetireSweepAddress)
 	return nil
 }
 
@@ -151,9 +183,11 @@ var DefaultL1ValidatorConfig = L1ValidatorConfig{
 	StartValidationFromStaked: true,
 	ContractWalletAddress:     "",
 	GasRefunderAddress:        "",
+	RetireSweepAddress:        "",
 	DataPoster:                dataposter.DefaultDataPosterConfigForValidator,
 	RedisUrl:                  "",
 	ExtraGas:                  50000,
+	DecisionLogSize:           10000,
 	Dangerous:                 DefaultDangerousConfig,
 	ParentChainWallet:         DefaultValidatorL1WalletConfig,
 }
@@ -171,9 +205,11 @@ var TestL1ValidatorConfig = L1ValidatorConfig{
 	StartValidationFromStaked: true,
 	ContractWalletAddress:     "",
 	GasRefunderAddress:        "",
+	RetireSweepAddress:        "",
 	DataPoster:                dataposter.TestDataPosterConfigForValidator,
 	RedisUrl:                  "",
 	ExtraGas:                  50000,
+	DecisionLogSize:           100,
 	Dangerous:                 DefaultDangerousConfig,
 	ParentChainWallet:         DefaultValidatorL1WalletConfig,
 }
@@ -188,7 +224,7 @@ var DefaultValidatorL1WalletConfig = genericconf.WalletConfig{
 
 func L1ValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultL1ValidatorConfig.Enable, "enable validator")
-	f.String(prefix+".strategy", DefaultL1ValidatorConfig.Strategy, "L1 validator strategy, either watchtower, defensive, stakeLatest, or makeNodes")
+	f.String(prefix+".strategy", DefaultL1ValidatorConfig.Strategy, "L1 validator strategy, either watchtower, defensive, stakeLatest, makeNodes, or retire")
 	f.Duration(prefix+".staker-interval", DefaultL1ValidatorConfig.StakerInterval, "how often the L1 validator should check the status of the L1 rollup and maybe take action with its stake")
 	f.Duration(prefix+".make-assertion-interval", DefaultL1ValidatorConfig.MakeAssertionInterval, "if configured with the makeNodes strategy, how often to create new assertions (bypassed in case of a dispute)")
 	L1PostingStrategyAddOptions(prefix+".posting-strategy", f)
@@ -199,8 +235,10 @@ func L1ValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".start-validation-from-staked", DefaultL1ValidatorConfig.StartValidationFromStaked, "assume staked nodes are valid")
 	f.String(prefix+".contract-wallet-address", DefaultL1ValidatorConfig.ContractWalletAddress, "validator smart contract wallet public address")
 	f.String(prefix+".gas-refunder-address", DefaultL1ValidatorConfig.GasRefunderAddress, "The gas refunder contract address (optional)")
+	f.String(prefix+".retire-sweep-address", DefaultL1ValidatorConfig.RetireSweepAddress, "if configured with the retire strategy, where to send the smart contract wallet's funds once the stake is withdrawn (optional)")
 	f.String(prefix+".redis-url", DefaultL1ValidatorConfig.RedisUrl, "redis url for L1 validator")
 	f.Uint64(prefix+".extra-gas", DefaultL1ValidatorConfig.ExtraGas, "use this much more gas than estimation says is necessary to post transactions")
+	f.Uint64(prefix+".decision-log-size", DefaultL1ValidatorConfig.DecisionLogSize, "how many staker decisions to keep in the database (0 disables the decision log)")
 	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f, dataposter.DefaultDataPosterConfigForValidator)
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 	genericconf.WalletConfigAddOptions(prefix+".parent-chain-wallet", f, DefaultL1ValidatorConfig.ParentChainWallet.Pathname)
@@ -250,6 +288,11 @@ type Staker struct {
 	inboxReader             InboxReaderInterface
 	statelessBlockValidator *StatelessBlockValidator
 	fatalErr                chan<- error
+
+	// actMutex serializes Act and DryRunAct, which share the tx builder and strategy state
+	actMutex        sync.Mutex
+	currentDecision *StakerDecision
+	decisionLog     *decisionLog
 }
 
 type ValidatorWalletInterface interface {
@@ -283,6 +326,7 @@ func NewStaker(
 	stakedNotifiers []LatestStakedNotifier,
 	confirmedNotifiers []LatestConfirmedNotifier,
 	validatorUtilsAddress common.Address,
+	decisionLogDB ethdb.Database,
 	fatalErr chan<- error,
 ) (*Staker, error) {
 
@@ -299,6 +343,13 @@ func NewStaker(
 	if config.StartValidationFromStaked && blockValidator != nil {
 		stakedNotifiers = append(stakedNotifiers, blockValidator)
 	}
+	var decisions *decisionLog
+	if decisionLogDB != nil && config.DecisionLogSize > 0 {
+		decisions, err = newDecisionLog(decisionLogDB, config.DecisionLogSize)
+		if err != nil {
+			return nil, fmt.Errorf("error opening staker decision log: %w", err)
+		}
+	}
 	return &Staker{
 		L1Validator:             val,
 		l1Reader:                l1Reader,
@@ -311,6 +362,7 @@ func NewStaker(
 		inboxReader:             statelessBlockValidator.inboxReader,
 		statelessBlockValidator: statelessBlockValidator,
 		fatalErr:                fatalErr,
+		decisionLog:             decisions,
 	}, nil
 }
 
@@ -574,7 +626,88 @@ func (s *Staker) confirmDataPosterIsReady(ctx context.Context) error {
 	return nil
 }
 
+func (s *Staker) newDecision(dryRun bool) *StakerDecision {
+	decision := &StakerDecision{
+		Timestamp:     uint64(time.Now().Unix()),
+		DryRun:        dryRun,
+		Strategy:      s.config.strategy.String(),
+		WalletBalance: new(big.Int),
+	}
+	if s.blockValidator != nil {
+		decision.ValidatedCount = uint64(s.blockValidator.GetValidated())
+	}
+	return decision
+}
+
 func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
+	s.actMutex.Lock()
+	defer s.actMutex.Unlock()
+	decision := s.newDecision(false)
+	arbTx, err := s.act(ctx, decision, false)
+	if arbTx != nil {
+		decision.Transactions = append(decision.Transactions, arbTx.Hash())
+	}
+	if err != nil {
+		decision.Error = err.Error()
+	}
+	if s.decisionLog != nil {
+		if logErr := s.decisionLog.Append(decision); logErr != nil {
+			log.Warn("error recording staker decision", "err", logErr)
+		}
+	}
+	return arbTx, err
+}
+
+// DryRunAct evaluates what Act would do right now without sending any transactions.
+// The decision isn't recorded in the decision log, and strategy state is left untouched.
+func (s *Staker) DryRunAct(ctx context.Context) (*StakerDryRunResult, error) {
+	s.actMutex.Lock()
+	defer s.actMutex.Unlock()
+	highGasBlocksBuffer := new(big.Int).Set(s.highGasBlocksBuffer)
+	lastActCalledBlock := s.lastActCalledBlock
+	inactiveLastCheckedNode := s.inactiveLastCheckedNode
+	bringActiveUntilNode := s.bringActiveUntilNode
+	defer func() {
+		s.highGasBlocksBuffer = highGasBlocksBuffer
+		s.lastActCalledBlock = lastActCalledBlock
+		s.inactiveLastCheckedNode = inactiveLastCheckedNode
+		s.bringActiveUntilNode = bringActiveUntilNode
+		s.builder.ClearTransactions()
+	}()
+	decision := s.newDecision(true)
+	_, err := s.act(ctx, decision, true)
+	if err != nil {
+		decision.Error = err.Error()
+	}
+	txs := s.builder.Transactions()
+	for _, tx := range txs {
+		decision.Transactions = append(decision.Transactions, tx.Hash())
+	}
+	return &StakerDryRunResult{
+		Decision:     *decision,
+		Transactions: txs,
+	}, nil
+}
+
+// LatestDecisions returns up to count of the most recently recorded staker decisions, newest first.
+func (s *Staker) LatestDecisions(count uint64) ([]StakerDecision, error) {
+	if s.decisionLog == nil {
+		return nil, errors.New("staker decision log is disabled")
+	}
+	return s.decisionLog.Latest(count)
+}
+
+// executeTransactions sends everything queued in the builder, unless this is a dry run.
+func (s *Staker) executeTransactions(ctx context.Context, dryRun bool) (*types.Transaction, error) {
+	if dryRun {
+		return nil, nil
+	}
+	return s.wallet.ExecuteTransactions(ctx, s.builder, s.config.gasRefunder)
+}
+
+func (s *Staker) act(ctx context.Context, decision *StakerDecision, dryRun bool) (*types.Transaction, error) {
+	s.currentDecision = decision
+	defer func() { s.currentDecision = nil }()
 	if s.config.strategy != WatchtowerStrategy {
 		err := s.confirmDataPosterIsReady(ctx)
 		if err != nil {
@@ -590,6 +723,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 	}
 	if !s.shouldAct(ctx) {
 		// The fact that we're delaying acting is already logged in `shouldAct`
+		decision.addAction(StakerActionDelayedForGas)
 		return nil, nil
 	}
 	callOpts := s.getCallOpts(ctx)
@@ -607,7 +741,9 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 		} else {
 			stakerAmountStakedGauge.Update(0)
 		}
-		s.updateStakerBalanceMetric(ctx)
+		if balance := s.updateStakerBalanceMetric(ctx); balance != nil {
+			decision.WalletBalance = balance
+		}
 	}
 	// If the wallet address is zero, or the wallet address isn't staked,
 	// this will return the latest node and its hash (atomically).
@@ -618,6 +754,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 		return nil, fmt.Errorf("error getting latest staked node of own wallet %v: %w", walletAddressOrZero, err)
 	}
 	stakerLatestStakedNodeGauge.Update(int64(latestStakedNodeNum))
+	decision.LatestStakedNode = latestStakedNodeNum
 	if rawInfo != nil {
 		rawInfo.LatestStakedNode = latestStakedNodeNum
 	}
@@ -629,6 +766,10 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 		StakeExists:          rawInfo != nil,
 	}
 
+	if s.config.strategy == RetireStrategy {
+		return s.retire(ctx, decision, rawInfo, dryRun)
+	}
+
 	effectiveStrategy := s.config.strategy
 	nodesLinear, err := s.validatorUtils.AreUnresolvedNodesLinear(callOpts, s.rollupAddress)
 	if err != nil {
@@ -657,10 +798,13 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 		info.LatestStakedNodeHash = s.inactiveLastCheckedNode.hash
 	}
 
+	decision.EffectiveStrategy = effectiveStrategy.String()
+
 	latestConfirmedNode, err := s.rollup.LatestConfirmed(callOpts)
 	if err != nil {
 		return nil, fmt.Errorf("error getting latest confirmed node: %w", err)
 	}
+	decision.LatestConfirmedNode = latestConfirmedNode
 
 	requiredStakeElevated, err := s.isRequiredStakeElevated(ctx)
 	if err != nil {
@@ -673,17 +817,30 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 		(effectiveStrategy >= StakeLatestStrategy && rawInfo == nil && requiredStakeElevated)
 	resolvingNode := false
 	if shouldResolveNodes {
-		arbTx, err := s.resolveTimedOutChallenges(ctx)
+		timedOut, err := s.timedOutChallenges(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("error resolving timed out challenges: %w", err)
 		}
-		if arbTx != nil {
-			return arbTx, nil
+		if len(timedOut) > 0 {
+			decision.addAction(StakerActionTimeoutChallenges)
+			if dryRun {
+				return nil, nil
+			}
+			log.Info("timing out challenges", "count", len(timedOut))
+			return s.wallet.TimeoutChallenges(ctx, timedOut)
 		}
+		previouslyConfirmedNode := latestConfirmedNode
 		resolvingNode, err = s.resolveNextNode(ctx, rawInfo, &latestConfirmedNode)
 		if err != nil {
 			return nil, fmt.Errorf("error resolving node %v: %w", latestConfirmedNode+1, err)
 		}
+		if resolvingNode {
+			if latestConfirmedNode != previouslyConfirmedNode {
+				decision.addAction(StakerActionConfirmNode)
+			} else {
+				decision.addAction(StakerActionRejectNode)
+			}
+		}
 		if resolvingNode && rawInfo == nil && latestConfirmedNode > info.LatestStakedNode {
 			// If we hit this condition, we've resolved what was previously the latest confirmed node,
 			// and we don't have a stake yet. That means we were planning to enter the rollup on
@@ -726,7 +883,9 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 				return nil, fmt.Errorf("error withdrawing staker funds from our staker %v: %w", walletAddressOrZero, err)
 			}
 			log.Info("removing old stake and withdrawing funds")
-			return s.wallet.ExecuteTransactions(ctx, s.builder, s.config.gasRefunder)
+			decision.addAction(StakerActionReturnOldDeposit)
+			decision.addAction(StakerActionWithdrawFunds)
+			return s.executeTransactions(ctx, dryRun)
 		}
 	}
 
@@ -744,6 +903,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 			if err != nil {
 				return nil, fmt.Errorf("error withdrawing our staker %v funds: %w", walletAddressOrZero, err)
 			}
+			decision.addAction(StakerActionWithdrawFunds)
 		}
 	}
 
@@ -780,7 +940,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 	if info.StakerInfo == nil && info.StakeExists {
 		log.Info("staking to execute transactions")
 	}
-	return s.wallet.ExecuteTransactions(ctx, s.builder, s.config.gasRefunder)
+	return s.executeTransactions(ctx, dryRun)
 }
 
 func (s *Staker) handleConflict(ctx context.Context, info *StakerInfo) error {
@@ -815,7 +975,10 @@ func (s *Staker) handleConflict(ctx context.Context, info *StakerInfo) error {
 		s.activeChallenge = newChallengeManager
 	}
 
-	_, err := s.activeChallenge.Act(ctx)
+	challengeTx, err := s.activeChallenge.Act(ctx)
+	if challengeTx != nil {
+		s.currentDecision.addAction(StakerActionChallengeMove)
+	}
 	return err
 }
 
@@ -864,6 +1027,7 @@ func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiv
 			if err != nil {
 				return fmt.Errorf("error staking on new node: %w", err)
 			}
+			s.currentDecision.addAction(StakerActionStakeOnNewNode)
 			return nil
 		}
 
@@ -885,6 +1049,8 @@ func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiv
 		if err != nil {
 			return fmt.Errorf("error placing new stake on new node: %w", err)
 		}
+		s.currentDecision.addAction(StakerActionPlaceNewStake)
+		s.currentDecision.addAction(StakerActionStakeOnNewNode)
 		info.StakeExists = true
 		return nil
 	case existingNodeAction:
@@ -914,6 +1080,7 @@ func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiv
 			if err != nil {
 				return fmt.Errorf("error staking on existing node: %w", err)
 			}
+			s.currentDecision.addAction(StakerActionStakeOnExistingNode)
 			return nil
 		}
 
@@ -934,6 +1101,8 @@ func (s *Staker) advanceStake(ctx context.Context, info *OurStakerInfo, effectiv
 		if err != nil {
 			return fmt.Errorf("error placing new stake on existing node: %w", err)
 		}
+		s.currentDecision.addAction(StakerActionPlaceNewStake)
+		s.currentDecision.addAction(StakerActionStakeOnExistingNode)
 		info.StakeExists = true
 		return nil
 	default:
@@ -1021,6 +1190,7 @@ func (s *Staker) createConflict(ctx context.Context, info *StakerInfo) error {
 		if err != nil {
 			return fmt.Errorf("error creating challenge: %w", err)
 		}
+		s.currentDecision.addAction(StakerActionCreateChallenge)
 	}
 	// No conflicts exist
 	return nil
@@ -1034,16 +1204,18 @@ func (s *Staker) Rollup() *RollupWatcher {
 	return s.rollup
 }
 
-func (s *Staker) updateStakerBalanceMetric(ctx context.Context) {
+// updateStakerBalanceMetric returns the tx sender's balance, or nil if it couldn't be determined.
+func (s *Staker) updateStakerBalanceMetric(ctx context.Context) *big.Int {
 	txSenderAddress := s.wallet.TxSenderAddress()
 	if txSenderAddress == nil {
 		stakerBalanceGauge.Update(0)
-		return
+		return nil
 	}
 	balance, err := s.client.BalanceAt(ctx, *txSenderAddress, nil)
 	if err != nil {
 		log.Error("error getting staker balance", "txSenderAddress", *txSenderAddress, "err", err)
-		return
+		return nil
 	}
 	stakerBalanceGauge.Update(arbmath.BalancePerEther(balance))
+	return balance
 }
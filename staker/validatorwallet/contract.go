@@ -340,6 +340,27 @@ func (v *Contract) TimeoutChallenges(ctx context.Context, challenges []uint64) (
 	return v.dataPoster.PostSimpleTransaction(ctx, auth.Nonce.Uint64(), *v.Address(), data, gas, auth.Value)
 }
 
+// WithdrawEth sends amount of the wallet contract's own balance to destination.
+// This only succeeds if the tx sender owns the wallet.
+func (v *Contract) WithdrawEth(ctx context.Context, amount *big.Int, destination common.Address) (*types.Transaction, error) {
+	if v.Address() == nil {
+		return nil, errors.New("validator wallet contract not yet deployed")
+	}
+	auth, err := v.getAuth(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := validatorABI.Pack("withdrawEth", amount, destination)
+	if err != nil {
+		return nil, fmt.Errorf("packing arguments for withdrawEth: %w", err)
+	}
+	gas, err := v.gasForTxData(ctx, auth, data)
+	if err != nil {
+		return nil, fmt.Errorf("getting gas for tx data: %w", err)
+	}
+	return v.dataPoster.PostSimpleTransaction(ctx, auth.Nonce.Uint64(), *v.Address(), data, gas, auth.Value)
+}
+
 // gasForTxData returns auth.GasLimit if it's nonzero, otherwise returns estimate.
 func (v *Contract) gasForTxData(ctx context.Context, auth *bind.TransactOpts, data []byte) (uint64, error) {
 	if auth.GasLimit != 0 {
@@ -26,6 +26,8 @@ import (
 	"github.com/yingdianRao/nitro/solgen/go/mocksgen"
 	"github.com/yingdianRao/nitro/solgen/go/precompilesgen"
 	"github.com/yingdianRao/nitro/util/arbmath"
+	"github.com/yingdianRao/nitro/util/rpcclient"
+	"github.com/yingdianRao/nitro/validator/valnode"
 )
 
 type workloadType uint
@@ -253,3 +255,54 @@ func TestBlockValidatorSimpleLocalDAS(t *testing.T) {
 func TestBlockValidatorSimpleJITOnchain(t *testing.T) {
 	testBlockValidatorSimple(t, "files", 8, smallContract, false)
 }
+
+func TestBlockValidatorParallelCatchUp(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, true)
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User2")
+	perTransfer := big.NewInt(1e12)
+	for i := 0; i < 64; i++ {
+		tx := builder.L2Info.PrepareTx("Owner", "User2", builder.L2Info.TransferGas, perTransfer, nil)
+		Require(t, builder.L2.Client.SendTransaction(ctx, tx))
+		_, err := builder.L2.EnsureTxSucceeded(tx)
+		Require(t, err)
+	}
+
+	// the validator starts far behind, with two validation servers to spread its work across
+	validatorConfig := arbnode.ConfigDefaultL1NonSequencerTest()
+	validatorConfig.BlockValidator.Enable = true
+	validatorConfig.BlockValidator.PrerecordedBlocks = 2
+	validatorConfig.BlockValidator.CatchUp.Threshold = 8
+	validatorConfig.BlockValidator.ValidationServerConfigs = nil
+	for i := 0; i < 2; i++ {
+		valConfig := valnode.TestValidationConfig
+		valConfig.UseJit = true
+		_, valStack := createTestValidationNode(t, ctx, &valConfig)
+		serverConfig := rpcclient.TestClientConfig
+		serverConfig.URL = valStack.WSEndpoint()
+		serverConfig.JWTSecret = ""
+		validatorConfig.BlockValidator.ValidationServerConfigs = append(validatorConfig.BlockValidator.ValidationServerConfigs, serverConfig)
+	}
+	testClientB, cleanupB := builder.Build2ndNode(t, &SecondNodeParams{nodeConfig: validatorConfig})
+	defer cleanupB()
+
+	lastBlock, err := builder.L2.Client.BlockNumber(ctx)
+	Require(t, err)
+	timeout := getDeadlineTimeout(t, time.Minute*10)
+	// messageindex is same as block number here
+	if !testClientB.ConsensusNode.BlockValidator.WaitForPos(t, ctx, arbutil.MessageIndex(lastBlock), timeout) {
+		Fatal(t, "did not validate all blocks")
+	}
+	// each validation server got several validations at once
+	for i, peak := range testClientB.ConsensusNode.BlockValidator.PeakRunningValidations() {
+		if peak < 2 {
+			Fatal(t, "validation server", i, "ran at most", peak, "validations at once")
+		}
+	}
+}
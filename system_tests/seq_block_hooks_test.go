@@ -0,0 +1,104 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+// testBlockHook adds its transactions to the first block it's called for, and records the blocks it observes.
+type testBlockHook struct {
+	delay time.Duration
+
+	mutex   sync.Mutex
+	pending []*types.Transaction
+	added   map[common.Hash][]error
+	blocks  []*types.Block
+}
+
+func (h *testBlockHook) BeforeBlock(ctx context.Context, _ *gethexec.NextBlockInfo) ([]*types.Transaction, error) {
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	txs := h.pending
+	h.pending = nil
+	return txs, nil
+}
+
+func (h *testBlockHook) AfterBlock(_ context.Context, block *types.Block, txErrs []error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if block != nil {
+		h.blocks = append(h.blocks, block)
+		if len(txErrs) > 0 {
+			h.added[block.Hash()] = txErrs
+		}
+	}
+}
+
+func TestSequencerBlockHooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	builder.execConfig.Sequencer.BlockHooks.BeforeBlockTimeout = 500 * time.Millisecond
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User")
+	builder.L2Info.GenerateAccount("Oracle")
+	builder.L2.TransferBalance(t, "Owner", "User", big.NewInt(params.Ether), builder.L2Info)
+
+	// the operator's system transaction goes first in the next block
+	systemTx := builder.L2Info.PrepareTx("Owner", "Oracle", builder.L2Info.TransferGas, big.NewInt(1), nil)
+	hook := &testBlockHook{pending: []*types.Transaction{systemTx}, added: make(map[common.Hash][]error)}
+	builder.L2.ExecNode.Sequencer.AddBlockHook("oracle", hook)
+	// a hook slower than the timeout doesn't hold up blocks
+	slowHook := &testBlockHook{delay: time.Hour, added: make(map[common.Hash][]error)}
+	builder.L2.ExecNode.Sequencer.AddBlockHook("slow", slowHook)
+
+	tx := builder.L2Info.PrepareTx("User", "Owner", builder.L2Info.TransferGas, big.NewInt(1), nil)
+	start := time.Now()
+	Require(t, builder.L2.Client.SendTransaction(ctx, tx))
+	receipt, err := builder.L2.EnsureTxSucceeded(tx)
+	Require(t, err)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		Fatal(t, "slow block hook held up the block for", elapsed)
+	}
+	systemReceipt, err := builder.L2.EnsureTxSucceeded(systemTx)
+	Require(t, err)
+	if systemReceipt.BlockNumber.Cmp(receipt.BlockNumber) != 0 || systemReceipt.TransactionIndex != 1 || receipt.TransactionIndex != 2 {
+		Fatal(t, "system transaction wasn't sequenced ahead of the queued one", systemReceipt.BlockNumber, systemReceipt.TransactionIndex, receipt.BlockNumber, receipt.TransactionIndex)
+	}
+
+	// AfterBlock runs after the transactions' results are returned
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		hook.mutex.Lock()
+		txErrs, reported := hook.added[systemReceipt.BlockHash]
+		hook.mutex.Unlock()
+		if reported {
+			if len(txErrs) != 1 || txErrs[0] != nil {
+				Fatal(t, "unexpected system transaction results", txErrs)
+			}
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			Fatal(t, "block hook wasn't told about its transaction's block")
+		}
+	}
+}
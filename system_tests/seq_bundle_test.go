@@ -0,0 +1,78 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+func TestSequencerBundle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User")
+	builder.L2.TransferBalance(t, "Owner", "User", big.NewInt(params.Ether), builder.L2Info)
+
+	rpcClient := builder.L2.Stack.Attach()
+	defer rpcClient.Close()
+	sendBundle := func(txs []*types.Transaction, reverting ...common.Hash) error {
+		bundle := &gethexec.TxBundle{Txs: txs, RevertingTxHashes: reverting}
+		args, err := bundle.ToArgs()
+		Require(t, err)
+		var hashes []common.Hash
+		return rpcClient.CallContext(ctx, &hashes, "arb_sendBundle", args)
+	}
+	transfer := func() *types.Transaction {
+		return builder.L2Info.PrepareTx("User", "Owner", builder.L2Info.TransferGas, big.NewInt(1), nil)
+	}
+	// calling ArbSys with an unknown selector reverts
+	revert := func() *types.Transaction {
+		return builder.L2Info.PrepareTxTo("User", &types.ArbSysAddress, 100000, big.NewInt(0), []byte{0xde, 0xad, 0xbe, 0xef})
+	}
+
+	// a successful bundle is sequenced consecutively in a single block
+	first, second := transfer(), transfer()
+	Require(t, sendBundle([]*types.Transaction{first, second}))
+	firstReceipt, err := builder.L2.EnsureTxSucceeded(first)
+	Require(t, err)
+	secondReceipt, err := builder.L2.EnsureTxSucceeded(second)
+	Require(t, err)
+	if firstReceipt.BlockNumber.Cmp(secondReceipt.BlockNumber) != 0 || secondReceipt.TransactionIndex != firstReceipt.TransactionIndex+1 {
+		Fatal(t, "bundle transactions weren't sequenced consecutively", firstReceipt.BlockNumber, firstReceipt.TransactionIndex, secondReceipt.BlockNumber, secondReceipt.TransactionIndex)
+	}
+
+	// a reverting transaction fails the whole bundle
+	nonce := builder.L2Info.GetInfoWithPrivKey("User").Nonce
+	before, reverting := transfer(), revert()
+	if err := sendBundle([]*types.Transaction{before, reverting}); err == nil {
+		Fatal(t, "bundle with a reverting transaction accepted")
+	}
+	stateNonce, err := builder.L2.Client.NonceAt(ctx, builder.L2Info.GetAddress("User"), nil)
+	Require(t, err)
+	if stateNonce != nonce {
+		Fatal(t, "transactions of a failed bundle were sequenced, nonce", stateNonce, "expected", nonce)
+	}
+
+	// unless the bundle allows it to revert
+	Require(t, sendBundle([]*types.Transaction{before, reverting}, reverting.Hash()))
+	_, err = builder.L2.EnsureTxSucceeded(before)
+	Require(t, err)
+	receipt, err := builder.L2.Client.TransactionReceipt(ctx, reverting.Hash())
+	Require(t, err)
+	if receipt.Status != types.ReceiptStatusFailed {
+		Fatal(t, "expected the allowed transaction to revert")
+	}
+}
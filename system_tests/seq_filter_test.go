@@ -0,0 +1,96 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+func writeTxFilterRules(t *testing.T, path string, rules []gethexec.TxFilterRuleJSON) {
+	t.Helper()
+	data, err := json.Marshal(gethexec.TxFilterRulesJSON{Rules: rules})
+	Require(t, err)
+	// write and rename so the reload never sees a partial file
+	Require(t, os.WriteFile(path+".tmp", data, 0o600))
+	Require(t, os.Rename(path+".tmp", path))
+}
+
+func TestSequencerTxFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.json")
+	auditPath := filepath.Join(dir, "audit.jsonl")
+	deniedAddr := GetTestAddressForAccountName(t, "Denied")
+	writeTxFilterRules(t, rulesPath, []gethexec.TxFilterRuleJSON{
+		{Name: "denied-recipient", To: []common.Address{deniedAddr}},
+	})
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	builder.execConfig.Sequencer.TxFilter = gethexec.TxFilterConfig{
+		Enable:         true,
+		Source:         rulesPath,
+		ReloadInterval: 100 * time.Millisecond,
+		AuditLog:       auditPath,
+	}
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User")
+	builder.L2Info.GenerateAccount("Denied")
+	builder.L2.TransferBalance(t, "Owner", "User", big.NewInt(params.Ether), builder.L2Info)
+
+	tx := builder.L2Info.PrepareTx("User", "Denied", builder.L2Info.TransferGas, big.NewInt(1), nil)
+	err := builder.L2.Client.SendTransaction(ctx, tx)
+	if err == nil || !strings.Contains(err.Error(), gethexec.ErrTxDenied.Error()) {
+		Fatal(t, "expected transaction to a denied address to be rejected, got", err)
+	}
+
+	auditFile, err := os.Open(auditPath)
+	Require(t, err)
+	defer auditFile.Close()
+	scanner := bufio.NewScanner(auditFile)
+	if !scanner.Scan() {
+		Fatal(t, "rejection missing from the audit log")
+	}
+	var entry struct {
+		TxHash common.Hash `json:"txHash"`
+		Rule   string      `json:"rule"`
+	}
+	Require(t, json.Unmarshal(scanner.Bytes(), &entry))
+	if entry.TxHash != tx.Hash() || entry.Rule != "denied-recipient" {
+		Fatal(t, "unexpected audit log entry", scanner.Text())
+	}
+
+	// dropping the rule takes effect without a restart
+	writeTxFilterRules(t, rulesPath, []gethexec.TxFilterRuleJSON{
+		{Name: "unrelated", Senders: []common.Address{{1}}},
+	})
+	for i := 0; ; i++ {
+		err = builder.L2.Client.SendTransaction(ctx, tx)
+		if err == nil {
+			break
+		}
+		if i >= 50 || !strings.Contains(err.Error(), gethexec.ErrTxDenied.Error()) {
+			Fatal(t, "transaction still rejected after the rules were reloaded", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	_, err = builder.L2.EnsureTxSucceeded(tx)
+	Require(t, err)
+}
@@ -0,0 +1,94 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSeqCoordinatorHandoffForwardsQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodePaths := testNodes(t, 2)
+	redisServer, redisUrl := initRedis(ctx, t, nodePaths)
+	defer redisServer.Close()
+
+	builder := fallbackSequencer(ctx, t, &fallbackSequencerOpts{
+		ipcPath:              nodePaths[0],
+		redisUrl:             redisUrl,
+		enableSecCoordinator: true,
+	})
+	builder.nodeConfig.BatchPoster.Enable = false
+	builder.nodeConfig.SeqCoordinator.HandoffTimeout = 20 * time.Second
+	builder.nodeConfig.SeqCoordinator.QueueHandoffTimeout = 10 * time.Second
+	cleanup := builder.Build(t)
+	defer cleanup()
+	nodeA := builder.L2.ConsensusNode
+
+	testClientB, cleanupB := createSequencer(t, builder, nodePaths[1], redisUrl)
+	defer cleanupB()
+
+	for {
+		chosen, err := nodeA.SeqCoordinator.CurrentChosenSequencer(ctx)
+		Require(t, err)
+		if chosen == nodePaths[0] {
+			break
+		}
+		time.Sleep(builder.nodeConfig.SeqCoordinator.UpdateInterval)
+	}
+
+	const users = 4
+	for i := 0; i < users; i++ {
+		name := fmt.Sprintf("Handoff%d", i)
+		builder.L2Info.GenerateAccount(name)
+		tx := builder.L2Info.PrepareTx("Owner", name, builder.L2Info.TransferGas, transferAmount, nil)
+		Require(t, builder.L2.Client.SendTransaction(ctx, tx))
+		_, err := builder.L2.EnsureTxSucceeded(tx)
+		Require(t, err)
+		_, err = WaitForTx(ctx, testClientB.Client, tx.Hash(), 5*time.Second)
+		Require(t, err)
+	}
+
+	// slow down sequencer A, so the next transactions are still queued when it hands off the lockout
+	builder.execConfig.Sequencer.MaxBlockSpeed = 3 * time.Second
+	time.Sleep(100 * time.Millisecond)
+
+	txs := make([]*types.Transaction, users)
+	errs := make([]error, users)
+	var wg sync.WaitGroup
+	for i := range txs {
+		txs[i] = builder.L2Info.PrepareTx(fmt.Sprintf("Handoff%d", i), "Owner", builder.L2Info.TransferGas, transferAmount, nil)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = builder.L2.Client.SendTransaction(ctx, txs[i])
+		}(i)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// planned maintenance of A: it hands off to B, forwarding its queue first
+	nodeA.SeqCoordinator.PrepareForShutdown()
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			Fatal(t, "transaction", i, "dropped during handoff:", err)
+		}
+	}
+	for _, tx := range txs {
+		_, err := WaitForTx(ctx, testClientB.Client, tx.Hash(), 5*time.Second)
+		Require(t, err)
+	}
+	chosen, err := nodeA.SeqCoordinator.CurrentChosenSequencer(ctx)
+	Require(t, err)
+	if chosen != nodePaths[1] {
+		Fatal(t, "expected the lockout to be handed off to B, chosen is", chosen)
+	}
+}
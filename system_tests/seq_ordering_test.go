@@ -0,0 +1,86 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+func TestSequencerPriorityFeeOrdering(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	builder.execConfig.Sequencer.Ordering = gethexec.TxOrderingConfig{
+		Policy: gethexec.TxOrderingPriorityFee,
+		Window: 2 * time.Second,
+	}
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	for _, name := range []string{"A", "B", "C"} {
+		builder.L2Info.GenerateAccount(name)
+		builder.L2.TransferBalance(t, "Owner", name, big.NewInt(params.Ether), builder.L2Info)
+	}
+
+	prepare := func(from string, tip int64) *types.Transaction {
+		info := builder.L2Info.GetInfoWithPrivKey(from)
+		to := builder.L2Info.GetAddress("Owner")
+		txData := &types.DynamicFeeTx{
+			To:        &to,
+			Gas:       builder.L2Info.TransferGas,
+			GasTipCap: big.NewInt(tip),
+			GasFeeCap: new(big.Int).Add(builder.L2Info.GasPrice, big.NewInt(tip)),
+			Value:     big.NewInt(1),
+			Nonce:     info.Nonce,
+		}
+		info.Nonce++
+		return builder.L2Info.SignTxAs(from, txData)
+	}
+	a0 := prepare("A", 1)
+	a1 := prepare("A", 10) // outbids everyone, but must still follow a0
+	b := prepare("B", 3)
+	c := prepare("C", 2)
+
+	// sent concurrently so they all land in the same ordering window
+	var wg sync.WaitGroup
+	txs := []*types.Transaction{a0, a1, b, c}
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		wg.Add(1)
+		go func(i int, tx *types.Transaction) {
+			defer wg.Done()
+			errs[i] = builder.L2.Client.SendTransaction(ctx, tx)
+		}(i, tx)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		Require(t, err)
+	}
+
+	expected := []*types.Transaction{b, c, a0, a1}
+	var block *big.Int
+	var lastIndex uint
+	for i, tx := range expected {
+		receipt, err := builder.L2.EnsureTxSucceeded(tx)
+		Require(t, err)
+		if block == nil {
+			block = receipt.BlockNumber
+		} else if receipt.BlockNumber.Cmp(block) != 0 {
+			Fatal(t, "transactions weren't sequenced in the same block", block, receipt.BlockNumber)
+		} else if receipt.TransactionIndex <= lastIndex {
+			Fatal(t, "transaction", i, "sequenced at index", receipt.TransactionIndex, "after", lastIndex)
+		}
+		lastIndex = receipt.TransactionIndex
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+func TestSequencerRateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	builder.execConfig.Sequencer.RateLimit = gethexec.TxRateLimitConfig{
+		Enable:          true,
+		PerSenderRate:   0.001,
+		PerSenderBurst:  2,
+		SenderAllowlist: GetTestAddressForAccountName(t, "Owner").String(),
+	}
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User")
+
+	// Owner is on the allowlist, so it isn't limited to the burst
+	for i := 0; i < 3; i++ {
+		builder.L2.TransferBalance(t, "Owner", "User", big.NewInt(params.Ether), builder.L2Info)
+	}
+
+	// User may send its burst, but not more
+	for i := 0; i < 2; i++ {
+		builder.L2.TransferBalance(t, "User", "Owner", big.NewInt(1), builder.L2Info)
+	}
+	tx := builder.L2Info.PrepareTx("User", "Owner", builder.L2Info.TransferGas, big.NewInt(1), nil)
+	err := builder.L2.Client.SendTransaction(ctx, tx)
+	if err == nil {
+		Fatal(t, "transaction over the sender's rate limit accepted")
+	}
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != gethexec.TxRateLimitErrorCode {
+		Fatal(t, "unexpected error for rate limited transaction", err)
+	}
+
+	// the limit is hot-reloadable
+	builder.execConfig.Sequencer.RateLimit.Enable = false
+	err = builder.L2.Client.SendTransaction(ctx, tx)
+	Require(t, err)
+	_, err = builder.L2.EnsureTxSucceeded(tx)
+	Require(t, err)
+}
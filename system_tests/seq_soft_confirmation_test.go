@@ -0,0 +1,68 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+func TestSequencedTransactionsSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User")
+	tx := builder.L2Info.PrepareTx("Owner", "User", builder.L2Info.TransferGas, big.NewInt(1e12), nil)
+
+	rpcClient := builder.L2.Stack.Attach()
+	defer rpcClient.Close()
+	notifications := make(chan *gethexec.SequencedTransaction, 8)
+	filter := &gethexec.SequencedTransactionsFilter{Hashes: []common.Hash{tx.Hash()}}
+	sub, err := rpcClient.Subscribe(ctx, "arb", notifications, "sequencedTransactions", filter)
+	Require(t, err)
+	defer sub.Unsubscribe()
+
+	nextNotification := func() *gethexec.SequencedTransaction {
+		t.Helper()
+		select {
+		case notification := <-notifications:
+			return notification
+		case err := <-sub.Err():
+			Fatal(t, "subscription failed", err)
+		case <-time.After(10 * time.Second):
+			Fatal(t, "timed out waiting for soft confirmation")
+		}
+		return nil
+	}
+
+	Require(t, builder.L2.Client.SendTransaction(ctx, tx))
+	confirmation := nextNotification()
+	receipt, err := builder.L2.EnsureTxSucceeded(tx)
+	Require(t, err)
+	if confirmation.TxHash != tx.Hash() || confirmation.Error != "" || confirmation.Receipt == nil {
+		Fatal(t, "unexpected soft confirmation", confirmation)
+	}
+	if confirmation.BlockNumber.ToInt().Cmp(receipt.BlockNumber) != 0 || *confirmation.BlockHash != receipt.BlockHash || uint(*confirmation.TransactionIndex) != receipt.TransactionIndex {
+		Fatal(t, "soft confirmation doesn't match the receipt", confirmation, receipt)
+	}
+
+	// resending the same transaction is rejected for its nonce, which is reported as well
+	if err := builder.L2.Client.SendTransaction(ctx, tx); err == nil {
+		Fatal(t, "resent transaction accepted")
+	}
+	rejection := nextNotification()
+	if rejection.TxHash != tx.Hash() || rejection.Error == "" || rejection.Receipt != nil {
+		Fatal(t, "unexpected rejection notification", rejection)
+	}
+}
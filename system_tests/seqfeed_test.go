@@ -96,7 +96,7 @@ func TestRelayedSequencerFeed(t *testing.T) {
 	config.Chain.ID = bigChainId.Uint64()
 
 	feedErrChan := make(chan error, 10)
-	currentRelay, err := relay.NewRelay(&config, feedErrChan)
+	currentRelay, err := relay.NewRelay(ctx, &config, feedErrChan)
 	Require(t, err)
 	err = currentRelay.Start(ctx)
 	Require(t, err)
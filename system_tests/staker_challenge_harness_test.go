@@ -0,0 +1,338 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// race detection makes things slow and miss timeouts
+//go:build !race
+// +build !race
+
+package arbtest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/arbnode"
+	"github.com/yingdianRao/nitro/arbnode/dataposter/storage"
+	"github.com/yingdianRao/nitro/solgen/go/mocksgen"
+	"github.com/yingdianRao/nitro/solgen/go/rollupgen"
+	"github.com/yingdianRao/nitro/solgen/go/upgrade_executorgen"
+	"github.com/yingdianRao/nitro/staker"
+	"github.com/yingdianRao/nitro/staker/validatorwallet"
+	"github.com/yingdianRao/nitro/util/arbmath"
+	"github.com/yingdianRao/nitro/validator/valnode"
+)
+
+// stakerChallengeBehaviour scripts how the faulty staker of a stakerChallengeHarness handles being challenged.
+type stakerChallengeBehaviour int
+
+const (
+	// faultyStakerDefends keeps acting, defending its assertion until it runs out of moves.
+	faultyStakerDefends stakerChallengeBehaviour = iota
+	// faultyStakerStalls stops acting once it conflicts with the honest staker, so it loses by timeout.
+	faultyStakerStalls
+)
+
+type harnessedStaker struct {
+	name    string
+	address common.Address
+	wallet  *validatorwallet.EOA
+	staker  *staker.Staker
+}
+
+// stakerChallengeHarness runs an honest and a faulty staker, each on its own node, against the rollup
+// deployed on the parent chain of the system tests. The faulty node has a different genesis, so the
+// stakers make conflicting assertions and challenge each other, which the harness drives to completion.
+// Challenges are timed out by upgrading the challenge manager, as the parent chain's clock can't be moved.
+type stakerChallengeHarness struct {
+	t               *testing.T
+	ctx             context.Context
+	builder         *NodeBuilder
+	deployAuth      bind.TransactOpts
+	rollup          *rollupgen.RollupAdminLogic
+	upgradeExecutor *upgrade_executorgen.UpgradeExecutor
+	validatorUtils  *rollupgen.ValidatorUtils
+	honest          *harnessedStaker
+	faulty          *harnessedStaker
+	timedOut        bool
+	maxRounds       int
+}
+
+func newStakerChallengeHarness(t *testing.T, ctx context.Context) (*stakerChallengeHarness, func()) {
+	t.Helper()
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, true)
+	builder.nodeConfig.BatchPoster.MaxDelay = -1000 * time.Hour
+	cleanupHonest := builder.Build(t)
+
+	builder.L2Info.GenerateGenesisAccount("FaultyAddr", common.Big1)
+	faultyConfig := arbnode.ConfigDefaultL1Test()
+	faultyConfig.Sequencer = false
+	faultyConfig.DelayedSequencer.Enable = false
+	faultyConfig.BatchPoster.Enable = false
+	builder.execConfig.Sequencer.Enable = false
+	faultyClient, cleanupFaulty := builder.Build2ndNode(t, &SecondNodeParams{nodeConfig: faultyConfig})
+	cleanup := func() {
+		cleanupFaulty()
+		cleanupHonest()
+	}
+
+	builder.BridgeBalance(t, "Faucet", big.NewInt(1).Mul(big.NewInt(params.Ether), big.NewInt(10000)))
+
+	h := &stakerChallengeHarness{
+		t:          t,
+		ctx:        ctx,
+		builder:    builder,
+		deployAuth: builder.L1Info.GetDefaultTransactOpts("RollupOwner", ctx),
+		maxRounds:  200,
+	}
+	deployInfo := builder.L2.ConsensusNode.DeployInfo
+	var err error
+	h.rollup, err = rollupgen.NewRollupAdminLogic(deployInfo.Rollup, builder.L1.Client)
+	Require(t, err)
+	h.upgradeExecutor, err = upgrade_executorgen.NewUpgradeExecutor(deployInfo.UpgradeExecutor, builder.L1.Client)
+	Require(t, err)
+	h.validatorUtils, err = rollupgen.NewValidatorUtils(deployInfo.ValidatorUtils, builder.L1.Client)
+	Require(t, err)
+
+	_, valStack := createTestValidationNode(t, ctx, &valnode.TestValidationConfig)
+	h.honest = h.newStaker("honest", builder.L2, valStack)
+	h.faulty = h.newStaker("faulty", faultyClient, valStack)
+
+	rollupABI, err := abi.JSON(strings.NewReader(rollupgen.RollupAdminLogicABI))
+	Require(t, err)
+	h.executeAdminCall(rollupABI, deployInfo.Rollup, "setValidator", []common.Address{h.honest.address, h.faulty.address}, []bool{true, true})
+	h.executeAdminCall(rollupABI, deployInfo.Rollup, "setMinimumAssertionPeriod", big.NewInt(1))
+	return h, cleanup
+}
+
+func (h *stakerChallengeHarness) newStaker(name string, client *TestClient, valStack *node.Node) *harnessedStaker {
+	t, ctx := h.t, h.ctx
+	account := "Validator" + name
+	h.builder.L1Info.GenerateAccount(account)
+	h.builder.L1.TransferBalance(t, "Faucet", account, arbmath.BigMulByUint(big.NewInt(params.Ether), 100), h.builder.L1Info)
+	auth := h.builder.L1Info.GetDefaultTransactOpts(account, ctx)
+
+	parentChainID, err := h.builder.L1.Client.ChainID(ctx)
+	Require(t, err)
+	consensus := client.ConsensusNode
+	dp, err := arbnode.StakerDataposter(
+		ctx,
+		rawdb.NewTable(consensus.ArbDB, storage.StakerPrefix),
+		consensus.L1Reader,
+		&auth, NewFetcherFromConfig(arbnode.ConfigDefaultL1NonSequencerTest()),
+		nil,
+		parentChainID,
+	)
+	Require(t, err)
+	wallet, err := validatorwallet.NewEOA(dp, consensus.DeployInfo.Rollup, consensus.L1Reader.Client(), func() uint64 { return 0 })
+	Require(t, err)
+
+	blockValidatorConfig := staker.TestBlockValidatorConfig
+	stateless, err := staker.NewStatelessBlockValidator(
+		consensus.InboxReader,
+		consensus.InboxTracker,
+		consensus.TxStreamer,
+		client.ExecNode,
+		consensus.ArbDB,
+		nil,
+		nil,
+		nil,
+		StaticFetcherFrom(t, &blockValidatorConfig),
+		valStack,
+	)
+	Require(t, err)
+	Require(t, stateless.Start(ctx))
+
+	valConfig := staker.TestL1ValidatorConfig
+	valConfig.Strategy = "MakeNodes"
+	s, err := staker.NewStaker(
+		consensus.L1Reader,
+		wallet,
+		bind.CallOpts{},
+		valConfig,
+		nil,
+		stateless,
+		nil,
+		nil,
+		consensus.DeployInfo.ValidatorUtils,
+		rawdb.NewTable(consensus.ArbDB, storage.StakerDecisionLogPrefix),
+		nil,
+	)
+	Require(t, err)
+	Require(t, s.Initialize(ctx))
+	Require(t, wallet.Initialize(ctx))
+	return &harnessedStaker{name: name, address: auth.From, wallet: wallet, staker: s}
+}
+
+func (h *stakerChallengeHarness) executeAdminCall(contractABI abi.ABI, to common.Address, method string, args ...interface{}) {
+	calldata, err := contractABI.Pack(method, args...)
+	Require(h.t, err, "unable to pack", method, "calldata")
+	tx, err := h.upgradeExecutor.ExecuteCall(&h.deployAuth, to, calldata)
+	Require(h.t, err, "unable to call", method)
+	_, err = h.builder.L1.EnsureTxSucceeded(tx)
+	Require(h.t, err)
+}
+
+// timeOutChallenges upgrades the challenge manager to an implementation which says all challenges
+// are timed out, for the honest staker to win the ongoing one.
+func (h *stakerChallengeHarness) timeOutChallenges() {
+	if h.timedOut {
+		return
+	}
+	upgradeToTimedOutChallengeManager(h.t, h.ctx, h.builder, &h.deployAuth, h.upgradeExecutor, h.honest.wallet.ChallengeManagerAddress())
+	h.timedOut = true
+}
+
+// conflicting returns whether the faulty staker is staked on a node conflicting with the honest one's.
+func (h *stakerChallengeHarness) conflicting() bool {
+	conflictInfo, err := h.validatorUtils.FindStakerConflict(&bind.CallOpts{}, h.builder.L2.ConsensusNode.DeployInfo.Rollup, h.honest.address, h.faulty.address, big.NewInt(1024))
+	Require(h.t, err)
+	return staker.ConflictType(conflictInfo.Ty) == staker.CONFLICT_TYPE_FOUND
+}
+
+// act has s act once, retrying while it waits on its node, and returns the error of the faulty staker.
+func (h *stakerChallengeHarness) act(s *harnessedStaker) error {
+	for {
+		tx, err := s.staker.Act(h.ctx)
+		if err != nil && strings.Contains(err.Error(), "waiting") {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			if s == h.faulty {
+				return err
+			}
+			Require(h.t, err, "staker", s.name, "failed to act")
+		}
+		if tx != nil {
+			_, err = h.builder.L1.EnsureTxSucceeded(tx)
+			Require(h.t, err, "EnsureTxSucceeded failed for staker", s.name, "tx")
+		}
+		return nil
+	}
+}
+
+func isExpectedFaultyStakerError(err error) bool {
+	return strings.Contains(err.Error(), "agreed with entire challenge") ||
+		strings.Contains(err.Error(), "after msg 0 expected global state") ||
+		strings.Contains(err.Error(), "insufficient funds") ||
+		strings.Contains(err.Error(), "start state not in chain") ||
+		strings.Contains(err.Error(), "STAKER_IS_ZOMBIE")
+}
+
+// Run has the stakers act in turn, while L2 transactions are made for them to assert, until the faulty
+// staker lost its stake in a challenge.
+func (h *stakerChallengeHarness) Run(behaviour stakerChallengeBehaviour) {
+	t, builder := h.t, h.builder
+	builder.L2Info.GenerateAccount("BackgroundUser")
+	tx := builder.L2Info.PrepareTx("Faucet", "BackgroundUser", builder.L2Info.TransferGas, big.NewInt(params.Ether), nil)
+	Require(t, builder.L2.Client.SendTransaction(h.ctx, tx))
+	_, err := builder.L2.EnsureTxSucceeded(tx)
+	Require(t, err)
+
+	txsCtx, cancelTxs := context.WithCancel(h.ctx)
+	txsDone := make(chan struct{})
+	go func() {
+		defer close(txsDone)
+		err := makeBackgroundTxs(txsCtx, builder)
+		if !errors.Is(err, context.Canceled) {
+			log.Warn("error making background txs", "err", err)
+		}
+	}()
+	defer func() {
+		cancelTxs()
+		<-txsDone
+	}()
+
+	for round := 0; round < h.maxRounds; round++ {
+		Require(t, h.act(h.honest))
+		conflicting := h.conflicting()
+		if conflicting {
+			// the conflicting assertions are made, no need for more
+			cancelTxs()
+		}
+		if behaviour == faultyStakerStalls && conflicting {
+			h.timeOutChallenges()
+		} else if err := h.act(h.faulty); err != nil {
+			if !isExpectedFaultyStakerError(err) {
+				Require(t, err, "faulty staker failed to act")
+			}
+			t.Log("got expected faulty staker error", err)
+			// the faulty staker is losing the challenge, let it time out
+			h.timeOutChallenges()
+		}
+
+		zombie, err := h.rollup.IsZombie(&bind.CallOpts{}, h.faulty.address)
+		Require(t, err)
+		if zombie {
+			return
+		}
+		honestZombie, err := h.rollup.IsZombie(&bind.CallOpts{}, h.honest.address)
+		Require(t, err)
+		if honestZombie {
+			Fatal(t, "honest staker became a zombie")
+		}
+		for i := 0; i < 5; i++ {
+			builder.L1.TransferBalance(t, "Faucet", "Faucet", common.Big0, builder.L1Info)
+		}
+	}
+	Fatal(t, "faulty staker didn't lose its stake within", h.maxRounds, "rounds")
+}
+
+// upgradeToTimedOutChallengeManager upgrades the challenge manager at managerAddr to an implementation
+// which says challenges are always timed out.
+func upgradeToTimedOutChallengeManager(t *testing.T, ctx context.Context, builder *NodeBuilder, deployAuth *bind.TransactOpts, upgradeExecutor *upgrade_executorgen.UpgradeExecutor, managerAddr common.Address) {
+	t.Helper()
+	mockImpl, tx, _, err := mocksgen.DeployTimedOutChallengeManager(deployAuth, builder.L1.Client)
+	Require(t, err)
+	_, err = builder.L1.EnsureTxSucceeded(tx)
+	Require(t, err)
+
+	// 0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103
+	proxyAdminSlot := common.BigToHash(arbmath.BigSub(crypto.Keccak256Hash([]byte("eip1967.proxy.admin")).Big(), common.Big1))
+	proxyAdminBytes, err := builder.L1.Client.StorageAt(ctx, managerAddr, proxyAdminSlot, nil)
+	Require(t, err)
+	proxyAdminAddr := common.BytesToAddress(proxyAdminBytes)
+	if proxyAdminAddr == (common.Address{}) {
+		Fatal(t, "failed to get challenge manager proxy admin")
+	}
+
+	proxyAdminABI, err := abi.JSON(strings.NewReader(mocksgen.ProxyAdminForBindingABI))
+	Require(t, err)
+	upgradeCalldata, err := proxyAdminABI.Pack("upgrade", managerAddr, mockImpl)
+	Require(t, err)
+	tx, err = upgradeExecutor.ExecuteCall(deployAuth, proxyAdminAddr, upgradeCalldata)
+	Require(t, err)
+	_, err = builder.L1.EnsureTxSucceeded(tx)
+	Require(t, err)
+}
+
+func TestStakerChallengeHarnessFaultyStaker(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h, cleanup := newStakerChallengeHarness(t, ctx)
+	defer cleanup()
+	h.Run(faultyStakerDefends)
+}
+
+func TestStakerChallengeHarnessStalledStaker(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h, cleanup := newStakerChallengeHarness(t, ctx)
+	defer cleanup()
+	h.Run(faultyStakerStalls)
+}
@@ -22,7 +22,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 
@@ -30,13 +29,11 @@ import (
 	"github.com/yingdianRao/nitro/arbnode/dataposter/externalsignertest"
 	"github.com/yingdianRao/nitro/arbnode/dataposter/storage"
 	"github.com/yingdianRao/nitro/arbos/l2pricing"
-	"github.com/yingdianRao/nitro/solgen/go/mocksgen"
 	"github.com/yingdianRao/nitro/solgen/go/rollupgen"
 	"github.com/yingdianRao/nitro/solgen/go/upgrade_executorgen"
 	"github.com/yingdianRao/nitro/staker"
 	"github.com/yingdianRao/nitro/staker/validatorwallet"
 	"github.com/yingdianRao/nitro/util"
-	"github.com/yingdianRao/nitro/util/arbmath"
 	"github.com/yingdianRao/nitro/util/colors"
 	"github.com/yingdianRao/nitro/validator/valnode"
 )
@@ -226,6 +223,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 		nil,
 		nil,
 		l2nodeA.DeployInfo.ValidatorUtils,
+		rawdb.NewTable(l2nodeA.ArbDB, storage.StakerDecisionLogPrefix),
 		nil,
 	)
 	Require(t, err)
@@ -280,6 +278,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 		nil,
 		nil,
 		l2nodeB.DeployInfo.ValidatorUtils,
+		rawdb.NewTable(l2nodeB.ArbDB, storage.StakerDecisionLogPrefix),
 		nil,
 	)
 	Require(t, err)
@@ -302,6 +301,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 		nil,
 		l2nodeA.DeployInfo.ValidatorUtils,
 		nil,
+		nil,
 	)
 	Require(t, err)
 	if stakerC.Strategy() != staker.WatchtowerStrategy {
@@ -370,30 +370,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 				if !challengeMangerTimedOut {
 					// Upgrade the ChallengeManager contract to an implementation which says challenges are always timed out
 
-					mockImpl, tx, _, err := mocksgen.DeployTimedOutChallengeManager(&deployAuth, builder.L1.Client)
-					Require(t, err)
-					_, err = builder.L1.EnsureTxSucceeded(tx)
-					Require(t, err)
-
-					managerAddr := valWalletA.ChallengeManagerAddress()
-					// 0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103
-					proxyAdminSlot := common.BigToHash(arbmath.BigSub(crypto.Keccak256Hash([]byte("eip1967.proxy.admin")).Big(), common.Big1))
-					proxyAdminBytes, err := builder.L1.Client.StorageAt(ctx, managerAddr, proxyAdminSlot, nil)
-					Require(t, err)
-					proxyAdminAddr := common.BytesToAddress(proxyAdminBytes)
-					if proxyAdminAddr == (common.Address{}) {
-						Fatal(t, "failed to get challenge manager proxy admin")
-					}
-
-					proxyAdminABI, err := abi.JSON(strings.NewReader(mocksgen.ProxyAdminForBindingABI))
-					Require(t, err)
-					upgradeCalldata, err := proxyAdminABI.Pack("upgrade", managerAddr, mockImpl)
-					Require(t, err)
-					tx, err = upgradeExecutor.ExecuteCall(&deployAuth, proxyAdminAddr, upgradeCalldata)
-					Require(t, err)
-					_, err = builder.L1.EnsureTxSucceeded(tx)
-					Require(t, err)
-
+					upgradeToTimedOutChallengeManager(t, ctx, builder, &deployAuth, upgradeExecutor, valWalletA.ChallengeManagerAddress())
 					challengeMangerTimedOut = true
 				}
 			} else if strings.Contains(err.Error(), "insufficient funds") && sawStakerZombie {
@@ -474,6 +451,23 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 	if !stakerBWasStaked {
 		Fatal(t, "staker B was never staked")
 	}
+
+	decisionsA, err := stakerA.LatestDecisions(100)
+	Require(t, err)
+	madeTx := false
+	for _, decision := range decisionsA {
+		if len(decision.Transactions) > 0 {
+			madeTx = true
+		}
+	}
+	if !madeTx {
+		Fatal(t, "staker A decision log has no cycle with a transaction")
+	}
+	dryRun, err := stakerA.DryRunAct(ctx)
+	Require(t, err)
+	if !dryRun.Decision.DryRun {
+		Fatal(t, "dry run decision not marked as such")
+	}
 }
 
 func TestStakersCooperative(t *testing.T) {
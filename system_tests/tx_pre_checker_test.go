@@ -0,0 +1,56 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/yingdianRao/nitro/execution/gethexec"
+)
+
+func TestTxPreCheckerSimulation(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := NewNodeBuilder(ctx).DefaultConfig(t, false)
+	builder.execConfig.TxPreChecker.Strictness = gethexec.TxPreCheckerStrictnessSimulation
+	cleanup := builder.Build(t)
+	defer cleanup()
+
+	builder.L2Info.GenerateAccount("User")
+	builder.L2.TransferBalance(t, "Owner", "User", big.NewInt(params.Ether), builder.L2Info)
+
+	// init code reverting with Error("nope"), as a contract creation it executes more gas than the sequencer rejects reverts under
+	initCode := common.FromHex(
+		"7f08c379a0" + strings.Repeat("00", 28) + "600052" + // mstore(0, Error(string) selector)
+			"6020600452" + // mstore(4, string offset)
+			"6004602452" + // mstore(0x24, string length)
+			"7f6e6f7065" + strings.Repeat("00", 28) + "604452" + // mstore(0x44, "nope")
+			"60646000fd", // revert(0, 0x64)
+	)
+	reverting := builder.L2Info.PrepareTxTo("User", nil, 1000000, common.Big0, initCode)
+	err := builder.L2.Client.SendTransaction(ctx, reverting)
+	if err == nil || !strings.Contains(err.Error(), "execution reverted: nope") {
+		Fatal(t, "expected the simulated revert reason, got", err)
+	}
+	nonce, err := builder.L2.Client.NonceAt(ctx, builder.L2Info.GetAddress("User"), nil)
+	Require(t, err)
+	if nonce != reverting.Nonce() {
+		Fatal(t, "rejected transaction was sequenced, nonce", nonce)
+	}
+
+	// transactions that succeed in simulation are forwarded
+	builder.L2Info.GetInfoWithPrivKey("User").Nonce = nonce
+	tx := builder.L2Info.PrepareTx("User", "Owner", builder.L2Info.TransferGas, big.NewInt(1), nil)
+	Require(t, builder.L2.Client.SendTransaction(ctx, tx))
+	_, err = builder.L2.EnsureTxSucceeded(tx)
+	Require(t, err)
+}
@@ -0,0 +1,241 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SignerRegistryABI is the part of a signer registry contract read by SignerRegistry:
+//
+//	function feedSigners() external view returns (address[] memory signers, uint64[] memory validFrom, uint64[] memory validUntil);
+//
+// The registry allows each signer from its validFrom timestamp, until its validUntil timestamp
+// excluded if it's not 0. Rotating a key is adding the new signer a while before the old one's
+// validUntil, so that clients refreshing the registry in between accept both.
+const SignerRegistryABI = `[{"inputs":[],"name":"feedSigners","outputs":[{"internalType":"address[]","name":"signers","type":"address[]"},{"internalType":"uint64[]","name":"validFrom","type":"uint64[]"},{"internalType":"uint64[]","name":"validUntil","type":"uint64[]"}],"stateMutability":"view","type":"function"}]`
+
+type SignerRegistryConfig struct {
+	Address         string        `koanf:"address"`
+	RefreshInterval time.Duration `koanf:"refresh-interval"`
+	TimestampMargin time.Duration `koanf:"timestamp-margin"`
+	RetiredGrace    time.Duration `koanf:"retired-grace"`
+}
+
+var DefaultSignerRegistryConfig = SignerRegistryConfig{
+	Address:         "",
+	RefreshInterval: time.Minute,
+	TimestampMargin: time.Minute,
+	RetiredGrace:    time.Hour,
+}
+
+func SignerRegistryConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".address", DefaultSignerRegistryConfig.Address, "if set, the parent chain address of a contract listing the allowed feed signers and their rotation windows")
+	f.Duration(prefix+".refresh-interval", DefaultSignerRegistryConfig.RefreshInterval, "interval to read the allowed feed signers from the signer registry at")
+	f.Duration(prefix+".timestamp-margin", DefaultSignerRegistryConfig.TimestampMargin, "how far the timestamp of a message may be outside of the rotation window of its signer, for the delay between sequencing and signing it")
+	f.Duration(prefix+".retired-grace", DefaultSignerRegistryConfig.RetiredGrace, "how long after the end of its rotation window messages of a signer are still accepted, for catching up on the feed backlog signed before the rotation")
+}
+
+func (c *SignerRegistryConfig) Enable() bool {
+	return c.Address != ""
+}
+
+func (c *SignerRegistryConfig) Validate() error {
+	if !c.Enable() {
+		return nil
+	}
+	if !common.IsHexAddress(c.Address) {
+		return fmt.Errorf("invalid signer registry address \"%v\"", c.Address)
+	}
+	if c.RefreshInterval <= 0 {
+		return errors.New("signer registry refresh-interval must be positive")
+	}
+	if c.TimestampMargin < 0 {
+		return errors.New("signer registry timestamp-margin must not be negative")
+	}
+	if c.RetiredGrace < 0 {
+		return errors.New("signer registry retired-grace must not be negative")
+	}
+	return nil
+}
+
+// ErrSignerRegistryUnavailable is returned when the signer registry was never read successfully,
+// so it can't tell whether a signer is allowed yet.
+var ErrSignerRegistryUnavailable = errors.New("signer registry unavailable")
+
+type SignerRegistryInterface interface {
+	// IsAllowedSigner returns whether addr was allowed to sign messages timestamped at.
+	IsAllowedSigner(ctx context.Context, addr common.Address, at time.Time) (bool, error)
+}
+
+type registeredSigner struct {
+	validFrom  time.Time
+	validUntil time.Time
+}
+
+func (s registeredSigner) validAt(t time.Time, margin time.Duration) bool {
+	return !t.Before(s.validFrom.Add(-margin)) && (s.validUntil.IsZero() || t.Before(s.validUntil.Add(margin)))
+}
+
+// retiredBy returns whether the rotation window of the signer ended more than grace before now.
+// The timestamp of a message is chosen by its signer, so a retired key could otherwise keep
+// signing messages backdated into its window.
+func (s registeredSigner) retiredBy(now time.Time, grace time.Duration) bool {
+	return !s.validUntil.IsZero() && !now.Before(s.validUntil.Add(grace))
+}
+
+// unknownSignerRefreshInterval bounds how often a signer missing from the registry makes it read again,
+// so that clients accept a new signer without waiting for the refresh interval.
+var unknownSignerRefreshInterval = 5 * time.Second
+
+// SignerRegistry reads the allowed signers from a signer registry contract, see SignerRegistryABI,
+// and caches them for the refresh interval.
+type SignerRegistry struct {
+	contract        *bind.BoundContract
+	refreshInterval time.Duration
+	timestampMargin time.Duration
+	retiredGrace    time.Duration
+
+	// refreshMutex is held while reading the registry, so that it's only read once at a time
+	// while the signers read before keep being used
+	refreshMutex sync.Mutex
+	mutex        sync.Mutex
+	signers      map[common.Address][]registeredSigner
+	fetchedAt    time.Time
+}
+
+func NewSignerRegistry(config *SignerRegistryConfig, caller bind.ContractCaller) (*SignerRegistry, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	registryABI, err := abi.JSON(strings.NewReader(SignerRegistryABI))
+	if err != nil {
+		return nil, err
+	}
+	return &SignerRegistry{
+		contract:        bind.NewBoundContract(common.HexToAddress(config.Address), registryABI, caller, nil, nil),
+		refreshInterval: config.RefreshInterval,
+		timestampMargin: config.TimestampMargin,
+		retiredGrace:    config.RetiredGrace,
+	}, nil
+}
+
+func (r *SignerRegistry) fetch(ctx context.Context) (map[common.Address][]registeredSigner, error) {
+	var results []interface{}
+	if err := r.contract.Call(&bind.CallOpts{Context: ctx}, &results, "feedSigners"); err != nil {
+		return nil, err
+	}
+	if len(results) != 3 {
+		return nil, fmt.Errorf("signer registry returned %d values, expected 3", len(results))
+	}
+	addrs, ok := results[0].([]common.Address)
+	validFrom, ok2 := results[1].([]uint64)
+	validUntil, ok3 := results[2].([]uint64)
+	if !ok || !ok2 || !ok3 {
+		return nil, errors.New("unexpected signer registry result types")
+	}
+	if len(validFrom) != len(addrs) || len(validUntil) != len(addrs) {
+		return nil, fmt.Errorf("signer registry returned %d signers with %d and %d window bounds", len(addrs), len(validFrom), len(validUntil))
+	}
+	signers := make(map[common.Address][]registeredSigner, len(addrs))
+	for i, addr := range addrs {
+		signer := registeredSigner{validFrom: time.Unix(int64(validFrom[i]), 0)}
+		if validUntil[i] != 0 {
+			signer.validUntil = time.Unix(int64(validUntil[i]), 0)
+		}
+		signers[addr] = append(signers[addr], signer)
+	}
+	return signers, nil
+}
+
+func (r *SignerRegistry) cached() (map[common.Address][]registeredSigner, time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.signers, r.fetchedAt
+}
+
+// refresh reads the registry again, keeping the signers read before if it fails.
+// Unless wait is set, it returns right away if the registry is already being read.
+func (r *SignerRegistry) refresh(ctx context.Context, wait bool) error {
+	if wait {
+		r.refreshMutex.Lock()
+		defer r.refreshMutex.Unlock()
+		if signers, _ := r.cached(); signers != nil {
+			// read while waiting
+			return nil
+		}
+	} else if !r.refreshMutex.TryLock() {
+		return nil
+	} else {
+		defer r.refreshMutex.Unlock()
+	}
+	signers, err := r.fetch(ctx)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err != nil {
+		if r.signers == nil {
+			return fmt.Errorf("%w: %w", ErrSignerRegistryUnavailable, err)
+		}
+		log.Warn("error reading signer registry, using the signers read before", "fetchedAt", r.fetchedAt, "err", err)
+		// don't read it again for every message until the next refresh
+		r.fetchedAt = time.Now()
+		return nil
+	}
+	r.signers = signers
+	r.fetchedAt = time.Now()
+	return nil
+}
+
+// IsAllowedSigner returns whether the registry allows addr to sign messages timestamped at,
+// give or take the timestamp margin, and its window didn't end more than the retired grace ago.
+func (r *SignerRegistry) IsAllowedSigner(ctx context.Context, addr common.Address, at time.Time) (bool, error) {
+	signers, fetchedAt := r.cached()
+	if signers == nil {
+		if err := r.refresh(ctx, true); err != nil {
+			return false, err
+		}
+	} else if time.Since(fetchedAt) >= r.refreshInterval {
+		if err := r.refresh(ctx, false); err != nil {
+			return false, err
+		}
+	} else if _, known := signers[addr]; !known && time.Since(fetchedAt) >= unknownSignerRefreshInterval {
+		if err := r.refresh(ctx, false); err != nil {
+			return false, err
+		}
+	}
+	signers, _ = r.cached()
+	now := time.Now()
+	for _, signer := range signers[addr] {
+		if signer.validAt(at, r.timestampMargin) && !signer.retiredBy(now, r.retiredGrace) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func NewMockSignerRegistry(validAddr common.Address) *MockSignerRegistry {
+	return &MockSignerRegistry{
+		validAddr: validAddr,
+	}
+}
+
+type MockSignerRegistry struct {
+	validAddr common.Address
+}
+
+func (r *MockSignerRegistry) IsAllowedSigner(_ context.Context, addr common.Address, _ time.Time) (bool, error) {
+	return addr == r.validAddr, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package contracts
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testRegistryCaller answers feedSigners calls with its current signers.
+type testRegistryCaller struct {
+	t          *testing.T
+	mutex      sync.Mutex
+	signers    []common.Address
+	validFrom  []uint64
+	validUntil []uint64
+	calls      int
+	fail       bool
+}
+
+func (c *testRegistryCaller) set(signers []common.Address, validFrom []uint64, validUntil []uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.signers, c.validFrom, c.validUntil = signers, validFrom, validUntil
+}
+
+func (c *testRegistryCaller) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *testRegistryCaller) CallContract(context.Context, ethereum.CallMsg, *big.Int) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls++
+	if c.fail {
+		return nil, errors.New("parent chain unavailable")
+	}
+	registryABI, err := abi.JSON(strings.NewReader(SignerRegistryABI))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return registryABI.Methods["feedSigners"].Outputs.Pack(c.signers, c.validFrom, c.validUntil)
+}
+
+func TestSignerRegistry(t *testing.T) {
+	ctx := context.Background()
+	oldKey := common.BigToAddress(big.NewInt(1))
+	newKey := common.BigToAddress(big.NewInt(2))
+	retiredKey := common.BigToAddress(big.NewInt(3))
+	futureKey := common.BigToAddress(big.NewInt(4))
+	expiredKey := common.BigToAddress(big.NewInt(6))
+	now := uint64(time.Now().Unix())
+
+	caller := &testRegistryCaller{t: t}
+	// the old key is being rotated to the new one, both are allowed during the window
+	caller.set(
+		[]common.Address{oldKey, newKey, retiredKey, futureKey, expiredKey},
+		[]uint64{0, now - 60, 0, now + 3600, 0},
+		[]uint64{now + 60, 0, now - 60, 0, now - 7200},
+	)
+	config := SignerRegistryConfig{Address: common.BigToAddress(big.NewInt(100)).Hex(), RefreshInterval: time.Hour, TimestampMargin: time.Second, RetiredGrace: time.Hour}
+	registry, err := NewSignerRegistry(&config, caller)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectAllowed := func(addr common.Address, expected bool) {
+		t.Helper()
+		allowed, err := registry.IsAllowedSigner(ctx, addr, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != expected {
+			t.Fatalf("signer %v allowed: %v, expected %v", addr, allowed, expected)
+		}
+	}
+	expectAllowed(oldKey, true)
+	expectAllowed(newKey, true)
+	expectAllowed(retiredKey, false)
+	expectAllowed(futureKey, false)
+	if caller.calls != 1 {
+		t.Fatal("expected the registry to be read once, got", caller.calls)
+	}
+
+	// messages are checked at their own timestamp, give or take the margin
+	expectAllowedAt := func(addr common.Address, at uint64, expected bool) {
+		t.Helper()
+		allowed, err := registry.IsAllowedSigner(ctx, addr, time.Unix(int64(at), 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != expected {
+			t.Fatalf("signer %v allowed at %v: %v, expected %v", addr, at, allowed, expected)
+		}
+	}
+	expectAllowedAt(retiredKey, now-120, true)
+	expectAllowedAt(retiredKey, now-60, true)
+	expectAllowedAt(retiredKey, now-58, false)
+	expectAllowedAt(futureKey, now+3599, true)
+	expectAllowedAt(futureKey, now+3598, false)
+	expectAllowedAt(oldKey, now+120, false)
+
+	// a signer retired for longer than the grace can't backdate messages into its window
+	expectAllowedAt(expiredKey, now-7300, false)
+	registry.retiredGrace = 3 * time.Hour
+	expectAllowedAt(expiredKey, now-7300, true)
+	registry.retiredGrace = config.RetiredGrace
+
+	// signers missing from the registry make it read again once in a while
+	addedKey := common.BigToAddress(big.NewInt(5))
+	caller.set([]common.Address{oldKey, addedKey}, []uint64{0, 0}, []uint64{now + 60, 0})
+	expectAllowed(addedKey, false)
+	registry.fetchedAt = time.Now().Add(-unknownSignerRefreshInterval)
+	expectAllowed(addedKey, true)
+	expectAllowed(oldKey, true)
+	if caller.calls != 2 {
+		t.Fatal("expected the registry to be read twice, got", caller.calls)
+	}
+
+	// failing to read the registry again keeps the signers read before
+	caller.fail = true
+	registry.fetchedAt = time.Now().Add(-config.RefreshInterval)
+	expectAllowed(addedKey, true)
+	fresh, err := NewSignerRegistry(&config, caller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fresh.IsAllowedSigner(ctx, addedKey, time.Now()); !errors.Is(err, ErrSignerRegistryUnavailable) {
+		t.Fatal("registry never read didn't report being unavailable, got", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/yingdianRao/nitro/util/redisutil"
+)
+
+// Coordinator reads the sequencer coordination state out of a Store.
+// The keys it uses are the ones defined in redisutil, whichever the backend.
+type Coordinator struct {
+	Store Store
+}
+
+func NewCoordinator(store Store) *Coordinator {
+	return &Coordinator{Store: store}
+}
+
+// RecommendSequencerWantingLockout returns the top priority sequencer wanting the lockout
+func (c *Coordinator) RecommendSequencerWantingLockout(ctx context.Context) (string, error) {
+	prioritiesString, err := c.Store.Get(ctx, redisutil.PRIORITIES_KEY)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			err = errors.New("sequencer priorities unset")
+		}
+		return "", err
+	}
+	priorities := strings.Split(prioritiesString, ",")
+	for _, url := range priorities {
+		_, err := c.Store.Get(ctx, redisutil.WantsLockoutKeyFor(url))
+		if errors.Is(err, ErrNotFound) { // wants lockout not set
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return url, nil
+	}
+	log.Error("no sequencer appears to want the lockout", "priorities", prioritiesString)
+	return "", nil
+}
+
+// CurrentChosenSequencer retrieves the current chosen sequencer holding the lock
+func (c *Coordinator) CurrentChosenSequencer(ctx context.Context) (string, error) {
+	current, err := c.Store.Get(ctx, redisutil.CHOSENSEQ_KEY)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return current, nil
+}
+
+// GetPriorities returns the priority list of sequencers
+func (c *Coordinator) GetPriorities(ctx context.Context) ([]string, error) {
+	prioritiesString, err := c.Store.Get(ctx, redisutil.PRIORITIES_KEY)
+	if errors.Is(err, ErrNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return []string{}, err
+	}
+	return strings.Split(prioritiesString, ","), nil
+}
+
+// UpdatePriorities updates the priority list of sequencers
+func (c *Coordinator) UpdatePriorities(ctx context.Context, priorities []string) error {
+	if len(priorities) == 0 {
+		return c.Store.Del(ctx, redisutil.PRIORITIES_KEY)
+	}
+	return c.Store.Set(ctx, redisutil.PRIORITIES_KEY, strings.Join(priorities, ","), 0)
+}
+
+// GetLiveliness returns a list of sequencers that have their liveliness set to OK
+func (c *Coordinator) GetLiveliness(ctx context.Context) ([]string, error) {
+	keys, err := c.Store.Keys(ctx, redisutil.WANTS_LOCKOUT_KEY_PREFIX)
+	if err != nil {
+		return []string{}, err
+	}
+	livelinessList := make([]string, 0, len(keys))
+	for _, key := range keys {
+		livelinessList = append(livelinessList, strings.TrimPrefix(key, redisutil.WANTS_LOCKOUT_KEY_PREFIX))
+	}
+	return livelinessList, nil
+}
+
+// ErrPrioritiesChanged is returned by SwapPriorities when the priority list isn't the expected one.
+var ErrPrioritiesChanged = errors.New("sequencer priorities changed concurrently")
+
+// SwapPriorities replaces the priority list of sequencers, only if it still is the expected one.
+func (c *Coordinator) SwapPriorities(ctx context.Context, expected []string, priorities []string) error {
+	err := c.Store.Watch(ctx, func(tx Tx) error {
+		current, err := tx.Get(ctx, redisutil.PRIORITIES_KEY)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if current != strings.Join(expected, ",") {
+			return ErrPrioritiesChanged
+		}
+		if len(priorities) == 0 {
+			tx.Del(redisutil.PRIORITIES_KEY)
+		} else {
+			tx.Set(redisutil.PRIORITIES_KEY, strings.Join(priorities, ","), 0)
+		}
+		return nil
+	}, redisutil.PRIORITIES_KEY)
+	if errors.Is(err, ErrTxFailed) {
+		return ErrPrioritiesChanged
+	}
+	return err
+}
@@ -0,0 +1,931 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/yingdianRao/nitro/util/signature"
+	"github.com/yingdianRao/nitro/util/stopwaiter"
+)
+
+var (
+	raftLeaderGauge = metrics.NewRegisteredGauge("arb/coordination/raft/leader", nil)
+	raftTermGauge   = metrics.NewRegisteredGauge("arb/coordination/raft/term", nil)
+	raftCommitGauge = metrics.NewRegisteredGauge("arb/coordination/raft/commit", nil)
+)
+
+var (
+	errNotLeader      = errors.New("raft member is not the leader")
+	errLeadershipLost = errors.New("raft leadership lost before the command was committed")
+)
+
+type RaftConfig struct {
+	Url                 string        `koanf:"url"`
+	Addr                string        `koanf:"addr"`
+	Peers               []string      `koanf:"peers"`
+	DataDir             string        `koanf:"data-dir"`
+	JWTSecret           string        `koanf:"jwtsecret"`
+	ElectionTimeout     time.Duration `koanf:"election-timeout"`
+	HeartbeatInterval   time.Duration `koanf:"heartbeat-interval"`
+	RequestTimeout      time.Duration `koanf:"request-timeout"`
+	MaxEntriesPerAppend int           `koanf:"max-entries-per-append"`
+	SnapshotThreshold   uint64        `koanf:"snapshot-threshold"`
+	// InMemory lets a member run without a data directory, forgetting its votes and log when
+	// restarted. It has no flag, as it's only safe for tests that never restart members.
+	InMemory bool `koanf:"-"`
+}
+
+func RaftConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".url", DefaultRaftConfig.Url, "the URL the other members of the Raft group reach this member at, which also identifies it")
+	f.String(prefix+".addr", DefaultRaftConfig.Addr, "the address to listen on for the other members of the Raft group")
+	f.StringSlice(prefix+".peers", DefaultRaftConfig.Peers, "the URLs of the other members of the Raft group")
+	f.String(prefix+".data-dir", DefaultRaftConfig.DataDir, "the directory to persist the Raft log and votes in (required)")
+	f.String(prefix+".jwtsecret", DefaultRaftConfig.JWTSecret, "path to the file with the hex-encoded 32 byte secret the members of the Raft group and the nodes following it authenticate with (required)")
+	f.Duration(prefix+".election-timeout", DefaultRaftConfig.ElectionTimeout, "the minimum time without hearing from the leader before a member starts an election")
+	f.Duration(prefix+".heartbeat-interval", DefaultRaftConfig.HeartbeatInterval, "the interval the leader replicates its log at, even if it has nothing new")
+	f.Duration(prefix+".request-timeout", DefaultRaftConfig.RequestTimeout, "the timeout of requests to the other members")
+	f.Int(prefix+".max-entries-per-append", DefaultRaftConfig.MaxEntriesPerAppend, "the maximum number of log entries sent to a member at once")
+	f.Uint64(prefix+".snapshot-threshold", DefaultRaftConfig.SnapshotThreshold, "the number of applied log entries after which the log is compacted into a snapshot")
+}
+
+var DefaultRaftConfig = RaftConfig{
+	Url:                 "",
+	Addr:                "",
+	Peers:               []string{},
+	DataDir:             "",
+	JWTSecret:           "",
+	ElectionTimeout:     time.Second,
+	HeartbeatInterval:   100 * time.Millisecond,
+	RequestTimeout:      2 * time.Second,
+	MaxEntriesPerAppend: 256,
+	SnapshotThreshold:   8192,
+}
+
+var TestRaftConfig = RaftConfig{
+	Url:                 "",
+	Addr:                "",
+	Peers:               []string{},
+	DataDir:             "",
+	JWTSecret:           TestRaftJWTSecret,
+	ElectionTimeout:     150 * time.Millisecond,
+	HeartbeatInterval:   20 * time.Millisecond,
+	RequestTimeout:      100 * time.Millisecond,
+	MaxEntriesPerAppend: 64,
+	SnapshotThreshold:   128,
+	InMemory:            true,
+}
+
+// TestRaftJWTSecret is the secret the members of test Raft groups authenticate with.
+const TestRaftJWTSecret = "0x0102030405060708091011121314151617181920212223242526272829303132"
+
+func (c *RaftConfig) Validate() error {
+	if c.Url == "" {
+		return errors.New("raft coordination requires the url of this member")
+	}
+	if c.DataDir == "" && !c.InMemory {
+		return errors.New("raft coordination requires a data directory to persist its log and votes in")
+	}
+	if c.JWTSecret == "" {
+		return errors.New("raft coordination requires a jwt secret for the members to authenticate with")
+	}
+	if c.HeartbeatInterval <= 0 || c.HeartbeatInterval >= c.ElectionTimeout {
+		return fmt.Errorf("raft heartbeat interval %v must be positive and below the election timeout %v", c.HeartbeatInterval, c.ElectionTimeout)
+	}
+	if c.MaxEntriesPerAppend <= 0 {
+		return errors.New("raft max entries per append must be positive")
+	}
+	for _, peer := range c.Peers {
+		if peer == c.Url {
+			return errors.New("raft peers must not include this member's own url")
+		}
+	}
+	return nil
+}
+
+type raftRole int
+
+const (
+	raftFollower raftRole = iota
+	raftCandidate
+	raftLeader
+)
+
+type raftPeer struct {
+	url     string
+	client  *rpc.Client
+	trigger chan struct{}
+
+	// leader state, protected by the node's mutex
+	nextIndex  uint64
+	matchIndex uint64
+	lastAck    time.Time // when the last request this peer acknowledged in the current term was sent
+}
+
+func (p *raftPeer) notify() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+type raftWaiter struct {
+	term   uint64
+	result chan error
+}
+
+// RaftNode is a member of an embedded Raft group replicating the coordination store among the sequencers.
+// Writes are committed through the leader's log, and reads are served by the leader while it holds a lease
+// on its leadership, so a member can only act on the coordination state a majority of the group agrees on.
+type RaftNode struct {
+	stopwaiter.StopWaiterSafe
+	raftStore
+
+	config   *RaftConfig
+	peers    []*raftPeer
+	storage  *raftStorage
+	server   *http.Server
+	listener net.Listener
+
+	mutex            sync.Mutex
+	role             raftRole
+	term             uint64
+	votedFor         string
+	leader           string
+	lastContact      time.Time // when the leader was last heard from
+	electionDeadline time.Time
+	leaderSince      time.Time
+	leaderStartIndex uint64
+	votes            int
+	log              []*raftEntry // entries after snapshotIndex
+	snapshotIndex    uint64
+	snapshotTerm     uint64
+	commitIndex      uint64
+	lastApplied      uint64
+	fsm              *raftFSM
+	waiters          map[uint64]*raftWaiter
+}
+
+func NewRaftNode(config *RaftConfig) (*RaftNode, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewRaftNodeOnListener(config, listener)
+}
+
+// NewRaftNodeOnListener starts a Raft member serving the other members on listener, which it takes ownership of.
+func NewRaftNodeOnListener(config *RaftConfig, listener net.Listener) (*RaftNode, error) {
+	if err := config.Validate(); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	jwtSecret, err := loadRaftJWTSecret(config.JWTSecret)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	storage, err := openRaftStorage(config.DataDir)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	n := &RaftNode{
+		config:   config,
+		storage:  storage,
+		listener: listener,
+		fsm:      newRaftFSM(),
+		waiters:  make(map[uint64]*raftWaiter),
+	}
+	n.raftStore.backend = n
+	if err := n.load(); err != nil {
+		_ = listener.Close()
+		_ = storage.close()
+		return nil, err
+	}
+	for _, url := range config.Peers {
+		client, err := dialRaftMember(url, jwtSecret)
+		if err != nil {
+			n.closePeers()
+			_ = listener.Close()
+			_ = storage.close()
+			return nil, err
+		}
+		n.peers = append(n.peers, &raftPeer{url: url, client: client, trigger: make(chan struct{}, 1)})
+	}
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("coordination", &raftAPI{n}); err != nil {
+		n.closePeers()
+		_ = listener.Close()
+		_ = storage.close()
+		return nil, err
+	}
+	n.server = &http.Server{
+		Handler:           node.NewHTTPHandlerStack(rpcServer, nil, []string{"*"}, jwtSecret),
+		ReadHeaderTimeout: config.RequestTimeout,
+	}
+	go func() {
+		err := n.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("raft coordination server failed", "err", err)
+		}
+	}()
+
+	n.electionDeadline = time.Now().Add(n.randomElectionTimeout())
+	if err := n.Start(context.Background(), n); err != nil {
+		return nil, err
+	}
+	if err := n.LaunchThreadSafe(n.tickLoop); err != nil {
+		return nil, err
+	}
+	for _, peer := range n.peers {
+		peer := peer
+		if err := n.LaunchThreadSafe(func(ctx context.Context) { n.replicationLoop(ctx, peer) }); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func loadRaftJWTSecret(path string) ([]byte, error) {
+	secret, err := signature.LoadSigningKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, errors.New("raft coordination requires a jwt secret")
+	}
+	return secret.Bytes(), nil
+}
+
+// dialRaftMember connects to the Raft group member at url, authenticating with jwtSecret.
+func dialRaftMember(url string, jwtSecret []byte) (*rpc.Client, error) {
+	return rpc.DialOptions(context.Background(), url, rpc.WithHTTPAuth(node.NewJWTAuth([32]byte(jwtSecret))))
+}
+
+func (n *RaftNode) load() error {
+	term, votedFor, err := n.storage.loadHardState()
+	if err != nil {
+		return err
+	}
+	n.term = term
+	n.votedFor = votedFor
+	snapshot, err := n.storage.loadSnapshot()
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		n.fsm.restore(snapshot.Items)
+		n.snapshotIndex = snapshot.Index
+		n.snapshotTerm = snapshot.Term
+		n.commitIndex = snapshot.Index
+		n.lastApplied = snapshot.Index
+	}
+	n.log, err = n.storage.loadEntries(n.snapshotIndex + 1)
+	return err
+}
+
+func (n *RaftNode) closePeers() {
+	for _, peer := range n.peers {
+		peer.client.Close()
+	}
+}
+
+func (n *RaftNode) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.RequestTimeout)
+	defer cancel()
+	_ = n.server.Shutdown(ctx)
+	if err := n.StopAndWait(); err != nil {
+		return err
+	}
+	n.closePeers()
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.failWaiters(errLeadershipLost)
+	return n.storage.close()
+}
+
+// Leader returns the url of the member currently known to lead the group, or an empty string if there's none.
+func (n *RaftNode) Leader() string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.leader
+}
+
+func (n *RaftNode) IsLeader() bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.role == raftLeader
+}
+
+func (n *RaftNode) majority() int {
+	return (len(n.peers)+1)/2 + 1
+}
+
+func (n *RaftNode) randomElectionTimeout() time.Duration {
+	return n.config.ElectionTimeout + time.Duration(rand.Int63n(int64(n.config.ElectionTimeout)))
+}
+
+func (n *RaftNode) lastIndex() uint64 {
+	return n.snapshotIndex + uint64(len(n.log))
+}
+
+func (n *RaftNode) lastTerm() uint64 {
+	if len(n.log) == 0 {
+		return n.snapshotTerm
+	}
+	return n.log[len(n.log)-1].Term
+}
+
+// termAt returns the term of the entry at index, or false if it was compacted or doesn't exist yet.
+func (n *RaftNode) termAt(index uint64) (uint64, bool) {
+	if index == n.snapshotIndex {
+		return n.snapshotTerm, true
+	}
+	if index < n.snapshotIndex || index > n.lastIndex() {
+		return 0, false
+	}
+	return n.log[index-n.snapshotIndex-1].Term, true
+}
+
+func (n *RaftNode) entryAt(index uint64) *raftEntry {
+	return n.log[index-n.snapshotIndex-1]
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) persistHardState() {
+	if err := n.storage.storeHardState(n.term, n.votedFor); err != nil {
+		// continuing could have this member vote twice in a term
+		panic(fmt.Sprintf("failed to persist raft state: %v", err))
+	}
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) becomeFollower(term uint64, leader string) {
+	if n.role == raftLeader {
+		log.Info("raft member stepping down", "url", n.config.Url, "term", n.term, "newTerm", term)
+		n.failWaiters(errLeadershipLost)
+	}
+	n.role = raftFollower
+	n.leader = leader
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.persistHardState()
+	}
+	raftLeaderGauge.Update(0)
+	raftTermGauge.Update(int64(n.term))
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) startElection() {
+	n.role = raftCandidate
+	n.leader = ""
+	n.term++
+	n.votedFor = n.config.Url
+	n.votes = 1
+	n.persistHardState()
+	n.electionDeadline = time.Now().Add(n.randomElectionTimeout())
+	raftTermGauge.Update(int64(n.term))
+	log.Debug("raft member starting election", "url", n.config.Url, "term", n.term)
+	if n.votes >= n.majority() {
+		n.becomeLeader()
+		return
+	}
+	args := &raftVoteArgs{
+		Term:         n.term,
+		Candidate:    n.config.Url,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+	for _, peer := range n.peers {
+		peer := peer
+		// a tracked thread can't be launched while stopping, in which case there's no point in campaigning
+		_ = n.LaunchThreadSafe(func(ctx context.Context) { n.requestVote(ctx, peer, args) })
+	}
+}
+
+func (n *RaftNode) requestVote(ctx context.Context, peer *raftPeer, args *raftVoteArgs) {
+	ctx, cancel := context.WithTimeout(ctx, n.config.RequestTimeout)
+	defer cancel()
+	var reply raftVoteReply
+	if err := peer.client.CallContext(ctx, &reply, "coordination_requestVote", args); err != nil {
+		log.Debug("raft vote request failed", "peer", peer.url, "err", err)
+		return
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if reply.Term > n.term {
+		n.becomeFollower(reply.Term, "")
+		return
+	}
+	if n.role != raftCandidate || n.term != args.Term || !reply.Granted {
+		return
+	}
+	n.votes++
+	if n.votes >= n.majority() {
+		n.becomeLeader()
+	}
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) becomeLeader() {
+	log.Info("raft member became leader", "url", n.config.Url, "term", n.term)
+	n.role = raftLeader
+	n.leader = n.config.Url
+	n.leaderSince = time.Now()
+	for _, peer := range n.peers {
+		peer.nextIndex = n.lastIndex() + 1
+		peer.matchIndex = 0
+		peer.lastAck = time.Time{}
+	}
+	raftLeaderGauge.Update(1)
+	// entries of previous terms can only be committed along with one of the current term
+	n.leaderStartIndex = n.appendEntry(nil).Index
+	n.advanceCommit()
+}
+
+// Requires the caller hold the mutex, and be the leader
+func (n *RaftNode) appendEntry(command *raftCommand) *raftEntry {
+	entry := &raftEntry{
+		Term:    n.term,
+		Index:   n.lastIndex() + 1,
+		Time:    time.Now().UnixMilli(),
+		Command: command,
+	}
+	if err := n.storage.storeEntries([]*raftEntry{entry}, entry.Index-1); err != nil {
+		panic(fmt.Sprintf("failed to persist raft log: %v", err))
+	}
+	n.log = append(n.log, entry)
+	for _, peer := range n.peers {
+		peer.notify()
+	}
+	return entry
+}
+
+// Requires the caller hold the mutex, and be the leader
+func (n *RaftNode) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		term, _ := n.termAt(index)
+		if term != n.term {
+			// only entries of the current term are committed by counting replicas
+			break
+		}
+		replicas := 1
+		for _, peer := range n.peers {
+			if peer.matchIndex >= index {
+				replicas++
+			}
+		}
+		if replicas >= n.majority() {
+			n.commitIndex = index
+			n.applyCommitted()
+			return
+		}
+	}
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) applyCommitted() {
+	raftCommitGauge.Update(int64(n.commitIndex))
+	for n.lastApplied < n.commitIndex {
+		entry := n.entryAt(n.lastApplied + 1)
+		err := n.fsm.apply(entry)
+		n.lastApplied = entry.Index
+		if waiter, ok := n.waiters[entry.Index]; ok {
+			delete(n.waiters, entry.Index)
+			if waiter.term != entry.Term {
+				err = errLeadershipLost
+			}
+			waiter.result <- err
+		}
+	}
+	if uint64(len(n.log)) > n.config.SnapshotThreshold && n.lastApplied > n.snapshotIndex {
+		n.compact()
+	}
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) compact() {
+	lastApplied := n.entryAt(n.lastApplied)
+	n.fsm.purge(lastApplied.Time)
+	snapshot := &raftSnapshot{
+		Index: lastApplied.Index,
+		Term:  lastApplied.Term,
+		Items: n.fsm.snapshot(),
+	}
+	if err := n.storage.storeSnapshot(snapshot, n.snapshotIndex+1, snapshot.Index); err != nil {
+		log.Error("failed to persist raft snapshot", "err", err)
+		return
+	}
+	n.log = append([]*raftEntry(nil), n.log[snapshot.Index-n.snapshotIndex:]...)
+	n.snapshotIndex = snapshot.Index
+	n.snapshotTerm = snapshot.Term
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) failWaiters(err error) {
+	for index, waiter := range n.waiters {
+		waiter.result <- err
+		delete(n.waiters, index)
+	}
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) hasLease(now time.Time) bool {
+	if n.role != raftLeader {
+		return false
+	}
+	// followers don't vote for another leader before hearing nothing from this one for an election timeout,
+	// minus a margin for the clocks drifting apart
+	leaseStart := now.Add(-n.config.ElectionTimeout * 9 / 10)
+	acks := 1
+	for _, peer := range n.peers {
+		if peer.lastAck.After(leaseStart) {
+			acks++
+		}
+	}
+	return acks >= n.majority()
+}
+
+func (n *RaftNode) tickLoop(ctx context.Context) {
+	ticker := time.NewTicker(n.config.HeartbeatInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n.mutex.Lock()
+			if n.role == raftLeader {
+				// step down if cut off from the majority, so that clients look for the new leader
+				if !n.hasLease(now) && now.Sub(n.leaderSince) > n.config.ElectionTimeout {
+					n.becomeFollower(n.term, "")
+					n.electionDeadline = now.Add(n.randomElectionTimeout())
+				}
+			} else if now.After(n.electionDeadline) {
+				n.startElection()
+			}
+			n.mutex.Unlock()
+		}
+	}
+}
+
+func (n *RaftNode) replicationLoop(ctx context.Context, peer *raftPeer) {
+	ticker := time.NewTicker(n.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-peer.trigger:
+		}
+		n.replicateTo(ctx, peer)
+	}
+}
+
+func (n *RaftNode) replicateTo(ctx context.Context, peer *raftPeer) {
+	n.mutex.Lock()
+	if n.role != raftLeader {
+		n.mutex.Unlock()
+		return
+	}
+	term := n.term
+	sent := time.Now()
+	if peer.nextIndex <= n.snapshotIndex {
+		// the state machine is ahead of the compacted log, so send it as of the last applied entry
+		appliedTerm, _ := n.termAt(n.lastApplied)
+		args := &raftSnapshotArgs{
+			Term:   n.term,
+			Leader: n.config.Url,
+			Snapshot: raftSnapshot{
+				Index: n.lastApplied,
+				Term:  appliedTerm,
+				Items: n.fsm.snapshot(),
+			},
+		}
+		n.mutex.Unlock()
+		n.sendSnapshot(ctx, peer, term, sent, args)
+		return
+	}
+	prevIndex := peer.nextIndex - 1
+	prevTerm, _ := n.termAt(prevIndex)
+	end := n.lastIndex()
+	if end-prevIndex > uint64(n.config.MaxEntriesPerAppend) {
+		end = prevIndex + uint64(n.config.MaxEntriesPerAppend)
+	}
+	args := &raftAppendArgs{
+		Term:         n.term,
+		Leader:       n.config.Url,
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  prevTerm,
+		Entries:      append([]*raftEntry(nil), n.log[prevIndex-n.snapshotIndex:end-n.snapshotIndex]...),
+		LeaderCommit: n.commitIndex,
+	}
+	n.mutex.Unlock()
+
+	callCtx, cancel := context.WithTimeout(ctx, n.config.RequestTimeout)
+	defer cancel()
+	var reply raftAppendReply
+	if err := peer.client.CallContext(callCtx, &reply, "coordination_appendEntries", args); err != nil {
+		log.Debug("raft append entries failed", "peer", peer.url, "err", err)
+		return
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if reply.Term > n.term {
+		n.becomeFollower(reply.Term, "")
+		n.electionDeadline = time.Now().Add(n.randomElectionTimeout())
+		return
+	}
+	if n.role != raftLeader || n.term != term {
+		return
+	}
+	peer.lastAck = sent
+	if reply.Success {
+		match := prevIndex + uint64(len(args.Entries))
+		if match > peer.matchIndex {
+			peer.matchIndex = match
+		}
+		peer.nextIndex = peer.matchIndex + 1
+		n.advanceCommit()
+	} else if reply.ConflictIndex < peer.nextIndex {
+		peer.nextIndex = reply.ConflictIndex
+		if peer.nextIndex == 0 {
+			peer.nextIndex = 1
+		}
+	} else {
+		peer.nextIndex--
+	}
+	if peer.nextIndex <= n.lastIndex() {
+		peer.notify()
+	}
+}
+
+func (n *RaftNode) sendSnapshot(ctx context.Context, peer *raftPeer, term uint64, sent time.Time, args *raftSnapshotArgs) {
+	callCtx, cancel := context.WithTimeout(ctx, n.config.RequestTimeout)
+	defer cancel()
+	var reply raftSnapshotReply
+	if err := peer.client.CallContext(callCtx, &reply, "coordination_installSnapshot", args); err != nil {
+		log.Debug("raft install snapshot failed", "peer", peer.url, "err", err)
+		return
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if reply.Term > n.term {
+		n.becomeFollower(reply.Term, "")
+		return
+	}
+	if n.role != raftLeader || n.term != term {
+		return
+	}
+	peer.lastAck = sent
+	if args.Snapshot.Index > peer.matchIndex {
+		peer.matchIndex = args.Snapshot.Index
+	}
+	peer.nextIndex = peer.matchIndex + 1
+	n.advanceCommit()
+	peer.notify()
+}
+
+func (n *RaftNode) handleRequestVote(args *raftVoteArgs) *raftVoteReply {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	now := time.Now()
+	if args.Term < n.term {
+		return &raftVoteReply{Term: n.term}
+	}
+	// don't let a member that lost touch with the leader disrupt it, and honor the leader's lease
+	if n.hasLease(now) || (n.role == raftFollower && n.leader != "" && now.Sub(n.lastContact) < n.config.ElectionTimeout) {
+		return &raftVoteReply{Term: n.term}
+	}
+	if args.Term > n.term {
+		n.becomeFollower(args.Term, "")
+	}
+	upToDate := args.LastLogTerm > n.lastTerm() || (args.LastLogTerm == n.lastTerm() && args.LastLogIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == args.Candidate) && upToDate {
+		n.votedFor = args.Candidate
+		n.persistHardState()
+		n.electionDeadline = now.Add(n.randomElectionTimeout())
+		return &raftVoteReply{Term: n.term, Granted: true}
+	}
+	return &raftVoteReply{Term: n.term}
+}
+
+// Requires the caller hold the mutex
+func (n *RaftNode) acceptLeader(term uint64, leader string) {
+	if term > n.term || n.role != raftFollower {
+		n.becomeFollower(term, leader)
+	}
+	n.leader = leader
+	n.lastContact = time.Now()
+	n.electionDeadline = n.lastContact.Add(n.randomElectionTimeout())
+}
+
+func (n *RaftNode) handleAppendEntries(args *raftAppendArgs) *raftAppendReply {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if args.Term < n.term {
+		return &raftAppendReply{Term: n.term}
+	}
+	n.acceptLeader(args.Term, args.Leader)
+
+	entries := args.Entries
+	prevIndex, prevTerm := args.PrevLogIndex, args.PrevLogTerm
+	// entries already compacted into the snapshot were committed, so they match
+	for len(entries) > 0 && prevIndex < n.snapshotIndex {
+		prevIndex, prevTerm = entries[0].Index, entries[0].Term
+		entries = entries[1:]
+	}
+	if prevIndex < n.snapshotIndex {
+		return &raftAppendReply{Term: n.term, Success: true}
+	}
+	if prevIndex > n.lastIndex() {
+		return &raftAppendReply{Term: n.term, ConflictIndex: n.lastIndex() + 1}
+	}
+	if term, _ := n.termAt(prevIndex); term != prevTerm {
+		// skip back over the whole conflicting term at once
+		conflict := prevIndex
+		for conflict > n.snapshotIndex+1 {
+			if before, _ := n.termAt(conflict - 1); before != term {
+				break
+			}
+			conflict--
+		}
+		return &raftAppendReply{Term: n.term, ConflictIndex: conflict}
+	}
+
+	for i, entry := range entries {
+		oldLastIndex := n.lastIndex()
+		if entry.Index <= oldLastIndex {
+			if term, _ := n.termAt(entry.Index); term == entry.Term {
+				continue
+			}
+			// a conflicting uncommitted suffix, replaced by the leader's
+			n.log = n.log[:entry.Index-n.snapshotIndex-1]
+		}
+		if err := n.storage.storeEntries(entries[i:], oldLastIndex); err != nil {
+			panic(fmt.Sprintf("failed to persist raft log: %v", err))
+		}
+		n.log = append(n.log, entries[i:]...)
+		break
+	}
+
+	lastNew := prevIndex + uint64(len(entries))
+	if args.LeaderCommit > n.commitIndex {
+		n.commitIndex = args.LeaderCommit
+		if lastNew < n.commitIndex {
+			n.commitIndex = lastNew
+		}
+		n.applyCommitted()
+	}
+	return &raftAppendReply{Term: n.term, Success: true}
+}
+
+func (n *RaftNode) handleInstallSnapshot(args *raftSnapshotArgs) *raftSnapshotReply {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if args.Term < n.term {
+		return &raftSnapshotReply{Term: n.term}
+	}
+	n.acceptLeader(args.Term, args.Leader)
+	snapshot := &args.Snapshot
+	if snapshot.Index <= n.lastApplied {
+		return &raftSnapshotReply{Term: n.term}
+	}
+	term, ok := n.termAt(snapshot.Index)
+	keepSuffix := ok && term == snapshot.Term
+	deleteTo := snapshot.Index
+	if !keepSuffix && n.lastIndex() > deleteTo {
+		deleteTo = n.lastIndex()
+	}
+	if err := n.storage.storeSnapshot(snapshot, n.snapshotIndex+1, deleteTo); err != nil {
+		log.Error("failed to persist raft snapshot", "err", err)
+		return &raftSnapshotReply{Term: n.term}
+	}
+	if keepSuffix {
+		n.log = append([]*raftEntry(nil), n.log[snapshot.Index-n.snapshotIndex:]...)
+	} else {
+		n.log = nil
+	}
+	n.fsm.restore(snapshot.Items)
+	n.snapshotIndex = snapshot.Index
+	n.snapshotTerm = snapshot.Term
+	n.lastApplied = snapshot.Index
+	if n.commitIndex < snapshot.Index {
+		n.commitIndex = snapshot.Index
+	}
+	n.applyCommitted()
+	return &raftSnapshotReply{Term: n.term}
+}
+
+// readLocal serves keys out of the local state, if this member is a leader holding its lease.
+func (n *RaftNode) readLocal(keys []string) ([]raftReadResult, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	now := time.Now()
+	if !n.hasLease(now) || n.lastApplied < n.leaderStartIndex {
+		return nil, errNotLeader
+	}
+	results := make([]raftReadResult, len(keys))
+	for i, key := range keys {
+		results[i] = n.fsm.read(key, now.UnixMilli())
+	}
+	return results, nil
+}
+
+func (n *RaftNode) keysLocal(prefix string) ([]string, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	now := time.Now()
+	if !n.hasLease(now) || n.lastApplied < n.leaderStartIndex {
+		return nil, errNotLeader
+	}
+	return n.fsm.keys(prefix, now.UnixMilli()), nil
+}
+
+func (n *RaftNode) applyLocal(ctx context.Context, command *raftCommand) error {
+	n.mutex.Lock()
+	if n.role != raftLeader {
+		n.mutex.Unlock()
+		return errNotLeader
+	}
+	entry := n.appendEntry(command)
+	waiter := &raftWaiter{term: entry.Term, result: make(chan error, 1)}
+	n.waiters[entry.Index] = waiter
+	n.advanceCommit()
+	n.mutex.Unlock()
+	select {
+	case err := <-waiter.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// leaderClient returns the client for the leader, if it's another known member.
+func (n *RaftNode) leaderClient() *rpc.Client {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, peer := range n.peers {
+		if peer.url == n.leader {
+			return peer.client
+		}
+	}
+	return nil
+}
+
+func (n *RaftNode) read(ctx context.Context, keys []string) ([]raftReadResult, error) {
+	results, err := n.readLocal(keys)
+	if !errors.Is(err, errNotLeader) {
+		return results, err
+	}
+	client := n.leaderClient()
+	if client == nil {
+		return nil, err
+	}
+	return raftRemoteRead(ctx, client, keys)
+}
+
+func (n *RaftNode) keys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := n.keysLocal(prefix)
+	if !errors.Is(err, errNotLeader) {
+		return keys, err
+	}
+	client := n.leaderClient()
+	if client == nil {
+		return nil, err
+	}
+	return raftRemoteKeys(ctx, client, prefix)
+}
+
+func (n *RaftNode) apply(ctx context.Context, command *raftCommand) error {
+	err := n.applyLocal(ctx, command)
+	if !errors.Is(err, errNotLeader) {
+		return err
+	}
+	client := n.leaderClient()
+	if client == nil {
+		return err
+	}
+	return raftRemoteApply(ctx, client, command)
+}
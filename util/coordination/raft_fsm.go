@@ -0,0 +1,163 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	raftOpSet      = "set"
+	raftOpExpireAt = "expireAt"
+	raftOpDel      = "del"
+)
+
+type raftOp struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	// Value is bytes so JSON preserves values that aren't valid UTF-8.
+	Value []byte `json:"value,omitempty"`
+	// TTL is in milliseconds from the time the leader appended the entry, ExpireAt in unix milliseconds.
+	TTL      int64 `json:"ttl,omitempty"`
+	ExpireAt int64 `json:"expireAt,omitempty"`
+}
+
+// raftCondition requires a key still be at the revision it was read at.
+type raftCondition struct {
+	Key      string `json:"key"`
+	Revision uint64 `json:"revision"`
+}
+
+type raftCommand struct {
+	Conditions []raftCondition `json:"conditions,omitempty"`
+	Ops        []raftOp        `json:"ops"`
+}
+
+type raftEntry struct {
+	Term  uint64 `json:"term"`
+	Index uint64 `json:"index"`
+	// Time is when the leader appended the entry in unix milliseconds, so every member expires keys alike.
+	Time    int64        `json:"time"`
+	Command *raftCommand `json:"command,omitempty"`
+}
+
+type raftItem struct {
+	Value    []byte `json:"value"`
+	ExpireAt int64  `json:"expireAt,omitempty"`
+	// Revision is the index of the entry that last wrote the item.
+	Revision uint64 `json:"revision"`
+}
+
+type raftReadResult struct {
+	Value    []byte `json:"value"`
+	Exists   bool   `json:"exists"`
+	Revision uint64 `json:"revision"`
+}
+
+type raftSnapshot struct {
+	Index uint64               `json:"index"`
+	Term  uint64               `json:"term"`
+	Items map[string]*raftItem `json:"items"`
+}
+
+// raftFSM is the key-value state machine the Raft log is applied to.
+type raftFSM struct {
+	items map[string]*raftItem
+}
+
+func newRaftFSM() *raftFSM {
+	return &raftFSM{items: make(map[string]*raftItem)}
+}
+
+func (f *raftFSM) get(key string, now int64) *raftItem {
+	item, ok := f.items[key]
+	if !ok {
+		return nil
+	}
+	if item.ExpireAt != 0 && item.ExpireAt <= now {
+		return nil
+	}
+	return item
+}
+
+// purge drops the items expired by now. Readers already don't see them.
+func (f *raftFSM) purge(now int64) {
+	for key, item := range f.items {
+		if item.ExpireAt != 0 && item.ExpireAt <= now {
+			delete(f.items, key)
+		}
+	}
+}
+
+func (f *raftFSM) read(key string, now int64) raftReadResult {
+	item := f.get(key, now)
+	if item == nil {
+		return raftReadResult{}
+	}
+	return raftReadResult{Value: item.Value, Exists: true, Revision: item.Revision}
+}
+
+func (f *raftFSM) keys(prefix string, now int64) []string {
+	var keys []string
+	for key := range f.items {
+		if strings.HasPrefix(key, prefix) && f.get(key, now) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apply returns ErrTxFailed without changing anything if one of the entry's conditions doesn't hold.
+func (f *raftFSM) apply(entry *raftEntry) error {
+	command := entry.Command
+	if command == nil {
+		return nil
+	}
+	for _, condition := range command.Conditions {
+		var revision uint64
+		if item := f.get(condition.Key, entry.Time); item != nil {
+			revision = item.Revision
+		}
+		if revision != condition.Revision {
+			return ErrTxFailed
+		}
+	}
+	for _, op := range command.Ops {
+		switch op.Kind {
+		case raftOpSet:
+			item := &raftItem{Value: op.Value, Revision: entry.Index}
+			if op.TTL > 0 {
+				item.ExpireAt = entry.Time + op.TTL
+			}
+			f.items[op.Key] = item
+		case raftOpExpireAt:
+			if item := f.get(op.Key, entry.Time); item != nil {
+				item.ExpireAt = op.ExpireAt
+				item.Revision = entry.Index
+			}
+		case raftOpDel:
+			delete(f.items, op.Key)
+		}
+	}
+	return nil
+}
+
+func (f *raftFSM) snapshot() map[string]*raftItem {
+	items := make(map[string]*raftItem, len(f.items))
+	for key, item := range f.items {
+		copied := *item
+		items[key] = &copied
+	}
+	return items
+}
+
+func (f *raftFSM) restore(items map[string]*raftItem) {
+	f.items = make(map[string]*raftItem, len(items))
+	for key, item := range items {
+		copied := *item
+		f.items[key] = &copied
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var (
+	raftHardStateKey   = []byte("raft-hard-state")
+	raftSnapshotKey    = []byte("raft-snapshot")
+	raftEntryKeyPrefix = []byte("raft-entry-")
+)
+
+type raftHardState struct {
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"votedFor"`
+}
+
+// raftStorage persists what a member mustn't forget across restarts: its vote, its log and the snapshot compacting it.
+type raftStorage struct {
+	db ethdb.Database
+}
+
+func openRaftStorage(dataDir string) (*raftStorage, error) {
+	if dataDir == "" {
+		return &raftStorage{db: rawdb.NewMemoryDatabase()}, nil
+	}
+	db, err := rawdb.NewLevelDBDatabase(dataDir, 16, 16, "coordination/raft/", false)
+	if err != nil {
+		return nil, err
+	}
+	return &raftStorage{db: db}, nil
+}
+
+func raftEntryKey(index uint64) []byte {
+	key := make([]byte, len(raftEntryKeyPrefix)+8)
+	copy(key, raftEntryKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(raftEntryKeyPrefix):], index)
+	return key
+}
+
+func (s *raftStorage) loadHardState() (uint64, string, error) {
+	has, err := s.db.Has(raftHardStateKey)
+	if err != nil || !has {
+		return 0, "", err
+	}
+	data, err := s.db.Get(raftHardStateKey)
+	if err != nil {
+		return 0, "", err
+	}
+	var state raftHardState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, "", err
+	}
+	return state.Term, state.VotedFor, nil
+}
+
+func (s *raftStorage) storeHardState(term uint64, votedFor string) error {
+	data, err := json.Marshal(&raftHardState{Term: term, VotedFor: votedFor})
+	if err != nil {
+		return err
+	}
+	return s.db.Put(raftHardStateKey, data)
+}
+
+func (s *raftStorage) loadSnapshot() (*raftSnapshot, error) {
+	has, err := s.db.Has(raftSnapshotKey)
+	if err != nil || !has {
+		return nil, err
+	}
+	data, err := s.db.Get(raftSnapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot raftSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// storeSnapshot stores snapshot, and deletes the entries from deleteFrom to deleteTo included.
+func (s *raftStorage) storeSnapshot(snapshot *raftSnapshot, deleteFrom, deleteTo uint64) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	batch := s.db.NewBatch()
+	if err := batch.Put(raftSnapshotKey, data); err != nil {
+		return err
+	}
+	for index := deleteFrom; index <= deleteTo; index++ {
+		if err := batch.Delete(raftEntryKey(index)); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// loadEntries loads the consecutive entries starting at index first.
+func (s *raftStorage) loadEntries(first uint64) ([]*raftEntry, error) {
+	var entries []*raftEntry
+	for index := first; ; index++ {
+		data, err := s.db.Get(raftEntryKey(index))
+		if err != nil {
+			if has, hasErr := s.db.Has(raftEntryKey(index)); hasErr == nil && !has {
+				return entries, nil
+			}
+			return nil, err
+		}
+		var entry raftEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+}
+
+// storeEntries writes entries, which must be consecutive, replacing any stored entry from the first of them to oldLastIndex.
+func (s *raftStorage) storeEntries(entries []*raftEntry, oldLastIndex uint64) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	batch := s.db.NewBatch()
+	newLastIndex := entries[len(entries)-1].Index
+	for index := newLastIndex + 1; index <= oldLastIndex; index++ {
+		if err := batch.Delete(raftEntryKey(index)); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(raftEntryKey(entry.Index), data); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+func (s *raftStorage) close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,311 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type raftVoteArgs struct {
+	Term         uint64 `json:"term"`
+	Candidate    string `json:"candidate"`
+	LastLogIndex uint64 `json:"lastLogIndex"`
+	LastLogTerm  uint64 `json:"lastLogTerm"`
+}
+
+type raftVoteReply struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+type raftAppendArgs struct {
+	Term         uint64       `json:"term"`
+	Leader       string       `json:"leader"`
+	PrevLogIndex uint64       `json:"prevLogIndex"`
+	PrevLogTerm  uint64       `json:"prevLogTerm"`
+	Entries      []*raftEntry `json:"entries"`
+	LeaderCommit uint64       `json:"leaderCommit"`
+}
+
+type raftAppendReply struct {
+	Term    uint64 `json:"term"`
+	Success bool   `json:"success"`
+	// ConflictIndex is the index the leader should resume replicating from on failure.
+	ConflictIndex uint64 `json:"conflictIndex,omitempty"`
+}
+
+type raftSnapshotArgs struct {
+	Term     uint64       `json:"term"`
+	Leader   string       `json:"leader"`
+	Snapshot raftSnapshot `json:"snapshot"`
+}
+
+type raftSnapshotReply struct {
+	Term uint64 `json:"term"`
+}
+
+// raftAPI is served to the other members of the group, and to the clients following it.
+type raftAPI struct {
+	node *RaftNode
+}
+
+func (a *raftAPI) RequestVote(args *raftVoteArgs) *raftVoteReply {
+	return a.node.handleRequestVote(args)
+}
+
+func (a *raftAPI) AppendEntries(args *raftAppendArgs) *raftAppendReply {
+	return a.node.handleAppendEntries(args)
+}
+
+func (a *raftAPI) InstallSnapshot(args *raftSnapshotArgs) *raftSnapshotReply {
+	return a.node.handleInstallSnapshot(args)
+}
+
+// Leader returns the url of the current leader, as far as this member knows.
+func (a *raftAPI) Leader() string {
+	return a.node.Leader()
+}
+
+// Read, Keys and Apply are only served by the leader, other members don't forward them again.
+func (a *raftAPI) Read(keys []string) ([]raftReadResult, error) {
+	return a.node.readLocal(keys)
+}
+
+func (a *raftAPI) Keys(prefix string) ([]string, error) {
+	return a.node.keysLocal(prefix)
+}
+
+func (a *raftAPI) Apply(ctx context.Context, command *raftCommand) error {
+	return a.node.applyLocal(ctx, command)
+}
+
+// remoteErr restores the errors callers check for, which lose their identity over RPC.
+func remoteErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, known := range []error{ErrTxFailed, errNotLeader, errLeadershipLost} {
+		if err.Error() == known.Error() {
+			return known
+		}
+	}
+	return err
+}
+
+func raftRemoteRead(ctx context.Context, client *rpc.Client, keys []string) ([]raftReadResult, error) {
+	var results []raftReadResult
+	err := client.CallContext(ctx, &results, "coordination_read", keys)
+	if err == nil && len(results) != len(keys) {
+		err = fmt.Errorf("raft member returned %d results reading %d keys", len(results), len(keys))
+	}
+	return results, remoteErr(err)
+}
+
+func raftRemoteKeys(ctx context.Context, client *rpc.Client, prefix string) ([]string, error) {
+	var keys []string
+	err := client.CallContext(ctx, &keys, "coordination_keys", prefix)
+	return keys, remoteErr(err)
+}
+
+func raftRemoteApply(ctx context.Context, client *rpc.Client, command *raftCommand) error {
+	return remoteErr(client.CallContext(ctx, nil, "coordination_apply", command))
+}
+
+type raftBackend interface {
+	read(ctx context.Context, keys []string) ([]raftReadResult, error)
+	keys(ctx context.Context, prefix string) ([]string, error)
+	apply(ctx context.Context, command *raftCommand) error
+}
+
+// raftStore implements the Store operations over a Raft group, either as a member or as a client.
+type raftStore struct {
+	backend raftBackend
+}
+
+func (s *raftStore) Get(ctx context.Context, key string) (string, error) {
+	results, err := s.backend.read(ctx, []string{key})
+	if err != nil {
+		return "", err
+	}
+	if !results[0].Exists {
+		return "", ErrNotFound
+	}
+	return string(results[0].Value), nil
+}
+
+func (s *raftStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	return s.backend.keys(ctx, prefix)
+}
+
+func ttlMillis(expiration time.Duration) int64 {
+	if expiration <= 0 {
+		return 0
+	}
+	ttl := expiration.Milliseconds()
+	if ttl == 0 {
+		ttl = 1
+	}
+	return ttl
+}
+
+func (s *raftStore) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	return s.backend.apply(ctx, &raftCommand{Ops: []raftOp{{Kind: raftOpSet, Key: key, Value: []byte(value), TTL: ttlMillis(expiration)}}})
+}
+
+func (s *raftStore) Del(ctx context.Context, keys ...string) error {
+	command := &raftCommand{}
+	for _, key := range keys {
+		command.Ops = append(command.Ops, raftOp{Kind: raftOpDel, Key: key})
+	}
+	return s.backend.apply(ctx, command)
+}
+
+type raftTx struct {
+	store   *raftStore
+	watched map[string]raftReadResult
+	ops     []raftOp
+}
+
+func (t *raftTx) Get(ctx context.Context, key string) (string, error) {
+	result, ok := t.watched[key]
+	if !ok {
+		results, err := t.store.backend.read(ctx, []string{key})
+		if err != nil {
+			return "", err
+		}
+		result = results[0]
+	}
+	if !result.Exists {
+		return "", ErrNotFound
+	}
+	return string(result.Value), nil
+}
+
+func (t *raftTx) Set(key string, value string, expiration time.Duration) {
+	t.ops = append(t.ops, raftOp{Kind: raftOpSet, Key: key, Value: []byte(value), TTL: ttlMillis(expiration)})
+}
+
+func (t *raftTx) ExpireAt(key string, at time.Time) {
+	t.ops = append(t.ops, raftOp{Kind: raftOpExpireAt, Key: key, ExpireAt: at.UnixMilli()})
+}
+
+func (t *raftTx) Del(key string) {
+	t.ops = append(t.ops, raftOp{Kind: raftOpDel, Key: key})
+}
+
+func (s *raftStore) Watch(ctx context.Context, fn func(tx Tx) error, keys ...string) error {
+	tx := &raftTx{store: s, watched: make(map[string]raftReadResult, len(keys))}
+	var conditions []raftCondition
+	if len(keys) > 0 {
+		results, err := s.backend.read(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			tx.watched[key] = results[i]
+			conditions = append(conditions, raftCondition{Key: key, Revision: results[i].Revision})
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	return s.backend.apply(ctx, &raftCommand{Conditions: conditions, Ops: tx.ops})
+}
+
+// RaftClient follows a Raft coordination group without being a member of it,
+// e.g. for a node forwarding transactions to the chosen sequencer.
+type RaftClient struct {
+	raftStore
+
+	mutex   sync.Mutex
+	clients []*rpc.Client
+	leader  int
+}
+
+// NewRaftClient connects to the members at urls, authenticating with the jwt secret read from jwtSecretPath.
+func NewRaftClient(urls []string, jwtSecretPath string) (*RaftClient, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no raft group member urls")
+	}
+	jwtSecret, err := loadRaftJWTSecret(jwtSecretPath)
+	if err != nil {
+		return nil, err
+	}
+	c := &RaftClient{}
+	c.raftStore.backend = c
+	for _, url := range urls {
+		client, err := dialRaftMember(url, jwtSecret)
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		c.clients = append(c.clients, client)
+	}
+	return c, nil
+}
+
+// call tries fn with the last known leader first, then with the other members until one of them leads.
+// Unless retryAny is set, it only moves on to the next member if the previous one certainly didn't act on the request.
+func (c *RaftClient) call(ctx context.Context, retryAny bool, fn func(client *rpc.Client) error) error {
+	c.mutex.Lock()
+	start := c.leader
+	c.mutex.Unlock()
+	var err error
+	for i := range c.clients {
+		pos := (start + i) % len(c.clients)
+		err = fn(c.clients[pos])
+		if err == nil || errors.Is(err, ErrTxFailed) {
+			c.mutex.Lock()
+			c.leader = pos
+			c.mutex.Unlock()
+			return err
+		}
+		if ctx.Err() != nil || (!retryAny && !errors.Is(err, errNotLeader)) {
+			return err
+		}
+	}
+	return fmt.Errorf("no raft group member could serve the request: %w", err)
+}
+
+func (c *RaftClient) read(ctx context.Context, keys []string) ([]raftReadResult, error) {
+	var results []raftReadResult
+	err := c.call(ctx, true, func(client *rpc.Client) error {
+		var err error
+		results, err = raftRemoteRead(ctx, client, keys)
+		return err
+	})
+	return results, err
+}
+
+func (c *RaftClient) keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := c.call(ctx, true, func(client *rpc.Client) error {
+		var err error
+		keys, err = raftRemoteKeys(ctx, client, prefix)
+		return err
+	})
+	return keys, err
+}
+
+func (c *RaftClient) apply(ctx context.Context, command *raftCommand) error {
+	return c.call(ctx, false, func(client *rpc.Client) error {
+		return raftRemoteApply(ctx, client, command)
+	})
+}
+
+func (c *RaftClient) Close() error {
+	for _, client := range c.clients {
+		client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yingdianRao/nitro/util/testhelpers"
+)
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
+
+func requireValue(t *testing.T, ctx context.Context, store Store, key string, expected string) {
+	t.Helper()
+	value, err := store.Get(ctx, key)
+	Require(t, err, "reading", key)
+	if value != expected {
+		Fail(t, "unexpected value for", key, "expected", expected, "got", value)
+	}
+}
+
+func followerOf(nodes []*RaftNode) *RaftNode {
+	for _, node := range nodes {
+		if !node.IsLeader() {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestRaftReplicatesWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := CreateTestRaftGroup(t, 3)
+	follower := followerOf(nodes)
+
+	// written through a follower, which forwards to the leader
+	binaryValue := string([]byte{0xff, 0x00, 0x80})
+	Require(t, follower.Set(ctx, "a", "1", 0))
+	Require(t, follower.Set(ctx, "prefix.b", binaryValue, 0))
+	Require(t, follower.Set(ctx, "prefix.c", "3", 0))
+	for _, node := range nodes {
+		requireValue(t, ctx, node, "a", "1")
+		requireValue(t, ctx, node, "prefix.b", binaryValue)
+	}
+	keys, err := follower.Keys(ctx, "prefix.")
+	Require(t, err)
+	if strings.Join(keys, ",") != "prefix.b,prefix.c" {
+		Fail(t, "unexpected keys", keys)
+	}
+
+	Require(t, follower.Del(ctx, "prefix.c"))
+	if _, err := nodes[0].Get(ctx, "prefix.c"); !errors.Is(err, ErrNotFound) {
+		Fail(t, "expected deleted key not to be found, got", err)
+	}
+
+	Require(t, follower.Set(ctx, "expiring", "x", 50*time.Millisecond))
+	requireValue(t, ctx, follower, "expiring", "x")
+	time.Sleep(100 * time.Millisecond)
+	if _, err := follower.Get(ctx, "expiring"); !errors.Is(err, ErrNotFound) {
+		Fail(t, "expected expired key not to be found, got", err)
+	}
+}
+
+func TestRaftWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := CreateTestRaftGroup(t, 3)
+
+	err := nodes[0].Watch(ctx, func(tx Tx) error {
+		if _, err := tx.Get(ctx, "lock"); !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("expected lock to be free, got %w", err)
+		}
+		tx.Set("lock", "first", 0)
+		tx.ExpireAt("lock", time.Now().Add(time.Minute))
+		return nil
+	}, "lock")
+	Require(t, err)
+	requireValue(t, ctx, nodes[1], "lock", "first")
+
+	err = nodes[1].Watch(ctx, func(tx Tx) error {
+		// another member changes the watched key before the writes are applied
+		if err := nodes[2].Set(ctx, "lock", "third", 0); err != nil {
+			return err
+		}
+		tx.Set("lock", "second", 0)
+		return nil
+	}, "lock")
+	if !errors.Is(err, ErrTxFailed) {
+		Fail(t, "expected the watch to fail, got", err)
+	}
+	requireValue(t, ctx, nodes[0], "lock", "third")
+}
+
+func TestRaftLeaderFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := CreateTestRaftGroup(t, 3)
+	leader := WaitForTestRaftLeader(t, nodes)
+	Require(t, leader.Set(ctx, "key", "before", 0))
+
+	Require(t, leader.Close())
+	var remaining []*RaftNode
+	for _, node := range nodes {
+		if node != leader {
+			remaining = append(remaining, node)
+		}
+	}
+	newLeader := WaitForTestRaftLeader(t, remaining)
+	if newLeader.Leader() != newLeader.config.Url {
+		Fail(t, "new leader doesn't know it leads")
+	}
+	for _, node := range remaining {
+		requireValue(t, ctx, node, "key", "before")
+	}
+	Require(t, followerOf(remaining).Set(ctx, "key", "after", 0))
+	requireValue(t, ctx, newLeader, "key", "after")
+}
+
+func TestRaftSnapshotCatchUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := CreateTestRaftGroup(t, 3)
+	lagging := followerOf(nodes)
+	config := *lagging.config
+	addr := lagging.listener.Addr().String()
+	Require(t, lagging.Close())
+
+	var live []*RaftNode
+	for _, node := range nodes {
+		if node != lagging {
+			live = append(live, node)
+		}
+	}
+	leader := WaitForTestRaftLeader(t, live)
+	entries := int(config.SnapshotThreshold) * 2
+	for i := 0; i < entries; i++ {
+		Require(t, leader.Set(ctx, fmt.Sprint("key.", i), fmt.Sprint(i), 0))
+	}
+	if leader.snapshotIndex == 0 {
+		Fail(t, "leader didn't compact its log")
+	}
+
+	// the member comes back without its log, and can only catch up through a snapshot
+	listener, err := net.Listen("tcp", addr)
+	Require(t, err)
+	restarted, err := NewRaftNodeOnListener(&config, listener)
+	Require(t, err)
+	defer restarted.Close()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		restarted.mutex.Lock()
+		caughtUp := restarted.lastApplied >= uint64(entries)
+		restarted.mutex.Unlock()
+		if caughtUp {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			Fail(t, "restarted member didn't catch up")
+		}
+	}
+	requireValue(t, ctx, restarted, fmt.Sprint("key.", entries-1), fmt.Sprint(entries-1))
+}
+
+func TestRaftPersistence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config := TestRaftConfig
+	config.Url = "http://single-member"
+	config.Addr = "127.0.0.1:0"
+	config.DataDir = t.TempDir()
+
+	node, err := NewRaftNode(&config)
+	Require(t, err)
+	WaitForTestRaftLeader(t, []*RaftNode{node})
+	Require(t, node.Set(ctx, "key", "persisted", 0))
+	Require(t, node.Close())
+
+	node, err = NewRaftNode(&config)
+	Require(t, err)
+	defer node.Close()
+	WaitForTestRaftLeader(t, []*RaftNode{node})
+	requireValue(t, ctx, node, "key", "persisted")
+}
+
+func TestRaftClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := CreateTestRaftGroup(t, 3)
+	var urls []string
+	for _, node := range nodes {
+		urls = append(urls, node.config.Url)
+	}
+	client, err := NewRaftClient(urls, TestRaftJWTSecret)
+	Require(t, err)
+	defer client.Close()
+
+	coordinator := NewCoordinator(client)
+	Require(t, coordinator.UpdatePriorities(ctx, []string{"seq1", "seq2"}))
+	priorities, err := NewCoordinator(nodes[0]).GetPriorities(ctx)
+	Require(t, err)
+	if strings.Join(priorities, ",") != "seq1,seq2" {
+		Fail(t, "unexpected priorities", priorities)
+	}
+	chosen, err := coordinator.CurrentChosenSequencer(ctx)
+	Require(t, err)
+	if chosen != "" {
+		Fail(t, "unexpected chosen sequencer", chosen)
+	}
+}
+
+func TestRaftAuthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := CreateTestRaftGroup(t, 1)
+
+	// a client with another secret can neither read nor write the coordination state
+	client, err := NewRaftClient([]string{nodes[0].config.Url}, "0x"+strings.Repeat("ab", 32))
+	Require(t, err)
+	defer client.Close()
+	if _, err := client.Keys(ctx, ""); err == nil {
+		Fail(t, "read with the wrong jwt secret")
+	}
+	if err := client.Set(ctx, "key", "value", 0); err == nil {
+		Fail(t, "wrote with the wrong jwt secret")
+	}
+	if _, err := nodes[0].Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		Fail(t, "unexpected write", err)
+	}
+}
+
+func TestRaftConfigRequiresPersistenceAndAuth(t *testing.T) {
+	config := DefaultRaftConfig
+	config.Url = "http://member"
+	config.JWTSecret = TestRaftJWTSecret
+	if err := config.Validate(); err == nil {
+		Fail(t, "accepted a member without a data directory")
+	}
+	config.DataDir = t.TempDir()
+	Require(t, config.Validate())
+	config.JWTSecret = ""
+	if err := config.Validate(); err == nil {
+		Fail(t, "accepted a member without a jwt secret")
+	}
+}
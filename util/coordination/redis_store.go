@@ -0,0 +1,128 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/yingdianRao/nitro/util/redisutil"
+)
+
+// RedisStore coordinates through a single Redis instance.
+type RedisStore struct {
+	Client redis.UniversalClient
+}
+
+func NewRedisStore(redisUrl string) (*RedisStore, error) {
+	client, err := redisutil.RedisClientFromURL(redisUrl)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errors.New("redis url is empty")
+	}
+	return &RedisStore{Client: client}, nil
+}
+
+func redisErr(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return ErrNotFound
+	}
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrTxFailed
+	}
+	return err
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.Client.Get(ctx, key).Result()
+	return value, redisErr(err)
+}
+
+func (s *RedisStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		var keySlice []string
+		var err error
+		keySlice, cursor, err = s.Client.Scan(ctx, cursor, prefix+"*", 0).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keySlice...)
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	return s.Client.Set(ctx, key, value, expiration).Err()
+}
+
+func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
+	return s.Client.Del(ctx, keys...).Err()
+}
+
+type redisTx struct {
+	tx   *redis.Tx
+	pipe redis.Pipeliner
+	ctx  context.Context
+}
+
+func (t *redisTx) Get(ctx context.Context, key string) (string, error) {
+	value, err := t.tx.Get(ctx, key).Result()
+	return value, redisErr(err)
+}
+
+func (t *redisTx) pipeline() redis.Pipeliner {
+	if t.pipe == nil {
+		t.pipe = t.tx.TxPipeline()
+	}
+	return t.pipe
+}
+
+func (t *redisTx) Set(key string, value string, expiration time.Duration) {
+	t.pipeline().Set(t.ctx, key, value, expiration)
+}
+
+func (t *redisTx) ExpireAt(key string, at time.Time) {
+	t.pipeline().PExpireAt(t.ctx, key, at)
+}
+
+func (t *redisTx) Del(key string) {
+	t.pipeline().Del(t.ctx, key)
+}
+
+func (s *RedisStore) Watch(ctx context.Context, fn func(tx Tx) error, keys ...string) error {
+	err := s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		wrapped := &redisTx{tx: tx, ctx: ctx}
+		if err := fn(wrapped); err != nil {
+			return err
+		}
+		if wrapped.pipe == nil {
+			return nil
+		}
+		cmders, err := wrapped.pipe.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cmder := range cmders {
+			if err := cmder.Err(); err != nil {
+				return fmt.Errorf("%s failed: %w", cmder.Name(), err)
+			}
+		}
+		return nil
+	}, keys...)
+	return redisErr(err)
+}
+
+func (s *RedisStore) Close() error {
+	return s.Client.Close()
+}
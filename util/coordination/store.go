@@ -0,0 +1,91 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package coordination holds the key-value store sequencer replicas coordinate through.
+// It is either backed by a Redis instance or by an embedded Raft group among the replicas.
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when reading a key that isn't set, or has expired.
+	ErrNotFound = errors.New("coordination key not found")
+	// ErrTxFailed is returned by Store.Watch when a watched key changed before the writes were applied.
+	ErrTxFailed = errors.New("coordination transaction failed: watched key changed")
+)
+
+const (
+	BackendRedis = "redis"
+	BackendRaft  = "raft"
+)
+
+// Tx is handed to the function passed to Store.Watch.
+// Reads are served immediately, while writes are queued and applied atomically once the function returns.
+type Tx interface {
+	Get(ctx context.Context, key string) (string, error)
+	// Set queues writing key, expiring after expiration unless it's zero.
+	Set(key string, value string, expiration time.Duration)
+	// ExpireAt queues setting the expiration of key, if it's set by then.
+	ExpireAt(key string, at time.Time)
+	Del(key string)
+}
+
+type Store interface {
+	Get(ctx context.Context, key string) (string, error)
+	// Keys returns all the keys starting with prefix.
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	// Set writes key, expiring after expiration unless it's zero.
+	Set(ctx context.Context, key string, value string, expiration time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	// Watch calls fn and then atomically applies the writes it queued.
+	// If any of keys changed since Watch was called, nothing is written and ErrTxFailed is returned.
+	Watch(ctx context.Context, fn func(tx Tx) error, keys ...string) error
+	Close() error
+}
+
+// NewStore creates the store a sequencer coordinates through, using backend.
+// It returns a nil store when the redis backend is selected but redisUrl is empty.
+func NewStore(backend string, raftConfig *RaftConfig, redisUrl string) (Store, error) {
+	switch backend {
+	case BackendRedis:
+		if redisUrl == "" {
+			return nil, nil
+		}
+		store, err := NewRedisStore(redisUrl)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case BackendRaft:
+		node, err := NewRaftNode(raftConfig)
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	default:
+		return nil, fmt.Errorf("unknown coordination backend %q", backend)
+	}
+}
+
+// NewClientStore connects to the coordination without taking part in it, as the command line tools do.
+// url is either a Redis URL, or the comma separated URLs of the Raft group members, which are
+// authenticated with the jwt secret read from raftJWTSecretPath.
+func NewClientStore(url string, raftJWTSecretPath string) (Store, error) {
+	if url == "" {
+		return nil, errors.New("coordination url is empty")
+	}
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		client, err := NewRaftClient(strings.Split(url, ","), raftJWTSecretPath)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	return NewRedisStore(url)
+}
@@ -0,0 +1,59 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package coordination
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yingdianRao/nitro/util/testhelpers"
+)
+
+// CreateTestRaftGroup starts an in-process Raft group of size members listening on localhost,
+// and waits for it to elect a leader. The members are closed when the test ends.
+func CreateTestRaftGroup(t *testing.T, size int) []*RaftNode {
+	t.Helper()
+	listeners := make([]net.Listener, size)
+	urls := make([]string, size)
+	for i := range listeners {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		testhelpers.RequireImpl(t, err)
+		listeners[i] = listener
+		urls[i] = "http://" + listener.Addr().String()
+	}
+	nodes := make([]*RaftNode, size)
+	for i := range nodes {
+		config := TestRaftConfig
+		config.Url = urls[i]
+		config.Peers = nil
+		for j, url := range urls {
+			if j != i {
+				config.Peers = append(config.Peers, url)
+			}
+		}
+		node, err := NewRaftNodeOnListener(&config, listeners[i])
+		testhelpers.RequireImpl(t, err)
+		t.Cleanup(func() { _ = node.Close() })
+		nodes[i] = node
+	}
+	WaitForTestRaftLeader(t, nodes)
+	return nodes
+}
+
+// WaitForTestRaftLeader waits for one of nodes to lead the group and serve reads, and returns it.
+func WaitForTestRaftLeader(t *testing.T, nodes []*RaftNode) *RaftNode {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range nodes {
+			if _, err := node.readLocal(nil); err == nil {
+				return node
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testhelpers.FailImpl(t, "raft group elected no leader")
+	return nil
+}
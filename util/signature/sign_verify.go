@@ -54,7 +54,7 @@ func NewSignVerify(config *SignVerifyConfig, signerFunc DataSignerFunc, bpValida
 			return nil, err
 		}
 	}
-	verifier, err := NewVerifier(&config.ECDSA, bpValidator)
+	verifier, err := NewVerifier(&config.ECDSA, bpValidator, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +67,7 @@ func NewSignVerify(config *SignVerifyConfig, signerFunc DataSignerFunc, bpValida
 }
 
 func (v *SignVerify) VerifySignature(ctx context.Context, signature []byte, data ...[]byte) error {
-	ecdsaErr := v.verifier.verifyClosure(ctx, signature, crypto.Keccak256Hash(data...))
+	ecdsaErr := v.verifier.VerifyData(ctx, signature, data...)
 	if ecdsaErr == nil {
 		return nil
 	}
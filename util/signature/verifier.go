@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	flag "github.com/spf13/pflag"
 
@@ -17,9 +18,10 @@ import (
 )
 
 type Verifier struct {
-	config        *VerifierConfig
-	authorizedMap map[common.Address]struct{}
-	addrVerifier  contracts.AddressVerifierInterface
+	config         *VerifierConfig
+	authorizedMap  map[common.Address]struct{}
+	addrVerifier   contracts.AddressVerifierInterface
+	signerRegistry contracts.SignerRegistryInterface
 }
 
 type VerifierConfig struct {
@@ -62,7 +64,9 @@ var TestingFeedVerifierConfig = VerifierConfig{
 	},
 }
 
-func NewVerifier(config *VerifierConfig, addrVerifier contracts.AddressVerifierInterface) (*Verifier, error) {
+// NewVerifier creates a Verifier accepting the allowed addresses, the signers allowed by signerRegistry
+// if it's not nil, and the batch posters and sequencers of addrVerifier if the config accepts them.
+func NewVerifier(config *VerifierConfig, addrVerifier contracts.AddressVerifierInterface, signerRegistry contracts.SignerRegistryInterface) (*Verifier, error) {
 	authorizedMap := make(map[common.Address]struct{}, len(config.AllowedAddresses))
 	for _, addrString := range config.AllowedAddresses {
 		addr := common.HexToAddress(addrString)
@@ -72,21 +76,28 @@ func NewVerifier(config *VerifierConfig, addrVerifier contracts.AddressVerifierI
 		return nil, errors.New("cannot read batch poster addresses")
 	}
 	return &Verifier{
-		config:        config,
-		authorizedMap: authorizedMap,
-		addrVerifier:  addrVerifier,
+		config:         config,
+		authorizedMap:  authorizedMap,
+		addrVerifier:   addrVerifier,
+		signerRegistry: signerRegistry,
 	}, nil
 }
 
 func (v *Verifier) VerifyHash(ctx context.Context, signature []byte, hash common.Hash) error {
-	return v.verifyClosure(ctx, signature, hash)
+	return v.verifyClosure(ctx, signature, hash, time.Now())
+}
+
+// VerifyHashAt verifies the signature of a message timestamped at, which the signer registry
+// checks against the rotation windows of its signers.
+func (v *Verifier) VerifyHashAt(ctx context.Context, signature []byte, hash common.Hash, at time.Time) error {
+	return v.verifyClosure(ctx, signature, hash, at)
 }
 
 func (v *Verifier) VerifyData(ctx context.Context, signature []byte, data ...[]byte) error {
-	return v.verifyClosure(ctx, signature, crypto.Keccak256Hash(data...))
+	return v.verifyClosure(ctx, signature, crypto.Keccak256Hash(data...), time.Now())
 }
 
-func (v *Verifier) verifyClosure(ctx context.Context, sig []byte, hash common.Hash) error {
+func (v *Verifier) verifyClosure(ctx context.Context, sig []byte, hash common.Hash, at time.Time) error {
 	if len(sig) == 0 {
 		if v.config.Dangerous.AcceptMissing {
 			// Signature missing and not required
@@ -107,11 +118,25 @@ func (v *Verifier) verifyClosure(ctx context.Context, sig []byte, hash common.Ha
 		return nil
 	}
 
-	if v.config.Dangerous.AcceptMissing && v.addrVerifier == nil {
+	// the sequencer may still approve the signer if the registry can't be read
+	var registryErr error
+	if v.signerRegistry != nil {
+		allowed, err := v.signerRegistry.IsAllowedSigner(ctx, addr, at)
+		if err != nil {
+			registryErr = err
+		} else if allowed {
+			return nil
+		}
+	}
+
+	if v.config.Dangerous.AcceptMissing && v.addrVerifier == nil && v.signerRegistry == nil {
 		return nil
 	}
 
 	if !v.config.AcceptSequencer || v.addrVerifier == nil {
+		if registryErr != nil {
+			return registryErr
+		}
 		return ErrSignerNotApproved
 	}
 
@@ -121,6 +146,9 @@ func (v *Verifier) verifyClosure(ctx context.Context, sig []byte, hash common.Ha
 	}
 
 	if !batchPosterOrSequencer {
+		if registryErr != nil {
+			return registryErr
+		}
 		return ErrSignerNotApproved
 	}
 
@@ -7,7 +7,9 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 
 	"github.com/yingdianRao/nitro/util/contracts"
@@ -25,7 +27,7 @@ func TestVerifier(t *testing.T) {
 
 	config := TestingFeedVerifierConfig
 	config.AllowedAddresses = []string{signingAddr.Hex()}
-	verifier, err := NewVerifier(&config, nil)
+	verifier, err := NewVerifier(&config, nil, nil)
 	Require(t, err)
 
 	data := []byte{0, 1, 2, 3, 4, 5, 6, 7}
@@ -53,7 +55,7 @@ func TestMissingRequiredSignature(t *testing.T) {
 
 	config := TestingFeedVerifierConfig
 	config.Dangerous.AcceptMissing = false
-	verifier, err := NewVerifier(&config, nil)
+	verifier, err := NewVerifier(&config, nil, nil)
 	Require(t, err)
 	err = verifier.VerifyData(ctx, nil, nil)
 	if !errors.Is(err, ErrMissingSignature) {
@@ -67,7 +69,7 @@ func TestMissingSignatureAllowed(t *testing.T) {
 
 	config := TestingFeedVerifierConfig
 	config.Dangerous.AcceptMissing = true
-	verifier, err := NewVerifier(&config, nil)
+	verifier, err := NewVerifier(&config, nil, nil)
 	Require(t, err)
 	err = verifier.VerifyData(ctx, nil, nil)
 	Require(t, err, "error verifying data")
@@ -85,7 +87,7 @@ func TestVerifierBatchPoster(t *testing.T) {
 	bpVerifier := contracts.NewMockAddressVerifier(signingAddr)
 	config := TestingFeedVerifierConfig
 	config.AcceptSequencer = true
-	verifier, err := NewVerifier(&config, bpVerifier)
+	verifier, err := NewVerifier(&config, bpVerifier, nil)
 	Require(t, err)
 
 	data := []byte{0, 1, 2, 3, 4, 5, 6, 7}
@@ -112,6 +114,69 @@ func TestVerifierBatchPoster(t *testing.T) {
 	}
 }
 
+func TestVerifierSignerRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signingAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
+	dataSigner := DataSignerFromPrivateKey(privateKey)
+
+	// the registry is enough to verify signatures, even accepting missing ones otherwise
+	config := TestingFeedVerifierConfig
+	config.Dangerous.AcceptMissing = true
+	verifier, err := NewVerifier(&config, nil, contracts.NewMockSignerRegistry(signingAddr))
+	Require(t, err)
+
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	signature, err := dataSigner(crypto.Keccak256(data))
+	Require(t, err, "error signing data")
+	err = verifier.VerifyData(ctx, signature, data)
+	Require(t, err, "error verifying data")
+
+	badKey, err := crypto.GenerateKey()
+	Require(t, err)
+	badSignature, err := DataSignerFromPrivateKey(badKey)(crypto.Keccak256(data))
+	Require(t, err, "error signing data")
+	err = verifier.VerifyData(ctx, badSignature, data)
+	if !errors.Is(err, ErrSignerNotApproved) {
+		t.Error("unexpected error", err)
+	}
+}
+
+type unavailableSignerRegistry struct{}
+
+func (unavailableSignerRegistry) IsAllowedSigner(context.Context, common.Address, time.Time) (bool, error) {
+	return false, contracts.ErrSignerRegistryUnavailable
+}
+
+func TestVerifierSignerRegistryUnavailable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	dataSigner := DataSignerFromPrivateKey(privateKey)
+	data := []byte{0, 1, 2, 3}
+	signature, err := dataSigner(crypto.Keccak256(data))
+	Require(t, err, "error signing data")
+
+	// the registry being unavailable is told apart from the signer not being approved
+	config := TestingFeedVerifierConfig
+	verifier, err := NewVerifier(&config, nil, unavailableSignerRegistry{})
+	Require(t, err)
+	if err := verifier.VerifyData(ctx, signature, data); !errors.Is(err, contracts.ErrSignerRegistryUnavailable) {
+		t.Error("unexpected error", err)
+	}
+
+	// while the allowed addresses are still accepted
+	config.AllowedAddresses = []string{crypto.PubkeyToAddress(privateKey.PublicKey).Hex()}
+	verifier, err = NewVerifier(&config, nil, unavailableSignerRegistry{})
+	Require(t, err)
+	Require(t, verifier.VerifyData(ctx, signature, data))
+}
+
 func Require(t *testing.T, err error, printables ...interface{}) {
 	t.Helper()
 	testhelpers.RequireImpl(t, err, printables...)
@@ -0,0 +1,418 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/util/signature"
+)
+
+var (
+	clientsUnauthorizedCounter = metrics.NewRegisteredCounter("arb/feed/clients/unauthorized", nil)
+	authReloadCounter          = metrics.NewRegisteredCounter("arb/feed/auth/reload/success", nil)
+	authReloadFailuresCounter  = metrics.NewRegisteredCounter("arb/feed/auth/reload/failures", nil)
+)
+
+var errUnauthorized = errors.New("invalid feed credentials")
+
+type AuthConfig struct {
+	Enable    bool   `koanf:"enable" reload:"hot"`
+	Require   bool   `koanf:"require" reload:"hot"`
+	KeysFile  string `koanf:"keys-file" reload:"hot"`
+	JWTSecret string `koanf:"jwt-secret" reload:"hot"`
+}
+
+var DefaultAuthConfig = AuthConfig{
+	Enable:    false,
+	Require:   false,
+	KeysFile:  "",
+	JWTSecret: "",
+}
+
+func AuthConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultAuthConfig.Enable, "let clients authenticate with an API key or JWT bearer token, to get the limits of their key's tier")
+	f.Bool(prefix+".require", DefaultAuthConfig.Require, "reject clients that don't authenticate, rather than holding them to the default limits")
+	f.String(prefix+".keys-file", DefaultAuthConfig.KeysFile, "path of the JSON file with the tiers and API keys, reloaded when it changes")
+	f.String(prefix+".jwt-secret", DefaultAuthConfig.JWTSecret, "hex encoded HS256 secret, or path to a file with it, to accept JWTs naming a key and its tier (if empty, only API keys are accepted)")
+}
+
+func (c *AuthConfig) Validate() error {
+	if c.Enable && c.KeysFile == "" {
+		return errors.New("feed authentication enabled without a keys file")
+	}
+	if c.Require && !c.Enable {
+		return errors.New("feed authentication required but not enabled")
+	}
+	return nil
+}
+
+type AuthConfigFetcher func() *AuthConfig
+
+// AuthTierJSON is a tier as found in the keys file, with the limits of each of its keys.
+type AuthTierJSON struct {
+	Name string `json:"name"`
+	// MaxConnections is the number of connections each key may have open, 0 means unlimited.
+	MaxConnections int `json:"maxConnections,omitempty"`
+	// MaxCatchup is the number of messages sent from the backlog on connect, -1 (the default) means unlimited.
+	MaxCatchup *int `json:"maxCatchup,omitempty"`
+	// ClientDelay delays the first messages sent to each connection, as a duration such as "500ms".
+	ClientDelay string `json:"clientDelay,omitempty"`
+	// Bandwidth is the number of bytes per second sent to all the connections of each key, 0 means unlimited.
+	Bandwidth int `json:"bandwidth,omitempty"`
+}
+
+// AuthKeyJSON is an API key as found in the keys file.
+// JWTs name the key and tier they are issued for in their "sub" and "tier" claims instead.
+type AuthKeyJSON struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Tier string `json:"tier"`
+}
+
+type AuthKeysJSON struct {
+	Tiers []AuthTierJSON `json:"tiers"`
+	Keys  []AuthKeyJSON  `json:"keys"`
+}
+
+type authTier struct {
+	name           string
+	maxConnections int
+	maxCatchup     int
+	clientDelay    time.Duration
+	bandwidth      int
+}
+
+type authKey struct {
+	name string
+	key  []byte
+	tier *authTier
+}
+
+type authKeys struct {
+	tiers     map[string]*authTier
+	keys      []authKey
+	jwtSecret []byte
+}
+
+func parseAuthKeys(data []byte) (*authKeys, error) {
+	var parsed AuthKeysJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse feed keys: %w", err)
+	}
+	keys := &authKeys{tiers: make(map[string]*authTier, len(parsed.Tiers))}
+	for i, t := range parsed.Tiers {
+		if t.Name == "" {
+			return nil, fmt.Errorf("feed tier %v has no name", i)
+		}
+		if _, ok := keys.tiers[t.Name]; ok {
+			return nil, fmt.Errorf("duplicate feed tier name \"%v\"", t.Name)
+		}
+		tier := &authTier{
+			name:           t.Name,
+			maxConnections: t.MaxConnections,
+			maxCatchup:     -1,
+			bandwidth:      t.Bandwidth,
+		}
+		if t.MaxCatchup != nil {
+			tier.maxCatchup = *t.MaxCatchup
+		}
+		if t.ClientDelay != "" {
+			delay, err := time.ParseDuration(t.ClientDelay)
+			if err != nil {
+				return nil, fmt.Errorf("feed tier \"%v\" has an invalid client delay: %w", t.Name, err)
+			}
+			tier.clientDelay = delay
+		}
+		if tier.maxConnections < 0 || tier.maxCatchup < -1 || tier.clientDelay < 0 || tier.bandwidth < 0 {
+			return nil, fmt.Errorf("feed tier \"%v\" has negative limits", t.Name)
+		}
+		keys.tiers[t.Name] = tier
+	}
+	names := make(map[string]struct{}, len(parsed.Keys))
+	for i, k := range parsed.Keys {
+		if k.Name == "" || k.Key == "" {
+			return nil, fmt.Errorf("feed key %v has no name or key", i)
+		}
+		if _, ok := names[k.Name]; ok {
+			return nil, fmt.Errorf("duplicate feed key name \"%v\"", k.Name)
+		}
+		names[k.Name] = struct{}{}
+		tier, ok := keys.tiers[k.Tier]
+		if !ok {
+			return nil, fmt.Errorf("feed key \"%v\" has unknown tier \"%v\"", k.Name, k.Tier)
+		}
+		keys.keys = append(keys.keys, authKey{name: k.Name, key: []byte(k.Key), tier: tier})
+	}
+	return keys, nil
+}
+
+// feedClaims are the claims of the JWTs clients may authenticate with, the subject is the key name.
+type feedClaims struct {
+	Tier string `json:"tier"`
+	jwt.RegisteredClaims
+}
+
+// keyState is kept for each key across reloads of the keys file, it's shared by the key's connections.
+type keyState struct {
+	connections int
+	limiter     *rate.Limiter
+
+	current  metrics.Gauge
+	connects metrics.Counter
+	limited  metrics.Counter
+	sent     metrics.Counter
+}
+
+// ClientAccess is what a client authenticated as, and the limits it's held to.
+type ClientAccess struct {
+	// Key is empty for clients that didn't authenticate, which get the server's default limits.
+	Key            string
+	Tier           string
+	MaxConnections int
+	MaxCatchup     int
+	ClientDelay    time.Duration
+
+	state *keyState
+}
+
+func anonymousAccess(config *BroadcasterConfig) *ClientAccess {
+	return &ClientAccess{
+		MaxCatchup:  config.MaxCatchup,
+		ClientDelay: config.ClientDelay,
+	}
+}
+
+// Authenticated returns whether the client authenticated with a key.
+func (a *ClientAccess) Authenticated() bool {
+	return a.Key != ""
+}
+
+// waitBandwidth waits until n more bytes may be sent within the bandwidth of the client's key.
+func (a *ClientAccess) waitBandwidth(ctx context.Context, n int) error {
+	if a.state == nil || a.state.limiter.Limit() == rate.Inf {
+		return nil
+	}
+	// a message may be larger than the burst, which is a second's worth of bytes
+	for n > 0 {
+		wait := n
+		if burst := a.state.limiter.Burst(); wait > burst {
+			wait = burst
+		}
+		if err := a.state.limiter.WaitN(ctx, wait); err != nil {
+			return err
+		}
+		n -= wait
+	}
+	return nil
+}
+
+func (a *ClientAccess) recordSent(n int) {
+	if a.state != nil {
+		a.state.sent.Inc(int64(n))
+	}
+}
+
+// Authenticator checks the credentials of clients against the keys file, and tracks the connections of each key.
+type Authenticator struct {
+	config AuthConfigFetcher
+
+	mutex         sync.Mutex
+	keys          *authKeys
+	keysFile      string
+	keysModTime   time.Time
+	jwtSecretConf string
+	states        map[string]*keyState
+}
+
+func NewAuthenticator(config AuthConfigFetcher) *Authenticator {
+	return &Authenticator{
+		config: config,
+		states: make(map[string]*keyState),
+	}
+}
+
+// load reloads the keys file if it changed, keeping the keys last loaded if it can't be read.
+func (a *Authenticator) load() (*authKeys, error) {
+	config := a.config()
+	info, err := os.Stat(config.KeysFile)
+	if err != nil {
+		return a.keys, fmt.Errorf("failed to read feed keys file: %w", err)
+	}
+	if a.keys != nil && config.KeysFile == a.keysFile && info.ModTime().Equal(a.keysModTime) && config.JWTSecret == a.jwtSecretConf {
+		return a.keys, nil
+	}
+	data, err := os.ReadFile(config.KeysFile)
+	if err != nil {
+		return a.keys, fmt.Errorf("failed to read feed keys file: %w", err)
+	}
+	keys, err := parseAuthKeys(data)
+	if err != nil {
+		return a.keys, err
+	}
+	if config.JWTSecret != "" {
+		secret, err := signature.LoadSigningKey(config.JWTSecret)
+		if err != nil {
+			return a.keys, fmt.Errorf("failed to load feed jwt secret: %w", err)
+		}
+		keys.jwtSecret = secret.Bytes()
+	}
+	a.keys = keys
+	a.keysFile = config.KeysFile
+	a.keysModTime = info.ModTime()
+	a.jwtSecretConf = config.JWTSecret
+	authReloadCounter.Inc(1)
+	log.Info("loaded feed keys", "tiers", len(keys.tiers), "keys", len(keys.keys))
+	return keys, nil
+}
+
+func (a *Authenticator) setBandwidth(state *keyState, tier *authTier) {
+	if tier.bandwidth == 0 {
+		state.limiter.SetLimit(rate.Inf)
+		return
+	}
+	state.limiter.SetLimit(rate.Limit(tier.bandwidth))
+	state.limiter.SetBurst(tier.bandwidth)
+}
+
+// Authenticate returns the access of a client giving token, which is either an API key or a JWT.
+func (a *Authenticator) Authenticate(token string) (*ClientAccess, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	keys, err := a.load()
+	if err != nil {
+		authReloadFailuresCounter.Inc(1)
+		if keys == nil {
+			return nil, err
+		}
+		log.Warn("failed reloading feed keys, using the ones loaded before", "err", err)
+	}
+
+	var name string
+	var tier *authTier
+	for _, key := range keys.keys {
+		// go through every key, so as not to leak which ones share a prefix with the given one
+		if subtle.ConstantTimeCompare(key.key, []byte(token)) == 1 {
+			name, tier = key.name, key.tier
+		}
+	}
+	if tier == nil && len(keys.jwtSecret) > 0 && strings.Count(token, ".") == 2 {
+		name, tier, err = keys.parseJWT(token)
+		if err != nil {
+			log.Debug("rejected feed jwt", "err", err)
+		}
+	}
+	if tier == nil {
+		return nil, errUnauthorized
+	}
+
+	state, ok := a.states[name]
+	if !ok {
+		state = &keyState{
+			limiter:  rate.NewLimiter(rate.Inf, 0),
+			current:  metrics.GetOrRegisterGauge("arb/feed/clients/key/"+name+"/current", nil),
+			connects: metrics.GetOrRegisterCounter("arb/feed/clients/key/"+name+"/connect", nil),
+			limited:  metrics.GetOrRegisterCounter("arb/feed/clients/key/"+name+"/limited", nil),
+			sent:     metrics.GetOrRegisterCounter("arb/feed/clients/key/"+name+"/sent", nil),
+		}
+		a.states[name] = state
+	}
+	// the connections already open follow the bandwidth of the key's current tier
+	a.setBandwidth(state, tier)
+	return &ClientAccess{
+		Key:            name,
+		Tier:           tier.name,
+		MaxConnections: tier.maxConnections,
+		MaxCatchup:     tier.maxCatchup,
+		ClientDelay:    tier.clientDelay,
+		state:          state,
+	}, nil
+}
+
+func (k *authKeys) parseJWT(token string) (string, *authTier, error) {
+	var claims feedClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return k.jwtSecret, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if claims.ExpiresAt == nil {
+		return "", nil, errors.New("jwt has no expiry")
+	}
+	if claims.Subject == "" {
+		return "", nil, errors.New("jwt has no subject")
+	}
+	tier, ok := k.tiers[claims.Tier]
+	if !ok {
+		return "", nil, fmt.Errorf("jwt has unknown tier \"%v\"", claims.Tier)
+	}
+	return claims.Subject, tier, nil
+}
+
+// IsAllowed returns whether the client's key may open another connection.
+func (a *Authenticator) IsAllowed(access *ClientAccess) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.isAllowedImpl(access)
+}
+
+func (a *Authenticator) isAllowedImpl(access *ClientAccess) bool {
+	if access.state == nil || access.MaxConnections == 0 || access.state.connections < access.MaxConnections {
+		return true
+	}
+	access.state.limited.Inc(1)
+	return false
+}
+
+// Register counts a connection of the client's key, if it may open another one.
+func (a *Authenticator) Register(access *ClientAccess) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.isAllowedImpl(access) {
+		return false
+	}
+	if access.state != nil {
+		access.state.connections++
+		access.state.current.Update(int64(access.state.connections))
+		access.state.connects.Inc(1)
+	}
+	return true
+}
+
+// Release stops counting a connection registered for the client's key.
+func (a *Authenticator) Release(access *ClientAccess) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if access.state == nil {
+		return
+	}
+	if access.state.connections == 0 {
+		log.Error("BUG: Unbalanced Authenticator.Release calls", "key", access.Key)
+		return
+	}
+	access.state.connections--
+	access.state.current.Update(int64(access.state.connections))
+}
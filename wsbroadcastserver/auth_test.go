@@ -0,0 +1,172 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testAuthKeys = `{
+	"tiers": [
+		{"name": "partner", "maxConnections": 2, "maxCatchup": 10, "clientDelay": "0s", "bandwidth": 1000000},
+		{"name": "public", "maxConnections": 1, "clientDelay": "500ms"}
+	],
+	"keys": [
+		{"name": "acme", "key": "acme-key", "tier": "partner"},
+		{"name": "hobbyist", "key": "hobbyist-key", "tier": "public"}
+	]
+}`
+
+const testJWTSecret = "0x0102030405060708091011121314151617181920212223242526272829303132"
+
+func writeTestAuthKeys(t *testing.T, path string, keys string, modTime time.Time) {
+	t.Helper()
+	Require(t, os.WriteFile(path, []byte(keys), 0600))
+	Require(t, os.Chtimes(path, modTime, modTime))
+}
+
+func testJWT(t *testing.T, claims feedClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(common.FromHex(testJWTSecret))
+	Require(t, err)
+	return token
+}
+
+func TestParseAuthKeys(t *testing.T) {
+	keys, err := parseAuthKeys([]byte(testAuthKeys))
+	Require(t, err)
+	partner := keys.tiers["partner"]
+	if partner.maxConnections != 2 || partner.maxCatchup != 10 || partner.bandwidth != 1000000 {
+		t.Fatalf("unexpected partner tier %+v", partner)
+	}
+	public := keys.tiers["public"]
+	if public.maxCatchup != -1 || public.clientDelay != 500*time.Millisecond {
+		t.Fatalf("unexpected public tier %+v", public)
+	}
+
+	for _, invalid := range []string{
+		`{"tiers": [{"name": "a"}, {"name": "a"}]}`,
+		`{"tiers": [{"name": "a", "maxCatchup": -2}]}`,
+		`{"tiers": [{"name": "a", "clientDelay": "soon"}]}`,
+		`{"tiers": [{"name": "a"}], "keys": [{"name": "k", "key": "secret", "tier": "b"}]}`,
+		`{"tiers": [{"name": "a"}], "keys": [{"name": "k", "tier": "a"}]}`,
+		`{"tiers": [{"name": "a", "priority": 1}]}`,
+	} {
+		if _, err := parseAuthKeys([]byte(invalid)); err == nil {
+			t.Error("parsed invalid keys", invalid)
+		}
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeTestAuthKeys(t, keysFile, testAuthKeys, modTime)
+	config := AuthConfig{Enable: true, KeysFile: keysFile, JWTSecret: testJWTSecret}
+	a := NewAuthenticator(func() *AuthConfig { return &config })
+
+	access, err := a.Authenticate("acme-key")
+	Require(t, err)
+	if access.Key != "acme" || access.Tier != "partner" || access.MaxCatchup != 10 || !access.Authenticated() {
+		t.Fatalf("unexpected access %+v", access)
+	}
+	if _, err := a.Authenticate("acme-key-2"); err == nil {
+		t.Fatal("authenticated with an unknown key")
+	}
+
+	// each key is limited to its tier's connections
+	Expect(t, a.Register(access))
+	Expect(t, a.Register(access))
+	Expect(t, !a.IsAllowed(access))
+	Expect(t, !a.Register(access))
+	a.Release(access)
+	Expect(t, a.IsAllowed(access))
+	hobbyist, err := a.Authenticate("hobbyist-key")
+	Require(t, err)
+	Expect(t, a.Register(hobbyist))
+	Expect(t, !a.Register(hobbyist))
+
+	// jwts name the key and its tier, and must expire
+	expiry := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	access, err = a.Authenticate(testJWT(t, feedClaims{Tier: "public", RegisteredClaims: jwt.RegisteredClaims{Subject: "issued", ExpiresAt: expiry}}))
+	Require(t, err)
+	if access.Key != "issued" || access.Tier != "public" || access.ClientDelay != 500*time.Millisecond {
+		t.Fatalf("unexpected jwt access %+v", access)
+	}
+	expired := jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	for _, claims := range []feedClaims{
+		{Tier: "public", RegisteredClaims: jwt.RegisteredClaims{Subject: "issued", ExpiresAt: expired}},
+		{Tier: "public", RegisteredClaims: jwt.RegisteredClaims{Subject: "issued"}},
+		{Tier: "gold", RegisteredClaims: jwt.RegisteredClaims{Subject: "issued", ExpiresAt: expiry}},
+	} {
+		if _, err := a.Authenticate(testJWT(t, claims)); err == nil {
+			t.Errorf("authenticated with invalid jwt claims %+v", claims)
+		}
+	}
+
+	// the keys file is reloaded once it changes, an invalid one leaves the previous keys in use
+	writeTestAuthKeys(t, keysFile, `{"tiers": [{"name": "partner"}], "keys": [{"name": "acme", "key": "rotated", "tier": "partner"}]}`, modTime.Add(time.Minute))
+	if _, err := a.Authenticate("acme-key"); err == nil {
+		t.Fatal("authenticated with a key removed from the keys file")
+	}
+	access, err = a.Authenticate("rotated")
+	Require(t, err)
+	Expect(t, access.MaxConnections == 0 && access.MaxCatchup == -1)
+	writeTestAuthKeys(t, keysFile, `not json`, modTime.Add(2*time.Minute))
+	_, err = a.Authenticate("rotated")
+	Require(t, err)
+}
+
+func TestAuthenticatorBandwidth(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	writeTestAuthKeys(t, keysFile, `{
+		"tiers": [{"name": "limited", "bandwidth": 10000}, {"name": "unlimited"}],
+		"keys": [{"name": "acme", "key": "acme-key", "tier": "limited"}, {"name": "hobbyist", "key": "hobbyist-key", "tier": "unlimited"}]
+	}`, time.Now().Add(-time.Hour))
+	config := AuthConfig{Enable: true, KeysFile: keysFile}
+	a := NewAuthenticator(func() *AuthConfig { return &config })
+	ctx := context.Background()
+
+	// the connections of a key share its bandwidth
+	first, err := a.Authenticate("acme-key")
+	Require(t, err)
+	second, err := a.Authenticate("acme-key")
+	Require(t, err)
+	start := time.Now()
+	for _, access := range []*ClientAccess{first, second} {
+		// more than the burst, for the wait to be split
+		Require(t, access.waitBandwidth(ctx, 12000))
+		access.recordSent(12000)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Fatalf("sent 24000 bytes within %v at 10000 bytes per second", elapsed)
+	}
+	if first.state != second.state {
+		t.Fatal("connections of the same key don't share its state")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := second.waitBandwidth(canceled, 10000); err == nil {
+		t.Fatal("waited for bandwidth with a canceled context")
+	}
+
+	// other keys and anonymous clients aren't held back
+	hobbyist, err := a.Authenticate("hobbyist-key")
+	Require(t, err)
+	anonymous := anonymousAccess(&DefaultBroadcasterConfig)
+	start = time.Now()
+	Require(t, hobbyist.waitBandwidth(ctx, 1000000))
+	Require(t, anonymous.waitBandwidth(ctx, 1000000))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("unlimited clients waited %v for bandwidth", elapsed)
+	}
+}
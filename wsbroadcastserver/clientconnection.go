@@ -16,6 +16,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/yingdianRao/nitro/arbutil"
 	"github.com/yingdianRao/nitro/broadcaster/backlog"
+	"github.com/yingdianRao/nitro/broadcaster/filter"
 	m "github.com/yingdianRao/nitro/broadcaster/message"
 
 	"github.com/gobwas/ws"
@@ -57,10 +58,13 @@ type ClientConnection struct {
 	registered    chan bool
 	backlogSent   bool
 
-	compression bool
-	flateReader *wsflate.Reader
+	compression    bool
+	flateReader    *wsflate.Reader
+	binaryEncoding bool
+	feedFilter     *filter.Matcher
 
-	delay time.Duration
+	access *ClientAccess
+	delay  time.Duration
 }
 
 func NewClientConnection(
@@ -70,8 +74,10 @@ func NewClientConnection(
 	requestedSeqNum arbutil.MessageIndex,
 	connectingIP net.IP,
 	compression bool,
+	binaryEncoding bool,
+	feedFilter *filter.Matcher,
+	access *ClientAccess,
 	maxSendQueue int,
-	delay time.Duration,
 	bklg backlog.Backlog,
 ) *ClientConnection {
 	return &ClientConnection{
@@ -86,7 +92,10 @@ func NewClientConnection(
 		out:             make(chan message, maxSendQueue),
 		compression:     compression,
 		flateReader:     NewFlateReader(),
-		delay:           delay,
+		binaryEncoding:  binaryEncoding,
+		feedFilter:      feedFilter,
+		access:          access,
+		delay:           access.ClientDelay,
 		backlog:         bklg,
 		registered:      make(chan bool, 1),
 		backlogSent:     false,
@@ -101,6 +110,21 @@ func (cc *ClientConnection) Compression() bool {
 	return cc.compression
 }
 
+// BinaryEncoding returns whether the client is sent the binary feed encoding rather than json.
+func (cc *ClientConnection) BinaryEncoding() bool {
+	return cc.binaryEncoding
+}
+
+// Filter returns the filter of the messages sent to the client, or nil if it's sent the whole feed.
+func (cc *ClientConnection) Filter() *filter.Matcher {
+	return cc.feedFilter
+}
+
+// Access returns what the client authenticated as, and the limits it's held to.
+func (cc *ClientConnection) Access() *ClientAccess {
+	return cc.access
+}
+
 // Register sends the ClientConnection to be registered with the ClientManager.
 func (cc *ClientConnection) Register() {
 	cc.clientAction <- ClientConnectionAction{
@@ -117,7 +141,28 @@ func (cc *ClientConnection) Remove() {
 	}
 }
 
-func (cc *ClientConnection) writeBacklog(ctx context.Context, segment backlog.BacklogSegment) error {
+// catchupStart returns the sequence number to send the backlog from, which is the requested one
+// unless the client's catch-up limit only lets it get fewer of the latest messages.
+func (cc *ClientConnection) catchupStart() (uint64, bool) {
+	return catchupStart(cc.backlog, uint64(cc.requestedSeqNum), cc.access.MaxCatchup)
+}
+
+func catchupStart(bklg backlog.Backlog, start uint64, maxCatchup int) (uint64, bool) {
+	if maxCatchup < 0 {
+		return start, false
+	}
+	tail := bklg.Tail()
+	if backlog.IsBacklogSegmentNil(tail) {
+		return start, false
+	}
+	end := tail.End()
+	if end < start || end-start < uint64(maxCatchup) {
+		return start, false
+	}
+	return end + 1 - uint64(maxCatchup), true
+}
+
+func (cc *ClientConnection) writeBacklog(ctx context.Context, segment backlog.BacklogSegment, start uint64) error {
 	var prevSegment backlog.BacklogSegment
 	isFirstSegment := true
 	for !backlog.IsBacklogSegmentNil(segment) {
@@ -133,8 +178,8 @@ func (cc *ClientConnection) writeBacklog(ctx context.Context, segment backlog.Ba
 		}
 
 		msgs := prevSegment.Messages()
-		if isFirstSegment && prevSegment.Contains(uint64(cc.requestedSeqNum)) {
-			requestedIdx := int(cc.requestedSeqNum) - int(prevSegment.Start())
+		if isFirstSegment && prevSegment.Contains(start) {
+			requestedIdx := int(start) - int(prevSegment.Start())
 			// This might be false if messages were added after we fetched the segment's messages
 			if len(msgs) >= requestedIdx {
 				msgs = msgs[requestedIdx:]
@@ -148,7 +193,7 @@ func (cc *ClientConnection) writeBacklog(ctx context.Context, segment backlog.Ba
 			Version:  m.V1,
 			Messages: msgs,
 		}
-		err := cc.writeBroadcastMessage(bm)
+		err := cc.writeBroadcastMessage(ctx, bm)
 		if err != nil {
 			return err
 		}
@@ -163,8 +208,15 @@ func (cc *ClientConnection) writeBacklog(ctx context.Context, segment backlog.Ba
 	return nil
 }
 
-func (cc *ClientConnection) writeBroadcastMessage(bm *m.BroadcastMessage) error {
-	notCompressed, compressed, err := serializeMessage(bm, !cc.compression, cc.compression)
+// writeBroadcastMessage writes the part of bm passing the client's filter, if any.
+func (cc *ClientConnection) writeBroadcastMessage(ctx context.Context, bm *m.BroadcastMessage) error {
+	if cc.feedFilter != nil {
+		bm = cc.feedFilter.Apply(bm)
+		if bm == nil {
+			return nil
+		}
+	}
+	notCompressed, compressed, err := serializeMessage(bm, !cc.compression, cc.compression, cc.binaryEncoding)
 	if err != nil {
 		return err
 	}
@@ -175,7 +227,7 @@ func (cc *ClientConnection) writeBroadcastMessage(bm *m.BroadcastMessage) error
 	} else {
 		data = notCompressed.Bytes()
 	}
-	err = cc.writeRaw(data)
+	err = cc.writeRaw(ctx, data)
 	if err != nil {
 		return err
 	}
@@ -199,16 +251,27 @@ func (cc *ClientConnection) Start(parentCtx context.Context) {
 
 		// Send the current backlog before registering the ClientConnection in
 		// case the backlog is very large
+		start, limited := cc.catchupStart()
+		if limited {
+			// the messages before start aren't sent once broadcasting starts either
+			cc.LastSentSeqNum.Store(start - 1)
+			log.Debug("limiting client catch-up", "client", cc.Name, "requestedSeqNum", cc.requestedSeqNum, "start", start)
+		}
 		segment := cc.backlog.Head()
-		if !backlog.IsBacklogSegmentNil(segment) && segment.Start() < uint64(cc.requestedSeqNum) {
-			s, err := cc.backlog.Lookup(uint64(cc.requestedSeqNum))
+		if !backlog.IsBacklogSegmentNil(segment) && segment.Start() < start {
+			s, err := cc.backlog.Lookup(start)
 			if err != nil {
-				logWarn(err, "error finding requested sequence number in backlog: sending the entire backlog instead")
+				if limited {
+					// none of the backlog is within the catch-up limit
+					segment = nil
+				} else {
+					logWarn(err, "error finding requested sequence number in backlog: sending the entire backlog instead")
+				}
 			} else {
 				segment = s
 			}
 		}
-		err := cc.writeBacklog(ctx, segment)
+		err := cc.writeBacklog(ctx, segment, start)
 		if errors.Is(err, errContextDone) {
 			return
 		} else if err != nil {
@@ -248,7 +311,7 @@ func (cc *ClientConnection) Start(parentCtx context.Context) {
 						return
 					}
 
-					err = cc.writeBroadcastMessage(bm)
+					err = cc.writeBroadcastMessage(ctx, bm)
 					if err != nil {
 						logWarn(err, fmt.Sprintf("error writing messages %d to %d from backlog", expSeqNum, catchupSeqNum))
 						cc.Remove()
@@ -257,7 +320,7 @@ func (cc *ClientConnection) Start(parentCtx context.Context) {
 				}
 				cc.backlogSent = true
 
-				err := cc.writeRaw(msg.data)
+				err := cc.writeRaw(ctx, msg.data)
 				if err != nil {
 					logWarn(err, "error writing data to client")
 					cc.Remove()
@@ -316,11 +379,17 @@ func (cc *ClientConnection) readRequest(ctx context.Context, timeout time.Durati
 	return data, opCode, err
 }
 
-func (cc *ClientConnection) writeRaw(p []byte) error {
+// writeRaw writes p to the client once its key's bandwidth allows it.
+func (cc *ClientConnection) writeRaw(ctx context.Context, p []byte) error {
+	if err := cc.access.waitBandwidth(ctx, len(p)); err != nil {
+		return err
+	}
+
 	cc.ioMutex.Lock()
 	defer cc.ioMutex.Unlock()
 
-	_, err := cc.conn.Write(p)
+	n, err := cc.conn.Write(p)
+	cc.access.recordSent(n)
 
 	return err
 }
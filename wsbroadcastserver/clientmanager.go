@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -44,15 +45,18 @@ type ClientManager struct {
 	stopwaiter.StopWaiter
 
 	clientPtrMap  map[*ClientConnection]bool
+	streamClients map[*streamClient]bool
 	clientCount   int32
 	pool          *gopool.Pool
 	poller        netpoll.Poller
 	broadcastChan chan *m.BroadcastMessage
 	clientAction  chan ClientConnectionAction
+	streamAction  chan streamClientAction
 	config        BroadcasterConfigFetcher
 	backlog       backlog.Backlog
 
 	connectionLimiter *ConnectionLimiter
+	authenticator     *Authenticator
 }
 
 func NewClientManager(poller netpoll.Poller, configFetcher BroadcasterConfigFetcher, bklg backlog.Backlog) *ClientManager {
@@ -61,11 +65,14 @@ func NewClientManager(poller netpoll.Poller, configFetcher BroadcasterConfigFetc
 		poller:            poller,
 		pool:              gopool.NewPool(config.Workers, config.Queue, 1),
 		clientPtrMap:      make(map[*ClientConnection]bool),
+		streamClients:     make(map[*streamClient]bool),
 		broadcastChan:     make(chan *m.BroadcastMessage, 1),
 		clientAction:      make(chan ClientConnectionAction, 128),
+		streamAction:      make(chan streamClientAction, 128),
 		config:            configFetcher,
 		backlog:           bklg,
 		connectionLimiter: NewConnectionLimiter(func() *ConnectionLimiterConfig { return &configFetcher().ConnectionLimits }),
+		authenticator:     NewAuthenticator(func() *AuthConfig { return &configFetcher().Auth }),
 	}
 }
 
@@ -78,8 +85,8 @@ func (cm *ClientManager) registerClient(ctx context.Context, clientConnection *C
 
 	// TODO:(clamb) the clientsTotalFailedRegisterCounter was deleted after backlog logic moved to ClientConnection. Should this metric be reintroduced or will it be ok to just delete completely given the behaviour has changed, ask Lee
 
-	if cm.config().ConnectionLimits.Enable && !cm.connectionLimiter.Register(clientConnection.clientIp) {
-		return fmt.Errorf("Connection limited %s", clientConnection.clientIp)
+	if err := cm.registerAccess(clientConnection.access, clientConnection.clientIp); err != nil {
+		return err
 	}
 
 	clientsCurrentGauge.Inc(1)
@@ -92,12 +99,50 @@ func (cm *ClientManager) registerClient(ctx context.Context, clientConnection *C
 	return nil
 }
 
+// registerAccess counts a connection against the limits of the client's key, or of its IP if it didn't authenticate.
+func (cm *ClientManager) registerAccess(access *ClientAccess, clientIp net.IP) error {
+	if access.Authenticated() {
+		if !cm.authenticator.Register(access) {
+			return fmt.Errorf("Connection limited for key %s", access.Key)
+		}
+	} else if cm.config().ConnectionLimits.Enable && !cm.connectionLimiter.Register(clientIp) {
+		return fmt.Errorf("Connection limited %s", clientIp)
+	}
+	return nil
+}
+
+func (cm *ClientManager) releaseAccess(access *ClientAccess, clientIp net.IP) {
+	if access.Authenticated() {
+		cm.authenticator.Release(access)
+	} else if cm.config().ConnectionLimits.Enable {
+		cm.connectionLimiter.Release(clientIp)
+	}
+}
+
+func (cm *ClientManager) registerStreamClient(sc *streamClient) error {
+	if err := cm.registerAccess(sc.access, sc.clientIp); err != nil {
+		return err
+	}
+
+	clientsCurrentGauge.Inc(1)
+	clientsConnectCount.Inc(1)
+
+	atomic.AddInt32(&cm.clientCount, 1)
+	cm.streamClients[sc] = true
+	clientsTotalSuccessCounter.Inc(1)
+
+	return nil
+}
+
 // removeAll removes all clients after main ClientManager thread exits
 func (cm *ClientManager) removeAll() {
 	// Only called after main ClientManager thread exits, so remove client directly
 	for client := range cm.clientPtrMap {
 		cm.removeClientImpl(client)
 	}
+	for sc := range cm.streamClients {
+		cm.removeStreamClientImpl(sc)
+	}
 }
 
 func (cm *ClientManager) removeClientImpl(clientConnection *ClientConnection) {
@@ -129,13 +174,35 @@ func (cm *ClientManager) removeClient(clientConnection *ClientConnection) {
 	}
 
 	cm.removeClientImpl(clientConnection)
-	if cm.config().ConnectionLimits.Enable {
-		cm.connectionLimiter.Release(clientConnection.clientIp)
-	}
+	cm.releaseAccess(clientConnection.access, clientConnection.clientIp)
 
 	delete(cm.clientPtrMap, clientConnection)
 }
 
+func (cm *ClientManager) removeStreamClientImpl(sc *streamClient) {
+	close(sc.removed)
+
+	if cm.config().LogDisconnect {
+		log.Info("client removed", "client", sc.name, "age", time.Since(sc.creation))
+	}
+
+	clientsDurationHistogram.Update(time.Since(sc.creation).Microseconds())
+	clientsCurrentGauge.Dec(1)
+	clientsDisconnectCount.Inc(1)
+	atomic.AddInt32(&cm.clientCount, -1)
+}
+
+func (cm *ClientManager) removeStreamClient(sc *streamClient) {
+	if !cm.streamClients[sc] {
+		return
+	}
+
+	cm.removeStreamClientImpl(sc)
+	cm.releaseAccess(sc.access, sc.clientIp)
+
+	delete(cm.streamClients, sc)
+}
+
 func (cm *ClientManager) ClientCount() int32 {
 	return atomic.LoadInt32(&cm.clientCount)
 }
@@ -159,15 +226,43 @@ func (cm *ClientManager) doBroadcast(bm *m.BroadcastMessage) ([]*ClientConnectio
 	//                                        /-> wsutil.Writer -> not compressed msg buffer
 	// bm -> json.Encoder -> io.MultiWriter -|
 	//                                        \-> flateWriter -> wsutil.Writer -> compressed msg buffer
-
-	notCompressed, compressed, err := serializeMessage(bm, !config.RequireCompression, config.EnableCompression)
-	if err != nil {
-		return nil, err
-	}
+	//
+	// Clients asking for the binary encoding get rlp instead of json, and filtered
+	// clients only get the messages their filter matches along with the confirmation.
+	// Each of those is only serialized once a client getting it is found.
+	serializedMsgs := make(map[serializationKey]*serializedMessage)
+	var confirmation *m.BroadcastMessage
 
 	sendQueueTooLargeCount := 0
 	clientDeleteList := make([]*ClientConnection, 0, len(cm.clientPtrMap))
 	for client := range cm.clientPtrMap {
+		clientBm := bm
+		if feedFilter := client.Filter(); feedFilter != nil {
+			clientBm = feedFilter.Apply(bm)
+			if clientBm == nil {
+				continue
+			}
+			// the whole message and the lone confirmation are shared by the clients getting them
+			if len(clientBm.Messages) == len(bm.Messages) {
+				clientBm = bm
+			} else if len(clientBm.Messages) == 0 {
+				if confirmation == nil {
+					confirmation = clientBm
+				}
+				clientBm = confirmation
+			}
+		}
+		key := serializationKey{bm: clientBm, binaryEncoding: client.BinaryEncoding()}
+		serializedMsg, ok := serializedMsgs[key]
+		if !ok {
+			notCompressed, compressed, err := serializeMessage(clientBm, !config.RequireCompression, config.EnableCompression, client.BinaryEncoding())
+			if err != nil {
+				return nil, err
+			}
+			serializedMsg = &serializedMessage{notCompressed: notCompressed, compressed: compressed}
+			serializedMsgs[key] = serializedMsg
+		}
+		notCompressed, compressed := &serializedMsg.notCompressed, &serializedMsg.compressed
 		var data []byte
 		if client.Compression() {
 			if config.EnableCompression {
@@ -187,6 +282,7 @@ func (cm *ClientManager) doBroadcast(bm *m.BroadcastMessage) ([]*ClientConnectio
 			}
 		}
 
+		// the sequence number of messages filtered out is kept, for the client to catch up from the backlog
 		var seqNum *arbutil.MessageIndex
 		n := len(bm.Messages)
 		if n == 0 {
@@ -210,6 +306,15 @@ func (cm *ClientManager) doBroadcast(bm *m.BroadcastMessage) ([]*ClientConnectio
 		}
 	}
 
+	for sc := range cm.streamClients {
+		select {
+		case sc.out <- bm:
+		default:
+			sendQueueTooLargeCount++
+			cm.removeStreamClient(sc)
+		}
+	}
+
 	if sendQueueTooLargeCount > 0 {
 		if sendQueueTooLargeCount < 10 {
 			log.Warn("disconnecting clients because send queue too large", "count", sendQueueTooLargeCount)
@@ -221,23 +326,39 @@ func (cm *ClientManager) doBroadcast(bm *m.BroadcastMessage) ([]*ClientConnectio
 	return clientDeleteList, nil
 }
 
-func serializeMessage(bm *m.BroadcastMessage, enableNonCompressedOutput, enableCompressedOutput bool) (bytes.Buffer, bytes.Buffer, error) {
+type serializationKey struct {
+	bm             *m.BroadcastMessage
+	binaryEncoding bool
+}
+
+type serializedMessage struct {
+	notCompressed bytes.Buffer
+	compressed    bytes.Buffer
+}
+
+// serializeMessage returns the websocket frames of bm, as json in text frames or
+// if binaryEncoding is set as rlp in binary frames.
+func serializeMessage(bm *m.BroadcastMessage, enableNonCompressedOutput, enableCompressedOutput, binaryEncoding bool) (bytes.Buffer, bytes.Buffer, error) {
 	flateWriter, err := flate.NewWriterDict(nil, DeflateCompressionLevel, GetStaticCompressorDictionary())
 	if err != nil {
 		return bytes.Buffer{}, bytes.Buffer{}, fmt.Errorf("unable to create flate writer: %w", err)
 	}
 
+	opCode := ws.OpText
+	if binaryEncoding {
+		opCode = ws.OpBinary
+	}
 	var notCompressed bytes.Buffer
 	var compressed bytes.Buffer
 	writers := []io.Writer{}
 	var notCompressedWriter *wsutil.Writer
 	var compressedWriter *wsutil.Writer
 	if enableNonCompressedOutput {
-		notCompressedWriter = wsutil.NewWriter(&notCompressed, ws.StateServerSide, ws.OpText)
+		notCompressedWriter = wsutil.NewWriter(&notCompressed, ws.StateServerSide, opCode)
 		writers = append(writers, notCompressedWriter)
 	}
 	if enableCompressedOutput {
-		compressedWriter = wsutil.NewWriter(&compressed, ws.StateServerSide|ws.StateExtended, ws.OpText)
+		compressedWriter = wsutil.NewWriter(&compressed, ws.StateServerSide|ws.StateExtended, opCode)
 		var msg wsflate.MessageState
 		msg.SetCompressed(true)
 		compressedWriter.SetExtensions(&msg)
@@ -246,9 +367,19 @@ func serializeMessage(bm *m.BroadcastMessage, enableNonCompressedOutput, enableC
 	}
 
 	multiWriter := io.MultiWriter(writers...)
-	encoder := json.NewEncoder(multiWriter)
-	if err := encoder.Encode(bm); err != nil {
-		return bytes.Buffer{}, bytes.Buffer{}, fmt.Errorf("unable to encode message: %w", err)
+	if binaryEncoding {
+		data, err := bm.MarshalBinary()
+		if err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, fmt.Errorf("unable to encode message: %w", err)
+		}
+		if _, err := multiWriter.Write(data); err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, fmt.Errorf("unable to write message: %w", err)
+		}
+	} else {
+		encoder := json.NewEncoder(multiWriter)
+		if err := encoder.Encode(bm); err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, fmt.Errorf("unable to encode message: %w", err)
+		}
 	}
 	if notCompressedWriter != nil {
 		if err := notCompressedWriter.Flush(); err != nil {
@@ -317,6 +448,16 @@ func (cm *ClientManager) Start(parentCtx context.Context) {
 				} else {
 					cm.removeClient(clientAction.cc)
 				}
+			case streamAction := <-cm.streamAction:
+				if streamAction.create {
+					err := cm.registerStreamClient(streamAction.sc)
+					if err != nil {
+						log.Debug("stream client not registered", "client", streamAction.sc.name, "err", err)
+					}
+					streamAction.sc.registered <- err == nil
+				} else {
+					cm.removeStreamClient(streamAction.sc)
+				}
 			case bm := <-cm.broadcastChan:
 				var err error
 				for i, msg := range bm.Messages {
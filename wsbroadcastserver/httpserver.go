@@ -0,0 +1,639 @@
+// Copyright 2021-2024, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package wsbroadcastserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/yingdianRao/nitro/broadcaster/backlog"
+	"github.com/yingdianRao/nitro/broadcaster/filter"
+	m "github.com/yingdianRao/nitro/broadcaster/message"
+)
+
+var (
+	HTTPHeaderNextSequenceNumber = textproto.CanonicalMIMEHeaderKey("Arbitrum-Next-Sequence-Number")
+	HTTPHeaderLastEventID        = textproto.CanonicalMIMEHeaderKey("Last-Event-ID")
+
+	httpRequestsCounter = metrics.NewRegisteredCounter("arb/feed/http/requests", nil)
+	httpMessagesCounter = metrics.NewRegisteredCounter("arb/feed/http/messages", nil)
+	httpGoneCounter     = metrics.NewRegisteredCounter("arb/feed/http/gone", nil)
+	httpStreamsGauge    = metrics.NewRegisteredGauge("arb/feed/http/streams", nil)
+)
+
+const (
+	MessagesURI = "/messages"
+	StreamURI   = "/stream"
+)
+
+var errBacklogGone = errors.New("requested messages are no longer in the backlog")
+
+type HTTPConfig struct {
+	Enable     bool          `koanf:"enable"`
+	Addr       string        `koanf:"addr"`
+	Port       string        `koanf:"port"`
+	MaxLimit   int           `koanf:"max-limit" reload:"hot"`
+	MaxWait    time.Duration `koanf:"max-wait" reload:"hot"`
+	CORSDomain []string      `koanf:"corsdomain" reload:"hot"`
+}
+
+var DefaultHTTPConfig = HTTPConfig{
+	Enable:     false,
+	Addr:       "",
+	Port:       "9643",
+	MaxLimit:   1000,
+	MaxWait:    30 * time.Second,
+	CORSDomain: []string{},
+}
+
+var DefaultTestHTTPConfig = HTTPConfig{
+	Enable:     false,
+	Addr:       "0.0.0.0",
+	Port:       "0",
+	MaxLimit:   1000,
+	MaxWait:    5 * time.Second,
+	CORSDomain: []string{},
+}
+
+func HTTPConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultHTTPConfig.Enable, "also serve the feed over HTTP, as server-sent events on "+StreamURI+" and as catch-up requests on "+MessagesURI)
+	f.String(prefix+".addr", DefaultHTTPConfig.Addr, "address to bind the HTTP feed output to")
+	f.String(prefix+".port", DefaultHTTPConfig.Port, "port to bind the HTTP feed output to")
+	f.Int(prefix+".max-limit", DefaultHTTPConfig.MaxLimit, "the maximum number of messages returned by a catch-up request")
+	f.Duration(prefix+".max-wait", DefaultHTTPConfig.MaxWait, "the maximum duration a catch-up request may wait for new messages")
+	f.StringSlice(prefix+".corsdomain", DefaultHTTPConfig.CORSDomain, "Comma separated list of domains from which to accept cross origin requests (browser enforced)")
+}
+
+func (c *HTTPConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.MaxLimit <= 0 {
+		return errors.New("HTTP feed max-limit must be positive")
+	}
+	if c.MaxWait < 0 {
+		return errors.New("HTTP feed max-wait can't be negative")
+	}
+	return nil
+}
+
+// streamClient is an HTTP client sent the broadcast messages by the ClientManager,
+// it's held to the same limits as the websocket clients and counted with them.
+type streamClient struct {
+	name     string
+	clientIp net.IP
+	access   *ClientAccess
+	creation time.Time
+
+	out        chan *m.BroadcastMessage
+	registered chan bool
+	// removed is closed once the ClientManager stops sending to the client
+	removed chan struct{}
+}
+
+type streamClientAction struct {
+	sc     *streamClient
+	create bool
+}
+
+func newStreamClient(name string, clientIp net.IP, access *ClientAccess, maxSendQueue int) *streamClient {
+	return &streamClient{
+		name:       name,
+		clientIp:   clientIp,
+		access:     access,
+		creation:   time.Now(),
+		out:        make(chan *m.BroadcastMessage, maxSendQueue),
+		registered: make(chan bool, 1),
+		removed:    make(chan struct{}),
+	}
+}
+
+// isAllowed returns whether the limits of the client's key, or of its IP if it didn't authenticate, let it connect.
+func (cm *ClientManager) isAllowed(access *ClientAccess, clientIp net.IP) bool {
+	if access.Authenticated() {
+		return cm.authenticator.IsAllowed(access)
+	}
+	return !cm.config().ConnectionLimits.Enable || cm.connectionLimiter.IsAllowed(clientIp)
+}
+
+// registerStream starts sending the broadcast messages to sc, it returns false if the client's limits don't allow it.
+func (cm *ClientManager) registerStream(sc *streamClient) bool {
+	ctx := cm.GetContext()
+	select {
+	case cm.streamAction <- streamClientAction{sc: sc, create: true}:
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case ok := <-sc.registered:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (cm *ClientManager) removeStream(sc *streamClient) {
+	select {
+	case cm.streamAction <- streamClientAction{sc: sc, create: false}:
+	case <-cm.GetContext().Done():
+	}
+}
+
+// HTTPServer serves the feed to clients that can't use websockets, as server-sent events on StreamURI
+// and as catch-up requests on MessagesURI. It shares the backlog and client manager of the websocket server.
+type HTTPServer struct {
+	config        BroadcasterConfigFetcher
+	backlog       backlog.Backlog
+	clientManager *ClientManager
+	chainId       uint64
+	filterDecoder *filter.Decoder
+
+	server   *http.Server
+	listener net.Listener
+}
+
+func NewHTTPServer(config BroadcasterConfigFetcher, bklg backlog.Backlog, clientManager *ClientManager, chainId uint64, filterDecoder *filter.Decoder) *HTTPServer {
+	return &HTTPServer{
+		config:        config,
+		backlog:       bklg,
+		clientManager: clientManager,
+		chainId:       chainId,
+		filterDecoder: filterDecoder,
+	}
+}
+
+func (s *HTTPServer) Start(fatalErrChan chan error) error {
+	config := s.config()
+	ln, err := net.Listen("tcp", config.HTTP.Addr+":"+config.HTTP.Port)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	s.server = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: config.HandshakeTimeout,
+		// streams set a deadline for each write instead
+		WriteTimeout: 0,
+	}
+	log.Info("arbitrum HTTP broadcast server is listening", "address", ln.Addr().String())
+	go func() {
+		err := s.server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatalErrChan <- fmt.Errorf("HTTP broadcast server failed: %w", err)
+		}
+	}()
+	return nil
+}
+
+func (s *HTTPServer) ListenerAddr() net.Addr {
+	return s.listener.Addr()
+}
+
+// StopAndWait closes the server and its connections, which ends its streams.
+func (s *HTTPServer) StopAndWait() {
+	if err := s.server.Close(); err != nil {
+		log.Warn("error closing HTTP broadcast server", "err", err)
+	}
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	httpRequestsCounter.Inc(1)
+	config := s.config()
+	header := w.Header()
+	header.Set(HTTPHeaderFeedServerVersion, strconv.Itoa(FeedServerVersion))
+	header.Set(HTTPHeaderChainId, strconv.FormatUint(s.chainId, 10))
+	if origin := r.Header.Get("Origin"); origin != "" && corsAllowed(config.HTTP.CORSDomain, origin) {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Headers", strings.Join([]string{HTTPHeaderAuthorization, HTTPHeaderFeedFilter, HTTPHeaderLastEventID}, ", "))
+		header.Set("Access-Control-Expose-Headers", strings.Join([]string{HTTPHeaderChainId, HTTPHeaderNextSequenceNumber}, ", "))
+		header.Add("Vary", "Origin")
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are served.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestPath := path.Clean(r.URL.Path)
+	if requestPath == "/"+LivenessProbeURI {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if requestPath != MessagesURI && requestPath != StreamURI {
+		http.Error(w, "Unknown feed endpoint.", http.StatusNotFound)
+		return
+	}
+	req, status, reason := s.parseRequest(r, config)
+	if status != http.StatusOK {
+		http.Error(w, reason, status)
+		return
+	}
+	if requestPath == MessagesURI {
+		s.serveMessages(w, r, config, req)
+	} else {
+		s.serveStream(w, r, config, req)
+	}
+}
+
+func corsAllowed(domains []string, origin string) bool {
+	for _, domain := range domains {
+		if domain == "*" || strings.EqualFold(domain, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpRequest is what an HTTP client authenticated as and asked for.
+type httpRequest struct {
+	name     string
+	clientIp net.IP
+	access   *ClientAccess
+	filter   *filter.Matcher
+	from     uint64
+	hasFrom  bool
+}
+
+// parseRequest reads the parameters common to the HTTP endpoints, which are the websocket handshake's headers,
+// or query parameters for clients such as browsers that can't set headers.
+func (s *HTTPServer) parseRequest(r *http.Request, config *BroadcasterConfig) (*httpRequest, int, string) {
+	query := r.URL.Query()
+	req := &httpRequest{access: anonymousAccess(config)}
+
+	req.clientIp = net.ParseIP(r.Header.Get(HTTPHeaderCloudflareConnectingIP))
+	if req.clientIp == nil {
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			req.clientIp = net.ParseIP(host)
+		}
+	}
+	req.name = fmt.Sprintf("%s@%s", req.clientIp, r.RemoteAddr)
+
+	authToken, _ := strings.CutPrefix(r.Header.Get(HTTPHeaderAuthorization), "Bearer ")
+	if authToken == "" {
+		authToken = query.Get("access_token")
+	}
+	if config.Auth.Enable && (authToken != "" || config.Auth.Require) {
+		access, err := s.clientManager.authenticator.Authenticate(authToken)
+		if err != nil {
+			clientsUnauthorizedCounter.Inc(1)
+			log.Debug("HTTP feed client failed to authenticate", "connectingIP", req.clientIp, "err", err)
+			return nil, http.StatusUnauthorized, "Invalid feed credentials."
+		}
+		req.access = access
+	}
+
+	filterValue := r.Header.Get(HTTPHeaderFeedFilter)
+	if filterValue == "" {
+		filterValue = query.Get("filter")
+	}
+	if filterValue != "" {
+		if !config.EnableFilters {
+			return nil, http.StatusBadRequest, "Feed filters are not enabled."
+		}
+		f, err := filter.Parse(filterValue)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("Malformed feed filter: %v", err)
+		}
+		req.filter = filter.NewMatcher(f, s.filterDecoder)
+	}
+
+	// streams resume after the last event an EventSource received
+	if lastEventID := r.Header.Get(HTTPHeaderLastEventID); lastEventID != "" {
+		last, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Sprintf("Malformed HTTP header %s", HTTPHeaderLastEventID)
+		}
+		req.from, req.hasFrom = last+1, true
+	} else if from := query.Get("from"); from != "" {
+		num, err := strconv.ParseUint(from, 10, 64)
+		if err != nil {
+			return nil, http.StatusBadRequest, "Malformed from parameter."
+		}
+		req.from, req.hasFrom = num, true
+	}
+	return req, http.StatusOK, ""
+}
+
+// readBacklog returns up to limit messages of the backlog from start, none if the backlog doesn't reach start yet,
+// or errBacklogGone if messages from start were already dropped from it.
+func readBacklog(bklg backlog.Backlog, start uint64, limit int) (*m.BroadcastMessage, error) {
+	bm := &m.BroadcastMessage{Version: m.V1}
+	head := bklg.Head()
+	tail := bklg.Tail()
+	if backlog.IsBacklogSegmentNil(head) || backlog.IsBacklogSegmentNil(tail) {
+		return bm, nil
+	}
+	if start < head.Start() {
+		return nil, errBacklogGone
+	}
+	end := tail.End()
+	if end < start {
+		return bm, nil
+	}
+	if end-start >= uint64(limit) {
+		end = start + uint64(limit) - 1
+	}
+	read, err := bklg.Get(start, end)
+	if err != nil {
+		return nil, err
+	}
+	// Get starts from the backlog's head if start was dropped from it meanwhile
+	if len(read.Messages) > 0 && uint64(read.Messages[0].SequenceNumber) > start {
+		return nil, errBacklogGone
+	}
+	return read, nil
+}
+
+// serveMessages answers catch-up requests for up to limit messages from a sequence number. If there are none yet,
+// it waits for them for up to the requested duration, the client counting as a connection meanwhile.
+func (s *HTTPServer) serveMessages(w http.ResponseWriter, r *http.Request, config *BroadcasterConfig, req *httpRequest) {
+	query := r.URL.Query()
+	if !req.hasFrom {
+		http.Error(w, "Missing from parameter.", http.StatusBadRequest)
+		return
+	}
+	limit := config.HTTP.MaxLimit
+	if limitValue := query.Get("limit"); limitValue != "" {
+		num, err := strconv.Atoi(limitValue)
+		if err != nil || num <= 0 {
+			http.Error(w, "Malformed limit parameter.", http.StatusBadRequest)
+			return
+		}
+		if num < limit {
+			limit = num
+		}
+	}
+	var wait time.Duration
+	if waitValue := query.Get("wait"); waitValue != "" {
+		var err error
+		wait, err = time.ParseDuration(waitValue)
+		if err != nil || wait < 0 {
+			http.Error(w, "Malformed wait parameter.", http.StatusBadRequest)
+			return
+		}
+		if wait > config.HTTP.MaxWait {
+			wait = config.HTTP.MaxWait
+		}
+	}
+
+	start, limited := catchupStart(s.backlog, req.from, req.access.MaxCatchup)
+	if limited {
+		log.Debug("limiting HTTP client catch-up", "client", req.name, "requestedSeqNum", req.from, "start", start)
+	}
+	bm, err := readBacklog(s.backlog, start, limit)
+	if err == nil && len(bm.Messages) == 0 && wait > 0 {
+		bm, err = s.waitForMessages(r.Context(), config, req, start, limit, wait)
+	}
+	if errors.Is(err, errBacklogGone) {
+		httpGoneCounter.Inc(1)
+		http.Error(w, "Requested messages are no longer in the backlog.", http.StatusGone)
+		return
+	} else if errors.Is(err, errConnectionLimited) {
+		http.Error(w, "Too many open feed connections.", http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		logWarn(err, "error reading messages from backlog")
+		http.Error(w, "Failed to read messages.", http.StatusInternalServerError)
+		return
+	}
+
+	next := start
+	if n := len(bm.Messages); n > 0 {
+		next = uint64(bm.Messages[n-1].SequenceNumber) + 1
+	}
+	if req.filter != nil {
+		bm = req.filter.Apply(bm)
+		if bm == nil {
+			bm = &m.BroadcastMessage{Version: m.V1}
+		}
+	}
+	data, err := json.Marshal(bm)
+	if err != nil {
+		logWarn(err, "error encoding messages")
+		http.Error(w, "Failed to encode messages.", http.StatusInternalServerError)
+		return
+	}
+	if err := req.access.waitBandwidth(r.Context(), len(data)); err != nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	// clients continue from there, filtered clients couldn't tell from the messages they were sent
+	w.Header().Set(HTTPHeaderNextSequenceNumber, strconv.FormatUint(next, 10))
+	n, err := w.Write(data)
+	req.access.recordSent(n)
+	if err != nil {
+		logWarn(err, "error writing messages to HTTP client")
+		return
+	}
+	httpMessagesCounter.Inc(int64(len(bm.Messages)))
+}
+
+var errConnectionLimited = errors.New("connection limited")
+
+func (s *HTTPServer) waitForMessages(ctx context.Context, config *BroadcasterConfig, req *httpRequest, start uint64, limit int, wait time.Duration) (*m.BroadcastMessage, error) {
+	sc := newStreamClient(req.name, req.clientIp, req.access, config.MaxSendQueue)
+	if !s.clientManager.registerStream(sc) {
+		return nil, errConnectionLimited
+	}
+	defer s.clientManager.removeStream(sc)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		// messages are added to the backlog before being sent to clients, possibly before the client registered
+		bm, err := readBacklog(s.backlog, start, limit)
+		if err != nil || len(bm.Messages) > 0 {
+			return bm, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-sc.removed:
+			return bm, nil
+		case <-timer.C:
+			return bm, nil
+		case <-sc.out:
+		}
+	}
+}
+
+// eventStream writes broadcast messages as server-sent events.
+type eventStream struct {
+	w            http.ResponseWriter
+	rc           *http.ResponseController
+	access       *ClientAccess
+	filter       *filter.Matcher
+	writeTimeout time.Duration
+}
+
+func (es *eventStream) writeRaw(ctx context.Context, p []byte) error {
+	if err := es.access.waitBandwidth(ctx, len(p)); err != nil {
+		return err
+	}
+	if err := es.rc.SetWriteDeadline(time.Now().Add(es.writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	n, err := es.w.Write(p)
+	es.access.recordSent(n)
+	if err != nil {
+		return err
+	}
+	return es.rc.Flush()
+}
+
+// write sends the part of bm passing the client's filter as an event, whose id is the sequence number
+// of its last message for EventSources to resume after it.
+func (es *eventStream) write(ctx context.Context, bm *m.BroadcastMessage) error {
+	if es.filter != nil {
+		bm = es.filter.Apply(bm)
+		if bm == nil {
+			return nil
+		}
+	}
+	data, err := json.Marshal(bm)
+	if err != nil {
+		return err
+	}
+	var event bytes.Buffer
+	if n := len(bm.Messages); n > 0 {
+		fmt.Fprintf(&event, "id: %d\n", bm.Messages[n-1].SequenceNumber)
+	}
+	event.WriteString("data: ")
+	event.Write(data)
+	event.WriteString("\n\n")
+	if err := es.writeRaw(ctx, event.Bytes()); err != nil {
+		return err
+	}
+	httpMessagesCounter.Inc(int64(len(bm.Messages)))
+	return nil
+}
+
+// serveStream streams the feed as server-sent events, first from the backlog as websocket clients are sent it,
+// then the broadcast messages. Each event's data is a json BroadcastMessage.
+// The stream is registered before catching up, for it to count against the client limits meanwhile,
+// the messages broadcast during the catch-up are queued to be sent after it.
+func (s *HTTPServer) serveStream(w http.ResponseWriter, r *http.Request, config *BroadcasterConfig, req *httpRequest) {
+	ctx := r.Context()
+	if !s.clientManager.isAllowed(req.access, req.clientIp) {
+		http.Error(w, "Too many open feed connections.", http.StatusTooManyRequests)
+		return
+	}
+	sc := newStreamClient(req.name, req.clientIp, req.access, config.MaxSendQueue)
+	if !s.clientManager.registerStream(sc) {
+		http.Error(w, "Too many open feed connections.", http.StatusTooManyRequests)
+		return
+	}
+	defer s.clientManager.removeStream(sc)
+	httpStreamsGauge.Inc(1)
+	defer httpStreamsGauge.Dec(1)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-store")
+	// for proxies not to buffer the events
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	stream := &eventStream{
+		w:            w,
+		rc:           http.NewResponseController(w),
+		access:       req.access,
+		filter:       req.filter,
+		writeTimeout: config.WriteTimeout,
+	}
+	if err := stream.rc.Flush(); err != nil {
+		return
+	}
+
+	if req.access.ClientDelay != 0 {
+		t := time.NewTimer(req.access.ClientDelay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+
+	next, limited := catchupStart(s.backlog, req.from, req.access.MaxCatchup)
+	if limited {
+		log.Debug("limiting HTTP client catch-up", "client", req.name, "requestedSeqNum", req.from, "start", next)
+	}
+	// as for websocket clients, the whole backlog is sent if the requested messages aren't in it anymore
+	if head := s.backlog.Head(); !backlog.IsBacklogSegmentNil(head) && head.Start() > next {
+		next = head.Start()
+	}
+	for {
+		bm, err := readBacklog(s.backlog, next, config.HTTP.MaxLimit)
+		if err != nil {
+			logWarn(err, "error reading messages from backlog for HTTP client")
+			return
+		}
+		if len(bm.Messages) == 0 {
+			break
+		}
+		if err := stream.write(ctx, bm); err != nil {
+			logWarn(err, "error writing messages to HTTP client")
+			return
+		}
+		next = uint64(bm.Messages[len(bm.Messages)-1].SequenceNumber) + 1
+	}
+
+	ping := time.NewTicker(config.Ping)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sc.removed:
+			return
+		case <-ping.C:
+			// a comment, for proxies not to time the stream out
+			if err := stream.writeRaw(ctx, []byte(": ping\n\n")); err != nil {
+				return
+			}
+		case bm := <-sc.out:
+			if len(bm.Messages) > 0 {
+				seqNum := uint64(bm.Messages[0].SequenceNumber)
+				if seqNum < next {
+					continue
+				}
+				if seqNum > next {
+					missed, err := s.backlog.Get(next, seqNum-1)
+					if err != nil {
+						logWarn(err, fmt.Sprintf("error reading messages %d to %d from backlog", next, seqNum-1))
+						return
+					}
+					if err := stream.write(ctx, missed); err != nil {
+						logWarn(err, "error writing messages to HTTP client")
+						return
+					}
+				}
+				next = uint64(bm.Messages[len(bm.Messages)-1].SequenceNumber) + 1
+			}
+			if err := stream.write(ctx, bm); err != nil {
+				logWarn(err, "error writing messages to HTTP client")
+				return
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/textproto"
@@ -26,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/yingdianRao/nitro/arbutil"
 	"github.com/yingdianRao/nitro/broadcaster/backlog"
+	"github.com/yingdianRao/nitro/broadcaster/filter"
 	m "github.com/yingdianRao/nitro/broadcaster/message"
 )
 
@@ -35,6 +37,9 @@ var (
 	HTTPHeaderFeedClientVersion       = textproto.CanonicalMIMEHeaderKey("Arbitrum-Feed-Client-Version")
 	HTTPHeaderRequestedSequenceNumber = textproto.CanonicalMIMEHeaderKey("Arbitrum-Requested-Sequence-Number")
 	HTTPHeaderChainId                 = textproto.CanonicalMIMEHeaderKey("Arbitrum-Chain-Id")
+	HTTPHeaderFeedEncoding            = textproto.CanonicalMIMEHeaderKey("Arbitrum-Feed-Encoding")
+	HTTPHeaderFeedFilter              = textproto.CanonicalMIMEHeaderKey("Arbitrum-Feed-Filter")
+	HTTPHeaderAuthorization           = textproto.CanonicalMIMEHeaderKey("Authorization")
 	upgradeToWSTimer                  = metrics.NewRegisteredTimer("arb/feed/clients/upgrade/duration", nil)
 	startWithHeaderTimer              = metrics.NewRegisteredTimer("arb/feed/clients/start/duration", nil)
 )
@@ -64,10 +69,14 @@ type BroadcasterConfig struct {
 	LogDisconnect      bool                    `koanf:"log-disconnect"`
 	EnableCompression  bool                    `koanf:"enable-compression" reload:"hot"`  // if reloaded to false will cause disconnection of clients with enabled compression on next broadcast
 	RequireCompression bool                    `koanf:"require-compression" reload:"hot"` // if reloaded to true will cause disconnection of clients with disabled compression on next broadcast
+	EnableBinary       bool                    `koanf:"enable-binary" reload:"hot"`       // reloading will affect only new connections
+	EnableFilters      bool                    `koanf:"enable-filters" reload:"hot"`      // reloading will affect only new connections
 	LimitCatchup       bool                    `koanf:"limit-catchup" reload:"hot"`
 	MaxCatchup         int                     `koanf:"max-catchup" reload:"hot"`
 	ConnectionLimits   ConnectionLimiterConfig `koanf:"connection-limits" reload:"hot"`
 	ClientDelay        time.Duration           `koanf:"client-delay" reload:"hot"`
+	Auth               AuthConfig              `koanf:"auth" reload:"hot"` // reloading will affect only new connections
+	HTTP               HTTPConfig              `koanf:"http" reload:"hot"` // only the limits are reloaded
 	Backlog            backlog.Config          `koanf:"backlog" reload:"hot"`
 }
 
@@ -75,7 +84,13 @@ func (bc *BroadcasterConfig) Validate() error {
 	if !bc.EnableCompression && bc.RequireCompression {
 		return errors.New("require-compression cannot be true while enable-compression is false")
 	}
-	return nil
+	if err := bc.Auth.Validate(); err != nil {
+		return err
+	}
+	if err := bc.HTTP.Validate(); err != nil {
+		return err
+	}
+	return bc.Backlog.Validate()
 }
 
 type BroadcasterConfigFetcher func() *BroadcasterConfig
@@ -99,10 +114,14 @@ func BroadcasterConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".log-disconnect", DefaultBroadcasterConfig.LogDisconnect, "log every client disconnect")
 	f.Bool(prefix+".enable-compression", DefaultBroadcasterConfig.EnableCompression, "enable per message deflate compression support")
 	f.Bool(prefix+".require-compression", DefaultBroadcasterConfig.RequireCompression, "require clients to use compression")
+	f.Bool(prefix+".enable-binary", DefaultBroadcasterConfig.EnableBinary, "send the binary (rlp) feed encoding to clients asking for it, instead of json")
+	f.Bool(prefix+".enable-filters", DefaultBroadcasterConfig.EnableFilters, "let clients ask for a filtered feed, which can't be used to sync a node, at the cost of decoding messages to filter them")
 	f.Bool(prefix+".limit-catchup", DefaultBroadcasterConfig.LimitCatchup, "only supply catchup buffer if requested sequence number is reasonable")
-	f.Int(prefix+".max-catchup", DefaultBroadcasterConfig.MaxCatchup, "the maximum size of the catchup buffer (-1 means unlimited)")
+	f.Int(prefix+".max-catchup", DefaultBroadcasterConfig.MaxCatchup, "the maximum number of backlog messages sent to a client catching up, clients authenticated with a key get their tier's instead (-1 means unlimited)")
 	ConnectionLimiterConfigAddOptions(prefix+".connection-limits", f)
 	f.Duration(prefix+".client-delay", DefaultBroadcasterConfig.ClientDelay, "delay the first messages sent to each client by this amount")
+	AuthConfigAddOptions(prefix+".auth", f)
+	HTTPConfigAddOptions(prefix+".http", f)
 	backlog.AddOptions(prefix+".backlog", f)
 }
 
@@ -125,10 +144,14 @@ var DefaultBroadcasterConfig = BroadcasterConfig{
 	LogDisconnect:      false,
 	EnableCompression:  false,
 	RequireCompression: false,
+	EnableBinary:       true,
+	EnableFilters:      false,
 	LimitCatchup:       false,
 	MaxCatchup:         -1,
 	ConnectionLimits:   DefaultConnectionLimiterConfig,
 	ClientDelay:        0,
+	Auth:               DefaultAuthConfig,
+	HTTP:               DefaultHTTPConfig,
 	Backlog:            backlog.DefaultConfig,
 }
 
@@ -151,10 +174,14 @@ var DefaultTestBroadcasterConfig = BroadcasterConfig{
 	LogDisconnect:      false,
 	EnableCompression:  true,
 	RequireCompression: false,
+	EnableBinary:       true,
+	EnableFilters:      true,
 	LimitCatchup:       false,
 	MaxCatchup:         -1,
 	ConnectionLimits:   DefaultConnectionLimiterConfig,
 	ClientDelay:        0,
+	Auth:               DefaultAuthConfig,
+	HTTP:               DefaultTestHTTPConfig,
 	Backlog:            backlog.DefaultTestConfig,
 }
 
@@ -169,18 +196,21 @@ type WSBroadcastServer struct {
 	config        BroadcasterConfigFetcher
 	started       bool
 	clientManager *ClientManager
+	httpServer    *HTTPServer
 	backlog       backlog.Backlog
 	chainId       uint64
+	filterDecoder *filter.Decoder
 	fatalErrChan  chan error
 }
 
 func NewWSBroadcastServer(config BroadcasterConfigFetcher, bklg backlog.Backlog, chainId uint64, fatalErrChan chan error) *WSBroadcastServer {
 	return &WSBroadcastServer{
-		config:       config,
-		started:      false,
-		backlog:      bklg,
-		chainId:      chainId,
-		fatalErrChan: fatalErrChan,
+		config:        config,
+		started:       false,
+		backlog:       bklg,
+		chainId:       chainId,
+		filterDecoder: filter.NewDecoder(chainId),
+		fatalErrChan:  fatalErrChan,
 	}
 }
 
@@ -256,8 +286,12 @@ func (s *WSBroadcastServer) StartWithHeader(ctx context.Context, header ws.Hands
 			negotiate = compress.Negotiate
 		}
 		var feedClientVersionSeen bool
+		var binaryEncoding bool
+		var feedFilter *filter.Matcher
+		var authToken string
 		var connectingIP net.IP
 		var requestedSeqNum arbutil.MessageIndex
+		access := anonymousAccess(config)
 		upgrader := ws.Upgrader{
 			OnRequest: func(uri []byte) error {
 				if strings.Contains(string(uri), LivenessProbeURI) {
@@ -293,6 +327,27 @@ func (s *WSBroadcastServer) StartWithHeader(ctx context.Context, header ws.Hands
 						)
 					}
 					requestedSeqNum = arbutil.MessageIndex(num)
+				} else if headerName == HTTPHeaderFeedEncoding {
+					// clients asking for an encoding the server doesn't send get json
+					binaryEncoding = config.EnableBinary && string(value) == m.EncodingRLP
+				} else if headerName == HTTPHeaderFeedFilter {
+					// sending the whole feed instead could overwhelm a client expecting part of it
+					if !config.EnableFilters {
+						return ws.RejectConnectionError(
+							ws.RejectionStatus(http.StatusBadRequest),
+							ws.RejectionReason("Feed filters are not enabled."),
+						)
+					}
+					f, err := filter.Parse(string(value))
+					if err != nil {
+						return ws.RejectConnectionError(
+							ws.RejectionStatus(http.StatusBadRequest),
+							ws.RejectionReason(fmt.Sprintf("Malformed HTTP header %s: %v", HTTPHeaderFeedFilter, err)),
+						)
+					}
+					feedFilter = filter.NewMatcher(f, s.filterDecoder)
+				} else if headerName == HTTPHeaderAuthorization {
+					authToken, _ = strings.CutPrefix(string(value), "Bearer ")
 				} else if headerName == HTTPHeaderCloudflareConnectingIP {
 					connectingIP = net.ParseIP(string(value))
 					log.Trace("Client IP parsed from header", "ip", connectingIP, "header", headerName, "value", string(value))
@@ -316,13 +371,37 @@ func (s *WSBroadcastServer) StartWithHeader(ctx context.Context, header ws.Hands
 					}
 				}
 
-				if config.ConnectionLimits.Enable && !s.clientManager.connectionLimiter.IsAllowed(connectingIP) {
+				if config.Auth.Enable && (authToken != "" || config.Auth.Require) {
+					var err error
+					access, err = s.clientManager.authenticator.Authenticate(authToken)
+					if err != nil {
+						clientsUnauthorizedCounter.Inc(1)
+						log.Debug("feed client failed to authenticate", "connectingIP", connectingIP, "err", err)
+						return nil, ws.RejectConnectionError(
+							ws.RejectionStatus(http.StatusUnauthorized),
+							ws.RejectionReason("Invalid feed credentials."),
+						)
+					}
+				}
+
+				// authenticated clients are limited per key rather than per IP
+				if access.Authenticated() {
+					if !s.clientManager.authenticator.IsAllowed(access) {
+						return nil, ws.RejectConnectionError(
+							ws.RejectionStatus(http.StatusTooManyRequests),
+							ws.RejectionReason("Too many open feed connections for this key."),
+						)
+					}
+				} else if config.ConnectionLimits.Enable && !s.clientManager.connectionLimiter.IsAllowed(connectingIP) {
 					return nil, ws.RejectConnectionError(
 						ws.RejectionStatus(http.StatusTooManyRequests),
 						ws.RejectionReason("Too many open feed connections."),
 					)
 				}
 
+				if binaryEncoding {
+					return handshakeHeaders{header, feedEncodingHeaderRLP}, nil
+				}
 				return header, nil
 			},
 			Negotiate: negotiate,
@@ -378,7 +457,7 @@ func (s *WSBroadcastServer) StartWithHeader(ctx context.Context, header ws.Hands
 		// Register incoming client in clientManager.
 		safeConn := writeDeadliner{conn, config.WriteTimeout}
 
-		client := NewClientConnection(safeConn, desc, s.clientManager.clientAction, requestedSeqNum, connectingIP, compressionAccepted, s.config().MaxSendQueue, s.config().ClientDelay, s.backlog)
+		client := NewClientConnection(safeConn, desc, s.clientManager.clientAction, requestedSeqNum, connectingIP, compressionAccepted, binaryEncoding, feedFilter, access, s.config().MaxSendQueue, s.backlog)
 		client.Start(ctx)
 
 		// Subscribe to events about conn.
@@ -498,6 +577,14 @@ func (s *WSBroadcastServer) StartWithHeader(ctx context.Context, header ws.Hands
 		return err
 	}
 
+	if config.HTTP.Enable {
+		s.httpServer = NewHTTPServer(s.config, s.backlog, s.clientManager, s.chainId, s.filterDecoder)
+		if err := s.httpServer.Start(s.fatalErrChan); err != nil {
+			log.Error("error starting HTTP broadcast server", "err", err)
+			return err
+		}
+	}
+
 	s.started = true
 
 	return nil
@@ -507,7 +594,20 @@ func (s *WSBroadcastServer) ListenerAddr() net.Addr {
 	return s.listener.Addr()
 }
 
+// HTTPListenerAddr returns the address the feed is served over HTTP on, or nil if it isn't.
+func (s *WSBroadcastServer) HTTPListenerAddr() net.Addr {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.ListenerAddr()
+}
+
 func (s *WSBroadcastServer) StopAndWait() {
+	if s.httpServer != nil {
+		s.httpServer.StopAndWait()
+		s.httpServer = nil
+	}
+
 	err := s.listener.Close()
 	if err != nil {
 		log.Warn("error in listener.Close", "err", err)
@@ -556,3 +656,22 @@ func (d writeDeadliner) Write(p []byte) (int, error) {
 	}
 	return d.Conn.Write(p)
 }
+
+var feedEncodingHeaderRLP = ws.HandshakeHeaderHTTP(http.Header{
+	HTTPHeaderFeedEncoding: []string{m.EncodingRLP},
+})
+
+// handshakeHeaders writes each of its headers in turn, to extend the server's handshake header per connection.
+type handshakeHeaders []ws.HandshakeHeader
+
+func (h handshakeHeaders) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, header := range h {
+		n, err := header.WriteTo(w)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}